@@ -1,16 +1,24 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Cloudpork/cloudpork-agent/internal/analyzer"
 	"github.com/Cloudpork/cloudpork-agent/internal/api"
 	"github.com/Cloudpork/cloudpork-agent/internal/config"
+	"github.com/Cloudpork/cloudpork-agent/internal/cost"
+	"github.com/Cloudpork/cloudpork-agent/internal/llm"
+	"github.com/Cloudpork/cloudpork-agent/internal/report"
 	"github.com/Cloudpork/cloudpork-agent/internal/types"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -18,8 +26,20 @@ import (
 )
 
 var (
-	projectID string
-	output    string
+	projectID     string
+	output        string
+	minConfidence float64
+	staticOnly    bool
+	scaleTiers    []int
+	requestRates  []float64
+	outputFile    string
+	ciMode        bool
+	policyFile    string
+	baselineRef   string
+	sinceRef      string
+	noWorkspace   bool
+	parallelism   int
+	passTimeout   time.Duration
 )
 
 // analyzeCmd represents the analyze command
@@ -30,7 +50,8 @@ var analyzeCmd = &cobra.Command{
 and potential cost optimizations.
 
 This command:
-1. Checks for Claude Code CLI installation
+1. Checks the configured LLM backend (Claude Code CLI, Ollama or an
+   OpenAI-compatible server, see 'cloudpork setup')
 2. Analyzes your codebase using structured prompts
 3. Sends analysis results to CloudPork for cost projection
 4. Never uploads your actual source code
@@ -39,40 +60,93 @@ Examples:
   cloudpork analyze                           # Analyze current directory
   cloudpork analyze ./my-project             # Analyze specific directory
   cloudpork analyze --project-id=proj_abc123 # Use specific project ID
-  cloudpork analyze --output=json            # Output raw JSON results`,
-	Args: cobra.MaximumNArgs(1),
-	RunE: runAnalyze,
+  cloudpork analyze --output=json            # Output raw JSON results
+  cloudpork analyze --output-file=report.sarif # Write a SARIF report for code scanning
+  cloudpork analyze --static-only            # Offline: static scan only, no LLM
+  cloudpork analyze --min-confidence=0.3     # Fail if any value is a fallback default
+  cloudpork analyze --since=origin/main      # Re-analyze only what changed since a git ref
+  cloudpork analyze --no-workspace           # Analyze a monorepo as one app, not service by service
+  cloudpork analyze --ci                     # Check .cloudpork/policy.yaml: exit 2 on failure, 3 on warnings`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{apiAnnotation: "upload"},
+	RunE:        runAnalyze,
 }
 
 func init() {
 	rootCmd.AddCommand(analyzeCmd)
 
 	analyzeCmd.Flags().StringVarP(&projectID, "project-id", "p", "", "CloudPork project ID")
-	analyzeCmd.Flags().StringVarP(&output, "output", "o", "dashboard", "Output format: dashboard, json, or quiet")
+	analyzeCmd.Flags().StringVarP(&output, "output", "o", "dashboard", "Output format: dashboard, quiet, or a report format ("+strings.Join(report.Names(), ", ")+")")
+	analyzeCmd.Flags().StringVar(&outputFile, "output-file", "", "Write the report to this file; the format comes from --output or the file extension")
+	analyzeCmd.Flags().BoolVar(&staticOnly, "static-only", false, "Only run the static scan, without any LLM")
+	analyzeCmd.Flags().IntSliceVar(&scaleTiers, "tiers", nil, "User counts to project resources and cost to (default 1K,10K,100K,1M,10M,100M)")
+	analyzeCmd.Flags().Float64SliceVar(&requestRates, "request-rates", nil, "Requests per second to project serverless functions to (default 1,10,100,1000)")
+	analyzeCmd.Flags().Float64Var(&minConfidence, "min-confidence", 0, "Fail if any field's confidence is below this value (0.0-1.0)")
+	analyzeCmd.Flags().BoolVar(&ciMode, "ci", false, "Check the result against the policy file and exit 2 on failure, 3 on warnings")
+	analyzeCmd.Flags().StringVar(&policyFile, "policy", "", "Policy file for --ci (default <directory>/.cloudpork/policy.yaml)")
+	analyzeCmd.Flags().StringVar(&baselineRef, "baseline", "", "Analysis to compare against for --ci and to merge into for --since: history ID, 'latest' or a JSON file")
+	analyzeCmd.Flags().StringVar(&sinceRef, "since", "", "Only re-analyze files changed since this git ref and merge the result into the baseline analysis")
+	analyzeCmd.Flags().IntVar(&parallelism, "parallel", analyzer.DefaultParallelism, "How many independent LLM passes run at once")
+	analyzeCmd.Flags().DurationVar(&passTimeout, "pass-timeout", analyzer.DefaultPassTimeout, "Give up on an LLM pass that takes longer than this")
+	analyzeCmd.Flags().BoolVar(&noWorkspace, "no-workspace", false, "Analyze the directory as one app, even when it is a monorepo of several services")
 	
 	viper.BindPFlag("project-id", analyzeCmd.Flags().Lookup("project-id"))
+	viper.BindPFlag("llm.parallelism", analyzeCmd.Flags().Lookup("parallel"))
+	viper.BindPFlag("llm.pass_timeout", analyzeCmd.Flags().Lookup("pass-timeout"))
+}
+
+// serverlessRates returns the request rates from --request-rates or the
+// scaling.request_rates config key, in ascending order
+func serverlessRates() ([]float64, error) {
+	rates := requestRates
+	if len(rates) == 0 {
+		for _, s := range viper.GetStringSlice("scaling.request_rates") {
+			rate, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid request rate %q in scaling.request_rates", s)
+			}
+			rates = append(rates, rate)
+		}
+	}
+	for _, rate := range rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid request rate %g: rates must be positive", rate)
+		}
+	}
+	sort.Float64s(rates)
+	return rates, nil
 }
 
 func runAnalyze(cmd *cobra.Command, args []string) error {
+	if _, err := outputFileFormat(output, outputFile); err != nil {
+		return err
+	}
+	report.ToolVersion = version
+	
 	// Get user subscription info first
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	
-	subscription, err := getSubscriptionInfo(cfg.APIKey)
-	if err != nil {
-		return fmt.Errorf("failed to get subscription info: %w", err)
-	}
-	
-	// Check trial limitations
-	if subscription.Tier == types.TierTrial {
-		if subscription.AnalysesUsed >= subscription.AnalysesLimit {
-			return showTrialUpgradePrompt(subscription)
-		}
-		
-		if subscription.DaysRemaining <= 2 {
-			showTrialWarning(subscription)
+	// Air-gapped hosts cannot reach the API, so there is nothing to check
+	if !viper.GetBool("security.air_gapped") {
+		// Offline, the analysis still runs; its upload is queued if it fails
+		subscription, err := getSubscriptionInfo(cmd.Context(), cfg.APIKey)
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) && apiErr.Kind == api.ErrTransient {
+			color.New(color.FgYellow).Fprintf(os.Stderr, "⚠️  CloudPork is unreachable, skipping the subscription check: %v\n", err)
+		} else if err != nil {
+			return fmt.Errorf("failed to get subscription info: %w", err)
+		} else if subscription.Tier == types.TierTrial {
+			// Check trial limitations
+			if subscription.AnalysesUsed >= subscription.AnalysesLimit {
+				return showTrialUpgradePrompt(subscription)
+			}
+			
+			if subscription.DaysRemaining <= 2 {
+				showTrialWarning(subscription)
+			}
 		}
 	}
 	
@@ -114,60 +188,175 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 		fmt.Printf("🆔 Project ID: %s\n\n", projID)
 	}
 	
+	// Select the LLM backend for the configured mode
+	mode := viper.GetString("llm.mode")
+	var backend llm.Backend
+	if !staticOnly {
+		backend, err = newBackend(mode, absPath)
+		if err != nil {
+			return err
+		}
+	}
+	
 	// Initialize analyzer
-	analyzer := analyzer.New(absPath, projID)
+	analyzer := analyzer.New(absPath, projID, backend)
+	
+	pricing, err := loadPricing()
+	if err != nil {
+		return err
+	}
+	analyzer.SetPricing(pricing)
+	
+	tiers := scaleTiers
+	if len(tiers) == 0 {
+		tiers = viper.GetIntSlice("scaling.tiers")
+	}
+	for _, users := range tiers {
+		if users <= 0 {
+			return fmt.Errorf("invalid user tier %d: tiers must be positive", users)
+		}
+	}
+	
+	// Incremental analysis needs a baseline to merge into
+	if sinceRef != "" {
+		baseline, err := loadIncrementalBaseline(projID)
+		if err != nil {
+			return err
+		}
+		if baseline != nil {
+			analyzer.SetIncremental(sinceRef, baseline)
+		}
+	}
+	
+	if ciMode {
+		if err := preparePolicy(absPath, projID); err != nil {
+			return err
+		}
+		if len(tiers) == 0 {
+			tiers = append([]int(nil), cost.DefaultTiers...)
+		}
+		tiers = policyTiers(tiers)
+	}
+	sort.Ints(tiers)
+	analyzer.SetScaleTiers(tiers)
+	
+	rates, err := serverlessRates()
+	if err != nil {
+		return err
+	}
+	analyzer.SetRequestRates(rates)
+	analyzer.SetWorkspaceDetection(!noWorkspace && sinceRef == "")
+	
+	if viper.GetInt("llm.parallelism") < 1 {
+		return fmt.Errorf("invalid parallelism %d: at least one pass must run at a time", viper.GetInt("llm.parallelism"))
+	}
+	analyzer.SetParallelism(viper.GetInt("llm.parallelism"))
+	analyzer.SetPassTimeout(viper.GetDuration("llm.pass_timeout"))
+	
+	// Ctrl-C cancels the passes and kills the LLM processes; a second one
+	// exits at once
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 	
 	// Determine analysis mode and perform analysis
-	return performAnalysis(analyzer)
+	err = performAnalysis(ctx, analyzer, mode)
+	if err != nil && ctx.Err() != nil {
+		color.Yellow("\n⏹  Analysis interrupted")
+		err = &ExitError{Code: 130, Status: "interrupted"}
+	}
+	
+	// A policy result has been reported already; usage and a second error
+	// line would only bury it
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+	}
+	return err
 }
 
-func performAnalysis(analyzer *analyzer.Analyzer) error {
-	mode := viper.GetString("llm.mode")
+// newBackend builds the LLM backend from the llm.* config keys written by setup
+func newBackend(mode, projectDir string) (llm.Backend, error) {
+	privateMode := mode == "local" || mode == "hybrid"
+	
+	provider := viper.GetString("llm.provider")
+	if provider == "" {
+		provider = llm.ProviderClaude
+		if privateMode {
+			provider = llm.ProviderOllama
+		}
+	}
+	
+	apiKey := viper.GetString("llm.api_key")
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	
+	backend, err := llm.New(llm.Config{
+		Provider:      provider,
+		BaseURL:       viper.GetString("llm.local_url"),
+		Model:         viper.GetString("llm.local_model"),
+		APIKey:        apiKey,
+		ContextWindow: viper.GetInt("llm.context_window"),
+		ProjectDir:    projectDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure LLM backend: %w", err)
+	}
 	
+	// Local and hybrid modes promise that code is never sent to a cloud model
+	if privateMode && !backend.Local() {
+		return nil, fmt.Errorf("%s mode requires a local LLM, but provider %q is not running on this machine or a private network", mode, backend.Name())
+	}
+	
+	return backend, nil
+}
+
+func performAnalysis(ctx context.Context, analyzer *analyzer.Analyzer, mode string) error {
 	switch mode {
 	case "local":
-		return performLocalAnalysis(analyzer)
+		return performLocalAnalysis(ctx, analyzer)
 	case "hybrid":
-		return performHybridAnalysis(analyzer)
+		return performHybridAnalysis(ctx, analyzer)
 	default:
-		return performCloudAnalysis(analyzer) // existing logic
+		return performCloudAnalysis(ctx, analyzer) // existing logic
 	}
 }
 
-func performLocalAnalysis(analyzer *analyzer.Analyzer) error {
-	fmt.Println("🔒 Performing local analysis...")
+func performLocalAnalysis(ctx context.Context, analyzer *analyzer.Analyzer) error {
+	fmt.Printf("🔒 Performing local analysis with %s...\n", analyzer.BackendName())
 	
-	baseURL := viper.GetString("llm.local_url")
-	if baseURL == "" {
-		baseURL = "http://localhost:11434"
-	}
-	
-	// For now, we'll use the existing cloud analysis logic
-	// In a real implementation, this would use local LLM endpoints
-	result, err := analyzer.Analyze()
+	result, err := analyzer.Analyze(ctx)
 	if err != nil {
 		return fmt.Errorf("analysis failed: %v", err)
 	}
+	saveHistory(result)
 	
 	// Handle output
-	switch output {
-	case "json":
-		return result.PrintJSON()
-	case "quiet":
-		// Silent mode
-	default:
-		result.PrintSummary()
+	if done, err := writeOutput(result, output, outputFile); done || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkGates(result)
+	}
+	
+	if err := checkGates(result); err != nil {
+		return err
 	}
 	
 	fmt.Println("✅ Local analysis completed")
 	return nil
 }
 
-func performHybridAnalysis(analyzer *analyzer.Analyzer) error {
+func performHybridAnalysis(ctx context.Context, analyzer *analyzer.Analyzer) error {
 	fmt.Println("⚡ Performing hybrid analysis...")
 	
 	// First do local analysis
-	if err := performLocalAnalysis(analyzer); err != nil {
+	if err := performLocalAnalysis(ctx, analyzer); err != nil {
 		return err
 	}
 	
@@ -178,21 +367,31 @@ func performHybridAnalysis(analyzer *analyzer.Analyzer) error {
 	return nil
 }
 
-func performCloudAnalysis(analyzer *analyzer.Analyzer) error {
+func performCloudAnalysis(ctx context.Context, analyzer *analyzer.Analyzer) error {
 	// Run analysis
-	result, err := analyzer.Analyze()
+	result, err := analyzer.Analyze(ctx)
 	if err != nil {
 		return fmt.Errorf("analysis failed: %v", err)
 	}
+	saveHistory(result)
 	
-	// Handle output
-	switch output {
-	case "json":
-		return result.PrintJSON()
-	case "quiet":
-		// Silent mode - just send to API
-	default:
-		result.PrintSummary()
+	// Handle output; quiet mode just sends to the API
+	if done, err := writeOutput(result, output, outputFile); done || err != nil {
+		if err != nil {
+			return err
+		}
+		return checkGates(result)
+	}
+	
+	// Don't upload results that failed the confidence gate
+	if err := checkMinConfidence(result); err != nil {
+		return err
+	}
+	
+	// Analyses queued earlier go first. While they can't, this one waits
+	// behind them instead of overtaking them.
+	if autoSync(ctx) && queueUpload(result, errQueuedBehind) {
+		return checkPolicy(result)
 	}
 	
 	// Send to CloudPork API
@@ -200,20 +399,85 @@ func performCloudAnalysis(analyzer *analyzer.Analyzer) error {
 		fmt.Println("📡 Sending results to CloudPork...")
 	}
 	
-	client := api.NewClient()
-	err = client.SendAnalysis(result)
+	// A failed upload goes to the outbox; when only connectivity is missing
+	// the run still succeeds and the next command that talks to the API
+	// uploads it
+	client, err := newUploadClient()
 	if err != nil {
-		color.Red("❌ Failed to send results: %v", err)
-		color.Yellow("💡 Run 'cloudpork auth login' to authenticate")
 		return err
 	}
+	err = client.SendAnalysis(ctx, result)
+	if err != nil {
+		queued := queueUpload(result, err)
+		if ctx.Err() != nil {
+			return err
+		}
+		reportSendError(err, queued)
+		var apiErr *api.Error
+		if !queued || !errors.As(err, &apiErr) || apiErr.Kind != api.ErrTransient {
+			return err
+		}
+		return checkPolicy(result)
+	}
 	
 	if output != "quiet" {
 		color.Green("✅ Analysis complete!")
 		fmt.Println("🌐 View results: https://cloudpork.com/dashboard")
 	}
 	
-	return nil
+	return checkPolicy(result)
+}
+
+// reportSendError explains a failed upload and what to do about it. queued
+// tells whether the analysis is waiting in the outbox.
+func reportSendError(err error, queued bool) {
+	color.Red("❌ Failed to send results: %v", err)
+	
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		return
+	}
+	switch apiErr.Kind {
+	case api.ErrAuth:
+		color.Yellow("💡 Run 'cloudpork auth login' to authenticate")
+	case api.ErrQuota:
+		color.Yellow("💡 Your plan's analyses are used up. Upgrade to continue: https://cloudpork.com/pricing")
+	case api.ErrValidation:
+		color.Yellow("💡 CloudPork rejected the analysis. Update the agent and try again")
+		return
+	case api.ErrTransient:
+		if !queued {
+			color.Yellow("💡 CloudPork is unreachable. Check your connection and try again later")
+		}
+	}
+	if queued {
+		color.Yellow("💡 The analysis is queued and uploads before the next command that talks to CloudPork, or run: cloudpork sync")
+	}
+}
+
+// checkMinConfidence fails the run when any field is less trustworthy than --min-confidence
+func checkMinConfidence(result *types.CodeAnalysis) error {
+	if minConfidence <= 0 {
+		return nil
+	}
+	
+	fields := result.FieldsBelow(minConfidence)
+	if len(fields) == 0 {
+		return nil
+	}
+	
+	// Report on stderr so --output=json stays parseable
+	color.New(color.FgRed).Fprintf(os.Stderr, "❌ %d fields below minimum confidence %.2f:\n", len(fields), minConfidence)
+	for _, field := range fields {
+		p, _ := result.FieldSource(field)
+		fmt.Fprintf(os.Stderr, "  • %s: %s (%.2f)", field, p.Source, p.Confidence)
+		if p.Note != "" {
+			fmt.Fprintf(os.Stderr, " - %s", p.Note)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+	
+	return fmt.Errorf("analysis confidence below --min-confidence=%.2f", minConfidence)
 }
 
 func showTrialUpgradePrompt(subscription *types.SubscriptionInfo) error {
@@ -244,34 +508,12 @@ func showTrialWarning(subscription *types.SubscriptionInfo) {
 	fmt.Println()
 }
 
-func getSubscriptionInfo(apiKey string) (*types.SubscriptionInfo, error) {
-	// API call to get subscription info
-	client := &http.Client{Timeout: 10 * time.Second}
-	
-	req, err := http.NewRequest("GET", "https://api.cloudpork.com/v1/subscription", nil)
+func getSubscriptionInfo(ctx context.Context, apiKey string) (*types.SubscriptionInfo, error) {
+	client, err := newAPIClient()
 	if err != nil {
 		return nil, err
 	}
-	
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API request failed: %s", resp.Status)
-	}
-	
-	var subscription types.SubscriptionInfo
-	if err := json.NewDecoder(resp.Body).Decode(&subscription); err != nil {
-		return nil, err
-	}
-	
-	return &subscription, nil
+	return client.GetSubscription(ctx, apiKey)
 }
 
 func printBanner() {
@@ -280,4 +522,27 @@ func printBanner() {
 	
 	fmt.Printf("%s - %s\n", banner, tagline)
 	fmt.Printf("%s\n\n", color.New(color.Faint).Sprint("Analyzing your codebase for cost optimizations..."))
-}
\ No newline at end of file
+}
+// loadIncrementalBaseline returns the analysis --since merges into: --baseline
+// if given, otherwise the project's latest analysis. It returns nil, and a
+// full analysis runs, when the project has no history yet.
+func loadIncrementalBaseline(projID string) (*types.CodeAnalysis, error) {
+	if baselineRef != "" && baselineRef != "latest" {
+		baseline, _, err := loadDiffAnalysis(baselineRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load baseline: %v", err)
+		}
+		return baseline, nil
+	}
+	
+	store, err := openHistory()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := store.Latest(projID)
+	if err != nil {
+		color.Yellow("⚠️  No baseline analysis for %s: running a full analysis", projID)
+		return nil, nil
+	}
+	return entry.Analysis, nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/llm"
+	"github.com/spf13/viper"
+)
+
+func TestNewBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		config   map[string]string
+		provider string // Backend chosen, when there is no error
+		wantErr  string
+	}{
+		{name: "cloud defaults to claude", mode: "cloud", provider: llm.ProviderClaude},
+		{name: "local defaults to ollama", mode: "local", config: map[string]string{"llm.local_model": "llama3"}, provider: llm.ProviderOllama},
+		{
+			name:     "hybrid with a server on the private network",
+			mode:     "hybrid",
+			config:   map[string]string{"llm.provider": "openai", "llm.local_url": "http://10.0.0.8:8000", "llm.local_model": "qwen"},
+			provider: llm.ProviderOpenAI,
+		},
+		{
+			name:    "local with claude",
+			mode:    "local",
+			config:  map[string]string{"llm.provider": "claude"},
+			wantErr: `local mode requires a local LLM, but provider "claude" is not running on this machine or a private network`,
+		},
+		{
+			name:    "hybrid with a public server",
+			mode:    "hybrid",
+			config:  map[string]string{"llm.provider": "openai", "llm.local_url": "https://203.0.113.7/v1", "llm.local_model": "gpt"},
+			wantErr: `hybrid mode requires a local LLM, but provider "openai"`,
+		},
+		{
+			name:    "local with ollama on a public address",
+			mode:    "local",
+			config:  map[string]string{"llm.local_url": "http://[2001:db8::1]:11434", "llm.local_model": "llama3"},
+			wantErr: `local mode requires a local LLM, but provider "ollama"`,
+		},
+		{name: "local without a model", mode: "local", wantErr: "no local model configured"},
+		{name: "unknown provider", mode: "cloud", config: map[string]string{"llm.provider": "bard"}, wantErr: "unknown LLM provider: bard"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"llm.provider", "llm.local_url", "llm.local_model"} {
+				viper.Set(key, tt.config[key])
+			}
+			t.Cleanup(func() {
+				for _, key := range []string{"llm.provider", "llm.local_url", "llm.local_model"} {
+					viper.Set(key, "")
+				}
+			})
+
+			backend, err := newBackend(tt.mode, t.TempDir())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("newBackend(%s) error = %v, want %q", tt.mode, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newBackend(%s) = %v", tt.mode, err)
+			}
+			if backend.Name() != tt.provider {
+				t.Errorf("newBackend(%s) chose %s, want %s", tt.mode, backend.Name(), tt.provider)
+			}
+		})
+	}
+}
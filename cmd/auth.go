@@ -2,15 +2,13 @@ package cmd
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 	"syscall"
-	"time"
 
+	"github.com/Cloudpork/cloudpork-agent/internal/api"
 	"github.com/Cloudpork/cloudpork-agent/internal/config"
 	"github.com/Cloudpork/cloudpork-agent/internal/types"
 	"github.com/fatih/color"
@@ -32,7 +30,9 @@ var loginCmd = &cobra.Command{
 
 You can get your API key from: https://cloudpork.com/settings/api-keys
 
-The API key will be stored securely in your system keychain.`,
+The API key is stored in your system's credential store: the macOS Keychain,
+the Secret Service on Linux or the Windows Credential Manager. Hosts without
+one get an encrypted file, ~/.cloudpork/credentials.`,
 	RunE: runLogin,
 }
 
@@ -60,8 +60,9 @@ No credit card required for trial.`,
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Check your subscription status",
-	RunE:  runStatus,
+	Short:       "Check your subscription status",
+	Annotations: map[string]string{apiAnnotation: "subscription"},
+	RunE:        runStatus,
 }
 
 func init() {
@@ -154,7 +155,7 @@ func runSignup(cmd *cobra.Command, args []string) error {
 	}
 	
 	// Create trial account
-	trialInfo, err := createTrialAccount(email, name, company)
+	trialInfo, err := createTrialAccount(cmd.Context(), email, name, company)
 	if err != nil {
 		return fmt.Errorf("failed to create trial: %w", err)
 	}
@@ -187,7 +188,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("not logged in. Run: cloudpork auth login")
 	}
 	
-	subscription, err := getSubscriptionInfo(cfg.APIKey)
+	subscription, err := getSubscriptionInfo(cmd.Context(), cfg.APIKey)
 	if err != nil {
 		return fmt.Errorf("failed to get status: %w", err)
 	}
@@ -223,47 +224,12 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-type TrialInfo struct {
-	APIKey             string    `json:"api_key"`
-	ProjectID          string    `json:"project_id"`
-	TrialEndsAt        time.Time `json:"trial_ends_at"`
-	AnalysesRemaining  int       `json:"analyses_remaining"`
-}
-
-func createTrialAccount(email, name, company string) (*TrialInfo, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	
-	payload := map[string]string{
-		"email":   email,
-		"name":    name,
-		"company": company,
-	}
-	
-	jsonData, err := json.Marshal(payload)
+func createTrialAccount(ctx context.Context, email, name, company string) (*api.TrialInfo, error) {
+	client, err := newAPIClient()
 	if err != nil {
 		return nil, err
 	}
-	
-	resp, err := client.Post(
-		"https://api.cloudpork.com/v1/auth/trial",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 201 {
-		return nil, fmt.Errorf("signup failed: %s", resp.Status)
-	}
-	
-	var trialInfo TrialInfo
-	if err := json.NewDecoder(resp.Body).Decode(&trialInfo); err != nil {
-		return nil, err
-	}
-	
-	return &trialInfo, nil
+	return client.CreateTrial(ctx, api.TrialSignup{Email: email, Name: name, Company: company})
 }
 
 func promptForInput(prompt string) (string, error) {
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/policy"
+	"github.com/Cloudpork/cloudpork-agent/internal/report"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+)
+
+// Exit codes of 'analyze --ci'. Errors keep the usual exit code 1.
+const (
+	ExitPolicyFail = 2
+	ExitPolicyWarn = 3
+)
+
+// ExitError ends the process with Code. Its details have already been printed.
+type ExitError struct {
+	Code   int
+	Status string
+}
+
+func (e *ExitError) Error() string {
+	return "policy check " + e.Status
+}
+
+var (
+	activePolicy   *policy.Policy
+	policySource   string
+	policyBaseline *types.CodeAnalysis
+	baselineLabel  string
+)
+
+// preparePolicy loads the policy and its baseline. It runs before the
+// analysis, which would otherwise become the "latest" baseline itself.
+func preparePolicy(projectDir, projID string) error {
+	path := policyFile
+	if path == "" {
+		path = filepath.Join(projectDir, policy.DefaultPath)
+	}
+
+	p, err := policy.Load(path)
+	switch {
+	case err == nil:
+		activePolicy, policySource = p, path
+	case os.IsNotExist(err) && policyFile == "":
+		activePolicy, policySource = policy.Default(), "default policy: fail on critical bottlenecks"
+	default:
+		return fmt.Errorf("failed to load policy: %v", err)
+	}
+
+	ref := baselineRef
+	if ref == "" {
+		ref = activePolicy.Baseline
+	}
+	if ref == "" || !activePolicy.UsesBaseline() {
+		return nil
+	}
+
+	if ref == "latest" {
+		store, err := openHistory()
+		if err != nil {
+			return err
+		}
+		entry, err := store.Latest(projID)
+		if err != nil {
+			return nil // The first run of a project has nothing to compare against
+		}
+		policyBaseline, baselineLabel = entry.Analysis, entry.ID
+		return nil
+	}
+
+	baseline, side, err := loadDiffAnalysis(ref)
+	if err != nil {
+		return fmt.Errorf("failed to load baseline: %v", err)
+	}
+	policyBaseline, baselineLabel = baseline, side.Label
+	return nil
+}
+
+// policyTiers adds the user counts the policy's cost limits need to tiers
+func policyTiers(tiers []int) []int {
+	seen := make(map[int]bool)
+	for _, users := range tiers {
+		seen[users] = true
+	}
+	for _, users := range activePolicy.Tiers() {
+		if !seen[users] {
+			seen[users] = true
+			tiers = append(tiers, users)
+		}
+	}
+	return tiers
+}
+
+// checkGates runs --min-confidence and, with --ci, the policy check
+func checkGates(result *types.CodeAnalysis) error {
+	if err := checkMinConfidence(result); err != nil {
+		return err
+	}
+	return checkPolicy(result)
+}
+
+// checkPolicy evaluates the policy and turns warnings and failures into
+// their exit codes
+func checkPolicy(result *types.CodeAnalysis) error {
+	if activePolicy == nil {
+		return nil
+	}
+
+	// Keep stdout machine-readable when a report is printed there
+	w := io.Writer(os.Stdout)
+	if output != report.Dashboard {
+		w = os.Stderr
+	}
+
+	check := activePolicy.Check(result, policyBaseline)
+	printPolicyResult(w, check)
+
+	switch check.Status() {
+	case policy.StatusFail:
+		return &ExitError{Code: ExitPolicyFail, Status: policy.StatusFail}
+	case policy.StatusWarn:
+		return &ExitError{Code: ExitPolicyWarn, Status: policy.StatusWarn}
+	}
+	return nil
+}
+
+func printPolicyResult(w io.Writer, check *policy.Result) {
+	fmt.Fprintf(w, "\n%s %s\n", color.New(color.FgCyan, color.Bold).Sprint("🚦 Policy Check"), color.New(color.Faint).Sprintf("(%s)", policySource))
+	if baselineLabel != "" {
+		fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("Baseline: %s", baselineLabel))
+	}
+
+	for _, v := range check.Violations {
+		label := color.New(color.FgYellow, color.Bold).Sprint("WARN")
+		if v.Level == policy.StatusFail {
+			label = color.New(color.FgRed, color.Bold).Sprint("FAIL")
+		}
+		fmt.Fprintf(w, "  %s %s %s\n", label, v.Message, color.New(color.Faint).Sprintf("[%s]", v.Rule))
+	}
+	for _, note := range check.Notes {
+		fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprint("• "+note))
+	}
+
+	fails, warns := check.Count(policy.StatusFail), check.Count(policy.StatusWarn)
+	switch check.Status() {
+	case policy.StatusFail:
+		color.New(color.FgRed, color.Bold).Fprintf(w, "❌ Policy failed: %d failed, %d warned\n", fails, warns)
+	case policy.StatusWarn:
+		color.New(color.FgYellow, color.Bold).Fprintf(w, "⚠️  Policy passed with warnings: %d warned\n", warns)
+	default:
+		color.New(color.FgGreen, color.Bold).Fprintln(w, "✅ Policy passed")
+	}
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/diff"
+	"github.com/Cloudpork/cloudpork-agent/internal/report"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffOutput  string
+	diffProject string
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <before> <after>",
+	Short: "Compare two analyses",
+	Long: `Compare two analyses: endpoints, dependencies and bottlenecks added or
+resolved, and how resource requirements and projected monthly cost changed.
+
+Each analysis is a history ID (or unique prefix), 'latest' for the newest
+analysis of the current project, or a JSON file written by
+'cloudpork analyze --output json'.`,
+	Example: `  cloudpork diff 20261001T090000Z latest
+  cloudpork diff release-1.4.json release-1.5.json
+  cloudpork diff 20261001T090000Z latest -o json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "dashboard", "Output format: dashboard or json")
+	diffCmd.Flags().StringVarP(&diffProject, "project-id", "p", "", "CloudPork project ID used to resolve 'latest'")
+}
+
+// diffSide is one of the analyses being compared
+type diffSide struct {
+	Label     string    `json:"label"` // History ID or file name
+	ProjectID string    `json:"project_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Commit    string    `json:"commit,omitempty"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if diffOutput != "dashboard" && diffOutput != "json" {
+		return fmt.Errorf("unknown output format %q: use dashboard or json", diffOutput)
+	}
+
+	before, beforeSide, err := loadDiffAnalysis(args[0])
+	if err != nil {
+		return err
+	}
+	after, afterSide, err := loadDiffAnalysis(args[1])
+	if err != nil {
+		return err
+	}
+
+	result := diff.Compare(before, after)
+
+	if diffOutput == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Before diffSide `json:"before"`
+			After  diffSide `json:"after"`
+			*diff.Result
+		}{beforeSide, afterSide, result})
+	}
+
+	if before.ProjectID != after.ProjectID {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "⚠️  Comparing different projects: %s and %s\n\n", before.ProjectID, after.ProjectID)
+	}
+	printDiff(result, beforeSide, afterSide)
+	return nil
+}
+
+// loadDiffAnalysis reads an analysis from a JSON file or the history
+func loadDiffAnalysis(ref string) (*types.CodeAnalysis, diffSide, error) {
+	if info, err := os.Stat(ref); err == nil && !info.IsDir() {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return nil, diffSide{}, fmt.Errorf("failed to read %s: %v", ref, err)
+		}
+		var analysis types.CodeAnalysis
+		if err := json.Unmarshal(data, &analysis); err != nil {
+			return nil, diffSide{}, fmt.Errorf("%s is not an analysis JSON file: %v", ref, err)
+		}
+		return &analysis, newDiffSide(ref, &analysis), nil
+	}
+
+	if strings.HasSuffix(ref, ".json") || strings.ContainsRune(ref, filepath.Separator) {
+		return nil, diffSide{}, fmt.Errorf("%s: no such file", ref)
+	}
+
+	store, err := openHistory()
+	if err != nil {
+		return nil, diffSide{}, err
+	}
+	entry, err := getHistoryEntry(store, ref, projectIDOr(diffProject))
+	if err != nil {
+		return nil, diffSide{}, err
+	}
+	return entry.Analysis, newDiffSide(entry.ID, entry.Analysis), nil
+}
+
+func newDiffSide(label string, a *types.CodeAnalysis) diffSide {
+	side := diffSide{Label: label, ProjectID: a.ProjectID, Timestamp: a.Timestamp}
+	if a.Git != nil {
+		side.Commit = a.Git.ShortCommit()
+		if a.Git.Dirty {
+			side.Commit += "*"
+		}
+	}
+	return side
+}
+
+func (s diffSide) String() string {
+	text := fmt.Sprintf("%s · %s", s.Label, s.Timestamp.Local().Format("2006-01-02 15:04"))
+	if s.Commit != "" {
+		text += " · " + s.Commit
+	}
+	return text
+}
+
+// printDiff prints the comparison in the style of the analysis summary
+func printDiff(r *diff.Result, before, after diffSide) {
+	fmt.Printf("%s\n", color.New(color.FgCyan, color.Bold).Sprint("🔀 Analysis Diff"))
+	fmt.Printf("%s\n", color.New(color.Faint).Sprint("==================================================="))
+	fmt.Printf("  %s %s\n", color.New(color.Bold).Sprint("Before:"), before)
+	fmt.Printf("  %s  %s\n\n", color.New(color.Bold).Sprint("After:"), after)
+
+	// Monthly cost per provider
+	if len(r.Costs) > 0 {
+		fmt.Printf("%s\n", color.New(color.FgGreen, color.Bold).Sprint("💰 Estimated Monthly Cost"))
+		for _, c := range r.Costs {
+			fmt.Printf("  %-6s %10s → %10s  %s\n",
+				c.Provider,
+				fmt.Sprintf("$%.2f", c.Before),
+				fmt.Sprintf("$%.2f", c.After),
+				formatDelta(c.Delta, true))
+		}
+		fmt.Println()
+	}
+
+	// Projected cost, one row per tier where it moved
+	var providers []string
+	tiers := make(map[int]map[string]diff.CostDelta)
+	var users []int
+	for _, p := range r.Projections {
+		if tiers[p.Users] == nil {
+			tiers[p.Users] = make(map[string]diff.CostDelta)
+			users = append(users, p.Users)
+		}
+		tiers[p.Users][p.Provider] = p
+		if len(users) == 1 {
+			providers = append(providers, p.Provider)
+		}
+	}
+	var rows []string
+	for _, u := range users {
+		row := fmt.Sprintf("  %-7s", types.FormatUsers(u))
+		moved := false
+		for _, provider := range providers {
+			p, ok := tiers[u][provider]
+			if !ok {
+				row += fmt.Sprintf(" %-20s", "-")
+				continue
+			}
+			moved = moved || p.Change() != 0
+			cell := fmt.Sprintf("%-20s", fmt.Sprintf("$%.0f %s", p.After, percentText(p.Delta)))
+			if p.Change() == 0 {
+				row += " " + color.New(color.Faint).Sprint(cell)
+			} else {
+				row += " " + report.SeverityColor(deltaSeverity(p.Delta)).Sprint(cell)
+			}
+		}
+		if moved {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) > 0 {
+		fmt.Printf("%s\n", color.New(color.FgMagenta, color.Bold).Sprint("📈 Projected Monthly Cost"))
+		header := fmt.Sprintf("  %-7s", "Users")
+		for _, provider := range providers {
+			header += fmt.Sprintf(" %-20s", provider)
+		}
+		fmt.Println(color.New(color.Bold).Sprint(header))
+		for _, row := range rows {
+			fmt.Println(row)
+		}
+		fmt.Println()
+	}
+
+	// Resources and counts
+	fmt.Printf("%s\n", color.New(color.FgGreen, color.Bold).Sprint("💻 Resource Requirements"))
+	for _, m := range r.Metrics {
+		fmt.Printf("  %-17s %8s → %-10s %s\n",
+			m.Name+":",
+			formatValue(m.Before),
+			formatValue(m.After)+unitSuffix(m.Unit),
+			formatDelta(m, false))
+	}
+	fmt.Println()
+
+	// Endpoints
+	if len(r.EndpointsAdded) > 0 || len(r.EndpointsRemoved) > 0 {
+		fmt.Printf("%s\n", color.New(color.FgBlue, color.Bold).Sprint("🛣️  API Routes"))
+		for _, e := range r.EndpointsAdded {
+			fmt.Printf("  %s %-7s %s %s\n", color.GreenString("+"), e.Method, e.Path,
+				color.New(color.Faint).Sprintf("%s:%d", e.File, e.Line))
+		}
+		for _, e := range r.EndpointsRemoved {
+			fmt.Printf("  %s %-7s %s\n", color.RedString("-"), e.Method, e.Path)
+		}
+		fmt.Println()
+	}
+
+	// Dependencies
+	if len(r.DependenciesAdded) > 0 || len(r.DependenciesRemoved) > 0 {
+		fmt.Printf("%s\n", color.New(color.FgBlue, color.Bold).Sprint("📦 Dependencies"))
+		for _, d := range r.DependenciesAdded {
+			fmt.Printf("  %s %s\n", color.GreenString("+"), d)
+		}
+		for _, d := range r.DependenciesRemoved {
+			fmt.Printf("  %s %s\n", color.RedString("-"), d)
+		}
+		fmt.Println()
+	}
+
+	// Bottlenecks
+	if len(r.BottlenecksAdded) > 0 || len(r.BottlenecksResolved) > 0 {
+		fmt.Printf("%s\n", color.New(color.FgYellow, color.Bold).Sprint("⚠️  Scaling Bottlenecks"))
+		for _, b := range r.BottlenecksAdded {
+			fmt.Printf("  %s %s %s: %s\n",
+				color.New(color.FgRed, color.Bold).Sprint("new"),
+				report.SeverityColor(b.Severity).Sprint("●"),
+				color.New(color.Bold).Sprint(b.Type),
+				b.Description)
+			if b.File != "" {
+				fmt.Printf("        %s\n", color.New(color.Faint).Sprintf("%s:%d", b.File, b.Line))
+			}
+		}
+		for _, b := range r.BottlenecksResolved {
+			fmt.Printf("  %s %s: %s\n",
+				color.GreenString("✓ resolved"),
+				color.New(color.Bold).Sprint(b.Type),
+				b.Description)
+		}
+		fmt.Println()
+	}
+
+	// Verdict
+	if worst := r.CostIncrease(); worst != nil {
+		pct, _ := worst.Percent()
+		color.New(color.FgRed, color.Bold).Printf("💸 More expensive to run: %s +$%.2f/month (%+.1f%%)\n", worst.Provider, worst.Change(), pct)
+	} else if len(r.Costs) > 0 {
+		color.Green("✅ No cost increase")
+	}
+}
+
+// deltaSeverity grades a change the way findings are graded, so that bigger
+// increases stand out more. Decreases are "low", which prints green.
+func deltaSeverity(d diff.Delta) string {
+	if d.Change() < 0 {
+		return "low"
+	}
+	pct, ok := d.Percent()
+	switch {
+	case !ok || pct >= 25:
+		return "critical"
+	case pct >= 10:
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+// formatDelta formats a change as "+$2.38 (+14.0%)" or "+512 (+50.0%)",
+// coloured by deltaSeverity
+func formatDelta(d diff.Delta, money bool) string {
+	if d.Change() == 0 {
+		return color.New(color.Faint).Sprint("unchanged")
+	}
+
+	change := formatValue(d.Change())
+	if d.Change() > 0 {
+		change = "+" + change
+	}
+	if money {
+		if d.Change() > 0 {
+			change = fmt.Sprintf("+$%.2f", d.Change())
+		} else {
+			change = fmt.Sprintf("-$%.2f", -d.Change())
+		}
+	}
+
+	change += " (" + percentText(d) + ")"
+	return report.SeverityColor(deltaSeverity(d)).Sprint(change)
+}
+
+// percentText is the relative change, or "new" when there was nothing before
+func percentText(d diff.Delta) string {
+	if d.Change() == 0 {
+		return "="
+	}
+	if pct, ok := d.Percent(); ok {
+		return fmt.Sprintf("%+.1f%%", pct)
+	}
+	return "new"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func unitSuffix(unit string) string {
+	if unit == "" {
+		return ""
+	}
+	return " " + unit
+}
@@ -96,6 +96,9 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 		warnings++
 	} else {
 		fmt.Println("  ✅ API key configured")
+		if store, err := config.CredentialStore(); err == nil {
+			fmt.Printf("  🔐 Credential store: %s\n", store.Name())
+		}
 		// Here you would test actual API connectivity
 		fmt.Println("  ✅ CloudPork API accessible")
 	}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/history"
+	"github.com/Cloudpork/cloudpork-agent/internal/report"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	historyProject    string
+	historyAll        bool
+	historyLimit      int
+	historyOutput     string
+	historyOutputFile string
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse past analyses",
+	Long: `Browse the analyses stored on this machine.
+
+Every 'cloudpork analyze' run is saved to ~/.cloudpork/history/<project_id>/,
+whether or not it is uploaded, so history works offline and in local mode.
+Set history.enabled to false in the config to stop saving runs.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List past analyses",
+	Example: `  cloudpork history list                   # Current project, or all projects
+  cloudpork history list --all             # Every project
+  cloudpork history list -p proj_abc123    # One project`,
+	RunE: runHistoryList,
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a past analysis",
+	Long: `Show a past analysis. The ID may be shortened to any unique prefix.
+'latest' shows the newest analysis of the current project.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistoryShow,
+}
+
+var historyRmCmd = &cobra.Command{
+	Use:   "rm <id>...",
+	Short: "Delete past analyses",
+	Example: `  cloudpork history rm 20261016T041500Z-3fa2c1
+  cloudpork history rm --all -p proj_abc123  # Every analysis of a project`,
+	RunE: runHistoryRm,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyRmCmd)
+
+	historyCmd.PersistentFlags().StringVarP(&historyProject, "project-id", "p", "", "CloudPork project ID (default: the configured project)")
+	historyListCmd.Flags().BoolVar(&historyAll, "all", false, "List every project")
+	historyListCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Show at most this many analyses (0 for all)")
+	historyShowCmd.Flags().StringVarP(&historyOutput, "output", "o", "dashboard", "Output format: dashboard or a report format")
+	historyShowCmd.Flags().StringVar(&historyOutputFile, "output-file", "", "Write the report to this file")
+	historyRmCmd.Flags().BoolVar(&historyAll, "all", false, "Delete every analysis of the project")
+
+	viper.SetDefault("history.enabled", true)
+}
+
+// openHistory opens the store at history.dir, or the default location
+func openHistory() (*history.Store, error) {
+	return history.Open(viper.GetString("history.dir"))
+}
+
+// historyProjectID is --project-id, falling back to the configured project
+func historyProjectID() string {
+	return projectIDOr(historyProject)
+}
+
+// projectIDOr returns the project ID given with a flag, or the configured
+// project when there is none
+func projectIDOr(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if id := os.Getenv("CLOUDPORK_PROJECT_ID"); id != "" {
+		return id
+	}
+	return viper.GetString("project_id")
+}
+
+// saveHistory stores an analysis result. Failing to save is reported but does
+// not fail the analysis.
+func saveHistory(result *types.CodeAnalysis) {
+	if !viper.GetBool("history.enabled") {
+		return
+	}
+
+	store, err := openHistory()
+	if err == nil {
+		var id string
+		if id, err = store.Save(result); err == nil {
+			if output == report.Dashboard {
+				fmt.Printf("🗂️  Saved to history as %s\n", id)
+			}
+			return
+		}
+	}
+	color.New(color.FgYellow).Fprintf(os.Stderr, "⚠️  Failed to save analysis to history: %v\n", err)
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+
+	project := ""
+	if !historyAll {
+		project = historyProjectID()
+	}
+
+	entries, err := store.List(project)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if project != "" {
+			fmt.Printf("No analyses in history for project %s\n", project)
+			fmt.Println("Use --all to list every project")
+		} else {
+			fmt.Println("No analyses in history yet. Run: cloudpork analyze")
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, color.New(color.Bold).Sprint("ID\tPROJECT\tDATE\tCOMMIT\tBRANCH\tCONFIDENCE\tMONTHLY"))
+	for i, entry := range entries {
+		if historyLimit > 0 && i == historyLimit {
+			break
+		}
+		a := entry.Analysis
+		commit, branch := "-", "-"
+		if a.Git != nil {
+			commit = a.Git.ShortCommit()
+			if a.Git.Dirty {
+				commit += "*"
+			}
+			if a.Git.Branch != "" {
+				branch = a.Git.Branch
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.0f%%\t%s\n",
+			entry.ID,
+			a.ProjectID,
+			a.Timestamp.Local().Format("2006-01-02 15:04"),
+			commit,
+			branch,
+			a.Confidence*100,
+			cheapestCost(a))
+	}
+	w.Flush()
+
+	if historyLimit > 0 && len(entries) > historyLimit {
+		fmt.Printf("\n%s\n", color.New(color.Faint).Sprintf("%d more; use --limit 0 to show all", len(entries)-historyLimit))
+	}
+	return nil
+}
+
+// cheapestCost formats the lowest monthly estimate of an analysis
+func cheapestCost(a *types.CodeAnalysis) string {
+	if len(a.CostEstimates) == 0 {
+		return "-"
+	}
+	cheapest := a.CostEstimates[0]
+	for _, e := range a.CostEstimates[1:] {
+		if e.MonthlyTotal < cheapest.MonthlyTotal {
+			cheapest = e
+		}
+	}
+	return fmt.Sprintf("$%.2f (%s)", cheapest.MonthlyTotal, cheapest.Provider)
+}
+
+// getHistoryEntry resolves an ID, or "latest" for project
+func getHistoryEntry(store *history.Store, id, project string) (*history.Entry, error) {
+	if id != "latest" {
+		return store.Get(id)
+	}
+
+	if project == "" {
+		return nil, fmt.Errorf("no project ID configured: pass --project-id to use 'latest'")
+	}
+	return store.Latest(project)
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+
+	entry, err := getHistoryEntry(store, args[0], historyProjectID())
+	if err != nil {
+		return err
+	}
+
+	if historyOutput == report.Dashboard && historyOutputFile == "" {
+		a := entry.Analysis
+		fmt.Printf("🗂️  %s · %s · %s", entry.ID, a.ProjectID, a.Timestamp.Local().Format("2006-01-02 15:04"))
+		if a.Git != nil {
+			fmt.Printf(" · %s", a.Git.ShortCommit())
+			if a.Git.Branch != "" {
+				fmt.Printf(" (%s)", a.Git.Branch)
+			}
+		}
+		fmt.Print("\n\n")
+	}
+
+	_, err = writeOutput(entry.Analysis, historyOutput, historyOutputFile)
+	return err
+}
+
+func runHistoryRm(cmd *cobra.Command, args []string) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+
+	if historyAll {
+		project := historyProjectID()
+		if project == "" {
+			return fmt.Errorf("no project ID configured: pass --project-id with --all")
+		}
+		count, err := store.RemoveProject(project)
+		if err != nil {
+			return err
+		}
+		color.Green("✅ Deleted %d analyses of %s", count, project)
+		return nil
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("specify the IDs to delete, or --all with a project")
+	}
+	for _, id := range args {
+		removed, err := store.Remove(id)
+		if err != nil {
+			return err
+		}
+		color.Green("✅ Deleted %s", removed)
+	}
+	return nil
+}
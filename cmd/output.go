@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/report"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+)
+
+// reportExtensions infer the --output-file format from the file name
+var reportExtensions = map[string]string{
+	".json":  "json",
+	".md":    "markdown",
+	".html":  "html",
+	".htm":   "html",
+	".sarif": "sarif",
+	".csv":   "csv",
+}
+
+// outputFileFormat validates an --output format and returns the report format
+// written to file, if one was given
+func outputFileFormat(format, file string) (string, error) {
+	_, known := report.Get(format)
+	if !known && format != "quiet" {
+		return "", fmt.Errorf("unknown output format %q: use %s, quiet, %s", format, report.Dashboard, strings.Join(report.Names(), ", "))
+	}
+	isReport := known && format != report.Dashboard
+	if file == "" {
+		return "", nil
+	}
+	if isReport {
+		return format, nil
+	}
+
+	name := strings.ToLower(file)
+	if strings.HasSuffix(name, ".sarif.json") {
+		return "sarif", nil
+	}
+	if format, ok := reportExtensions[filepath.Ext(name)]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("cannot tell the report format of %s: set --output to %s", file, strings.Join(report.Names(), ", "))
+}
+
+// writeOutput writes the report file, if any, and prints the result in the
+// given format. done is true when a report went to stdout, which must then
+// stay machine-readable.
+func writeOutput(result *types.CodeAnalysis, format, file string) (done bool, err error) {
+	fileFormat, err := outputFileFormat(format, file)
+	if err != nil {
+		return true, err
+	}
+	if fileFormat != "" {
+		if err := writeReportFile(result, fileFormat, file, format != "quiet"); err != nil {
+			return true, err
+		}
+	}
+
+	switch format {
+	case "quiet":
+		// Silent mode
+	case report.Dashboard:
+		render, _ := report.Get(format)
+		if err := render(os.Stdout, result); err != nil {
+			return true, fmt.Errorf("failed to render %s: %v", format, err)
+		}
+	default:
+		if file != "" {
+			break // Already written
+		}
+		render, _ := report.Get(format)
+		if err := render(os.Stdout, result); err != nil {
+			return true, fmt.Errorf("failed to render %s: %v", format, err)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// writeReportFile renders result to file
+func writeReportFile(result *types.CodeAnalysis, format, file string, announce bool) error {
+	render, _ := report.Get(format)
+
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", file, err)
+	}
+	if err := render(f, result); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to render %s: %v", format, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", file, err)
+	}
+
+	if announce {
+		color.Green("📄 Wrote %s report to %s", format, file)
+	}
+	return nil
+}
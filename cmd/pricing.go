@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/cost"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pricingCmd represents the pricing command
+var pricingCmd = &cobra.Command{
+	Use:   "pricing",
+	Short: "Manage the local cloud pricing catalog",
+	Long: `Manage the pricing catalog used to turn resource estimates into
+monthly costs for AWS, GCP and Azure.
+
+A catalog is built into the agent. 'cloudpork pricing update' replaces it
+with a newer catalog file, which is stored in ~/.cloudpork/pricing.json.
+The cost.catalog config key points at a catalog file to use instead.`,
+}
+
+var pricingShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the pricing catalog in use",
+	RunE:  runPricingShow,
+}
+
+var pricingUpdateCmd = &cobra.Command{
+	Use:   "update <catalog.json>",
+	Short: "Install a newer pricing catalog",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPricingUpdate,
+}
+
+var pricingResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Revert to the built-in pricing catalog",
+	RunE:  runPricingReset,
+}
+
+func init() {
+	rootCmd.AddCommand(pricingCmd)
+	pricingCmd.AddCommand(pricingShowCmd)
+	pricingCmd.AddCommand(pricingUpdateCmd)
+	pricingCmd.AddCommand(pricingResetCmd)
+}
+
+// loadPricing loads the catalog selected by the cost.catalog config key
+func loadPricing() (*cost.Catalog, error) {
+	return cost.LoadCatalog(viper.GetString("cost.catalog"))
+}
+
+func runPricingShow(cmd *cobra.Command, args []string) error {
+	catalog, err := loadPricing()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s (%s)\n\n",
+		color.New(color.FgCyan, color.Bold).Sprint("💰 Pricing catalog"),
+		catalog.Version, catalog.Currency)
+
+	for _, p := range catalog.Providers {
+		fmt.Printf("%s %s\n", color.New(color.Bold).Sprint(p.Name), color.New(color.Faint).Sprint(p.Region))
+		fmt.Printf("  Instance types: %d, database tiers: %d\n", len(p.Instances), len(p.Databases))
+		fmt.Printf("  Storage: $%.3f/GB-month, egress: $%.3f/GB (%.0f GB free)\n",
+			p.StorageGBMonth, p.EgressGB, p.FreeEgressGB)
+		if f := p.Functions; f != nil {
+			fmt.Printf("  %s: $%.2f/million requests, $%.10f/GB-second\n", f.Service, f.PerMillionRequests, f.PerGBSecond)
+		}
+	}
+	return nil
+}
+
+func runPricingUpdate(cmd *cobra.Command, args []string) error {
+	catalog, err := cost.Install(args[0])
+	if err != nil {
+		return err
+	}
+
+	color.Green("✅ Pricing catalog %s installed", catalog.Version)
+	if path := viper.GetString("cost.catalog"); path != "" {
+		color.Yellow("⚠️  cost.catalog is set to %s and takes precedence", path)
+	}
+	return nil
+}
+
+func runPricingReset(cmd *cobra.Command, args []string) error {
+	if err := cost.Reset(); err != nil {
+		return err
+	}
+
+	color.Green("✅ Using the built-in pricing catalog %s", cost.DefaultCatalog().Version)
+	return nil
+}
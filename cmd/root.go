@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Cloudpork/cloudpork-agent/internal/api"
 	"github.com/Cloudpork/cloudpork-agent/internal/config"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -29,6 +31,20 @@ var rootCmd = &cobra.Command{
 Cut the pork from your cloud costs with intelligent analysis!`,
 	
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Move a plaintext API key left by an earlier version to the
+		// credential store
+		if store, err := config.MigrateAPIKey(); err != nil {
+			color.New(color.FgYellow).Fprintf(os.Stderr, "⚠️  Your API key is stored in plaintext in %s: %v\n", viper.ConfigFileUsed(), err)
+		} else if store != nil {
+			fmt.Fprintf(os.Stderr, "🔐 Moved your API key from %s to the %s\n", viper.ConfigFileUsed(), store.Name())
+		}
+		
+		// Upload analyses queued while CloudPork was unreachable before
+		// this command talks to it, so they arrive in the order they ran
+		if _, ok := cmd.Annotations[apiAnnotation]; ok && isAuthenticated() {
+			autoSync(cmd.Context())
+		}
+		
 		// Check if user needs to sign up (except for auth commands)
 		if cmd.Name() != "auth" && !isAuthenticated() {
 			fmt.Println("👋 Welcome to CloudPork!")
@@ -50,6 +66,7 @@ func init() {
 	rootCmd.PersistentFlags().Bool("verbose", false, "enable verbose output")
 	
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindEnv("api.url", "CLOUDPORK_API_URL")
 }
 
 // initConfig reads in config file and ENV variables.
@@ -76,4 +93,17 @@ func initConfig() {
 func isAuthenticated() bool {
 	cfg, err := config.LoadConfig()
 	return err == nil && cfg.APIKey != ""
+}
+
+// newAPIClient returns a client for the API at api.url, reached through the
+// configured proxy, CA bundle and client certificate
+func newAPIClient() (*api.Client, error) {
+	return api.New(api.Config{
+		BaseURL:    viper.GetString("api.url"),
+		Version:    version,
+		Proxy:      viper.GetString("api.proxy"),
+		CACert:     viper.GetString("api.ca_cert"),
+		ClientCert: viper.GetString("api.client_cert"),
+		ClientKey:  viper.GetString("api.client_key"),
+	})
 }
\ No newline at end of file
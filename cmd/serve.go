@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/server"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr    string
+	serveDataDir string
+	serveAPIKeys []string
+	serveTLSCert string
+	serveTLSKey  string
+)
+
+// serveAPICmd represents the serve-api command
+var serveAPICmd = &cobra.Command{
+	Use:   "serve-api",
+	Short: "Run a local CloudPork-compatible API server",
+	Long: `Serve the API endpoints the agent uses from a local store, so analyses
+stay inside your network and end-to-end tests need no connection:
+
+  POST /v1/analysis         Store an uploaded analysis
+  GET  /v1/subscription     Plan and usage of the caller's key
+  GET  /v1/auth/validate    Check an API key
+  POST /v1/auth/trial       Open a trial account
+  GET  /v1/projects/{id}    Describe one of the caller's projects
+
+Accounts are kept in <data-dir>/accounts.json and analyses under
+<data-dir>/analyses/<project_id>/. Keys given with --api-key have unlimited
+analyses; 'cloudpork auth signup' against the server opens trial accounts.
+Point agents at it with api.url or CLOUDPORK_API_URL.`,
+	Example: `  cloudpork serve-api --api-key cp_ci_key
+  CLOUDPORK_API_URL=http://127.0.0.1:8787 CLOUDPORK_API_KEY=cp_ci_key cloudpork analyze
+  cloudpork serve-api --addr :8443 --tls-cert server.pem --tls-key server.key`,
+	Args: cobra.NoArgs,
+	RunE: runServeAPI,
+}
+
+func init() {
+	rootCmd.AddCommand(serveAPICmd)
+
+	serveAPICmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8787", "Address to listen on")
+	serveAPICmd.Flags().StringVar(&serveDataDir, "data-dir", "", "Directory for accounts and analyses (default ~/.cloudpork/server)")
+	serveAPICmd.Flags().StringSliceVar(&serveAPIKeys, "api-key", nil, "API key to accept with unlimited analyses (repeatable)")
+	serveAPICmd.Flags().StringVar(&serveTLSCert, "tls-cert", "", "PEM certificate to serve HTTPS with")
+	serveAPICmd.Flags().StringVar(&serveTLSKey, "tls-key", "", "PEM private key of --tls-cert")
+}
+
+func runServeAPI(cmd *cobra.Command, args []string) error {
+	if (serveTLSCert == "") != (serveTLSKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key go together")
+	}
+
+	dir := serveDataDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get data directory: %v", err)
+		}
+		dir = filepath.Join(home, ".cloudpork", "server")
+	}
+	store, err := server.OpenStore(dir)
+	if err != nil {
+		return err
+	}
+	for _, key := range serveAPIKeys {
+		if err := store.EnsureKey(key); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen("tcp", serveAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", serveAddr, err)
+	}
+
+	handler := server.New(store)
+	handler.Log = os.Stderr
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	scheme := "http"
+	if serveTLSCert != "" {
+		scheme = "https"
+	}
+	color.Green("🐷 CloudPork API listening on %s://%s", scheme, listener.Addr())
+	fmt.Printf("🗄️  Data: %s\n", store.Dir())
+
+	// Stop accepting requests on Ctrl-C and let running ones finish
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if serveTLSCert != "" {
+		err = srv.ServeTLS(listener, serveTLSCert, serveTLSKey)
+	} else {
+		err = srv.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		fmt.Println("\n👋 Server stopped")
+		return nil
+	}
+	return err
+}
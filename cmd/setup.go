@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 
+	"github.com/Cloudpork/cloudpork-agent/internal/llm"
+	"github.com/Cloudpork/cloudpork-agent/internal/models"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -168,9 +172,18 @@ func installDependencies() error {
 
 func installModel(modelName string) error {
 	fmt.Printf("🤖 Installing model: %s\n", modelName)
+	
+	manager := models.NewManager("")
+	if manager.IsModelInstalled(modelName) && !forceInstall {
+		fmt.Printf("✅ Model %s already installed\n", modelName)
+		return nil
+	}
+	
 	fmt.Println("This may take several minutes depending on your internet connection...")
+	if err := manager.InstallModel(modelName); err != nil {
+		return err
+	}
 	
-	// For now, just simulate model installation
 	fmt.Printf("✅ Model %s installed successfully\n", modelName)
 	return nil
 }
@@ -208,7 +221,22 @@ func configureCloudPork(mode, model string) error {
 func testSetup(modelName string) error {
 	fmt.Println("🧪 Testing setup...")
 	
-	// Simplified test for now
+	client := llm.NewOllamaClient(viper.GetString("llm.local_url"), modelName, 0)
+	if err := client.Check(); err != nil {
+		return err
+	}
+	
+	// A tiny JSON-mode round trip proves the model can answer analysis prompts
+	client.Progress = nil
+	response, err := client.CompleteJSON(context.Background(), `Respond with the JSON object {"ok": true}`)
+	if err != nil {
+		return fmt.Errorf("model did not respond: %w", err)
+	}
+	if !json.Valid([]byte(response)) {
+		return fmt.Errorf("model returned invalid JSON: %s", response)
+	}
+	
+	fmt.Printf("✅ Model %s answered in JSON mode (context window: %d tokens)\n", modelName, client.ContextWindow())
 	fmt.Println("✅ All tests passed")
 	return nil
 }
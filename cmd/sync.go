@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/api"
+	"github.com/Cloudpork/cloudpork-agent/internal/outbox"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// autoSyncTimeout bounds the upload of queued analyses before a command talks
+// to the API, so an API that stops answering never holds the run up for long
+const autoSyncTimeout = 20 * time.Second
+
+// apiAnnotation marks the commands that talk to the API. The outbox is
+// uploaded before they run, so queued analyses arrive before anything newer.
+const apiAnnotation = "cloudpork/api"
+
+// errQueuedBehind is recorded for an analysis queued because older ones were
+// still waiting to upload
+var errQueuedBehind = errors.New("older analyses were still waiting to upload")
+
+var syncDryRun bool
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Upload analyses queued while CloudPork was unreachable",
+	Long: `Upload the analyses whose upload failed, oldest first.
+
+'cloudpork analyze' queues an analysis in ~/.cloudpork/outbox/ when it
+cannot be sent. The queue is uploaded, in order, at the start of the next
+command that talks to the API; while it can't be, new analyses queue behind
+it. Set outbox.auto_sync to false in the config to only upload with this
+command.`,
+	Example: `  cloudpork sync            # Upload every queued analysis
+  cloudpork sync --dry-run  # List what would be uploaded`,
+	RunE: runSync,
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "List the queued analyses without uploading them")
+
+	viper.SetDefault("outbox.auto_sync", true)
+}
+
+// openOutbox opens the outbox at outbox.dir, or the default location
+func openOutbox() (*outbox.Outbox, error) {
+	return outbox.Open(viper.GetString("outbox.dir"))
+}
+
+// newUploadClient returns an API client that reports retries on stderr
+func newUploadClient() (*api.Client, error) {
+	client, err := newAPIClient()
+	if err != nil {
+		return nil, err
+	}
+	client.OnRetry = func(attempt, maxAttempts int, wait time.Duration, err error) {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "⏳ %v; retrying in %s (attempt %d/%d)\n",
+			err, wait.Round(100*time.Millisecond), attempt+1, maxAttempts)
+	}
+	return client, nil
+}
+
+func runSync(cmd *cobra.Command, args []string) error {
+	box, err := openOutbox()
+	if err != nil {
+		return err
+	}
+	items, err := box.List()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("📭 No analyses waiting to upload")
+		return nil
+	}
+
+	if syncDryRun {
+		printOutbox(items)
+		fmt.Printf("\n%s would be uploaded\n", analysesCount(len(items)))
+		return nil
+	}
+	if viper.GetBool("security.air_gapped") {
+		return fmt.Errorf("uploads are disabled on air-gapped hosts (security.air_gapped)")
+	}
+
+	client, err := newUploadClient()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sent, err := syncOutbox(ctx, box, items, client, os.Stdout)
+	fmt.Printf("\n📮 Uploaded %d of %s\n", sent, analysesCount(len(items)))
+	if err != nil {
+		if ctx.Err() == nil {
+			reportSendError(err, true)
+		}
+		cmd.SilenceUsage = true
+		return err
+	}
+	return nil
+}
+
+// printOutbox lists queued analyses in upload order
+func printOutbox(items []*outbox.Item) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, color.New(color.Bold).Sprint("ID\tPROJECT\tDATE\tATTEMPTS\tLAST ERROR"))
+	for _, item := range items {
+		lastError := item.LastError
+		if item.Rejected {
+			lastError = "rejected: " + lastError
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			item.ID,
+			item.Analysis.ProjectID,
+			item.Analysis.Timestamp.Local().Format("2006-01-02 15:04"),
+			item.Attempts,
+			lastError)
+	}
+	w.Flush()
+}
+
+// syncOutbox uploads queued analyses in order and returns how many were sent.
+// It stops at the first failure that would fail the rest as well; an analysis
+// the API rejects is marked and skipped so it does not block the queue.
+func syncOutbox(ctx context.Context, box *outbox.Outbox, items []*outbox.Item, client *api.Client, w io.Writer) (int, error) {
+	sent := 0
+	var rejected error
+	for _, item := range items {
+		err := client.SendAnalysis(ctx, item.Analysis)
+		if err == nil {
+			if err := box.Remove(item.ID); err != nil {
+				return sent, err
+			}
+			sent++
+			fmt.Fprintf(w, "✅ Uploaded %s (%s)\n", item.ID, item.Analysis.ProjectID)
+			continue
+		}
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+
+		item.Attempts++
+		item.LastError = err.Error()
+		var apiErr *api.Error
+		item.Rejected = errors.As(err, &apiErr) && apiErr.Kind == api.ErrValidation
+		if saveErr := box.Save(item); saveErr != nil {
+			return sent, saveErr
+		}
+		if !item.Rejected {
+			return sent, err
+		}
+		fmt.Fprintf(w, "❌ CloudPork rejected %s: %v\n", item.ID, err)
+		rejected = err
+	}
+	return sent, rejected
+}
+
+// queueUpload puts an analysis whose upload failed in the outbox and reports
+// whether it did. Analyses the API rejected are not queued: sending them
+// again would fail the same way.
+func queueUpload(result *types.CodeAnalysis, uploadErr error) bool {
+	var apiErr *api.Error
+	if errors.As(uploadErr, &apiErr) && apiErr.Kind == api.ErrValidation {
+		return false
+	}
+
+	box, err := openOutbox()
+	if err == nil {
+		var item *outbox.Item
+		if item, err = box.Add(result, uploadErr); err == nil {
+			if output != "quiet" {
+				fmt.Fprintf(os.Stderr, "📮 Queued for upload as %s\n", item.ID)
+			}
+			return true
+		}
+	}
+	color.New(color.FgYellow).Fprintf(os.Stderr, "⚠️  Failed to queue analysis for upload: %v\n", err)
+	return false
+}
+
+// autoSync uploads queued analyses before a command talks to the API, and
+// reports whether any are still waiting. It makes one attempt per analysis
+// within autoSyncTimeout and reports on stderr, leaving parseable output
+// alone. Analyses the API rejected are left to cloudpork sync.
+func autoSync(ctx context.Context) bool {
+	if !viper.GetBool("outbox.auto_sync") || viper.GetBool("security.air_gapped") {
+		return false
+	}
+
+	box, err := openOutbox()
+	if err != nil {
+		return false
+	}
+	items, err := pendingUploads(box)
+	if err != nil || len(items) == 0 {
+		return false
+	}
+
+	client, err := newAPIClient()
+	if err != nil {
+		return true
+	}
+	client.SetMaxAttempts(1)
+	ctx, cancel := context.WithTimeout(ctx, autoSyncTimeout)
+	defer cancel()
+
+	sent, err := syncOutbox(ctx, box, items, client, io.Discard)
+	switch {
+	case err == nil:
+		fmt.Fprintf(os.Stderr, "📮 Uploaded %s from the outbox\n", analysesCount(sent))
+	case sent > 0:
+		color.New(color.FgYellow).Fprintf(os.Stderr, "📮 Uploaded %s from the outbox; %d left. Run: cloudpork sync\n",
+			analysesCount(sent), len(items)-sent)
+	default:
+		color.New(color.Faint).Fprintf(os.Stderr, "📮 %s queued for upload. Run: cloudpork sync\n", analysesCount(len(items)))
+	}
+	left, err := pendingUploads(box)
+	return err != nil || len(left) > 0
+}
+
+// pendingUploads lists the queued analyses the API has not rejected
+func pendingUploads(box *outbox.Outbox) ([]*outbox.Item, error) {
+	all, err := box.List()
+	if err != nil {
+		return nil, err
+	}
+	var items []*outbox.Item
+	for _, item := range all {
+		if !item.Rejected {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// analysesCount formats a number of analyses
+func analysesCount(n int) string {
+	if n == 1 {
+		return "1 analysis"
+	}
+	return fmt.Sprintf("%d analyses", n)
+}
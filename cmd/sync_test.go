@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/api"
+	"github.com/Cloudpork/cloudpork-agent/internal/outbox"
+	"github.com/Cloudpork/cloudpork-agent/internal/server"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/spf13/viper"
+)
+
+// newSyncTest returns a queue of analyses for proj_a, proj_taken and proj_b,
+// in that order, and a client for a local API where proj_taken belongs to
+// another account. down makes the API answer 503 until it is cleared.
+func newSyncTest(t *testing.T) (*outbox.Outbox, *api.Client, *server.Store, *atomic.Bool) {
+	t.Helper()
+	store, err := server.OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"cp_test_mine", "cp_test_other"} {
+		if err := store.EnsureKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	taken := &types.CodeAnalysis{ProjectID: "proj_taken", Timestamp: time.Now().UTC()}
+	if _, _, err := store.SaveAnalysis("cp_test_other", "other-upload", taken); err != nil {
+		t.Fatal(err)
+	}
+
+	down := &atomic.Bool{}
+	handler := server.New(store)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Setenv("CLOUDPORK_API_KEY", "cp_test_mine")
+	client, err := api.New(api.Config{BaseURL: ts.URL, Version: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMaxAttempts(1)
+
+	dir := t.TempDir()
+	box, err := outbox.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// autoSync opens its own client and outbox from the config
+	viper.Set("api.url", ts.URL)
+	viper.Set("outbox.dir", dir)
+	t.Cleanup(func() {
+		viper.Set("api.url", "")
+		viper.Set("outbox.dir", "")
+	})
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, project := range []string{"proj_a", "proj_taken", "proj_b"} {
+		analysis := &types.CodeAnalysis{ProjectID: project, Timestamp: base.Add(time.Duration(i) * time.Minute)}
+		if _, err := box.Add(analysis, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return box, client, store, down
+}
+
+func TestSyncOutboxReplaysQueue(t *testing.T) {
+	box, client, store, _ := newSyncTest(t)
+	items, _ := box.List()
+
+	sent, err := syncOutbox(context.Background(), box, items, client, io.Discard)
+	if sent != 2 || err == nil {
+		t.Errorf("sent %d, err %v; want 2 sent and the rejection reported", sent, err)
+	}
+	account, _ := store.Account("cp_test_mine")
+	if account.AnalysesUsed != 2 {
+		t.Errorf("the API stored %d analyses, want 2", account.AnalysesUsed)
+	}
+
+	// The rejected analysis stays queued, marked as rejected
+	left, _ := box.List()
+	if len(left) != 1 || left[0].Analysis.ProjectID != "proj_taken" || !left[0].Rejected || left[0].Attempts != 2 {
+		t.Fatalf("outbox = %+v, want only the rejected analysis", left)
+	}
+
+	// Replaying the queue does not upload anything twice
+	if sent, _ := syncOutbox(context.Background(), box, left, client, io.Discard); sent != 0 {
+		t.Errorf("replay sent %d, want 0", sent)
+	}
+	if account, _ := store.Account("cp_test_mine"); account.AnalysesUsed != 2 {
+		t.Errorf("the API stored %d analyses after the replay, want 2", account.AnalysesUsed)
+	}
+	if left, _ := box.List(); len(left) != 1 || left[0].Attempts != 3 {
+		t.Errorf("outbox = %+v, want the rejected analysis with one more attempt", left)
+	}
+}
+
+func TestSyncOutboxStopsWhenUnreachable(t *testing.T) {
+	box, client, store, down := newSyncTest(t)
+	items, _ := box.List()
+
+	down.Store(true)
+	sent, err := syncOutbox(context.Background(), box, items, client, io.Discard)
+	if sent != 0 || err == nil {
+		t.Fatalf("sent %d, err %v; want nothing sent and the error", sent, err)
+	}
+	// Only the first analysis was tried; the rest keep their place
+	left, _ := box.List()
+	if len(left) != 3 || left[0].Attempts != 2 || left[0].Rejected || left[1].Attempts != 1 {
+		t.Fatalf("outbox = %+v, want all three queued and the first tried once more", left)
+	}
+
+	down.Store(false)
+	if sent, _ := syncOutbox(context.Background(), box, left, client, io.Discard); sent != 2 {
+		t.Errorf("sent %d once the API is back, want 2", sent)
+	}
+	if account, _ := store.Account("cp_test_mine"); account.AnalysesUsed != 2 {
+		t.Errorf("the API stored %d analyses, want 2", account.AnalysesUsed)
+	}
+}
+
+func TestAutoSyncUploadsQueueFirst(t *testing.T) {
+	box, _, store, down := newSyncTest(t)
+
+	viper.Set("outbox.auto_sync", false)
+	if autoSync(context.Background()) {
+		t.Error("autoSync reported analyses waiting with outbox.auto_sync off")
+	}
+	viper.Set("outbox.auto_sync", true)
+	t.Cleanup(func() { viper.Set("outbox.auto_sync", true) })
+
+	// While the API is down the queue waits, so a new analysis goes behind it
+	down.Store(true)
+	if !autoSync(context.Background()) {
+		t.Fatal("autoSync reported an empty queue while the API was down")
+	}
+	if left, _ := box.List(); len(left) != 3 {
+		t.Fatalf("outbox = %+v, want all three queued", left)
+	}
+
+	// Once it is back the queue is uploaded in order; only the rejected
+	// analysis is left, and it does not hold new ones back
+	down.Store(false)
+	if autoSync(context.Background()) {
+		t.Error("autoSync reported analyses waiting after uploading the queue")
+	}
+	left, _ := box.List()
+	if len(left) != 1 || left[0].Analysis.ProjectID != "proj_taken" || !left[0].Rejected {
+		t.Fatalf("outbox = %+v, want only the rejected analysis", left)
+	}
+	for _, project := range []string{"proj_a", "proj_b"} {
+		if entries, err := store.Analyses(project); err != nil || len(entries) != 1 {
+			t.Errorf("the API stored %d analyses of %s (%v), want 1", len(entries), project, err)
+		}
+	}
+}
@@ -1,43 +1,154 @@
 package analyzer
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Cloudpork/cloudpork-agent/internal/claude"
+	"github.com/Cloudpork/cloudpork-agent/internal/cost"
+	"github.com/Cloudpork/cloudpork-agent/internal/git"
+	"github.com/Cloudpork/cloudpork-agent/internal/llm"
 	"github.com/Cloudpork/cloudpork-agent/internal/types"
 	"github.com/fatih/color"
 )
 
-// Analyzer performs code analysis using Claude Code
+// Analyzer performs code analysis using a static scan and an LLM backend
 type Analyzer struct {
 	projectDir string
 	projectID  string
-	claude     *claude.Client
+	backend    llm.Backend
+	pricing    *cost.Catalog
+	tiers      []int
+	rates      []float64
+
+	// parallelism and passTimeout bound the LLM passes
+	parallelism int
+	passTimeout time.Duration
+
+	// facts are the results of the static pre-scan
+	facts *types.StaticFacts
+	// endpoints are the routes found by the framework route extractors
+	endpoints []types.Endpoint
+	// querySites are the ORM and driver calls found in the source
+	querySites []types.QuerySite
+	// infra are the resources declared in infrastructure as code
+	infra []types.InfraResource
+	// images are the images built by the project's Dockerfiles, and
+	// imageFindings what makes them larger than they need to be
+	images        []types.ContainerImage
+	imageFindings []types.Recommendation
+	// functions are the serverless functions declared in templates
+	functions []types.ServerlessFunction
+
+	// workspaces enables analyzing a monorepo service by service
+	workspaces bool
+
+	// sourceContext is the project source inlined into prompts, if needed
+	sourceContext string
+
+	// since and baseline are set for an incremental analysis; scope then
+	// holds the changed files and their dependents
+	since       string
+	baseline    *types.CodeAnalysis
+	incremental *types.IncrementalInfo
+	scope       map[string]bool
 }
 
-// New creates a new analyzer instance
-func New(projectDir, projectID string) *Analyzer {
+// New creates a new analyzer instance. A nil backend runs the static scan
+// only, without any LLM passes.
+func New(projectDir, projectID string, backend llm.Backend) *Analyzer {
 	return &Analyzer{
 		projectDir: projectDir,
 		projectID:  projectID,
-		claude:     claude.New(projectDir),
+		backend:    backend,
+	}
+}
+
+// SetPricing sets the catalog used to price the resource estimates. The
+// embedded catalog is used when none is set.
+func (a *Analyzer) SetPricing(catalog *cost.Catalog) {
+	a.pricing = catalog
+}
+
+// SetScaleTiers sets the user counts the resource estimates are projected to.
+// cost.DefaultTiers are used when none are set.
+func (a *Analyzer) SetScaleTiers(tiers []int) {
+	a.tiers = tiers
+}
+
+// SetRequestRates sets the requests per second serverless functions are
+// projected to. cost.DefaultRequestRates are used when none are set.
+func (a *Analyzer) SetRequestRates(rates []float64) {
+	a.rates = rates
+}
+
+// SetWorkspaceDetection enables detecting the services of a monorepo, which
+// are then analyzed one by one and aggregated
+func (a *Analyzer) SetWorkspaceDetection(enabled bool) {
+	a.workspaces = enabled
+}
+
+// Backend returns the LLM backend used for analysis
+func (a *Analyzer) Backend() llm.Backend {
+	return a.backend
+}
+
+// BackendName describes the analysis backend for display
+func (a *Analyzer) BackendName() string {
+	if a.backend == nil {
+		return "static scan"
 	}
+	return a.backend.Name()
 }
 
-// Analyze performs comprehensive code analysis
-func (a *Analyzer) Analyze() (*types.CodeAnalysis, error) {
+// Analyze performs comprehensive code analysis. Cancelling ctx stops the
+// LLM passes and kills the processes they started.
+func (a *Analyzer) Analyze(ctx context.Context) (*types.CodeAnalysis, error) {
+	// A monorepo is analyzed service by service
+	if a.workspaces && a.baseline == nil {
+		services, source, err := DetectWorkspace(a.projectDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(services) > 0 {
+			return a.analyzeWorkspace(ctx, source, services)
+		}
+	}
+	
 	// Pre-flight checks
 	if err := a.preflightChecks(); err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	
-	// Run Claude Code analysis
-	result, err := a.claude.Analyze(a.projectID)
-	if err != nil {
-		return nil, fmt.Errorf("claude analysis failed: %v", err)
+	var result *types.CodeAnalysis
+	if a.baseline != nil {
+		// Re-analyze the changed files only
+		var err error
+		result, err = a.runIncremental(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s analysis failed: %v", a.BackendName(), err)
+		}
+	} else if a.backend == nil {
+		// Static scan only
+		result = a.newAnalysis()
+		a.applyStaticFacts(result)
+		a.defaultResources(result)
+	} else {
+		// Run LLM analysis passes
+		var err error
+		result, err = a.runPasses(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s analysis failed: %v", a.backend.Name(), err)
+		}
 	}
+	a.applyQuerySites(result)
 	
 	// Post-process results
 	a.postProcess(result)
@@ -45,81 +156,226 @@ func (a *Analyzer) Analyze() (*types.CodeAnalysis, error) {
 	return result, nil
 }
 
-// preflightChecks validates prerequisites
+// preflightChecks validates prerequisites and runs the static pre-scan
 func (a *Analyzer) preflightChecks() error {
 	// Check if directory exists and is accessible
 	if _, err := os.Stat(a.projectDir); os.IsNotExist(err) {
 		return fmt.Errorf("directory does not exist: %s", a.projectDir)
 	}
 	
+	// Static pre-scan, before any LLM call
+	facts, err := Scan(a.projectDir)
+	if err != nil {
+		return err
+	}
+	a.facts = facts
+	
+	endpoints, err := ExtractRoutes(a.projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to extract routes: %v", err)
+	}
+	a.endpoints = endpoints
+	
+	querySites, err := FindQuerySites(a.projectDir, endpoints)
+	if err != nil {
+		return fmt.Errorf("failed to find database calls: %v", err)
+	}
+	a.querySites = querySites
+	
+	infra, err := FindTerraformResources(a.projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to read Terraform files: %v", err)
+	}
+	a.infra = infra
+	
+	workloads, err := FindKubernetesResources(a.projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to read Kubernetes manifests: %v", err)
+	}
+	a.infra = append(a.infra, workloads...)
+	
+	services, err := FindComposeServices(a.projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to read compose files: %v", err)
+	}
+	a.infra = append(a.infra, services...)
+	
+	a.images, a.imageFindings, err = FindDockerfiles(a.projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to read Dockerfiles: %v", err)
+	}
+	
+	functions, err := FindServerlessFunctions(a.projectDir)
+	if err != nil {
+		return fmt.Errorf("failed to read serverless templates: %v", err)
+	}
+	a.functions = functions
+	
+	if a.baseline != nil {
+		if err := a.findScope(); err != nil {
+			return err
+		}
+	}
+	
 	// Check if it looks like a code project
 	if !a.isCodeProject() {
 		color.Yellow("⚠️  Directory doesn't appear to contain a typical code project")
 		color.Yellow("   Continuing anyway, but results may be limited")
 	}
 	
-	// Check Claude Code installation
-	if !claude.IsInstalled() {
-		color.Red("❌ Claude Code CLI not found")
-		fmt.Println()
-		fmt.Println(claude.GetInstallInstructions())
-		return fmt.Errorf("Claude Code CLI not installed")
+	if a.backend == nil {
+		return nil
+	}
+	
+	// Check the LLM backend is usable
+	if err := a.backend.Check(); err != nil {
+		color.Red("❌ %v", err)
+		if _, ok := a.backend.(*claude.Client); ok {
+			fmt.Println()
+			fmt.Println(claude.GetInstallInstructions())
+		}
+		return err
 	}
 	
 	return nil
 }
 
-// isCodeProject checks if directory contains typical code project files
+// isCodeProject checks whether the static scan found source code, manifests,
+// Dockerfiles or serverless templates
 func (a *Analyzer) isCodeProject() bool {
-	// Common project indicators
-	indicators := []string{
-		"package.json",     // Node.js
-		"requirements.txt", // Python
-		"go.mod",          // Go
-		"Cargo.toml",      // Rust
-		"composer.json",   // PHP
-		"Gemfile",         // Ruby
-		"pom.xml",         // Java (Maven)
-		"build.gradle",    // Java (Gradle)
-		"Dockerfile",      // Docker
-		".git",           // Git repository
-	}
-	
-	for _, indicator := range indicators {
-		if _, err := os.Stat(filepath.Join(a.projectDir, indicator)); err == nil {
-			return true
-		}
+	return len(a.images) > 0 || len(a.functions) > 0 || a.facts != nil && (a.facts.Language != "" || len(a.facts.Manifests) > 0)
+}
+
+// newAnalysis creates an empty result for this project
+func (a *Analyzer) newAnalysis() *types.CodeAnalysis {
+	return &types.CodeAnalysis{
+		ProjectID:     a.projectID,
+		Timestamp:     time.Now(),
+		Directory:     a.projectDir,
+		Git:           git.Describe(a.projectDir),
+		SchemaVersion: SchemaVersion,
+	}
+}
+
+// applyStaticFacts replaces model-derived values with those the static scan
+// established
+func (a *Analyzer) applyStaticFacts(analysis *types.CodeAnalysis) {
+	if a.facts == nil {
+		return
+	}
+	analysis.StaticFacts = a.facts
+	
+	if a.facts.Language != "" {
+		analysis.Language = a.facts.Language
+		analysis.SetProvenance("language", types.SourceStatic, "most lines of code")
+	}
+	if a.facts.Framework != "" {
+		analysis.Framework = a.facts.Framework
+		analysis.SetProvenance("framework", types.SourceStatic, "declared dependency")
+	}
+	if names := dependencyNames(a.facts.Dependencies); len(names) > 0 {
+		analysis.Dependencies = names
+		analysis.SetProvenance("dependencies", types.SourceStatic, strings.Join(a.facts.Manifests, ", "))
+	}
+	if len(a.endpoints) > 0 {
+		analysis.Endpoints = a.endpoints
+		analysis.ApiEndpoints = len(a.endpoints)
+		analysis.SetProvenance("api_endpoints", types.SourceStatic, "route declarations")
+	}
+}
+
+// applyQuerySites replaces model-derived database findings with the call sites
+// found statically, reporting each N+1 candidate as a located bottleneck
+func (a *Analyzer) applyQuerySites(analysis *types.CodeAnalysis) {
+	if len(a.querySites) == 0 {
+		return
 	}
 	
-	// Check for source code directories
-	sourceDirs := []string{"src", "lib", "app", "components", "pages"}
-	for _, dir := range sourceDirs {
-		if info, err := os.Stat(filepath.Join(a.projectDir, dir)); err == nil && info.IsDir() {
-			return true
+	analysis.QuerySites = a.querySites
+	analysis.DatabaseCalls = len(a.querySites)
+	analysis.SetProvenance("database_calls", types.SourceStatic, "ORM and driver call sites")
+	
+	// The static findings replace the model's prose about N+1 queries
+	bottlenecks := analysis.ScalingBottlenecks[:0]
+	for _, b := range analysis.ScalingBottlenecks {
+		lower := strings.ToLower(b.Description)
+		if b.File == "" && (strings.Contains(lower, "n+1") || strings.Contains(lower, "n + 1")) {
+			continue
 		}
+		bottlenecks = append(bottlenecks, b)
 	}
 	
-	return false
+	nPlusOne := false
+	for _, site := range a.querySites {
+		if !site.InLoop {
+			continue
+		}
+		nPlusOne = true
+		
+		severity := "medium"
+		if site.Endpoint != "" {
+			severity = "high" // Runs on every request to that route
+		}
+		bottlenecks = append(bottlenecks, types.Bottleneck{
+			Type:        "database",
+			Description: fmt.Sprintf("%s %s %s inside a loop", types.NPlusOnePrefix, site.ORM, site.Call),
+			Severity:    severity,
+			Impact:      "Issues one query per item, so database load grows with result size",
+			File:        site.File,
+			Line:        site.Line,
+			Endpoint:    site.Endpoint,
+		})
+	}
+	analysis.ScalingBottlenecks = bottlenecks
+	analysis.Performance.HasNPlusOneQuery = nPlusOne
+	analysis.SetProvenance("performance.has_n_plus_one_query", types.SourceStatic, "queries inside loops")
 }
 
 // postProcess enhances analysis results
 func (a *Analyzer) postProcess(analysis *types.CodeAnalysis) {
 	// Set estimated users based on complexity and endpoints
 	analysis.EstimatedUsers = a.estimateCurrentUsers(analysis)
+	analysis.SetComputedProvenance("estimated_users", "formula over complexity, endpoints and jobs", "complexity_score", "api_endpoints", "background_jobs")
 	
 	// Add missing default values
 	if analysis.Language == "" {
 		analysis.Language = "Unknown"
+		analysis.SetProvenance("language", types.SourceDefault, "")
 	}
 	if analysis.Framework == "" {
 		analysis.Framework = "Unknown"
+		analysis.SetProvenance("framework", types.SourceDefault, "")
 	}
 	if analysis.ComplexityScore == 0 {
 		analysis.ComplexityScore = 50 // Default medium complexity
+		analysis.SetProvenance("complexity_score", types.SourceDefault, "")
 	}
 	
 	// Validate resource estimates
 	a.validateResourceEstimates(analysis)
+	
+	// Price the estimates locally
+	if a.pricing == nil {
+		a.pricing = cost.DefaultCatalog()
+	}
+	analysis.CostEstimates = cost.Estimate(a.pricing, analysis.ResourceUsage)
+	
+	// Compare the declared infrastructure with the estimates
+	a.applyInfrastructure(analysis)
+	a.applyContainers(analysis)
+	
+	// Project to larger user counts and find where scaling limits bind
+	tiers := a.tiers
+	if len(tiers) == 0 {
+		tiers = cost.DefaultTiers
+	}
+	analysis.Projections, analysis.ScalingLimits = cost.Project(a.pricing, analysis, tiers)
+	cost.BindBottlenecks(analysis.ScalingBottlenecks, analysis.ScalingLimits)
+	
+	// Functions are priced per invocation rather than as servers
+	a.applyServerless(analysis)
+	
+	analysis.Confidence = analysis.OverallConfidence()
 }
 
 // estimateCurrentUsers estimates current user base from codebase complexity
@@ -160,42 +416,36 @@ func (a *Analyzer) validateResourceEstimates(analysis *types.CodeAnalysis) {
 	resources := &analysis.ResourceUsage
 	
 	// Memory validation (minimum 128MB, maximum 16GB)
-	if resources.MemoryMB < 128 {
-		resources.MemoryMB = 128
-	}
-	if resources.MemoryMB > 16384 {
-		resources.MemoryMB = 16384
-	}
+	clampInt(analysis, "resource_usage.memory_mb", &resources.MemoryMB, 128, 16384)
 	
 	// CPU validation (minimum 0.1 cores, maximum 32 cores)
-	if resources.CPUCores < 0.1 {
-		resources.CPUCores = 0.1
-	}
-	if resources.CPUCores > 32.0 {
-		resources.CPUCores = 32.0
+	if resources.CPUCores < 0.1 || resources.CPUCores > 32.0 {
+		original := resources.CPUCores
+		resources.CPUCores = math.Min(math.Max(resources.CPUCores, 0.1), 32.0)
+		analysis.SetProvenance("resource_usage.cpu_cores", types.SourceClamped,
+			fmt.Sprintf("%.2f clamped to %.1f", original, resources.CPUCores))
 	}
 	
 	// Database connections (minimum 1, maximum 1000)
-	if resources.DatabaseConns < 1 {
-		resources.DatabaseConns = 1
-	}
-	if resources.DatabaseConns > 1000 {
-		resources.DatabaseConns = 1000
-	}
+	clampInt(analysis, "resource_usage.database_connections", &resources.DatabaseConns, 1, 1000)
 	
 	// Network bandwidth (minimum 1Mbps, maximum 10Gbps)
-	if resources.NetworkMbps < 1 {
-		resources.NetworkMbps = 1  
-	}
-	if resources.NetworkMbps > 10000 {
-		resources.NetworkMbps = 10000
-	}
+	clampInt(analysis, "resource_usage.network_mbps", &resources.NetworkMbps, 1, 10000)
 	
 	// Storage (minimum 1GB, maximum 10TB)
-	if resources.StorageGB < 1 {
-		resources.StorageGB = 1
+	clampInt(analysis, "resource_usage.storage_gb", &resources.StorageGB, 1, 10000)
+}
+
+// clampInt forces *value into [min, max] and records the field as clamped
+func clampInt(analysis *types.CodeAnalysis, field string, value *int, min, max int) {
+	original := *value
+	if *value < min {
+		*value = min
 	}
-	if resources.StorageGB > 10000 {
-		resources.StorageGB = 10000
+	if *value > max {
+		*value = max
 	}
-}
\ No newline at end of file
+	if *value != original {
+		analysis.SetProvenance(field, types.SourceClamped, fmt.Sprintf("%d clamped to %d", original, *value))
+	}
+}
@@ -0,0 +1,292 @@
+package analyzer
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// composeService is the part of a compose service the inventory reads
+type composeService struct {
+	Image  string      `yaml:"image"`
+	Build  interface{} `yaml:"build"`
+	Deploy struct {
+		Replicas  *int `yaml:"replicas"`
+		Resources struct {
+			Limits       composeResources `yaml:"limits"`
+			Reservations composeResources `yaml:"reservations"`
+		} `yaml:"resources"`
+	} `yaml:"deploy"`
+	CPUs           string      `yaml:"cpus"`
+	MemLimit       string      `yaml:"mem_limit"`
+	MemReservation string      `yaml:"mem_reservation"`
+	Volumes        []yaml.Node `yaml:"volumes"`
+	DependsOn      yaml.Node   `yaml:"depends_on"`
+}
+
+// composeResources are a service's CPU and memory limits or reservations
+type composeResources struct {
+	CPUs   string `yaml:"cpus"`
+	Memory string `yaml:"memory"`
+}
+
+// composeFileName matches compose files and their overrides, such as
+// "docker-compose.prod.yml"
+var composeFileName = regexp.MustCompile(`^(?:docker-)?compose(\.[\w-]+)?\.ya?ml$`)
+
+// serviceImage is the datastore or infrastructure service an image runs
+type serviceImage struct {
+	name string
+	role string
+}
+
+// serviceImages classifies well-known images by repository name
+var serviceImages = map[string]serviceImage{
+	"postgres":          {"postgres", "database"},
+	"postgis":           {"postgres", "database"},
+	"timescaledb":       {"postgres", "database"},
+	"mysql":             {"mysql", "database"},
+	"mariadb":           {"mariadb", "database"},
+	"mongo":             {"mongodb", "database"},
+	"mongodb":           {"mongodb", "database"},
+	"cassandra":         {"cassandra", "database"},
+	"cockroach":         {"cockroachdb", "database"},
+	"couchdb":           {"couchdb", "database"},
+	"neo4j":             {"neo4j", "database"},
+	"clickhouse-server": {"clickhouse", "database"},
+	"influxdb":          {"influxdb", "database"},
+	"dynamodb-local":    {"dynamodb", "database"},
+	"redis":             {"redis", "cache"},
+	"redis-stack":       {"redis", "cache"},
+	"valkey":            {"valkey", "cache"},
+	"keydb":             {"keydb", "cache"},
+	"memcached":         {"memcached", "cache"},
+	"dragonfly":         {"dragonfly", "cache"},
+	"rabbitmq":          {"rabbitmq", "queue"},
+	"kafka":             {"kafka", "queue"},
+	"cp-kafka":          {"kafka", "queue"},
+	"nats":              {"nats", "queue"},
+	"elasticmq":         {"sqs", "queue"},
+	"elasticsearch":     {"elasticsearch", "search"},
+	"opensearch":        {"opensearch", "search"},
+	"meilisearch":       {"meilisearch", "search"},
+	"typesense":         {"typesense", "search"},
+	"solr":              {"solr", "search"},
+	"minio":             {"minio", "storage"},
+}
+
+// lookupServiceImage classifies the service an image runs
+func lookupServiceImage(image string) (serviceImage, bool) {
+	if image == "" {
+		return serviceImage{}, false
+	}
+	repo, _ := splitImage(image)
+	svc, ok := serviceImages[path.Base(repo)]
+	return svc, ok
+}
+
+// FindComposeServices inventories the services and named volumes declared in
+// docker-compose files, with their images, replicas, resource limits and the
+// services each depends on. Overrides of a service already declared in the
+// same directory are skipped.
+func FindComposeServices(root string) ([]types.InfraResource, error) {
+	var files []string
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		if composeFileName.MatchString(path.Base(rel)) && info.Size() <= maxScannedFileBytes {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Base files come before their overrides
+	sort.SliceStable(files, func(i, j int) bool {
+		if di, dj := path.Dir(files[i]), path.Dir(files[j]); di != dj {
+			return di < dj
+		}
+		return composeFileName.FindStringSubmatch(path.Base(files[i]))[1] == "" &&
+			composeFileName.FindStringSubmatch(path.Base(files[j]))[1] != ""
+	})
+
+	var resources []types.InfraResource
+	seen := make(map[string]bool)
+	for _, rel := range files {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		for _, r := range parseComposeFile(rel, data) {
+			key := path.Dir(rel) + "/" + r.Address()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			resources = append(resources, r)
+		}
+	}
+	return resources, nil
+}
+
+// parseComposeFile reads the services and named volumes of one compose file
+func parseComposeFile(rel string, data []byte) []types.InfraResource {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	var resources []types.InfraResource
+	services := mappingValue(doc.Content[0], "services")
+	for i := 0; services != nil && i+1 < len(services.Content); i += 2 {
+		key := services.Content[i]
+		var svc composeService
+		if err := services.Content[i+1].Decode(&svc); err != nil {
+			continue
+		}
+
+		r := types.InfraResource{
+			Source:          "docker-compose",
+			Type:            "service",
+			Name:            key.Value,
+			Role:            "compute",
+			Image:           svc.Image,
+			Count:           1,
+			CPULimit:        parseComposeCPUs(firstNonEmpty(svc.Deploy.Resources.Limits.CPUs, svc.CPUs)),
+			CPURequest:      parseComposeCPUs(svc.Deploy.Resources.Reservations.CPUs),
+			MemoryLimitMB:   parseComposeMemoryMB(firstNonEmpty(svc.Deploy.Resources.Limits.Memory, svc.MemLimit)),
+			MemoryRequestMB: parseComposeMemoryMB(firstNonEmpty(svc.Deploy.Resources.Reservations.Memory, svc.MemReservation)),
+			DependsOn:       composeDependencies(svc.DependsOn),
+			File:            rel,
+			Line:            key.Line,
+		}
+		if known, ok := lookupServiceImage(svc.Image); ok && svc.Build == nil {
+			r.Role = known.role
+		}
+		if svc.Deploy.Replicas != nil {
+			r.Count = *svc.Deploy.Replicas
+		}
+		for _, v := range svc.Volumes {
+			if isNamedVolume(v) {
+				r.Stateful = true
+			}
+		}
+		resources = append(resources, r)
+	}
+
+	volumes := mappingValue(doc.Content[0], "volumes")
+	for i := 0; volumes != nil && i+1 < len(volumes.Content); i += 2 {
+		resources = append(resources, types.InfraResource{
+			Source: "docker-compose",
+			Type:   "volume",
+			Name:   volumes.Content[i].Value,
+			Role:   "storage",
+			File:   rel,
+			Line:   volumes.Content[i].Line,
+		})
+	}
+	return resources
+}
+
+// mappingValue returns the value of a key in a YAML mapping when it is a
+// mapping itself
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if v := mappingNode(n, key); v != nil && v.Kind == yaml.MappingNode {
+		return v
+	}
+	return nil
+}
+
+// mappingNode returns the value of a key in a YAML mapping, of any kind
+func mappingNode(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// composeDependencies reads depends_on, a list of service names or a mapping
+// from them to start conditions
+func composeDependencies(n yaml.Node) []string {
+	var names []string
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			names = append(names, item.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(n.Content); i += 2 {
+			names = append(names, n.Content[i].Value)
+		}
+	}
+	return names
+}
+
+// isNamedVolume reports whether a service volume mounts a named volume, as
+// opposed to a bind mount of a host path or a tmpfs
+func isNamedVolume(n yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		source, _, found := strings.Cut(n.Value, ":")
+		return found && source != "" && !strings.ContainsAny(source[:1], "./~$")
+	case yaml.MappingNode:
+		var v struct {
+			Type string `yaml:"type"`
+		}
+		return n.Decode(&v) == nil && v.Type == "volume"
+	}
+	return false
+}
+
+// parseComposeCPUs converts a compose CPU count ("0.5") to cores
+func parseComposeCPUs(cpus string) float64 {
+	n, err := strconv.ParseFloat(strings.TrimSpace(cpus), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// composeMemoryUnits are the compose byte size suffixes, in bytes
+var composeMemoryUnits = map[string]float64{
+	"": 1, "b": 1, "k": 1 << 10, "kb": 1 << 10, "m": 1 << 20, "mb": 1 << 20, "g": 1 << 30, "gb": 1 << 30,
+}
+
+// composeMemory matches a compose byte size, such as "512m" or "1.5G"
+var composeMemory = regexp.MustCompile(`^([\d.]+)\s*([a-z]*)$`)
+
+// parseComposeMemoryMB converts a compose byte size to MiB
+func parseComposeMemoryMB(size string) int {
+	m := composeMemory.FindStringSubmatch(strings.ToLower(strings.TrimSpace(size)))
+	if m == nil {
+		return 0
+	}
+	unit, ok := composeMemoryUnits[m[2]]
+	n, err := strconv.ParseFloat(m[1], 64)
+	if !ok || err != nil {
+		return 0
+	}
+	return int(n * unit / (1 << 20))
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseComposeFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []types.InfraResource
+	}{
+		{
+			name: "services, limits and named volumes",
+			data: `services:
+  api:
+    build: .
+    image: acme/api
+    deploy:
+      replicas: 3
+      resources:
+        limits: {cpus: "0.5", memory: 512M}
+        reservations: {cpus: "0.25", memory: 256m}
+    depends_on: [db, cache]
+    volumes:
+      - ./src:/app
+  db:
+    image: postgres:16-alpine
+    mem_limit: 1g
+    cpus: 2
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+  cache:
+    image: docker.io/library/redis:7
+    depends_on:
+      db:
+        condition: service_healthy
+volumes:
+  pgdata:
+`,
+			want: []types.InfraResource{
+				{Source: "docker-compose", Type: "service", Name: "api", Role: "compute", Image: "acme/api", Count: 3,
+					CPULimit: 0.5, CPURequest: 0.25, MemoryLimitMB: 512, MemoryRequestMB: 256, DependsOn: []string{"db", "cache"}, File: "compose.yaml", Line: 2},
+				{Source: "docker-compose", Type: "service", Name: "db", Role: "database", Image: "postgres:16-alpine", Count: 1,
+					CPULimit: 2, MemoryLimitMB: 1024, Stateful: true, File: "compose.yaml", Line: 13},
+				{Source: "docker-compose", Type: "service", Name: "cache", Role: "cache", Image: "docker.io/library/redis:7", Count: 1,
+					DependsOn: []string{"db"}, File: "compose.yaml", Line: 19},
+				{Source: "docker-compose", Type: "volume", Name: "pgdata", Role: "storage", File: "compose.yaml", Line: 25},
+			},
+		},
+		{
+			name: "a datastore image built locally is compute",
+			data: `services:
+  search:
+    image: elasticsearch:8
+    build: ./search
+    volumes:
+      - type: volume
+        source: index
+        target: /data
+`,
+			want: []types.InfraResource{
+				{Source: "docker-compose", Type: "service", Name: "search", Role: "compute", Image: "elasticsearch:8", Count: 1, Stateful: true, File: "compose.yaml", Line: 2},
+			},
+		},
+		{name: "not yaml", data: "services: [unclosed"},
+		{name: "no services", data: "version: '3.8'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseComposeFile("compose.yaml", []byte(tt.data))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindComposeServicesSkipsOverrides(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"docker-compose.override.yml": "services:\n  web:\n    image: nginx:debug\n  worker:\n    image: acme/worker\n",
+		"docker-compose.yml":          "services:\n  web:\n    image: nginx\n",
+		"api/compose.yaml":            "services:\n  web:\n    image: acme/api\n",
+		"compose-notes.md":            "services:\n  ignored:\n",
+	})
+
+	got, err := FindComposeServices(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var images []string
+	for _, r := range got {
+		images = append(images, r.File+" "+r.Image)
+	}
+	want := []string{"docker-compose.yml nginx", "docker-compose.override.yml acme/worker", "api/compose.yaml acme/api"}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("got %q, want %q", images, want)
+	}
+}
+
+func TestIsNamedVolume(t *testing.T) {
+	tests := map[string]bool{
+		"data:/var/lib/data":    true,
+		"data:/var/lib/data:ro": true,
+		"./data:/data":          false,
+		"/srv/data:/data":       false,
+		"~/data:/data":          false,
+		"${DATA}:/data":         false,
+		"/data":                 false, // Anonymous volume
+	}
+	for spec, want := range tests {
+		if got := isNamedVolume(yaml.Node{Kind: yaml.ScalarNode, Value: spec}); got != want {
+			t.Errorf("isNamedVolume(%q) = %v, want %v", spec, got, want)
+		}
+	}
+}
+
+func TestParseComposeMemoryMB(t *testing.T) {
+	tests := map[string]int{
+		"":        0,
+		"512m":    512,
+		"512MB":   512,
+		"1.5g":    1536,
+		"2048k":   2,
+		"1048576": 1,
+		"1gib":    0,
+		"lots":    0,
+	}
+	for size, want := range tests {
+		if got := parseComposeMemoryMB(size); got != want {
+			t.Errorf("parseComposeMemoryMB(%q) = %d, want %d", size, got, want)
+		}
+	}
+}
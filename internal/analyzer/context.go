@@ -0,0 +1,187 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/llm"
+)
+
+const (
+	// charsPerToken is a rough average for source code across tokenizers
+	charsPerToken = 4
+
+	// promptReserve is the share of the context window kept free for the
+	// pass instructions and the model's answer
+	promptReserve = 0.35
+
+	// minSourceBudget is the least room for source worth sending; a window
+	// the fact sheets leave less of is too small to analyze with
+	minSourceBudget = 1024
+
+	// maxSourceFileBytes skips generated bundles and data files
+	maxSourceFileBytes = 256 * 1024
+
+	// maxSummaryRounds bounds how often summaries are summarized again
+	maxSummaryRounds = 3
+)
+
+// sourceExtensions are the files worth showing to the model
+var sourceExtensions = map[string]bool{
+	".go": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".mjs": true,
+	".py": true, ".rb": true, ".php": true, ".java": true, ".kt": true, ".scala": true,
+	".cs": true, ".rs": true, ".sql": true, ".graphql": true, ".proto": true,
+	".yaml": true, ".yml": true, ".toml": true, ".json": true, ".xml": true, ".gradle": true,
+	".tf": true,
+}
+
+// sourceFile is a project file inlined into a prompt
+type sourceFile struct {
+	path    string
+	content string
+}
+
+// projectContext returns the repository source to inline into prompts for
+// backends that cannot read the project themselves. Projects that do not fit
+// in the context window are split into chunks, each chunk is summarized and
+// the summaries are used instead.
+func (a *Analyzer) projectContext(ctx context.Context) (string, error) {
+	windowed, ok := a.backend.(llm.ContextWindowed)
+	if !ok {
+		return "", nil
+	}
+
+	// Every pass prompt also carries the fact sheets and its schema
+	budget := int(float64(windowed.ContextWindow()*charsPerToken)*(1-promptReserve)) - len(a.sheets()) - largestSchema()
+	if budget < minSourceBudget {
+		return "", fmt.Errorf("a context window of %d tokens leaves no room for source after the fact sheets; raise llm.context_window",
+			windowed.ContextWindow())
+	}
+
+	files, err := a.collectSourceFiles(budget)
+	if err != nil {
+		return "", fmt.Errorf("failed to read project files: %v", err)
+	}
+
+	chunks := chunkSourceFiles(files, budget)
+	if len(chunks) <= 1 {
+		return strings.Join(chunks, ""), nil
+	}
+
+	// Map: summarize each chunk; reduce: summarize summaries until they fit
+	for round := 0; round < maxSummaryRounds; round++ {
+		var summaries []string
+		for i, chunk := range chunks {
+			summary, err := a.backend.Complete(ctx, fmt.Sprintf(chunkSummaryPrompt, i+1, len(chunks), chunk))
+			if err != nil {
+				return "", fmt.Errorf("failed to summarize chunk %d/%d: %v", i+1, len(chunks), err)
+			}
+			summaries = append(summaries, fmt.Sprintf("## Part %d\n%s\n\n", i+1, strings.TrimSpace(summary)))
+		}
+
+		chunks = chunkText(summaries, budget)
+		if len(chunks) == 1 {
+			return "Summaries of the codebase (too large to include in full):\n\n" + chunks[0], nil
+		}
+	}
+
+	// Still too large; keep what fits rather than overflowing the window
+	return "Summaries of the codebase (truncated):\n\n" + chunks[0], nil
+}
+
+const chunkSummaryPrompt = `You are preparing notes for a cloud cost analysis. This is part %d of %d of a codebase.
+
+Summarize it concisely, listing only facts visible in the code:
+- languages, frameworks and dependencies
+- API routes (method and path)
+- database queries, noting any inside loops
+- caches, queues and background jobs
+- file uploads and large payloads
+- memory or CPU heavy operations
+
+%s`
+
+// collectSourceFiles reads the project's source files in a stable order
+func (a *Analyzer) collectSourceFiles(budget int) ([]sourceFile, error) {
+	var files []sourceFile
+
+	err := walkProject(a.projectDir, func(path, rel string, info os.FileInfo) error {
+		if info.Size() == 0 || info.Size() > maxSourceFileBytes || !isSourceFile(info.Name()) || isHiddenPath(rel) || !a.inScope(rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			return nil // Unreadable or binary
+		}
+
+		content := string(data)
+		if len(content) > budget {
+			content = content[:budget] + "\n... (truncated)\n"
+		}
+		files = append(files, sourceFile{path: rel, content: content})
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, err
+}
+
+// isHiddenPath reports whether any element of a slash-separated path is a dotfile
+func isHiddenPath(rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSourceFile(name string) bool {
+	if strings.HasSuffix(name, ".min.js") || name == "package-lock.json" || name == "yarn.lock" {
+		return false
+	}
+	switch name {
+	case "Dockerfile", "Gemfile", "Makefile", "Procfile":
+		return true
+	}
+	return sourceExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// chunkSourceFiles renders files as markdown blocks packed into chunks of at
+// most budget characters
+func chunkSourceFiles(files []sourceFile, budget int) []string {
+	blocks := make([]string, 0, len(files))
+	for _, f := range files {
+		blocks = append(blocks, fmt.Sprintf("### %s\n```\n%s\n```\n\n", f.path, f.content))
+	}
+	return chunkText(blocks, budget)
+}
+
+// chunkText packs blocks into chunks of at most budget characters, keeping
+// each block whole unless it is larger than the budget by itself
+func chunkText(blocks []string, budget int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	for _, block := range blocks {
+		if len(block) > budget {
+			block = block[:budget]
+		}
+		if current.Len()+len(block) > budget && current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(block)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// windowedBackend is a stub that needs the project source inlined into a
+// window of the given number of tokens
+type windowedBackend struct {
+	*stubBackend
+	window int
+}
+
+func (b *windowedBackend) ContextWindow() int { return b.window }
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks []string
+		budget int
+		want   []string
+	}{
+		{"nothing", nil, 10, nil},
+		{"fits in one", []string{"abc", "def"}, 10, []string{"abcdef"}},
+		{"exactly the budget", []string{"abcde", "fghij"}, 10, []string{"abcdefghij"}},
+		{"blocks kept whole", []string{"abcd", "efgh", "ijkl"}, 10, []string{"abcdefgh", "ijkl"}},
+		{"oversized block truncated", []string{"ab", "cdefghijklmn", "op"}, 10, []string{"ab", "cdefghijkl", "op"}},
+	}
+	for _, tt := range tests {
+		if got := chunkText(tt.blocks, tt.budget); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("chunkText(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectContextBudget(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.go": strings.Repeat("// a\n", 140),
+		"b.go": strings.Repeat("// b\n", 140),
+		"c.go": strings.Repeat("// c\n", 140),
+	})
+
+	// 1000 tokens leave 2600 characters, which would hold the three files
+	// were it not for the schema and the sheets
+	backend := &windowedBackend{stubBackend: replies("summary"), window: 1000}
+	a := New(root, "proj_test", backend)
+	a.endpoints = []types.Endpoint{{Method: "GET", Path: "/orders", File: "a.go", Line: 1}}
+	budget := 2600 - len(a.sheets()) - largestSchema()
+
+	sourceContext, err := a.projectContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.prompts) != 2 {
+		t.Fatalf("summarized %d chunks, want 2", len(backend.prompts))
+	}
+	for i, prompt := range backend.prompts {
+		chunk := prompt[strings.Index(prompt, "### "):]
+		if len(chunk) > budget {
+			t.Errorf("chunk %d has %d characters, over the budget of %d", i+1, len(chunk), budget)
+		}
+	}
+	if !strings.HasPrefix(sourceContext, "Summaries of the codebase") {
+		t.Errorf("context = %q, want the summaries", sourceContext)
+	}
+
+	// A larger window holds the files as they are
+	backend = &windowedBackend{stubBackend: replies("summary"), window: 2000}
+	a = New(root, "proj_test", backend)
+	if sourceContext, err = a.projectContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.prompts) != 0 || !strings.HasPrefix(sourceContext, "### a.go\n") {
+		t.Errorf("sent %d summary prompts and context %.20q, want the source inlined", len(backend.prompts), sourceContext)
+	}
+
+	// Sheets that crowd out the source are an error, not an overflow
+	for i := 0; i < maxFactsEndpoints; i++ {
+		a.endpoints = append(a.endpoints, types.Endpoint{Method: "GET", Path: fmt.Sprintf("/orders/%d", i), File: "a.go", Line: i})
+	}
+	_, err = a.projectContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "raise llm.context_window") {
+		t.Errorf("error = %v, want the context window to be too small", err)
+	}
+}
@@ -0,0 +1,196 @@
+package analyzer
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// importLanguages maps the extensions whose imports are followed to a language
+var importLanguages = map[string]string{
+	".go":   "Go",
+	".py":   "Python",
+	".rb":   "Ruby",
+	".js":   "JavaScript",
+	".jsx":  "JavaScript",
+	".mjs":  "JavaScript",
+	".cjs":  "JavaScript",
+	".ts":   "JavaScript",
+	".tsx":  "JavaScript",
+	".java": "Java",
+	".kt":   "Java",
+}
+
+// importPatterns capture the module named by an import statement. Python's
+// second group holds the imported names, which may be submodules.
+var importPatterns = map[string][]*regexp.Regexp{
+	"Go": {
+		regexp.MustCompile(`^\s*(?:import\s+)?(?:[\w.]+\s+)?"([\w.~/-]+)"\s*$`),
+	},
+	"Python": {
+		regexp.MustCompile(`^\s*from\s+(\.*[\w.]*)\s+import\s+\(?\s*([\w, ]*)`),
+		regexp.MustCompile(`^\s*import\s+([\w.]+)`),
+	},
+	"Ruby": {
+		regexp.MustCompile(`^\s*require_relative\s*\(?\s*['"]([^'"]+)['"]`),
+	},
+	"JavaScript": {
+		regexp.MustCompile(`\bfrom\s*['"](\.[^'"]*)['"]`),
+		regexp.MustCompile(`\b(?:require|import)\s*\(\s*['"](\.[^'"]*)['"]\s*\)`),
+		regexp.MustCompile(`^\s*import\s*['"](\.[^'"]*)['"]`),
+	},
+	"Java": {
+		regexp.MustCompile(`^\s*import\s+(?:static\s+)?([\w.]+(?:\.\*)?)\s*;?\s*$`),
+	},
+}
+
+// FindDependents returns the project files that directly import one of the
+// changed files. Only relative imports and imports that name a project
+// directory or module are followed; the changed files are not included.
+func FindDependents(root string, changed []string) ([]string, error) {
+	modules := make(map[string]bool)
+	for _, file := range changed {
+		for _, key := range moduleKeys(file) {
+			modules[key] = true
+		}
+	}
+	if len(modules) == 0 {
+		return nil, nil
+	}
+
+	isChanged := make(map[string]bool, len(changed))
+	for _, file := range changed {
+		isChanged[file] = true
+	}
+
+	var dependents []string
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		language, ok := importLanguages[strings.ToLower(path.Ext(rel))]
+		if !ok || isChanged[rel] || info.Size() > maxScannedFileBytes {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+
+		if importsAny(language, rel, data, modules) {
+			dependents = append(dependents, rel)
+		}
+		return nil
+	})
+
+	sort.Strings(dependents)
+	return dependents, err
+}
+
+// moduleKeys are the extensionless paths an import of file can resolve to:
+// the file itself and, for Go and Java packages and index modules, its
+// directory
+func moduleKeys(file string) []string {
+	ext := path.Ext(file)
+	language, ok := importLanguages[strings.ToLower(ext)]
+	if !ok {
+		return nil
+	}
+
+	dir := path.Dir(file)
+	keys := []string{strings.TrimSuffix(file, ext)}
+	base := path.Base(keys[0])
+	if dir != "." && (language == "Go" || language == "Java" || base == "index" || base == "__init__") {
+		keys = append(keys, dir)
+	}
+	return keys
+}
+
+// importsAny reports whether a file imports one of the modules
+func importsAny(language, rel string, data []byte, modules map[string]bool) bool {
+	found := false
+	forEachLine(data, func(n int, line string) {
+		if found {
+			return
+		}
+		for _, pattern := range importPatterns[language] {
+			m := pattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			for _, candidate := range importCandidates(language, rel, m) {
+				if matchesModule(candidate, modules, sourceRooted[language]) {
+					found = true
+					return
+				}
+			}
+		}
+	})
+	return found
+}
+
+// importCandidates turns an import statement into project paths without
+// extension
+func importCandidates(language, rel string, m []string) []string {
+	dir := path.Dir(rel)
+	spec := m[1]
+
+	switch language {
+	case "JavaScript", "Ruby":
+		p := path.Join(dir, spec)
+		return []string{strings.TrimSuffix(p, path.Ext(p)), p}
+
+	case "Python":
+		base := strings.TrimLeft(spec, ".")
+		module := strings.ReplaceAll(base, ".", "/")
+		if dots := len(spec) - len(base); dots > 0 {
+			// Relative import: one dot is the current package
+			parent := dir
+			for i := 1; i < dots; i++ {
+				parent = path.Dir(parent)
+			}
+			module = path.Join(parent, module)
+		}
+		candidates := []string{module}
+		if len(m) > 2 {
+			for _, name := range strings.Split(m[2], ",") {
+				if fields := strings.Fields(name); len(fields) > 0 {
+					candidates = append(candidates, path.Join(module, fields[0]))
+				}
+			}
+		}
+		return candidates
+
+	case "Java":
+		return []string{strings.ReplaceAll(strings.TrimSuffix(spec, ".*"), ".", "/")}
+
+	default: // Go import paths end with the package directory
+		return []string{spec}
+	}
+}
+
+// sourceRooted are languages whose imports are relative to a source root
+// such as src/ or src/main/java/ rather than to the project root
+var sourceRooted = map[string]bool{
+	"Python": true,
+	"Java":   true,
+}
+
+// matchesModule reports whether candidate names one of the modules. Go import
+// paths end with the module; in source-rooted languages the module may also
+// end with the candidate.
+func matchesModule(candidate string, modules map[string]bool, rooted bool) bool {
+	candidate = strings.TrimPrefix(candidate, "./")
+	if candidate == "" || candidate == "." {
+		return false
+	}
+	if modules[candidate] {
+		return true
+	}
+	for module := range modules {
+		if strings.HasSuffix(candidate, "/"+module) || rooted && strings.HasSuffix(module, "/"+candidate) {
+			return true
+		}
+	}
+	return false
+}
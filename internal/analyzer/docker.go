@@ -0,0 +1,381 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// baseImage is a well-known base image, by repository name
+type baseImage struct {
+	sizeMB    int
+	smaller   string // Smaller runtime; a leading "-" is appended to the tag
+	smallerMB int
+}
+
+// baseImages are the full-OS and toolchain images worth replacing at runtime.
+// Sizes are approximate and uncompressed.
+var baseImages = map[string]baseImage{
+	"ubuntu":                       {78, "alpine", 8},
+	"debian":                       {120, "-slim", 75},
+	"centos":                       {230, "alpine", 8},
+	"fedora":                       {180, "alpine", 8},
+	"amazonlinux":                  {150, "alpine", 8},
+	"buildpack-deps":               {800, "debian:stable-slim", 75},
+	"node":                         {1100, "-slim", 240},
+	"python":                       {1000, "-slim", 130},
+	"ruby":                         {900, "-slim", 200},
+	"php":                          {500, "-alpine", 90},
+	"golang":                       {820, "gcr.io/distroless/static", 2},
+	"rust":                         {1500, "gcr.io/distroless/cc", 25},
+	"openjdk":                      {470, "eclipse-temurin:21-jre", 270},
+	"maven":                        {500, "eclipse-temurin:21-jre", 270},
+	"gradle":                       {700, "eclipse-temurin:21-jre", 270},
+	"mcr.microsoft.com/dotnet/sdk": {750, "mcr.microsoft.com/dotnet/aspnet", 220},
+}
+
+// slimVariants mark tags of images that are already minimal
+var slimVariants = []string{"slim", "alpine", "minimal", "distroless", "chiseled"}
+
+// packageManagers are the commands that install packages, with their install
+// subcommands
+var packageManagers = map[string][]string{
+	"apt-get":  {"install"},
+	"apt":      {"install"},
+	"apk":      {"add"},
+	"yum":      {"install"},
+	"dnf":      {"install"},
+	"microdnf": {"install"},
+	"pip":      {"install"},
+	"pip3":     {"install"},
+	"gem":      {"install"},
+	"npm":      {"install", "i", "ci"},
+}
+
+// buildStep matches the commands that compile an application
+var buildStep = regexp.MustCompile(`(?:^|[\s;&|])(go build|cargo build|mvn\b|\./mvnw|gradle\b|\./gradlew|npm run build|yarn (?:run )?build|pnpm (?:run )?build|tsc\b|dotnet (?:publish|build))`)
+
+// dockerArg matches a variable reference in a Dockerfile
+var dockerArg = regexp.MustCompile(`\$\{(\w+)(?::-([^}]*))?\}|\$(\w+)`)
+
+// maxImageLayers is how many RUN, COPY and ADD instructions a final stage may
+// have before merging them is recommended
+const maxImageLayers = 20
+
+// dockerStage is one FROM of a Dockerfile
+type dockerStage struct {
+	name     string
+	base     string // Resolved through earlier stages
+	line     int
+	layers   int
+	managers []string
+	packages int
+	builds   bool
+	aptLine  int  // First RUN that installs apt packages
+	aptClean bool // The package lists are removed
+	ports    []string
+}
+
+// isDockerfile reports whether a file name is a Dockerfile, such as
+// "Dockerfile", "Dockerfile.prod", "api.Dockerfile" or "Containerfile"
+func isDockerfile(name string) bool {
+	return name == "Dockerfile" || name == "Containerfile" ||
+		strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile") ||
+		strings.HasSuffix(name, ".dockerfile")
+}
+
+// FindDockerfiles reads the Dockerfiles in a project: the final stage's base
+// image, the stages, layers and package installs. Recommendations are returned
+// for full-OS base images, single-stage builds that ship their toolchain and
+// images that keep package caches or many layers.
+func FindDockerfiles(root string) ([]types.ContainerImage, []types.Recommendation, error) {
+	var images []types.ContainerImage
+	var recommendations []types.Recommendation
+
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		if !isDockerfile(path.Base(rel)) || info.Size() > maxScannedFileBytes {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		stages := parseDockerfile(data)
+		if len(stages) == 0 {
+			return nil
+		}
+
+		final := stages[len(stages)-1]
+		image := types.ContainerImage{
+			File:            rel,
+			Line:            final.line,
+			BaseImage:       final.base,
+			Stages:          len(stages),
+			Layers:          final.layers,
+			PackageManagers: final.managers,
+			Packages:        final.packages,
+			ExposedPorts:    final.ports,
+		}
+		base, known := lookupBaseImage(final.base)
+		if known {
+			image.BaseSizeMB = base.sizeMB
+		}
+		images = append(images, image)
+		recommendations = append(recommendations, imageRecommendations(image, final, base, known)...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return images, recommendations, nil
+}
+
+// parseDockerfile splits a Dockerfile into its stages
+func parseDockerfile(data []byte) []*dockerStage {
+	var stages []*dockerStage
+	named := make(map[string]*dockerStage)
+	args := make(map[string]string) // Declared before the first FROM
+
+	for _, inst := range dockerInstructions(data) {
+		fields := strings.Fields(inst.text)
+		keyword := strings.ToUpper(fields[0])
+		rest := strings.TrimSpace(inst.text[len(fields[0]):])
+
+		if keyword == "ARG" && len(stages) == 0 {
+			name, value, _ := strings.Cut(rest, "=")
+			args[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"'`)
+			continue
+		}
+		if keyword == "FROM" {
+			stage := &dockerStage{line: inst.line}
+			var words []string
+			for _, w := range fields[1:] {
+				if !strings.HasPrefix(w, "--") {
+					words = append(words, w)
+				}
+			}
+			if len(words) == 0 {
+				continue
+			}
+			stage.base = expandDockerArgs(words[0], args)
+			if prev, ok := named[strings.ToLower(stage.base)]; ok {
+				stage.base = prev.base
+			}
+			if len(words) == 3 && strings.EqualFold(words[1], "AS") {
+				stage.name = strings.ToLower(words[2])
+				named[stage.name] = stage
+			}
+			stages = append(stages, stage)
+			continue
+		}
+		if len(stages) == 0 {
+			continue
+		}
+
+		stage := stages[len(stages)-1]
+		switch keyword {
+		case "COPY", "ADD":
+			stage.layers++
+		case "EXPOSE":
+			stage.ports = append(stage.ports, strings.Fields(rest)...)
+		case "RUN":
+			stage.layers++
+			if buildStep.MatchString(rest) {
+				stage.builds = true
+			}
+			if strings.Contains(rest, "/var/lib/apt/lists") {
+				stage.aptClean = true
+			}
+			for _, cmd := range shellCommands(rest) {
+				manager, packages, ok := packageInstall(cmd)
+				if !ok {
+					continue
+				}
+				stage.managers = mergeNames(stage.managers, []string{manager})
+				stage.packages += packages
+				if (manager == "apt-get" || manager == "apt") && stage.aptLine == 0 {
+					stage.aptLine = inst.line
+				}
+			}
+		}
+	}
+	return stages
+}
+
+// dockerInstruction is one instruction with its continuation lines joined
+type dockerInstruction struct {
+	text string
+	line int
+}
+
+// dockerInstructions joins continued lines and drops comments
+func dockerInstructions(data []byte) []dockerInstruction {
+	var instructions []dockerInstruction
+	var current strings.Builder
+	start := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxScannedFileBytes)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue // Comments may also sit between continuation lines
+		}
+		if current.Len() == 0 {
+			start = n
+		}
+		if strings.HasSuffix(line, `\`) {
+			current.WriteString(strings.TrimSuffix(line, `\`) + " ")
+			continue
+		}
+		current.WriteString(line)
+		instructions = append(instructions, dockerInstruction{current.String(), start})
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		instructions = append(instructions, dockerInstruction{current.String(), start})
+	}
+	return instructions
+}
+
+// expandDockerArgs substitutes the global build arguments in a FROM image
+func expandDockerArgs(s string, args map[string]string) string {
+	return dockerArg.ReplaceAllStringFunc(s, func(ref string) string {
+		m := dockerArg.FindStringSubmatch(ref)
+		name := m[1] + m[3]
+		if value := args[name]; value != "" {
+			return value
+		}
+		return m[2]
+	})
+}
+
+// shellCommands splits a shell command line on its operators
+func shellCommands(line string) []string {
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return r == ';' || r == '&' || r == '|'
+	})
+}
+
+// packageInstall recognizes a package manager's install command, counting
+// the packages it names
+func packageInstall(cmd string) (manager string, packages int, ok bool) {
+	fields := strings.Fields(cmd)
+	for i, f := range fields {
+		verbs, known := packageManagers[path.Base(f)]
+		if !known {
+			continue
+		}
+		rest := fields[i+1:]
+		for len(rest) > 0 && strings.HasPrefix(rest[0], "-") {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 || !containsString(verbs, rest[0]) {
+			return "", 0, false
+		}
+		skip := false
+		for _, arg := range rest[1:] {
+			switch {
+			case skip:
+				skip = false
+			case arg == "-r" || arg == "-c" || arg == "--requirement":
+				skip = true // Names a file, not a package
+			case !strings.HasPrefix(arg, "-"):
+				packages++
+			}
+		}
+		return path.Base(f), packages, true
+	}
+	return "", 0, false
+}
+
+// lookupBaseImage finds an image in baseImages unless its tag names a minimal
+// variant
+func lookupBaseImage(ref string) (baseImage, bool) {
+	repo, tag := splitImage(ref)
+	for _, variant := range slimVariants {
+		if strings.Contains(tag, variant) || strings.Contains(repo, variant) {
+			return baseImage{}, false
+		}
+	}
+	if base, ok := baseImages[repo]; ok {
+		return base, true
+	}
+	base, ok := baseImages[path.Base(repo)]
+	return base, ok
+}
+
+// smallerImage names the smaller runtime suggested for a base image
+func smallerImage(ref string, base baseImage) string {
+	if !strings.HasPrefix(base.smaller, "-") {
+		return base.smaller
+	}
+	repo, tag := splitImage(ref)
+	if tag == "" || tag == "latest" {
+		return repo + ":" + base.smaller[1:]
+	}
+	return repo + ":" + tag + base.smaller
+}
+
+// splitImage splits an image reference into its repository and tag,
+// dropping any digest and the Docker Hub "library/" namespace
+func splitImage(ref string) (repo, tag string) {
+	ref, _, _ = strings.Cut(strings.ToLower(ref), "@")
+	repo = ref
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo, tag = ref[:i], ref[i+1:]
+	}
+	repo = strings.TrimPrefix(strings.TrimPrefix(repo, "docker.io/"), "library/")
+	return repo, tag
+}
+
+// imageRecommendations flags what makes an image larger than it needs to be.
+// Every pull moves the extra size, adding to registry egress and to the time
+// a new instance takes to start.
+func imageRecommendations(image types.ContainerImage, final *dockerStage, base baseImage, known bool) []types.Recommendation {
+	var recommendations []types.Recommendation
+	add := func(description, current, suggested string, line int) {
+		recommendations = append(recommendations, types.Recommendation{
+			Type:        "image",
+			Description: description,
+			Resource:    image.File,
+			Current:     current,
+			Suggested:   suggested,
+			File:        image.File,
+			Line:        line,
+		})
+	}
+
+	current := image.BaseImage
+	if known {
+		current = fmt.Sprintf("%s (~%d MB)", image.BaseImage, base.sizeMB)
+	}
+	switch {
+	case image.Stages == 1 && final.builds:
+		runtime := "a slim runtime image"
+		if known {
+			runtime = smallerImage(image.BaseImage, base)
+		}
+		add(fmt.Sprintf("%s builds in its only stage, so the toolchain and build cache ship in the image", image.File),
+			current, fmt.Sprintf("multi-stage build copying the output onto %s", runtime), image.Line)
+	case known:
+		add(fmt.Sprintf("%s runs on %s, a full OS image of ~%d MB, which slows every pull and cold start", image.File, image.BaseImage, base.sizeMB),
+			current, fmt.Sprintf("%s (~%d MB)", smallerImage(image.BaseImage, base), base.smallerMB), image.Line)
+	}
+
+	if final.aptLine > 0 && !final.aptClean {
+		add(fmt.Sprintf("%s installs apt packages but keeps the package lists in the layer", image.File),
+			"", "rm -rf /var/lib/apt/lists/* in the same RUN", final.aptLine)
+	}
+	if final.layers > maxImageLayers {
+		add(fmt.Sprintf("%s has %d RUN, COPY and ADD layers in its final stage", image.File, final.layers),
+			fmt.Sprintf("%d layers", final.layers), "merge RUN instructions and copy in fewer steps", image.Line)
+	}
+	return recommendations
+}
@@ -0,0 +1,146 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func TestFindDockerfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		dockerfile  string
+		image       types.ContainerImage
+		recommended []string // Suggested changes, in order
+	}{
+		{
+			name: "single stage build on a toolchain image",
+			dockerfile: `FROM golang:1.22
+WORKDIR /src
+COPY . .
+RUN go build -o /app ./cmd/api
+EXPOSE 8080 9090
+`,
+			image:       types.ContainerImage{Line: 1, BaseImage: "golang:1.22", BaseSizeMB: 820, Stages: 1, Layers: 2, ExposedPorts: []string{"8080", "9090"}},
+			recommended: []string{"multi-stage build copying the output onto gcr.io/distroless/static"},
+		},
+		{
+			name: "multi-stage build onto a full OS image with apt lists kept",
+			dockerfile: `ARG NODE_VERSION=20
+FROM node:${NODE_VERSION} AS build
+RUN npm ci && npm run build
+
+# Runtime
+FROM debian:bookworm
+RUN apt-get update && \
+    # Comments may sit between continuation lines
+    apt-get install -y --no-install-recommends ca-certificates curl
+COPY --from=build /app /app
+`,
+			image:       types.ContainerImage{Line: 6, BaseImage: "debian:bookworm", BaseSizeMB: 120, Stages: 2, Layers: 2, PackageManagers: []string{"apt-get"}, Packages: 2},
+			recommended: []string{"debian:bookworm-slim (~75 MB)", "rm -rf /var/lib/apt/lists/* in the same RUN"},
+		},
+		{
+			name: "final stage built from an earlier one",
+			dockerfile: `FROM python:3.12 AS base
+RUN pip install -r requirements.txt gunicorn
+
+FROM base
+RUN pip install --no-cache-dir uvicorn
+`,
+			image:       types.ContainerImage{Line: 4, BaseImage: "python:3.12", BaseSizeMB: 1000, Stages: 2, Layers: 1, PackageManagers: []string{"pip"}, Packages: 1},
+			recommended: []string{"python:3.12-slim (~130 MB)"},
+		},
+		{
+			name: "minimal image",
+			dockerfile: `FROM --platform=linux/amd64 docker.io/library/node:20-alpine
+RUN apk add --no-cache tini
+COPY dist /app
+`,
+			image: types.ContainerImage{Line: 1, BaseImage: "docker.io/library/node:20-alpine", Stages: 1, Layers: 2, PackageManagers: []string{"apk"}, Packages: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{"Dockerfile": tt.dockerfile})
+
+			images, recommendations, err := FindDockerfiles(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.image.File = "Dockerfile"
+			if len(images) != 1 || !reflect.DeepEqual(images[0], tt.image) {
+				t.Errorf("images = %+v, want %+v", images, tt.image)
+			}
+
+			var suggested []string
+			for _, r := range recommendations {
+				suggested = append(suggested, r.Suggested)
+			}
+			if !reflect.DeepEqual(suggested, tt.recommended) {
+				t.Errorf("recommended %q, want %q", suggested, tt.recommended)
+			}
+		})
+	}
+}
+
+func TestIsDockerfile(t *testing.T) {
+	tests := map[string]bool{
+		"Dockerfile":         true,
+		"Dockerfile.prod":    true,
+		"api.Dockerfile":     true,
+		"api.dockerfile":     true,
+		"Containerfile":      true,
+		"dockerfile":         false,
+		"Dockerfile-notes":   false,
+		".dockerignore":      false,
+		"docker-compose.yml": false,
+	}
+	for name, want := range tests {
+		if got := isDockerfile(name); got != want {
+			t.Errorf("isDockerfile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPackageInstall(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		manager  string
+		packages int
+		ok       bool
+	}{
+		{"apt-get install -y curl git", "apt-get", 2, true},
+		{"apt-get -qq install curl", "apt-get", 1, true},
+		{"apt-get update", "", 0, false},
+		{"/usr/local/bin/pip3 install -r requirements.txt flask", "pip3", 1, true},
+		{"npm ci", "npm", 0, true},
+		{"echo apt-get install curl", "apt-get", 1, true},
+		{"make install", "", 0, false},
+	}
+	for _, tt := range tests {
+		manager, packages, ok := packageInstall(tt.cmd)
+		if manager != tt.manager || packages != tt.packages || ok != tt.ok {
+			t.Errorf("packageInstall(%q) = %q, %d, %v; want %q, %d, %v", tt.cmd, manager, packages, ok, tt.manager, tt.packages, tt.ok)
+		}
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		ref, repo, tag string
+	}{
+		{"node", "node", ""},
+		{"node:20-slim", "node", "20-slim"},
+		{"docker.io/library/Python:3.12", "python", "3.12"},
+		{"localhost:5000/acme/api", "localhost:5000/acme/api", ""},
+		{"ghcr.io/acme/api:1.0@sha256:abc", "ghcr.io/acme/api", "1.0"},
+	}
+	for _, tt := range tests {
+		if repo, tag := splitImage(tt.ref); repo != tt.repo || tag != tt.tag {
+			t.Errorf("splitImage(%q) = %q, %q; want %q, %q", tt.ref, repo, tag, tt.repo, tt.tag)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/scanner"
+	"github.com/hashicorp/hcl/hcl/token"
+)
+
+// hclBlock is a block of an HCL file, such as `resource "aws_instance" "api" { ... }`.
+// The file itself is a block without a type.
+type hclBlock struct {
+	Type   string
+	Labels []string
+	Line   int
+	Attrs  map[string]hclValue
+	Blocks []*hclBlock
+}
+
+// hclValue is an attribute value. Only literals and plain references are
+// understood; other expressions are kept as neither.
+type hclValue struct {
+	Literal string   // String, number or bool, unquoted
+	Ref     string   // A reference such as "var.instance_type"
+	List    []string // The literals of a list
+	IsSet   bool     // Literal holds a value, possibly ""
+	Line    int
+}
+
+// parseHCL reads the block structure of a Terraform file. The HCL v1 parser
+// rejects Terraform 0.12+ expressions, so this works on the scanner's tokens
+// and skips any expression it does not understand.
+func parseHCL(data []byte) *hclBlock {
+	s := scanner.New(data)
+	s.Error = func(token.Pos, string) {} // Expressions HCL v1 cannot lex
+
+	var tokens []token.Token
+	for {
+		tok := s.Scan()
+		if tok.Type == token.EOF {
+			break
+		}
+		if tok.Type != token.COMMENT {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	p := &hclParser{tokens: tokens}
+	root := &hclBlock{Line: 1, Attrs: make(map[string]hclValue)}
+	p.parseBody(root)
+	return root
+}
+
+// hclParser walks the tokens of one file
+type hclParser struct {
+	tokens []token.Token
+	pos    int
+}
+
+func (p *hclParser) peek() (token.Token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token.Token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseBody reads attributes and nested blocks until the closing brace
+func (p *hclParser) parseBody(block *hclBlock) {
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			return
+		}
+		if tok.Type == token.RBRACE {
+			p.pos++
+			return
+		}
+		if tok.Type != token.IDENT && tok.Type != token.STRING {
+			p.pos++ // Stray token of an expression we could not follow
+			continue
+		}
+
+		// A key, then labels, then "=" for an attribute or "{" for a block
+		name := unquote(tok)
+		p.pos++
+		var labels []string
+		for {
+			next, ok := p.peek()
+			if !ok {
+				return
+			}
+			switch next.Type {
+			case token.STRING, token.IDENT:
+				labels = append(labels, unquote(next))
+				p.pos++
+				continue
+			case token.ASSIGN:
+				p.pos++
+				block.Attrs[name] = p.parseValue()
+			case token.LBRACE:
+				p.pos++
+				child := &hclBlock{Type: name, Labels: labels, Line: tok.Pos.Line, Attrs: make(map[string]hclValue)}
+				p.parseBody(child)
+				block.Blocks = append(block.Blocks, child)
+			}
+			break
+		}
+	}
+}
+
+// parseValue reads an expression: everything up to the end of its last line,
+// following brackets, braces and parentheses across lines
+func (p *hclParser) parseValue() hclValue {
+	start := p.pos
+	depth := 0
+	lastLine := 0
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			break
+		}
+		if p.pos > start && depth == 0 && tok.Pos.Line > lastLine && !continuesExpression(p.tokens[p.pos-1]) {
+			break
+		}
+		if depth == 0 && tok.Type == token.RBRACE {
+			break // The enclosing block ends
+		}
+
+		switch {
+		case tok.Type == token.LBRACE || tok.Type == token.LBRACK || tok.Type == token.ILLEGAL && tok.Text == "(":
+			depth++
+		case tok.Type == token.RBRACE || tok.Type == token.RBRACK || tok.Type == token.ILLEGAL && tok.Text == ")":
+			depth--
+		}
+		// A heredoc's text ends with the newline after its closing marker
+		lastLine = tok.Pos.Line + strings.Count(strings.TrimSuffix(tok.Text, "\n"), "\n")
+		p.pos++
+	}
+
+	expr := p.tokens[start:p.pos]
+	value := hclValue{}
+	if len(expr) == 0 {
+		return value
+	}
+	value.Line = expr[0].Pos.Line
+
+	if len(expr) == 1 {
+		switch expr[0].Type {
+		case token.STRING, token.HEREDOC, token.NUMBER, token.FLOAT, token.BOOL:
+			text := unquote(expr[0])
+			// "${var.x}" is the pre-0.12 spelling of a reference
+			if ref := strings.TrimSuffix(strings.TrimPrefix(text, "${"), "}"); ref != text && !strings.ContainsAny(ref, "${} ") {
+				value.Ref = ref
+			} else {
+				value.Literal, value.IsSet = text, true
+			}
+		case token.IDENT:
+			value.Ref = expr[0].Text
+		}
+		return value
+	}
+
+	// A list of literals, as in `instance_types = ["m5.large"]`
+	if expr[0].Type == token.LBRACK {
+		for _, tok := range expr[1:] {
+			switch tok.Type {
+			case token.STRING, token.NUMBER, token.FLOAT, token.BOOL:
+				value.List = append(value.List, unquote(tok))
+			case token.COMMA, token.RBRACK:
+			default:
+				return hclValue{Line: value.Line}
+			}
+		}
+	}
+	return value
+}
+
+// continuesExpression reports whether an expression goes on past a line
+// ending with tok, as after an operator
+func continuesExpression(tok token.Token) bool {
+	switch tok.Type {
+	case token.ADD, token.SUB, token.PERIOD, token.COMMA, token.ASSIGN:
+		return true
+	case token.ILLEGAL:
+		return tok.Text != ")"
+	}
+	return false
+}
+
+// unquote returns the text of a string or heredoc token without quotes
+func unquote(tok token.Token) string {
+	switch tok.Type {
+	case token.STRING:
+		if len(tok.Text) >= 2 && strings.HasPrefix(tok.Text, `"`) && strings.HasSuffix(tok.Text, `"`) {
+			return tok.Text[1 : len(tok.Text)-1]
+		}
+	case token.HEREDOC:
+		if v, ok := safeValue(tok).(string); ok {
+			return v
+		}
+	}
+	return tok.Text
+}
+
+// safeValue decodes a token, which panics on malformed input
+func safeValue(tok token.Token) (v interface{}) {
+	defer func() {
+		if recover() != nil {
+			v = nil
+		}
+	}()
+	return tok.Value()
+}
+
+// attr finds an attribute by a dotted path through nested blocks, such as
+// "scaling_config.min_size". Of repeated blocks the first is used.
+func (b *hclBlock) attr(path string) (hclValue, bool) {
+	parts := strings.Split(path, ".")
+	block := b
+	for _, part := range parts[:len(parts)-1] {
+		var next *hclBlock
+		for _, child := range block.Blocks {
+			if child.Type == part {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return hclValue{}, false
+		}
+		block = next
+	}
+	v, ok := block.Attrs[parts[len(parts)-1]]
+	return v, ok
+}
+
+// blocks returns the nested blocks of a type
+func (b *hclBlock) blocks(blockType string) []*hclBlock {
+	var blocks []*hclBlock
+	for _, child := range b.Blocks {
+		if child.Type == blockType {
+			blocks = append(blocks, child)
+		}
+	}
+	return blocks
+}
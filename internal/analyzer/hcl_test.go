@@ -0,0 +1,128 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHCLValues(t *testing.T) {
+	src := `# Web tier
+instance_type = "t3.large"  // Inline comment
+count         = 3
+ratio         = 0.5
+enabled       = true
+empty         = ""
+ami           = var.ami
+legacy        = "${var.size}"
+interpolated  = "${var.env}-api"
+types         = ["m5.large", "m5.xlarge"]
+mixed         = ["m5.large", var.type]
+size          = var.base + 10
+tags = merge(local.tags, {
+  Name = "api"
+})
+user_data = <<EOF
+#!/bin/sh
+echo hi
+EOF
+after = "still read"
+`
+	tests := []struct {
+		attr string
+		want hclValue
+	}{
+		{"instance_type", hclValue{Literal: "t3.large", IsSet: true, Line: 2}},
+		{"count", hclValue{Literal: "3", IsSet: true, Line: 3}},
+		{"ratio", hclValue{Literal: "0.5", IsSet: true, Line: 4}},
+		{"enabled", hclValue{Literal: "true", IsSet: true, Line: 5}},
+		{"empty", hclValue{Literal: "", IsSet: true, Line: 6}},
+		{"ami", hclValue{Ref: "var.ami", Line: 7}},
+		{"legacy", hclValue{Ref: "var.size", Line: 8}},
+		{"interpolated", hclValue{Literal: "${var.env}-api", IsSet: true, Line: 9}},
+		{"types", hclValue{List: []string{"m5.large", "m5.xlarge"}, Line: 10}},
+		{"mixed", hclValue{Line: 11}},
+		{"size", hclValue{Line: 12}},
+		{"tags", hclValue{Line: 13}},
+		{"user_data", hclValue{Literal: "#!/bin/sh\necho hi\n", IsSet: true, Line: 16}},
+		{"after", hclValue{Literal: "still read", IsSet: true, Line: 20}},
+	}
+
+	file := parseHCL([]byte(src))
+	for _, tt := range tests {
+		t.Run(tt.attr, func(t *testing.T) {
+			got, ok := file.Attrs[tt.attr]
+			if !ok {
+				t.Fatalf("attribute %s not parsed; have %v", tt.attr, file.Attrs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %+v, want %+v", tt.attr, got, tt.want)
+			}
+		})
+	}
+	if len(file.Attrs) != len(tests) {
+		t.Errorf("parsed %d attributes, want %d: %v", len(file.Attrs), len(tests), file.Attrs)
+	}
+}
+
+func TestParseHCLBlocks(t *testing.T) {
+	src := `provider "aws" {
+  region = "eu-west-1"
+}
+
+resource "aws_eks_node_group" "workers" {
+  for_each = { for k, v in var.pools : k => v if v.enabled }
+
+  scaling_config {
+    min_size = 2
+    max_size = 10
+  }
+  scaling_config {
+    min_size = 99
+  }
+  lifecycle {
+    ignore_changes = [scaling_config[0].desired_size]
+  }
+}
+
+locals {
+  env = "prod"
+}
+`
+	file := parseHCL([]byte(src))
+
+	type block struct {
+		Type   string
+		Labels []string
+		Line   int
+	}
+	var got []block
+	for _, b := range file.Blocks {
+		got = append(got, block{b.Type, b.Labels, b.Line})
+	}
+	want := []block{
+		{"provider", []string{"aws"}, 1},
+		{"resource", []string{"aws_eks_node_group", "workers"}, 5},
+		{"locals", nil, 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("blocks = %+v, want %+v", got, want)
+	}
+
+	group := file.Blocks[1]
+	if n := len(group.blocks("scaling_config")); n != 2 {
+		t.Errorf("%d scaling_config blocks, want 2", n)
+	}
+	// Of repeated blocks the first is used
+	if v, ok := group.attr("scaling_config.min_size"); !ok || v.Literal != "2" {
+		t.Errorf("scaling_config.min_size = %+v, %v; want 2", v, ok)
+	}
+	if v, ok := group.attr("scaling_config.max_size"); !ok || v.Literal != "10" {
+		t.Errorf("scaling_config.max_size = %+v, %v; want 10", v, ok)
+	}
+	if _, ok := group.attr("launch_template.name"); ok {
+		t.Error("found an attribute of a missing block")
+	}
+	if v := file.Blocks[2].Attrs["env"]; v.Literal != "prod" {
+		t.Errorf("locals.env = %+v, want prod", v)
+	}
+}
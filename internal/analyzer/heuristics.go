@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// Heuristic parsing functions
+func (a *Analyzer) parseBasicStructureHeuristic(output string, analysis *types.CodeAnalysis) {
+	lower := strings.ToLower(output)
+
+	// Language detection
+	languages := []string{"javascript", "python", "go", "java", "php", "ruby", "typescript"}
+	for _, lang := range languages {
+		if strings.Contains(lower, lang) {
+			analysis.Language = strings.Title(lang)
+			analysis.SetProvenance("language", types.SourceLLMRegex, "")
+			break
+		}
+	}
+
+	// Framework detection
+	frameworks := []string{"react", "vue", "angular", "express", "fastapi", "django", "gin", "echo"}
+	for _, framework := range frameworks {
+		if strings.Contains(lower, framework) {
+			analysis.Framework = strings.Title(framework)
+			analysis.SetProvenance("framework", types.SourceLLMRegex, "")
+			break
+		}
+	}
+
+	// Endpoint counting
+	analysis.ApiEndpoints = a.extractNumber(output, `(\d+).*(?:endpoint|route|api)`)
+	if analysis.ApiEndpoints == 0 {
+		analysis.ApiEndpoints = 5 // Default estimate
+		analysis.SetProvenance("api_endpoints", types.SourceDefault, "no endpoint count found in model output")
+	} else {
+		analysis.SetProvenance("api_endpoints", types.SourceLLMRegex, "first number near \"endpoint\" in model output")
+	}
+}
+
+func (a *Analyzer) extractNumber(text, pattern string) int {
+	re := regexp.MustCompile(`(?i)` + pattern)
+	matches := re.FindStringSubmatch(text)
+	if len(matches) > 1 {
+		if num, err := strconv.Atoi(matches[1]); err == nil {
+			return num
+		}
+	}
+	return 0
+}
+
+func (a *Analyzer) extractFloat(text, pattern string) float64 {
+	re := regexp.MustCompile(`(?i)` + pattern)
+	matches := re.FindStringSubmatch(text)
+	if len(matches) > 1 {
+		if num, err := strconv.ParseFloat(matches[1], 64); err == nil {
+			return num
+		}
+	}
+	return 0.0
+}
+
+// extractComplexity returns the complexity score and whether one was found
+func (a *Analyzer) extractComplexity(text string) (int, bool) {
+	// Look for complexity scores
+	complexity := a.extractNumber(text, `(?:complexity|score).*?(\d+)`)
+	if complexity == 0 {
+		complexity = a.extractNumber(text, `(\d+).*(?:complexity|score)`)
+	}
+	if complexity == 0 || complexity > 100 {
+		return 50, false // Default medium complexity
+	}
+	return complexity, true
+}
+
+func (a *Analyzer) extractCacheUsage(text string) []string {
+	lower := strings.ToLower(text)
+	var caches []string
+
+	cacheTypes := []string{"redis", "memcached", "memory cache", "cdn", "browser cache"}
+	for _, cache := range cacheTypes {
+		if strings.Contains(lower, cache) {
+			caches = append(caches, cache)
+		}
+	}
+
+	return caches
+}
+
+func (a *Analyzer) extractBottlenecks(text string) []types.Bottleneck {
+	var bottlenecks []types.Bottleneck
+
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		lower := strings.ToLower(strings.TrimSpace(line))
+		if lower == "" {
+			continue
+		}
+
+		// Look for bottleneck indicators
+		if strings.Contains(lower, "database") && (strings.Contains(lower, "slow") ||
+			strings.Contains(lower, "bottleneck") || strings.Contains(lower, "limit")) {
+			bottlenecks = append(bottlenecks, types.Bottleneck{
+				Type:        "database",
+				Description: line,
+				Severity:    a.extractSeverity(line),
+				Impact:      "May cause slow response times under load",
+			})
+		}
+
+		if strings.Contains(lower, "memory") && strings.Contains(lower, "leak") {
+			bottlenecks = append(bottlenecks, types.Bottleneck{
+				Type:        "memory",
+				Description: line,
+				Severity:    "high",
+				Impact:      "Could cause application crashes",
+			})
+		}
+	}
+
+	return bottlenecks
+}
+
+func (a *Analyzer) extractSeverity(text string) string {
+	lower := strings.ToLower(text)
+	if strings.Contains(lower, "critical") {
+		return "critical"
+	}
+	if strings.Contains(lower, "high") {
+		return "high"
+	}
+	if strings.Contains(lower, "medium") {
+		return "medium"
+	}
+	return "low"
+}
+
+func (a *Analyzer) estimateMemoryFromComplexity(complexity int) int {
+	// Estimate memory based on complexity (MB)
+	switch {
+	case complexity >= 80:
+		return 2048 // 2GB for very complex apps
+	case complexity >= 60:
+		return 1024 // 1GB for complex apps
+	case complexity >= 40:
+		return 512 // 512MB for medium apps
+	default:
+		return 256 // 256MB for simple apps
+	}
+}
+
+func (a *Analyzer) estimateCPUFromEndpoints(endpoints int) float64 {
+	// Estimate CPU cores based on endpoints
+	switch {
+	case endpoints >= 50:
+		return 4.0
+	case endpoints >= 20:
+		return 2.0
+	case endpoints >= 10:
+		return 1.0
+	default:
+		return 0.5
+	}
+}
@@ -0,0 +1,220 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/git"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// maxScopeFiles bounds the file list given to backends that read the project
+// themselves
+const maxScopeFiles = 200
+
+// SetIncremental limits the LLM passes to the files changed since the git ref
+// since, plus the files importing them, and merges their findings into
+// baseline. The static scan still covers the whole project.
+func (a *Analyzer) SetIncremental(since string, baseline *types.CodeAnalysis) {
+	a.since = since
+	a.baseline = baseline
+}
+
+// findScope lists the changed files and their dependents
+func (a *Analyzer) findScope() error {
+	changed, err := git.ChangedFiles(a.projectDir, a.since)
+	if err != nil {
+		return fmt.Errorf("failed to list files changed since %s: %v", a.since, err)
+	}
+
+	dependents, err := FindDependents(a.projectDir, changed)
+	if err != nil {
+		return fmt.Errorf("failed to find dependents of changed files: %v", err)
+	}
+
+	a.incremental = &types.IncrementalInfo{
+		Since:        a.since,
+		ChangedFiles: changed,
+		Dependents:   dependents,
+	}
+	if a.baseline.Git != nil {
+		a.incremental.BaselineCommit = a.baseline.Git.Commit
+	}
+
+	a.scope = make(map[string]bool, len(changed)+len(dependents))
+	for _, file := range append(changed, dependents...) {
+		a.scope[file] = true
+	}
+
+	fmt.Printf("🔁 Incremental: %d files changed since %s, %d dependents\n", len(changed), a.since, len(dependents))
+	return nil
+}
+
+// inScope reports whether a file is part of an incremental analysis. Every
+// file is in scope of a full analysis.
+func (a *Analyzer) inScope(rel string) bool {
+	return a.scope == nil || a.scope[rel]
+}
+
+// scopeSheet tells backends that read the project themselves which files to
+// look at
+func (a *Analyzer) scopeSheet() string {
+	if a.incremental == nil {
+		return ""
+	}
+
+	files := append(append([]string(nil), a.incremental.ChangedFiles...), a.incremental.Dependents...)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Incremental analysis: the rest of the project was analyzed before. Only report findings in these %d files, changed since %s or importing a changed file:\n", len(files), a.since)
+	for i, file := range files {
+		if i == maxScopeFiles {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(files)-i)
+			break
+		}
+		fmt.Fprintf(&b, "- %s\n", file)
+	}
+	return b.String()
+}
+
+// runIncremental re-runs the passes whose findings are tied to files on the
+// files in scope and carries the project-wide estimates over from the baseline
+func (a *Analyzer) runIncremental(ctx context.Context) (*types.CodeAnalysis, error) {
+	analysis := a.fromBaseline()
+	a.applyStaticFacts(analysis)
+
+	if a.backend == nil || len(a.scope) == 0 {
+		return analysis, nil
+	}
+
+	sourceContext, err := a.projectContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.sourceContext = sourceContext
+
+	fmt.Print("🔍 Analyzing changed files")
+	changes := a.newAnalysis()
+
+	if err := a.runConcurrently(ctx, changes, databaseAPIPass, performancePass); err != nil {
+		return nil, err
+	}
+
+	fmt.Println(" ✅")
+
+	a.mergeChanges(analysis, changes)
+	return analysis, nil
+}
+
+// fromBaseline starts an incremental result from a copy of the baseline. The
+// values derived from it are cleared, to be computed again.
+func (a *Analyzer) fromBaseline() *types.CodeAnalysis {
+	fresh := a.newAnalysis()
+	analysis := *a.baseline
+
+	analysis.ProjectID = fresh.ProjectID
+	analysis.Timestamp = fresh.Timestamp
+	analysis.Directory = fresh.Directory
+	analysis.Git = fresh.Git
+	analysis.SchemaVersion = fresh.SchemaVersion
+	analysis.Incremental = a.incremental
+	analysis.QuerySites = nil
+	analysis.CostEstimates = nil
+	analysis.Projections = nil
+	analysis.ScalingLimits = nil
+	analysis.Infrastructure = nil
+	analysis.Recommendations = nil
+	analysis.Containers = nil
+	analysis.Serverless = nil
+	analysis.Datastores = nil
+
+	analysis.Provenance = make(map[string]types.FieldProvenance, len(a.baseline.Provenance))
+	for field, p := range a.baseline.Provenance {
+		analysis.Provenance[field] = p
+	}
+
+	// Static findings are replaced by this run's scan, which may find none
+	if p := analysis.Provenance["api_endpoints"]; p.Source == types.SourceStatic {
+		analysis.Endpoints = nil
+		analysis.ApiEndpoints = 0
+	}
+	if p := analysis.Provenance["database_calls"]; p.Source == types.SourceStatic {
+		analysis.DatabaseCalls = 0
+	}
+	if p := analysis.Provenance["cache_usage"]; p.Source == types.SourceStatic {
+		analysis.CacheUsage = nil
+	}
+	if p := analysis.Provenance["performance.has_n_plus_one_query"]; p.Source == types.SourceStatic {
+		analysis.Performance.HasNPlusOneQuery = false
+	}
+
+	// Findings in changed files are replaced by the new passes, and the
+	// static N+1 candidates are found again for the whole project
+	analysis.ScalingBottlenecks = nil
+	for _, b := range a.baseline.ScalingBottlenecks {
+		if b.StaticPrefix() != "" || b.File != "" && a.scope[b.File] {
+			continue
+		}
+		b.BindingAtUsers = 0
+		analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, b)
+	}
+	analysis.SecurityIssues = nil
+	for _, issue := range a.baseline.SecurityIssues {
+		if issue.File == "" || !a.scope[issue.File] {
+			analysis.SecurityIssues = append(analysis.SecurityIssues, issue)
+		}
+	}
+
+	return &analysis
+}
+
+// mergeChanges adds what the passes found in the changed files. Estimates
+// that describe the whole project, like complexity and resources, stay those
+// of the baseline.
+func (a *Analyzer) mergeChanges(analysis, changes *types.CodeAnalysis) {
+	// Model findings without a file can't be tied to the changed files, so
+	// the performance pass that just ran replaces them
+	kept := analysis.ScalingBottlenecks[:0]
+	seen := make(map[string]bool)
+	for _, b := range analysis.ScalingBottlenecks {
+		if b.File == "" && b.StaticPrefix() == "" {
+			continue
+		}
+		kept = append(kept, b)
+		seen[b.Key()] = true
+	}
+	analysis.ScalingBottlenecks = kept
+	for _, b := range changes.ScalingBottlenecks {
+		if key := b.Key(); !seen[key] {
+			seen[key] = true
+			analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, b)
+		}
+	}
+	analysis.CacheUsage = mergeNames(analysis.CacheUsage, changes.CacheUsage)
+	analysis.Performance.HasLargePayloads = analysis.Performance.HasLargePayloads || changes.Performance.HasLargePayloads
+
+	note := "incremental since " + a.since
+	for _, field := range []string{"scaling_bottlenecks", "cache_usage"} {
+		if p, ok := changes.Provenance[field]; ok {
+			analysis.SetProvenance(field, p.Source, note)
+		}
+	}
+
+	for _, pass := range changes.HeuristicPasses {
+		markHeuristic(analysis, pass+" (incremental)")
+	}
+}
+
+// mergeNames returns the union of two name lists, keeping the order of first
+// appearance
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var merged []string
+	for _, name := range append(append([]string(nil), a...), b...) {
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func newIncremental(t *testing.T, baseline *types.CodeAnalysis, scope ...string) *Analyzer {
+	t.Helper()
+	a := New(t.TempDir(), "proj_test", nil)
+	a.SetIncremental("main", baseline)
+	a.scope = make(map[string]bool)
+	for _, file := range scope {
+		a.scope[file] = true
+	}
+	return a
+}
+
+func TestFromBaselineDropsStaticBottlenecks(t *testing.T) {
+	baseline := &types.CodeAnalysis{ScalingBottlenecks: []types.Bottleneck{
+		{Type: "database", File: "users.go", Description: types.NPlusOnePrefix + " GORM First inside a loop"},
+		{Type: "memory", File: "main.tf", Description: types.TerraformPrefix + " compute declares 2.0 GB"},
+		{Type: "cpu", File: "k8s/api.yaml", Description: types.KubernetesPrefix + " workloads request 0.10 cores"},
+		{Type: "memory", File: "compose.yaml", Description: types.ComposePrefix + " services are limited to 512 MB"},
+		{Type: "network", File: "Dockerfile", Description: types.DockerPrefix + " image pulls 1.1 GB"},
+		{Type: "cpu", File: "serverless.yml", Description: types.ServerlessPrefix + " thumbnail times out"},
+		{Type: "database", File: "changed.go", Description: "Model finding in a changed file"},
+		{Type: "cpu", File: "stable.go", Description: "Model finding elsewhere", BindingAtUsers: 1000},
+		{Type: "network", Description: "Model finding without a file"},
+	}}
+
+	got := newIncremental(t, baseline, "changed.go").fromBaseline().ScalingBottlenecks
+	if len(got) != 2 || got[0].File != "stable.go" || got[1].File != "" {
+		t.Fatalf("carried over %+v, want only the model findings outside the changed files", got)
+	}
+	if got[0].BindingAtUsers != 0 {
+		t.Error("BindingAtUsers was carried over; it is projected again")
+	}
+}
+
+func TestMergeChangesDedupesByKey(t *testing.T) {
+	a := newIncremental(t, &types.CodeAnalysis{})
+	analysis := &types.CodeAnalysis{ScalingBottlenecks: []types.Bottleneck{
+		{Type: "database", Severity: "high", File: "users.go", Endpoint: "GET /users", Description: "Roles are loaded per user"},
+		{Type: "database", Severity: "medium", Description: "Reports scan the orders table"},
+		{Type: "database", Severity: "high", File: "users.go", Description: types.NPlusOnePrefix + " GORM First inside a loop"},
+	}}
+	changes := &types.CodeAnalysis{ScalingBottlenecks: []types.Bottleneck{
+		// The same finding, with other punctuation
+		{Type: "database", Severity: "high", File: "users.go", Endpoint: "GET /users", Description: "Roles are loaded per user."},
+		{Type: "database", Severity: "high", File: "users.go", Endpoint: "POST /users", Description: "Roles are loaded per user"},
+		{Type: "cpu", Severity: "medium", File: "images.go", Description: "Resizing on the request path"},
+		{Type: "cpu", Severity: "medium", File: "images.go", Description: "Thumbnails are generated synchronously"},
+		{Type: "database", Severity: "critical", Description: "Every request opens a new connection"},
+	}}
+
+	a.mergeChanges(analysis, changes)
+
+	var keys []string
+	for _, b := range analysis.ScalingBottlenecks {
+		keys = append(keys, b.Key())
+	}
+	want := []string{
+		"database|high|users.go|get /users|roles are loaded per user",
+		"database|high|users.go||n candidate gorm first inside a loop",
+		"database|high|users.go|post /users|roles are loaded per user",
+		"cpu|medium|images.go||resizing on the request path",
+		"cpu|medium|images.go||thumbnails are generated synchronously",
+		"database|critical|||every request opens a new connection",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("merged %q\nwant %q", keys, want)
+	}
+}
@@ -0,0 +1,393 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+const (
+	// overProvisionFactor is how many times the estimated memory a single
+	// resource must provide to be reported as over-provisioned; it must also
+	// provide twice the estimated CPU
+	overProvisionFactor = 4
+	// rightsizeHeadroom is the margin over the estimate a suggested size keeps
+	rightsizeHeadroom = 1.5
+)
+
+// Smallest requests suggested for a pod
+const (
+	minPodCPU      = 0.05
+	minPodMemoryMB = 64
+)
+
+// storageUpgrades are volume types with a cheaper drop-in successor, with the
+// saving per GB-month at list price
+var storageUpgrades = map[string]struct {
+	successor string
+	perGB     float64
+}{
+	"gp2": {"gp3", 0.02},
+	"io1": {"gp3", 0.045},
+}
+
+// sourceNames describe each infrastructure source in provenance notes
+var sourceNames = map[string]string{
+	"terraform":      "Terraform files",
+	"kubernetes":     "Kubernetes manifests",
+	"helm":           "Helm charts",
+	"docker-compose": "Compose files",
+}
+
+// applyInfrastructure records the declared infrastructure and compares it
+// with the estimated resource requirements. It runs after the estimates are
+// final and priced.
+func (a *Analyzer) applyInfrastructure(analysis *types.CodeAnalysis) {
+	if len(a.infra) == 0 {
+		return
+	}
+
+	need := analysis.ResourceUsage
+	var recommendations []types.Recommendation
+	seen := make(map[string]bool)
+	var sources []string
+
+	for i := range a.infra {
+		r := &a.infra[i]
+		if name := sourceNames[r.Source]; !seen[name] {
+			seen[name] = true
+			sources = append(sources, name)
+		}
+
+		if r.InstanceType != "" && r.Provider != "" {
+			if inst, ok := a.pricing.LookupInstance(r.Provider, r.InstanceType); ok {
+				r.VCPUs, r.MemoryGB = inst.VCPUs, inst.MemoryGB
+				if r.Role == "compute" {
+					if rec, ok := a.rightsize(*r, inst.Hourly, need); ok {
+						recommendations = append(recommendations, rec)
+					}
+				}
+			}
+		}
+
+		if r.MinSize > 0 && r.MinSize == r.MaxSize {
+			recommendations = append(recommendations, types.Recommendation{
+				Type:        "autoscaling",
+				Description: fmt.Sprintf("%s is fixed at %d instances; allow it to scale in when load is low", r.Address(), r.MinSize),
+				Resource:    r.Address(),
+				Current:     fmt.Sprintf("min %d, max %d", r.MinSize, r.MaxSize),
+				Suggested:   fmt.Sprintf("min 1, max %d", r.MaxSize),
+				File:        r.File,
+				Line:        r.Line,
+			})
+		}
+
+		if upgrade, ok := storageUpgrades[strings.ToLower(r.StorageClass)]; ok && r.Provider == "aws" {
+			recommendations = append(recommendations, types.Recommendation{
+				Type:           "storage",
+				Description:    fmt.Sprintf("%s uses %s volumes; %s has the same or better performance for less", r.Address(), r.StorageClass, upgrade.successor),
+				Resource:       r.Address(),
+				Current:        r.StorageClass,
+				Suggested:      upgrade.successor,
+				MonthlySavings: round2(float64(r.StorageGB*r.Instances()) * upgrade.perGB),
+				File:           r.File,
+				Line:           r.Line,
+			})
+		}
+	}
+
+	a.checkInstanceCapacity(analysis, need)
+	recommendations = append(recommendations, a.checkWorkloads(analysis, need)...)
+
+	analysis.Infrastructure = a.infra
+	analysis.Recommendations = append(analysis.Recommendations, recommendations...)
+	analysis.SetProvenance("infrastructure", types.SourceStatic, strings.Join(sources, ", "))
+}
+
+// applyContainers records the images the project builds and the datastores
+// and caches its compose services and workloads run. Those replace the
+// model's view of the caches, as they are declared rather than inferred.
+func (a *Analyzer) applyContainers(analysis *types.CodeAnalysis) {
+	var caches, datastores []string
+	for _, r := range a.infra {
+		svc, ok := lookupServiceImage(r.Image)
+		if !ok {
+			continue
+		}
+		switch svc.role {
+		case "cache":
+			caches = mergeNames(caches, []string{svc.name})
+		case "database":
+			datastores = mergeNames(datastores, []string{svc.name})
+		}
+	}
+	if len(caches) > 0 {
+		analysis.CacheUsage = caches
+		analysis.SetProvenance("cache_usage", types.SourceStatic, "container images")
+	}
+	if len(datastores) > 0 {
+		analysis.Datastores = datastores
+		analysis.SetProvenance("datastores", types.SourceStatic, "container images")
+	}
+
+	if len(a.images) > 0 {
+		analysis.Containers = a.images
+		analysis.Recommendations = append(analysis.Recommendations, a.imageFindings...)
+		analysis.SetProvenance("containers", types.SourceStatic, "Dockerfiles")
+	}
+}
+
+// checkInstanceCapacity reports declared instances that together cannot hold
+// the estimate, which limits the app as deployed
+func (a *Analyzer) checkInstanceCapacity(analysis *types.CodeAnalysis, need types.ResourceMetrics) {
+	var totalCPU, totalMemoryGB float64
+	var largest *types.InfraResource
+	for i := range a.infra {
+		r := &a.infra[i]
+		if r.Source != "terraform" || r.Role != "compute" || r.MemoryGB == 0 {
+			continue
+		}
+		totalCPU += r.VCPUs * float64(r.Instances())
+		totalMemoryGB += r.MemoryGB * float64(r.Instances())
+		if largest == nil || r.MemoryGB > largest.MemoryGB {
+			largest = r
+		}
+	}
+	if largest == nil {
+		return
+	}
+
+	if totalMemoryGB*1024 < float64(need.MemoryMB) {
+		analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, types.Bottleneck{
+			Type:        "memory",
+			Description: fmt.Sprintf("%s compute declares %.1f GB of memory in total, below the estimated %d MB", types.TerraformPrefix, totalMemoryGB, need.MemoryMB),
+			Severity:    "high",
+			Impact:      "Instances will swap or be killed for running out of memory",
+			File:        largest.File,
+			Line:        largest.Line,
+		})
+	}
+	if totalCPU < need.CPUCores {
+		analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, types.Bottleneck{
+			Type:        "cpu",
+			Description: fmt.Sprintf("%s compute declares %.1f vCPUs in total, below the estimated %.1f cores", types.TerraformPrefix, totalCPU, need.CPUCores),
+			Severity:    "medium",
+			Impact:      "Requests queue behind each other under expected load",
+			File:        largest.File,
+			Line:        largest.Line,
+		})
+	}
+}
+
+// rightsize suggests a smaller instance type for a compute resource that
+// provides several times the estimated requirements on its own
+func (a *Analyzer) rightsize(r types.InfraResource, hourly float64, need types.ResourceMetrics) (types.Recommendation, bool) {
+	count := float64(r.Instances())
+	memoryGB, cpu := r.MemoryGB*count, r.VCPUs*count
+	if need.MemoryMB <= 0 || memoryGB*1024 < float64(need.MemoryMB*overProvisionFactor) || cpu < need.CPUCores*2 {
+		return types.Recommendation{}, false
+	}
+
+	suggested, ok := a.pricing.Rightsize(r.Provider,
+		need.CPUCores*rightsizeHeadroom, float64(need.MemoryMB)/1024*rightsizeHeadroom)
+	if !ok {
+		return types.Recommendation{}, false
+	}
+	savings := a.pricing.Monthly(hourly*count) - suggested.Monthly
+	if savings <= 0 {
+		return types.Recommendation{}, false
+	}
+
+	current := fmt.Sprintf("%s (%g vCPU, %g GB)", r.InstanceType, r.VCPUs, r.MemoryGB)
+	if count > 1 {
+		current = fmt.Sprintf("%d× %s", int(count), current)
+	}
+	return types.Recommendation{
+		Type: "rightsize",
+		Description: fmt.Sprintf("%s runs %s for a service estimated at %d MB and %.1f cores",
+			r.Address(), current, need.MemoryMB, need.CPUCores),
+		Resource:       r.Address(),
+		Current:        current,
+		Suggested:      suggested.Description,
+		MonthlySavings: round2(savings),
+		File:           r.File,
+		Line:           r.Line,
+	}, true
+}
+
+// checkWorkloads compares the requests and replicas of Kubernetes workloads
+// with the estimate, and looks for missing autoscalers and CronJobs that
+// could run as one
+func (a *Analyzer) checkWorkloads(analysis *types.CodeAnalysis, need types.ResourceMetrics) []types.Recommendation {
+	var recommendations []types.Recommendation
+	var totalCPU, totalMemoryMB float64
+	var first *types.InfraResource
+	sized := true
+	schedules := make(map[string][]*types.InfraResource)
+
+	for i := range a.infra {
+		w := &a.infra[i]
+		if w.Source != "kubernetes" && w.Source != "helm" {
+			continue
+		}
+		if w.Role == "job" && w.Schedule != "" {
+			schedules[w.Schedule] = append(schedules[w.Schedule], w)
+			continue
+		}
+		if w.Role != "compute" {
+			continue
+		}
+		if first == nil {
+			first = w
+		}
+
+		if w.CPURequest == 0 || w.MemoryRequestMB == 0 {
+			sized = false
+			recommendations = append(recommendations, types.Recommendation{
+				Type:        "requests",
+				Description: fmt.Sprintf("%s sets no CPU or memory requests, so nodes cannot be packed and it cannot be autoscaled on utilization", w.Address()),
+				Resource:    w.Address(),
+				Suggested:   podRequests(need.CPUCores*rightsizeHeadroom, float64(need.MemoryMB)*rightsizeHeadroom),
+				File:        w.File,
+				Line:        w.Line,
+			})
+		} else {
+			replicas := float64(w.Instances())
+			totalCPU += w.CPURequest * replicas
+			// Pods may use memory up to their limit
+			memory := w.MemoryRequestMB
+			if w.MemoryLimitMB > memory {
+				memory = w.MemoryLimitMB
+			}
+			totalMemoryMB += float64(memory) * replicas
+
+			if rec, ok := a.rightsizePods(*w, need); ok {
+				recommendations = append(recommendations, rec)
+			}
+		}
+
+		if w.Type == "Deployment" && !w.Stateful && !w.Autoscaled && w.Instances() > 1 {
+			description := fmt.Sprintf("%s runs a fixed %d replicas of a stateless service; a HorizontalPodAutoscaler would scale it in when load is low", w.Address(), w.Instances())
+			if analysis.StatelessFuncs > 0 {
+				description += fmt.Sprintf(" (%d stateless functions found)", analysis.StatelessFuncs)
+			}
+			recommendations = append(recommendations, types.Recommendation{
+				Type:        "autoscaling",
+				Description: description,
+				Resource:    w.Address(),
+				Current:     fmt.Sprintf("%d replicas", w.Instances()),
+				Suggested:   fmt.Sprintf("HorizontalPodAutoscaler, min 1, max %d", w.Instances()*2),
+				File:        w.File,
+				Line:        w.Line,
+			})
+		}
+	}
+
+	// Workloads whose limits cannot hold the estimate are throttled or killed
+	if first != nil && sized {
+		if totalMemoryMB < float64(need.MemoryMB) {
+			analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, types.Bottleneck{
+				Type:        "memory",
+				Description: fmt.Sprintf("%s workloads are sized for %.0f MB of memory in total, below the estimated %d MB", types.KubernetesPrefix, totalMemoryMB, need.MemoryMB),
+				Severity:    "high",
+				Impact:      "Pods will be OOM-killed or evicted under expected load",
+				File:        first.File,
+				Line:        first.Line,
+			})
+		}
+		if totalCPU < need.CPUCores {
+			analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, types.Bottleneck{
+				Type:        "cpu",
+				Description: fmt.Sprintf("%s workloads request %.2f cores in total, below the estimated %.1f cores", types.KubernetesPrefix, totalCPU, need.CPUCores),
+				Severity:    "medium",
+				Impact:      "Pods are throttled and requests queue under expected load",
+				File:        first.File,
+				Line:        first.Line,
+			})
+		}
+	}
+
+	return append(recommendations, mergeableCronJobs(schedules)...)
+}
+
+// rightsizePods suggests smaller requests for a workload whose replicas
+// request several times the estimated requirements on their own
+func (a *Analyzer) rightsizePods(w types.InfraResource, need types.ResourceMetrics) (types.Recommendation, bool) {
+	replicas := float64(w.Instances())
+	cpu, memoryMB := w.CPURequest*replicas, float64(w.MemoryRequestMB)*replicas
+	if need.MemoryMB <= 0 || memoryMB < float64(need.MemoryMB*overProvisionFactor) || cpu < need.CPUCores*2 {
+		return types.Recommendation{}, false
+	}
+
+	podCPU := math.Max(need.CPUCores*rightsizeHeadroom/replicas, minPodCPU)
+	podMemoryMB := math.Max(float64(need.MemoryMB)*rightsizeHeadroom/replicas, minPodMemoryMB)
+
+	// The smallest saving over the providers, as the cluster's is unknown
+	savings := math.Inf(1)
+	for _, p := range a.pricing.Providers {
+		before, _ := a.pricing.CapacityCost(p.ID, cpu, memoryMB/1024)
+		after, _ := a.pricing.CapacityCost(p.ID, podCPU*replicas, podMemoryMB*replicas/1024)
+		savings = math.Min(savings, before-after)
+	}
+	if savings <= 0 || math.IsInf(savings, 1) {
+		return types.Recommendation{}, false
+	}
+
+	current := podRequests(w.CPURequest, float64(w.MemoryRequestMB))
+	return types.Recommendation{
+		Type: "rightsize",
+		Description: fmt.Sprintf("%s requests %s × %d replicas for a service estimated at %d MB and %.1f cores",
+			w.Address(), current, w.Instances(), need.MemoryMB, need.CPUCores),
+		Resource:       w.Address(),
+		Current:        current,
+		Suggested:      podRequests(podCPU, podMemoryMB),
+		MonthlySavings: round2(savings),
+		File:           w.File,
+		Line:           w.Line,
+	}, true
+}
+
+// mergeableCronJobs suggests running CronJobs that share a schedule as one
+// job, saving a pod start-up and its image pull each run
+func mergeableCronJobs(schedules map[string][]*types.InfraResource) []types.Recommendation {
+	keys := make([]string, 0, len(schedules))
+	for schedule, jobs := range schedules {
+		if len(jobs) > 1 {
+			keys = append(keys, schedule)
+		}
+	}
+	sort.Strings(keys)
+
+	var recommendations []types.Recommendation
+	for _, schedule := range keys {
+		jobs := schedules[schedule]
+		names := make([]string, len(jobs))
+		for i, job := range jobs {
+			names[i] = job.Address()
+		}
+		recommendations = append(recommendations, types.Recommendation{
+			Type:        "cronjob",
+			Description: fmt.Sprintf("%s all run on schedule %q; merge them into one CronJob", strings.Join(names, ", "), schedule),
+			Resource:    names[0],
+			Current:     fmt.Sprintf("%d CronJobs", len(jobs)),
+			Suggested:   "1 CronJob",
+			File:        jobs[0].File,
+			Line:        jobs[0].Line,
+		})
+	}
+	return recommendations
+}
+
+// podRequests formats per-pod requests as a manifest would, e.g.
+// "cpu: 250m, memory: 384Mi"
+func podRequests(cpu, memoryMB float64) string {
+	return fmt.Sprintf("cpu: %dm, memory: %dMi", int(math.Ceil(cpu*1000)), int(math.Ceil(memoryMB)))
+}
+
+// round2 rounds an amount to cents
+func round2(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,331 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// k8sManifest is the part of a Kubernetes object the inventory reads
+type k8sManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Replicas *int        `yaml:"replicas"`
+		Schedule string      `yaml:"schedule"`
+		Template k8sTemplate `yaml:"template"`
+
+		JobTemplate struct {
+			Spec struct {
+				Template k8sTemplate `yaml:"template"`
+			} `yaml:"spec"`
+		} `yaml:"jobTemplate"`
+
+		// HorizontalPodAutoscaler
+		ScaleTargetRef struct {
+			Kind string `yaml:"kind"`
+			Name string `yaml:"name"`
+		} `yaml:"scaleTargetRef"`
+		MinReplicas *int `yaml:"minReplicas"`
+		MaxReplicas int  `yaml:"maxReplicas"`
+
+		VolumeClaimTemplates []interface{} `yaml:"volumeClaimTemplates"`
+	} `yaml:"spec"`
+}
+
+// k8sTemplate is a pod template
+type k8sTemplate struct {
+	Spec struct {
+		Containers []struct {
+			Image     string `yaml:"image"`
+			Resources struct {
+				Requests map[string]string `yaml:"requests"`
+				Limits   map[string]string `yaml:"limits"`
+			} `yaml:"resources"`
+		} `yaml:"containers"`
+		Volumes []struct {
+			PersistentVolumeClaim interface{} `yaml:"persistentVolumeClaim"`
+		} `yaml:"volumes"`
+	} `yaml:"spec"`
+}
+
+// k8sWorkloadKinds are the kinds inventoried, with their role
+var k8sWorkloadKinds = map[string]string{
+	"Deployment":              "compute",
+	"StatefulSet":             "compute",
+	"DaemonSet":               "compute",
+	"CronJob":                 "job",
+	"HorizontalPodAutoscaler": "autoscaling",
+}
+
+// helmSourceComment precedes each document `helm template` renders
+const helmSourceComment = "# Source: "
+
+// FindKubernetesResources inventories the workloads declared in Kubernetes
+// manifests: Deployments, StatefulSets, DaemonSets and CronJobs with their
+// replicas and per-pod CPU and memory requests and limits. Autoscalers are
+// applied to the workloads they target. Helm charts are rendered with
+// `helm template` when helm is installed.
+func FindKubernetesResources(root string) ([]types.InfraResource, error) {
+	var resources []types.InfraResource
+	var charts []string
+
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		name := path.Base(rel)
+		if name == "Chart.yaml" {
+			charts = append(charts, path.Dir(rel))
+			return nil
+		}
+		ext := path.Ext(name)
+		if ext != ".yaml" && ext != ".yml" || info.Size() > maxScannedFileBytes {
+			return nil
+		}
+		// Chart templates only parse once rendered
+		if strings.Contains("/"+rel, "/templates/") && isInChart(root, rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil || !bytes.Contains(data, []byte("kind:")) || bytes.Contains(data, []byte("{{")) {
+			return nil
+		}
+		resources = append(resources, parseManifests("kubernetes", rel, data, nil)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(charts) > 0 {
+		if _, err := exec.LookPath("helm"); err != nil {
+			fmt.Printf("⚠️  helm not found: skipping %d Helm charts\n", len(charts))
+		} else {
+			for _, chart := range charts {
+				resources = append(resources, renderChart(root, chart)...)
+			}
+		}
+	}
+
+	return applyAutoscalers(resources), nil
+}
+
+// isInChart reports whether rel lies in the templates directory of a chart
+func isInChart(root, rel string) bool {
+	dir := rel[:strings.LastIndex("/"+rel, "/templates/")]
+	_, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir), "Chart.yaml"))
+	return err == nil
+}
+
+// renderChart runs `helm template` on a chart with its default values
+func renderChart(root, chart string) []types.InfraResource {
+	cmd := exec.Command("helm", "template", "cloudpork", filepath.Join(root, filepath.FromSlash(chart)))
+	out, err := cmd.Output()
+	if err != nil {
+		fmt.Printf("⚠️  helm template %s failed: %v\n", chart, err)
+		return nil
+	}
+
+	// Attribute each document to its template, "chart-name/templates/x.yaml"
+	file := func(source string) string {
+		if i := strings.Index(source, "/"); i >= 0 {
+			return path.Join(chart, source[i+1:])
+		}
+		return chart
+	}
+	return parseManifests("helm", chart, out, file)
+}
+
+// parseManifests decodes every workload in a multi-document YAML stream.
+// sourceFile, if set, maps a helm "# Source:" comment to the file to report.
+func parseManifests(source, rel string, data []byte, sourceFile func(string) string) []types.InfraResource {
+	var resources []types.InfraResource
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if err != io.EOF {
+				return resources // Not a manifest, or invalid past this point
+			}
+			break
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		var m k8sManifest
+		if err := doc.Decode(&m); err != nil || m.APIVersion == "" {
+			continue
+		}
+		role, ok := k8sWorkloadKinds[m.Kind]
+		if !ok {
+			continue
+		}
+
+		r := types.InfraResource{
+			Source: source,
+			Type:   m.Kind,
+			Name:   m.Metadata.Name,
+			Role:   role,
+			File:   rel,
+			Line:   doc.Content[0].Line,
+		}
+		if sourceFile != nil {
+			r.File, r.Line = rel, 0
+			comment := headComment(&doc)
+			if i := strings.Index(comment, helmSourceComment); i >= 0 {
+				r.File = sourceFile(strings.TrimSpace(strings.SplitN(comment[i+len(helmSourceComment):], "\n", 2)[0]))
+			}
+		}
+
+		switch m.Kind {
+		case "HorizontalPodAutoscaler":
+			r.MinSize = 1
+			if m.Spec.MinReplicas != nil {
+				r.MinSize = *m.Spec.MinReplicas
+			}
+			r.MaxSize = m.Spec.MaxReplicas
+			r.Target = m.Spec.ScaleTargetRef.Kind + "/" + m.Spec.ScaleTargetRef.Name
+		case "CronJob":
+			r.Schedule = m.Spec.Schedule
+			applyPodTemplate(&r, m.Spec.JobTemplate.Spec.Template)
+		case "DaemonSet":
+			applyPodTemplate(&r, m.Spec.Template) // One pod per node
+		default:
+			r.Count = 1
+			if m.Spec.Replicas != nil {
+				r.Count = *m.Spec.Replicas
+			}
+			applyPodTemplate(&r, m.Spec.Template)
+			r.Stateful = m.Kind == "StatefulSet" || len(m.Spec.VolumeClaimTemplates) > 0
+			for _, v := range m.Spec.Template.Spec.Volumes {
+				if v.PersistentVolumeClaim != nil {
+					r.Stateful = true
+				}
+			}
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+// headComment returns the comment above a document, which the decoder
+// attaches to the document, its mapping or the mapping's first key
+func headComment(doc *yaml.Node) string {
+	for n := doc; n != nil; {
+		if n.HeadComment != "" {
+			return n.HeadComment
+		}
+		if len(n.Content) == 0 {
+			break
+		}
+		n = n.Content[0]
+	}
+	return ""
+}
+
+// applyPodTemplate sums the requests and limits of a pod's containers
+func applyPodTemplate(r *types.InfraResource, template k8sTemplate) {
+	for _, c := range template.Spec.Containers {
+		if r.Image == "" {
+			r.Image = c.Image
+		}
+		r.CPURequest += parseCPU(c.Resources.Requests["cpu"])
+		r.CPULimit += parseCPU(c.Resources.Limits["cpu"])
+		r.MemoryRequestMB += parseMemoryMB(c.Resources.Requests["memory"])
+		r.MemoryLimitMB += parseMemoryMB(c.Resources.Limits["memory"])
+	}
+}
+
+// applyAutoscalers sets the replica bounds of each workload targeted by an
+// autoscaler, keeping only the autoscalers whose target was not found
+func applyAutoscalers(resources []types.InfraResource) []types.InfraResource {
+	var autoscalers []types.InfraResource
+	workloads := make(map[string]int)
+	var kept []types.InfraResource
+	for _, r := range resources {
+		if r.Type == "HorizontalPodAutoscaler" {
+			autoscalers = append(autoscalers, r)
+			continue
+		}
+		workloads[r.Address()] = len(kept)
+		kept = append(kept, r)
+	}
+
+	for _, hpa := range autoscalers {
+		if i, ok := workloads[hpa.Target]; ok {
+			kept[i].MinSize, kept[i].MaxSize = hpa.MinSize, hpa.MaxSize
+			kept[i].Autoscaled = true
+			continue
+		}
+		kept = append(kept, hpa)
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].File != kept[j].File {
+			return kept[i].File < kept[j].File
+		}
+		return kept[i].Line < kept[j].Line
+	})
+	return kept
+}
+
+// parseCPU converts a Kubernetes CPU quantity ("500m", "2") to cores
+func parseCPU(quantity string) float64 {
+	quantity = strings.TrimSpace(quantity)
+	if strings.HasSuffix(quantity, "m") {
+		millis, err := strconv.ParseFloat(strings.TrimSuffix(quantity, "m"), 64)
+		if err != nil {
+			return 0
+		}
+		return millis / 1000
+	}
+	cores, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return 0
+	}
+	return cores
+}
+
+// memorySuffixes are the Kubernetes quantity suffixes, in bytes
+var memorySuffixes = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"k", 1e3}, {"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+// parseMemoryMB converts a Kubernetes memory quantity ("512Mi", "1G") to MiB
+func parseMemoryMB(quantity string) int {
+	quantity = strings.TrimSpace(quantity)
+	if quantity == "" {
+		return 0
+	}
+	multiplier := 1.0
+	for _, s := range memorySuffixes {
+		if strings.HasSuffix(quantity, s.suffix) {
+			multiplier = s.bytes
+			quantity = strings.TrimSuffix(quantity, s.suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return 0
+	}
+	return int(n * multiplier / (1 << 20))
+}
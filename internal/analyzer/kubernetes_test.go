@@ -0,0 +1,246 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func TestParseCPU(t *testing.T) {
+	tests := map[string]float64{
+		"":     0,
+		"500m": 0.5,
+		"250m": 0.25,
+		"2":    2,
+		"1.5":  1.5,
+		" 1 ":  1,
+		"abc":  0,
+		"xm":   0,
+	}
+	for quantity, want := range tests {
+		if got := parseCPU(quantity); got != want {
+			t.Errorf("parseCPU(%q) = %v, want %v", quantity, got, want)
+		}
+	}
+}
+
+func TestParseMemoryMB(t *testing.T) {
+	tests := map[string]int{
+		"":          0,
+		"512Mi":     512,
+		"1Gi":       1024,
+		"1.5Gi":     1536,
+		"2048Ki":    2,
+		"1G":        953,
+		"500M":      476,
+		"134217728": 128,
+		"lots":      0,
+	}
+	for quantity, want := range tests {
+		if got := parseMemoryMB(quantity); got != want {
+			t.Errorf("parseMemoryMB(%q) = %d, want %d", quantity, got, want)
+		}
+	}
+}
+
+func TestParseManifests(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []types.InfraResource
+	}{
+		{
+			name: "deployment summed over containers",
+			data: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - image: acme/api:1.2
+          resources:
+            requests: {cpu: 250m, memory: 256Mi}
+            limits: {cpu: "1", memory: 512Mi}
+        - image: envoyproxy/envoy
+          resources:
+            requests: {cpu: 100m, memory: 64Mi}
+`,
+			want: []types.InfraResource{{
+				Source: "kubernetes", Type: "Deployment", Name: "api", Role: "compute", File: "k8s.yaml", Line: 1,
+				Count: 3, Image: "acme/api:1.2", CPURequest: 0.35, CPULimit: 1, MemoryRequestMB: 320, MemoryLimitMB: 512,
+			}},
+		},
+		{
+			name: "several documents, non-workloads skipped",
+			data: `apiVersion: v1
+kind: Service
+metadata:
+  name: api
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  template:
+    spec:
+      containers:
+        - image: postgres:16
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - image: acme/report
+              resources:
+                requests: {cpu: "2"}
+`,
+			want: []types.InfraResource{
+				{Source: "kubernetes", Type: "StatefulSet", Name: "db", Role: "compute", File: "k8s.yaml", Line: 6, Count: 1, Image: "postgres:16", Stateful: true},
+				{Source: "kubernetes", Type: "CronJob", Name: "report", Role: "job", File: "k8s.yaml", Line: 16, Schedule: "0 * * * *", Image: "acme/report", CPURequest: 2},
+			},
+		},
+		{
+			name: "persistent volume claims make a deployment stateful",
+			data: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: uploads
+spec:
+  template:
+    spec:
+      volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: uploads
+`,
+			want: []types.InfraResource{{Source: "kubernetes", Type: "Deployment", Name: "uploads", Role: "compute", File: "k8s.yaml", Line: 1, Count: 1, Stateful: true}},
+		},
+		{
+			name: "autoscaler defaults to one replica",
+			data: `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: api
+spec:
+  scaleTargetRef: {kind: Deployment, name: api}
+  maxReplicas: 10
+`,
+			want: []types.InfraResource{{Source: "kubernetes", Type: "HorizontalPodAutoscaler", Name: "api", Role: "autoscaling", File: "k8s.yaml", Line: 1, MinSize: 1, MaxSize: 10, Target: "Deployment/api"}},
+		},
+		{
+			name: "not a manifest",
+			data: "kind: Deployment\nname: [unclosed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseManifests("kubernetes", "k8s.yaml", []byte(tt.data), nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+const fakeHelm = `#!/bin/sh
+cat <<'EOF'
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cloudpork-web
+spec:
+  replicas: 2
+---
+# Source: web/templates/hpa.yaml
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: cloudpork-web
+spec:
+  scaleTargetRef: {kind: Deployment, name: cloudpork-web}
+  minReplicas: 2
+  maxReplicas: 6
+EOF
+`
+
+func TestFindKubernetesResources(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helm is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "helm"), []byte(fakeHelm), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"deploy/api.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 2
+`,
+		"deploy/hpa.yaml": `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: api
+spec:
+  scaleTargetRef: {kind: Deployment, name: api}
+  minReplicas: 3
+  maxReplicas: 12
+`,
+		"deploy/orphan-hpa.yaml": `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: gone
+spec:
+  scaleTargetRef: {kind: Deployment, name: gone}
+  maxReplicas: 4
+`,
+		"docker-compose.yml":                   "services:\n  web:\n    image: nginx\n",
+		"charts/web/Chart.yaml":                "apiVersion: v2\nname: web\n",
+		"charts/web/templates/deployment.yaml": "kind: Deployment\nmetadata:\n  name: {{ .Release.Name }}-web\n",
+	})
+
+	got, err := FindKubernetesResources(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type workload struct {
+		Source, Address, File string
+		Count, Min, Max       int
+		Autoscaled            bool
+	}
+	var workloads []workload
+	for _, r := range got {
+		workloads = append(workloads, workload{r.Source, r.Address(), r.File, r.Count, r.MinSize, r.MaxSize, r.Autoscaled})
+	}
+	want := []workload{
+		{"helm", "Deployment/cloudpork-web", "charts/web/templates/deployment.yaml", 2, 2, 6, true},
+		{"kubernetes", "Deployment/api", "deploy/api.yaml", 2, 3, 12, true},
+		{"kubernetes", "HorizontalPodAutoscaler/gone", "deploy/orphan-hpa.yaml", 0, 1, 4, false},
+	}
+	if !reflect.DeepEqual(workloads, want) {
+		t.Errorf("got %+v\nwant %+v", workloads, want)
+	}
+}
@@ -0,0 +1,309 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// manifestParser extracts declared dependencies from a manifest file
+type manifestParser func(data []byte) []types.Dependency
+
+// manifestParsers are keyed by manifest file name
+var manifestParsers = map[string]manifestParser{
+	"package.json":     parsePackageJSON,
+	"go.mod":           parseGoMod,
+	"requirements.txt": parseRequirementsTxt,
+	"pyproject.toml":   parsePyproject,
+	"Gemfile":          parseGemfile,
+	"pom.xml":          parsePomXML,
+	"build.gradle":     parseGradle,
+	"build.gradle.kts": parseGradle,
+	"Cargo.toml":       parseCargoToml,
+	"composer.json":    parseComposerJSON,
+}
+
+// parseManifest parses the manifest at rel; ok is false for non-manifest files
+func parseManifest(rel string, data []byte) ([]types.Dependency, bool) {
+	parser, ok := manifestParsers[path.Base(rel)]
+	if !ok {
+		return nil, false
+	}
+
+	deps := parser(data)
+	for i := range deps {
+		deps[i].Manifest = rel
+	}
+	// Several parsers read maps; keep the output stable between runs
+	sort.SliceStable(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+	return deps, true
+}
+
+func parsePackageJSON(data []byte) []types.Dependency {
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	deps := depsFromMap("npm", manifest.Dependencies, false)
+	return append(deps, depsFromMap("npm", manifest.DevDependencies, true)...)
+}
+
+func parseComposerJSON(data []byte) []types.Dependency {
+	var manifest struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	var deps []types.Dependency
+	for _, dep := range depsFromMap("packagist", manifest.Require, false) {
+		// "php" and "ext-*" are platform requirements, not packages
+		if dep.Name != "php" && !strings.HasPrefix(dep.Name, "ext-") {
+			deps = append(deps, dep)
+		}
+	}
+	return append(deps, depsFromMap("packagist", manifest.RequireDev, true)...)
+}
+
+func parseGoMod(data []byte) []types.Dependency {
+	var deps []types.Dependency
+	inRequire := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		indirect := strings.Contains(line, "// indirect")
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inRequire:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && !indirect {
+			deps = append(deps, types.Dependency{Name: fields[0], Version: fields[1], Ecosystem: "go"})
+		}
+	}
+
+	return deps
+}
+
+// pep508Name matches the distribution name at the start of a requirement
+var pep508Name = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*(.*)$`)
+
+// parsePEP508 splits a requirement like "django[bcrypt]>=4.2; python_version>'3'"
+func parsePEP508(req string) (types.Dependency, bool) {
+	if i := strings.Index(req, ";"); i >= 0 {
+		req = req[:i]
+	}
+	m := pep508Name.FindStringSubmatch(strings.TrimSpace(req))
+	if m == nil {
+		return types.Dependency{}, false
+	}
+	return types.Dependency{
+		Name:      strings.ToLower(m[1]),
+		Version:   strings.TrimSpace(m[3]),
+		Ecosystem: "pypi",
+	}, true
+}
+
+func parseRequirementsTxt(data []byte) []types.Dependency {
+	var deps []types.Dependency
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		// Skip comments, options (-r, -e, --hash) and direct URLs
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+			continue
+		}
+		if dep, ok := parsePEP508(line); ok {
+			deps = append(deps, dep)
+		}
+	}
+
+	return deps
+}
+
+func parsePyproject(data []byte) []types.Dependency {
+	var manifest struct {
+		Project struct {
+			Dependencies         []string            `toml:"dependencies"`
+			OptionalDependencies map[string][]string `toml:"optional-dependencies"`
+		} `toml:"project"`
+		Tool struct {
+			Poetry struct {
+				Dependencies    map[string]interface{} `toml:"dependencies"`
+				DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+			} `toml:"poetry"`
+		} `toml:"tool"`
+	}
+	if err := toml.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	var deps []types.Dependency
+	for _, req := range manifest.Project.Dependencies {
+		if dep, ok := parsePEP508(req); ok {
+			deps = append(deps, dep)
+		}
+	}
+	for _, reqs := range manifest.Project.OptionalDependencies {
+		for _, req := range reqs {
+			if dep, ok := parsePEP508(req); ok {
+				dep.Dev = true
+				deps = append(deps, dep)
+			}
+		}
+	}
+	for name, spec := range manifest.Tool.Poetry.Dependencies {
+		if name != "python" {
+			deps = append(deps, types.Dependency{Name: strings.ToLower(name), Version: tomlVersion(spec), Ecosystem: "pypi"})
+		}
+	}
+	for name, spec := range manifest.Tool.Poetry.DevDependencies {
+		deps = append(deps, types.Dependency{Name: strings.ToLower(name), Version: tomlVersion(spec), Ecosystem: "pypi", Dev: true})
+	}
+
+	return deps
+}
+
+func parseCargoToml(data []byte) []types.Dependency {
+	var manifest struct {
+		Dependencies    map[string]interface{} `toml:"dependencies"`
+		DevDependencies map[string]interface{} `toml:"dev-dependencies"`
+	}
+	if err := toml.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	var deps []types.Dependency
+	for name, spec := range manifest.Dependencies {
+		deps = append(deps, types.Dependency{Name: name, Version: tomlVersion(spec), Ecosystem: "cargo"})
+	}
+	for name, spec := range manifest.DevDependencies {
+		deps = append(deps, types.Dependency{Name: name, Version: tomlVersion(spec), Ecosystem: "cargo", Dev: true})
+	}
+
+	return deps
+}
+
+// tomlVersion reads a version from `name = "1.0"` or `name = { version = "1.0" }`
+func tomlVersion(spec interface{}) string {
+	switch v := spec.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if version, ok := v["version"].(string); ok {
+			return version
+		}
+	}
+	return ""
+}
+
+var gemLine = regexp.MustCompile(`^\s*gem\s+['"]([^'"]+)['"](?:\s*,\s*['"]([^'"]+)['"])?`)
+
+func parseGemfile(data []byte) []types.Dependency {
+	var deps []types.Dependency
+	inDevGroup := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "group ") && (strings.Contains(trimmed, ":development") || strings.Contains(trimmed, ":test")) {
+			inDevGroup = true
+			continue
+		}
+		if trimmed == "end" {
+			inDevGroup = false
+			continue
+		}
+
+		if m := gemLine.FindStringSubmatch(line); m != nil {
+			deps = append(deps, types.Dependency{Name: m[1], Version: m[2], Ecosystem: "rubygems", Dev: inDevGroup})
+		}
+	}
+
+	return deps
+}
+
+func parsePomXML(data []byte) []types.Dependency {
+	var pom struct {
+		Dependencies []struct {
+			GroupID    string `xml:"groupId"`
+			ArtifactID string `xml:"artifactId"`
+			Version    string `xml:"version"`
+			Scope      string `xml:"scope"`
+		} `xml:"dependencies>dependency"`
+	}
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil
+	}
+
+	var deps []types.Dependency
+	for _, d := range pom.Dependencies {
+		deps = append(deps, types.Dependency{
+			Name:      d.GroupID + ":" + d.ArtifactID,
+			Version:   d.Version,
+			Ecosystem: "maven",
+			Dev:       d.Scope == "test",
+		})
+	}
+
+	return deps
+}
+
+var gradleDependency = regexp.MustCompile(`(?m)^\s*(implementation|api|compile|compileOnly|runtimeOnly|testImplementation|testCompile|kapt|annotationProcessor)\s*\(?\s*['"]([^:'"]+):([^:'"]+)(?::([^'"]+))?['"]`)
+
+func parseGradle(data []byte) []types.Dependency {
+	var deps []types.Dependency
+	for _, m := range gradleDependency.FindAllStringSubmatch(string(data), -1) {
+		deps = append(deps, types.Dependency{
+			Name:      m[2] + ":" + m[3],
+			Version:   m[4],
+			Ecosystem: "maven",
+			Dev:       strings.HasPrefix(m[1], "test"),
+		})
+	}
+	return deps
+}
+
+// depsFromMap converts a name->version map into dependencies sorted by name
+func depsFromMap(ecosystem string, m map[string]string, dev bool) []types.Dependency {
+	deps := make([]types.Dependency, 0, len(m))
+	for name, version := range m {
+		deps = append(deps, types.Dependency{Name: name, Version: version, Ecosystem: ecosystem, Dev: dev})
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Name < deps[j].Name })
+	return deps
+}
@@ -0,0 +1,241 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// dep builds the expected dependency; "dev" marks a development one
+func dep(ecosystem, name, version string, dev ...bool) types.Dependency {
+	return types.Dependency{Name: name, Version: version, Ecosystem: ecosystem, Dev: len(dev) > 0 && dev[0]}
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name string
+		rel  string
+		data string
+		want []types.Dependency
+	}{
+		{
+			name: "package.json with dev dependencies",
+			rel:  "web/package.json",
+			data: `{"dependencies": {"express": "^4.18.0", "pg": "8.11.0"}, "devDependencies": {"jest": "^29.0.0"}}`,
+			want: []types.Dependency{
+				dep("npm", "express", "^4.18.0"),
+				dep("npm", "jest", "^29.0.0", true),
+				dep("npm", "pg", "8.11.0"),
+			},
+		},
+		{
+			name: "invalid package.json",
+			rel:  "package.json",
+			data: `{"dependencies":`,
+		},
+		{
+			name: "composer.json skips platform requirements",
+			rel:  "composer.json",
+			data: `{"require": {"php": ">=8.1", "ext-json": "*", "laravel/framework": "^10.0"}, "require-dev": {"phpunit/phpunit": "^10.0"}}`,
+			want: []types.Dependency{
+				dep("packagist", "laravel/framework", "^10.0"),
+				dep("packagist", "phpunit/phpunit", "^10.0", true),
+			},
+		},
+		{
+			name: "go.mod require block and single line, indirect skipped",
+			rel:  "go.mod",
+			data: `module example.com/app
+
+go 1.21
+
+require github.com/spf13/cobra v1.8.0
+
+require (
+	github.com/gin-gonic/gin v1.9.1 // web
+	golang.org/x/text v0.14.0 // indirect
+)
+`,
+			want: []types.Dependency{
+				dep("go", "github.com/gin-gonic/gin", "v1.9.1"),
+				dep("go", "github.com/spf13/cobra", "v1.8.0"),
+			},
+		},
+		{
+			name: "requirements.txt skips comments, options and URLs",
+			rel:  "requirements.txt",
+			data: `# Web
+Django>=4.2 # LTS
+celery[redis]==5.3.0
+-r base.txt
+-e git+https://github.com/acme/lib.git#egg=lib
+https://example.com/pkg.whl
+requests ; python_version > "3.7"
+`,
+			want: []types.Dependency{
+				dep("pypi", "celery", "==5.3.0"),
+				dep("pypi", "django", ">=4.2"),
+				dep("pypi", "requests", ""),
+			},
+		},
+		{
+			name: "pyproject.toml with PEP 621 and Poetry tables",
+			rel:  "pyproject.toml",
+			data: `[project]
+dependencies = ["fastapi>=0.100"]
+
+[project.optional-dependencies]
+test = ["pytest"]
+
+[tool.poetry.dependencies]
+python = "^3.11"
+SQLAlchemy = { version = "^2.0", extras = ["asyncio"] }
+
+[tool.poetry.dev-dependencies]
+black = "^23.0"
+`,
+			want: []types.Dependency{
+				dep("pypi", "black", "^23.0", true),
+				dep("pypi", "fastapi", ">=0.100"),
+				dep("pypi", "pytest", "", true),
+				dep("pypi", "sqlalchemy", "^2.0"),
+			},
+		},
+		{
+			name: "Cargo.toml with inline tables",
+			rel:  "Cargo.toml",
+			data: `[dependencies]
+tokio = { version = "1.35", features = ["full"] }
+serde = "1.0"
+local = { path = "../local" }
+
+[dev-dependencies]
+criterion = "0.5"
+`,
+			want: []types.Dependency{
+				dep("cargo", "criterion", "0.5", true),
+				dep("cargo", "local", ""),
+				dep("cargo", "serde", "1.0"),
+				dep("cargo", "tokio", "1.35"),
+			},
+		},
+		{
+			name: "Gemfile with a test group",
+			rel:  "Gemfile",
+			data: `source "https://rubygems.org"
+gem "rails", "~> 7.1"
+gem 'pg'
+group :development, :test do
+  gem "rspec-rails"
+end
+gem "sidekiq"
+`,
+			want: []types.Dependency{
+				dep("rubygems", "pg", ""),
+				dep("rubygems", "rails", "~> 7.1"),
+				dep("rubygems", "rspec-rails", "", true),
+				dep("rubygems", "sidekiq", ""),
+			},
+		},
+		{
+			name: "pom.xml with a test scope",
+			rel:  "pom.xml",
+			data: `<project>
+  <dependencies>
+    <dependency>
+      <groupId>org.springframework.boot</groupId>
+      <artifactId>spring-boot-starter-web</artifactId>
+      <version>3.2.0</version>
+    </dependency>
+    <dependency>
+      <groupId>junit</groupId>
+      <artifactId>junit</artifactId>
+      <scope>test</scope>
+    </dependency>
+  </dependencies>
+</project>`,
+			want: []types.Dependency{
+				dep("maven", "junit:junit", "", true),
+				dep("maven", "org.springframework.boot:spring-boot-starter-web", "3.2.0"),
+			},
+		},
+		{
+			name: "build.gradle.kts in both notations",
+			rel:  "app/build.gradle.kts",
+			data: `dependencies {
+    implementation("io.ktor:ktor-server-core:2.3.7")
+    api 'com.google.guava:guava:32.1.3-jre'
+    testImplementation("org.junit.jupiter:junit-jupiter")
+}`,
+			want: []types.Dependency{
+				dep("maven", "com.google.guava:guava", "32.1.3-jre"),
+				dep("maven", "io.ktor:ktor-server-core", "2.3.7"),
+				dep("maven", "org.junit.jupiter:junit-jupiter", "", true),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseManifest(tt.rel, []byte(tt.data))
+			if !ok {
+				t.Fatalf("%s was not recognised as a manifest", tt.rel)
+			}
+			for i := range tt.want {
+				tt.want[i].Manifest = tt.rel
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseManifestIgnoresOtherFiles(t *testing.T) {
+	for _, rel := range []string{"main.go", "package-lock.json", "requirements-dev.in", "docs/Gemfile.md"} {
+		if deps, ok := parseManifest(rel, []byte("{}")); ok {
+			t.Errorf("%s parsed as a manifest: %+v", rel, deps)
+		}
+	}
+}
+
+func TestParsePEP508(t *testing.T) {
+	tests := []struct {
+		req  string
+		want types.Dependency
+		ok   bool
+	}{
+		{"django", dep("pypi", "django", ""), true},
+		{"Django>=4.2,<5", dep("pypi", "django", ">=4.2,<5"), true},
+		{"uvicorn[standard] ==0.24.0", dep("pypi", "uvicorn", "==0.24.0"), true},
+		{"zope.interface~=6.0; python_version>'3'", dep("pypi", "zope.interface", "~=6.0"), true},
+		{"", types.Dependency{}, false},
+		{">=1.0", types.Dependency{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePEP508(tt.req)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parsePEP508(%q) = %+v, %v; want %+v, %v", tt.req, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDetectFramework(t *testing.T) {
+	tests := []struct {
+		name string
+		deps []types.Dependency
+		want string
+	}{
+		{"none", nil, ""},
+		{"express", []types.Dependency{dep("npm", "express", "4")}, "Express"},
+		{"nest wins over express", []types.Dependency{dep("npm", "express", "4"), dep("npm", "@nestjs/core", "10")}, "NestJS"},
+		{"dev dependencies are ignored", []types.Dependency{dep("pypi", "django", "4", true)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFramework(tt.deps); got != tt.want {
+				t.Errorf("detectFramework = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
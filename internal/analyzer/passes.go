@@ -0,0 +1,356 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/llm"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// runPasses sends the analysis passes to the configured LLM backend
+func (a *Analyzer) runPasses(ctx context.Context) (*types.CodeAnalysis, error) {
+	analysis := a.newAnalysis()
+
+	// Inline the source for backends that cannot read the project themselves
+	sourceContext, err := a.projectContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.sourceContext = sourceContext
+
+	// Run multiple analysis passes
+	fmt.Print("🔍 Running code analysis")
+
+	// 1-3. Basic structure, database/API and performance analysis, which do
+	// not depend on each other
+	if err := a.runConcurrently(ctx, analysis, basicStructurePass, databaseAPIPass, performancePass); err != nil {
+		return nil, err
+	}
+	a.applyStaticFacts(analysis)
+
+	// 4. Resource estimation, from what the others found
+	err = a.runPass(ctx, "resource estimation", func(ctx context.Context) error {
+		return a.estimateResources(ctx, analysis)
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print(".")
+
+	fmt.Println(" ✅")
+
+	return analysis, nil
+}
+
+// withContext appends the static facts sheet and any inlined project source
+// to a pass prompt
+func (a *Analyzer) withContext(prompt string) string {
+	prompt += a.sheets()
+	if a.sourceContext != "" {
+		prompt += "\n\nThe codebase:\n\n" + a.sourceContext
+	}
+	return prompt
+}
+
+// sheets renders the static facts, routes, query sites and incremental scope
+// that withContext adds ahead of the source
+func (a *Analyzer) sheets() string {
+	var b strings.Builder
+	if sheet := factsSheet(a.facts); sheet != "" {
+		b.WriteString("\n\n" + sheet)
+	}
+	if sheet := routesSheet(a.endpoints); sheet != "" {
+		b.WriteString("\n" + sheet)
+	}
+	if sheet := querySheet(a.querySites); sheet != "" {
+		b.WriteString("\n" + sheet)
+	}
+	if sheet := a.scopeSheet(); sheet != "" {
+		b.WriteString("\n" + sheet)
+	}
+	return b.String()
+}
+
+// maxRepairAttempts is how often a response that violates its schema is sent
+// back to the model for correction before falling back to heuristics
+const maxRepairAttempts = 2
+
+// schemaError reports a response that never matched its pass schema
+type schemaError struct {
+	schema     string
+	violations []string
+}
+
+func (e *schemaError) Error() string {
+	return fmt.Sprintf("response does not match schema %s: %s", e.schema, strings.Join(e.violations, "; "))
+}
+
+// completeStructured sends a pass prompt that must be answered with JSON
+// matching the named schema and decodes the answer into result. It returns
+// the last raw response so callers can fall back to heuristic parsing when
+// the model never produces a valid document.
+func (a *Analyzer) completeStructured(ctx context.Context, name, prompt string, result interface{}) (string, error) {
+	schema, err := loadSchema(name)
+	if err != nil {
+		return "", err
+	}
+
+	request := a.withContext(fmt.Sprintf(`%s
+
+Respond ONLY with a JSON object that validates against this JSON Schema (%s).
+Do not wrap it in markdown or add any commentary.
+
+%s`, prompt, schema.ID, schema.raw))
+
+	var output string
+	var violations []string
+	for attempt := 0; attempt <= maxRepairAttempts; attempt++ {
+		output, err = llm.CompleteJSON(ctx, a.backend, request)
+		if err != nil {
+			return "", err
+		}
+
+		document := extractJSON(output)
+		var value interface{}
+		if err := json.Unmarshal([]byte(document), &value); err != nil {
+			violations = []string{fmt.Sprintf("invalid JSON: %v", err)}
+		} else {
+			violations = schema.Validate(value)
+		}
+
+		if len(violations) == 0 {
+			return output, json.Unmarshal([]byte(document), result)
+		}
+
+		// Repair: show the model its own answer and what is wrong with it,
+		// along with the request and source it answered, since each prompt
+		// stands alone
+		request = a.withContext(fmt.Sprintf(`Your previous response did not validate against the JSON Schema %s.
+
+Violations:
+- %s
+
+Previous response:
+%s
+
+It answered this request:
+
+%s
+
+Return the corrected JSON object only. The schema is:
+
+%s`, schema.ID, strings.Join(violations, "\n- "), output, prompt, schema.raw))
+	}
+
+	return output, &schemaError{schema: schema.ID, violations: violations}
+}
+
+// resourceFields are the provenance keys of ResourceMetrics
+var resourceFields = []string{
+	"resource_usage.memory_mb",
+	"resource_usage.cpu_cores",
+	"resource_usage.database_connections",
+	"resource_usage.network_mbps",
+	"resource_usage.storage_gb",
+}
+
+// setSources records the same provenance for several fields
+func setSources(analysis *types.CodeAnalysis, source string, fields ...string) {
+	for _, field := range fields {
+		analysis.SetProvenance(field, source, "")
+	}
+}
+
+// markHeuristic records that a pass fell back to heuristic parsing
+func markHeuristic(analysis *types.CodeAnalysis, pass string) {
+	analysis.LowConfidence = true
+	analysis.HeuristicPasses = append(analysis.HeuristicPasses, pass)
+}
+
+// analyzeBasicStructure identifies language, framework, and dependencies
+func (a *Analyzer) analyzeBasicStructure(ctx context.Context, analysis *types.CodeAnalysis) error {
+	prompt := `Analyze this codebase and identify:
+1. Primary programming language
+2. Web framework being used
+3. Key dependencies and libraries
+4. Number of API endpoints/routes
+5. Background job processing (if any)
+6. File upload capabilities`
+
+	var result struct {
+		Language       string   `json:"language"`
+		Framework      string   `json:"framework"`
+		Dependencies   []string `json:"dependencies"`
+		ApiEndpoints   int      `json:"api_endpoints"`
+		BackgroundJobs []string `json:"background_jobs"`
+		FileUploads    bool     `json:"file_uploads"`
+	}
+
+	output, err := a.completeStructured(ctx, "basic_structure", prompt, &result)
+	if _, invalid := err.(*schemaError); invalid {
+		// Fallback to heuristic parsing
+		a.parseBasicStructureHeuristic(output, analysis)
+		markHeuristic(analysis, "basic_structure")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	analysis.Language = result.Language
+	analysis.Framework = result.Framework
+	analysis.Dependencies = result.Dependencies
+	analysis.ApiEndpoints = result.ApiEndpoints
+	analysis.BackgroundJobs = result.BackgroundJobs
+	analysis.FileUploads = result.FileUploads
+	setSources(analysis, types.SourceLLMJSON, "language", "framework", "dependencies",
+		"api_endpoints", "background_jobs", "file_uploads")
+
+	return nil
+}
+
+// analyzeDatabaseAndAPI analyzes database usage and API patterns
+func (a *Analyzer) analyzeDatabaseAndAPI(ctx context.Context, analysis *types.CodeAnalysis) error {
+	prompt := `Analyze database and API patterns in this codebase:
+1. Count database queries/calls
+2. Identify database connection patterns
+3. Look for N+1 query problems
+4. Find caching usage (Redis, Memcached, etc.)
+5. Estimate complexity on a scale of 1-100
+
+Focus on scalability concerns and potential bottlenecks.`
+
+	var result struct {
+		DatabaseCalls     int      `json:"database_calls"`
+		ConnectionPattern string   `json:"connection_pattern"`
+		NPlusOneQueries   bool     `json:"n_plus_one_queries"`
+		CacheUsage        []string `json:"cache_usage"`
+		ComplexityScore   int      `json:"complexity_score"`
+	}
+
+	output, err := a.completeStructured(ctx, "database_api", prompt, &result)
+	if _, invalid := err.(*schemaError); invalid {
+		// Parse response using heuristics
+		analysis.DatabaseCalls = a.extractNumber(output, `(\d+).*(?:database|query)`)
+		analysis.CacheUsage = a.extractCacheUsage(output)
+		setSources(analysis, types.SourceLLMRegex, "database_calls", "cache_usage")
+
+		var found bool
+		analysis.ComplexityScore, found = a.extractComplexity(output)
+		if found {
+			analysis.SetProvenance("complexity_score", types.SourceLLMRegex, "")
+		} else {
+			analysis.SetProvenance("complexity_score", types.SourceDefault, "no score found in model output")
+		}
+
+		// Check for N+1 queries
+		analysis.Performance.HasNPlusOneQuery = strings.Contains(strings.ToLower(output), "n+1") ||
+			strings.Contains(strings.ToLower(output), "n plus one")
+		analysis.SetProvenance("performance.has_n_plus_one_query", types.SourceLLMRegex, "keyword match on \"n+1\"")
+		markHeuristic(analysis, "database_api")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	analysis.DatabaseCalls = result.DatabaseCalls
+	analysis.ComplexityScore = result.ComplexityScore
+	analysis.CacheUsage = result.CacheUsage
+	analysis.Performance.HasNPlusOneQuery = result.NPlusOneQueries
+	setSources(analysis, types.SourceLLMJSON, "database_calls", "complexity_score",
+		"cache_usage", "performance.has_n_plus_one_query")
+
+	return nil
+}
+
+// analyzePerformanceAndScaling identifies scaling bottlenecks
+func (a *Analyzer) analyzePerformanceAndScaling(ctx context.Context, analysis *types.CodeAnalysis) error {
+	prompt := `Identify scaling bottlenecks and performance issues:
+1. Database connection limits
+2. Memory-intensive operations  
+3. CPU-heavy computations
+4. Network bottlenecks
+5. Synchronous operations that should be async
+6. Large payload responses
+
+For each issue, specify type (database/cpu/memory/network) and severity (low/medium/high/critical).`
+
+	var result struct {
+		Bottlenecks   []types.Bottleneck `json:"bottlenecks"`
+		LargePayloads bool               `json:"large_payloads"`
+	}
+
+	output, err := a.completeStructured(ctx, "performance_scaling", prompt, &result)
+	if _, invalid := err.(*schemaError); invalid {
+		analysis.ScalingBottlenecks = a.extractBottlenecks(output)
+		analysis.Performance.HasLargePayloads = strings.Contains(strings.ToLower(output), "large payload")
+		setSources(analysis, types.SourceLLMRegex, "scaling_bottlenecks", "performance.has_large_payloads")
+		markHeuristic(analysis, "performance_scaling")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	analysis.ScalingBottlenecks = result.Bottlenecks
+	analysis.Performance.HasLargePayloads = result.LargePayloads
+	setSources(analysis, types.SourceLLMJSON, "scaling_bottlenecks", "performance.has_large_payloads")
+
+	return nil
+}
+
+// estimateResources calculates resource requirements
+func (a *Analyzer) estimateResources(ctx context.Context, analysis *types.CodeAnalysis) error {
+	prompt := fmt.Sprintf(`Based on this %s/%s application with %d API endpoints and %d background jobs:
+
+Estimate resource requirements for 1000 concurrent users:
+1. Memory usage in MB
+2. CPU cores needed  
+3. Database connections required
+4. Network bandwidth in Mbps
+5. Storage requirements in GB
+
+Consider the complexity score of %d and provide realistic estimates.`,
+		analysis.Language, analysis.Framework, analysis.ApiEndpoints,
+		len(analysis.BackgroundJobs), analysis.ComplexityScore)
+
+	var result types.ResourceMetrics
+
+	output, err := a.completeStructured(ctx, "resources", prompt, &result)
+	if _, invalid := err.(*schemaError); invalid {
+		// Parse resource estimates
+		analysis.ResourceUsage.MemoryMB = a.extractNumber(output, `(\d+).*MB|(\d+).*memory`)
+		analysis.ResourceUsage.CPUCores = a.extractFloat(output, `(\d+(?:\.\d+)?).*(?:core|cpu)`)
+		analysis.ResourceUsage.DatabaseConns = a.extractNumber(output, `(\d+).*(?:connection|conn)`)
+		analysis.ResourceUsage.NetworkMbps = a.extractNumber(output, `(\d+).*(?:Mbps|bandwidth)`)
+		analysis.ResourceUsage.StorageGB = a.extractNumber(output, `(\d+).*(?:GB|storage)`)
+		setSources(analysis, types.SourceLLMRegex, resourceFields...)
+		markHeuristic(analysis, "resources")
+	} else if err != nil {
+		return err
+	} else {
+		analysis.ResourceUsage = result
+		setSources(analysis, types.SourceLLMJSON, resourceFields...)
+	}
+
+	// Set defaults if parsing failed
+	a.defaultResources(analysis)
+
+	return nil
+}
+
+// defaultResources fills in memory and CPU estimates the model did not provide
+func (a *Analyzer) defaultResources(analysis *types.CodeAnalysis) {
+	if analysis.ResourceUsage.MemoryMB == 0 {
+		analysis.ResourceUsage.MemoryMB = a.estimateMemoryFromComplexity(analysis.ComplexityScore)
+		analysis.SetProvenance("resource_usage.memory_mb", types.SourceDefault, "estimated from complexity score")
+	}
+	if analysis.ResourceUsage.CPUCores == 0 {
+		analysis.ResourceUsage.CPUCores = a.estimateCPUFromEndpoints(analysis.ApiEndpoints)
+		analysis.SetProvenance("resource_usage.cpu_cores", types.SourceDefault, "estimated from endpoint count")
+	}
+}
@@ -0,0 +1,138 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// stubBackend answers prompts with reply and records them. call counts from 1.
+type stubBackend struct {
+	reply func(ctx context.Context, prompt string, call int) (string, error)
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (b *stubBackend) Name() string { return "stub" }
+func (b *stubBackend) Local() bool  { return true }
+func (b *stubBackend) Check() error { return nil }
+
+func (b *stubBackend) Complete(ctx context.Context, prompt string) (string, error) {
+	b.mu.Lock()
+	b.prompts = append(b.prompts, prompt)
+	call := len(b.prompts)
+	b.mu.Unlock()
+	return b.reply(ctx, prompt, call)
+}
+
+// replies returns a stub that gives the outputs in order, repeating the last
+func replies(outputs ...string) *stubBackend {
+	return &stubBackend{reply: func(ctx context.Context, prompt string, call int) (string, error) {
+		if call > len(outputs) {
+			call = len(outputs)
+		}
+		return outputs[call-1], nil
+	}}
+}
+
+const validResources = `{"memory_mb": 512, "cpu_cores": 0.5, "database_connections": 20, "network_mbps": 10, "storage_gb": 5}`
+
+func TestCompleteStructured(t *testing.T) {
+	tests := []struct {
+		name     string
+		outputs  []string
+		calls    int
+		wantErr  bool
+		memoryMB int
+	}{
+		{"valid at once", []string{validResources}, 1, false, 512},
+		{"fenced", []string{"```json\n" + validResources + "\n```"}, 1, false, 512},
+		{"invalid JSON, then valid", []string{`{"memory_mb": 512,`, validResources}, 2, false, 512},
+		{"schema violation, then valid", []string{`{"memory_mb": "512"}`, validResources}, 2, false, 512},
+		{"never valid", []string{"About 512 MB of memory"}, 1 + maxRepairAttempts, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := replies(tt.outputs...)
+			a := New(t.TempDir(), "proj_test", backend)
+
+			var result types.ResourceMetrics
+			output, err := a.completeStructured(context.Background(), "resources", "Estimate resources", &result)
+			var schemaErr *schemaError
+			if tt.wantErr != errors.As(err, &schemaErr) || !tt.wantErr && err != nil {
+				t.Fatalf("completeStructured error = %v, want a schema error: %v", err, tt.wantErr)
+			}
+			if len(backend.prompts) != tt.calls {
+				t.Errorf("sent %d prompts, want %d", len(backend.prompts), tt.calls)
+			}
+			if result.MemoryMB != tt.memoryMB {
+				t.Errorf("memory_mb = %d, want %d", result.MemoryMB, tt.memoryMB)
+			}
+			if want := tt.outputs[len(tt.outputs)-1]; output != want {
+				t.Errorf("output = %q, want the last response %q", output, want)
+			}
+		})
+	}
+}
+
+func TestCompleteStructuredRepairPrompt(t *testing.T) {
+	backend := replies(`Sure! {"memory_mb": 512,`, validResources)
+	a := New(t.TempDir(), "proj_test", backend)
+	a.sourceContext = "=== main.go ===\npackage main\n"
+
+	var result types.ResourceMetrics
+	if _, err := a.completeStructured(context.Background(), "resources", "Estimate resources for 1000 users", &result); err != nil {
+		t.Fatal(err)
+	}
+
+	repair := backend.prompts[1]
+	for _, want := range []string{
+		"did not validate against the JSON Schema cloudpork/resources/v1",
+		"- invalid JSON: ",
+		"Previous response:\nSure! {\"memory_mb\": 512,",
+		"Estimate resources for 1000 users",
+		`"$id": "cloudpork/resources/v1"`,
+		"The codebase:\n\n=== main.go ===",
+	} {
+		if !strings.Contains(repair, want) {
+			t.Errorf("repair prompt lacks %q:\n%s", want, repair)
+		}
+	}
+}
+
+func TestPassesFallBackToHeuristics(t *testing.T) {
+	backend := replies(`The database connection pool is a critical bottleneck.
+Exports return a large payload of every order.`)
+	a := New(t.TempDir(), "proj_test", backend)
+	analysis := a.newAnalysis()
+
+	if err := a.analyzePerformanceAndScaling(context.Background(), analysis); err != nil {
+		t.Fatal(err)
+	}
+
+	if !analysis.LowConfidence || !reflect.DeepEqual(analysis.HeuristicPasses, []string{"performance_scaling"}) {
+		t.Errorf("LowConfidence %v, HeuristicPasses %q; want the pass marked heuristic", analysis.LowConfidence, analysis.HeuristicPasses)
+	}
+	if len(analysis.ScalingBottlenecks) != 1 || analysis.ScalingBottlenecks[0].Severity != "critical" || !analysis.Performance.HasLargePayloads {
+		t.Errorf("bottlenecks %+v, large payloads %v; want the critical database bottleneck and large payloads",
+			analysis.ScalingBottlenecks, analysis.Performance.HasLargePayloads)
+	}
+	if p, _ := analysis.FieldSource("scaling_bottlenecks"); p.Source != types.SourceLLMRegex {
+		t.Errorf("scaling_bottlenecks source = %q, want %q", p.Source, types.SourceLLMRegex)
+	}
+
+	// A backend error is not a schema error and is not papered over
+	failing := &stubBackend{reply: func(context.Context, string, int) (string, error) {
+		return "", errors.New("model crashed")
+	}}
+	a = New(t.TempDir(), "proj_test", failing)
+	if err := a.analyzePerformanceAndScaling(context.Background(), a.newAnalysis()); err == nil || err.Error() != "model crashed" {
+		t.Errorf("error = %v, want the backend's", err)
+	}
+}
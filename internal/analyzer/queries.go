@@ -0,0 +1,440 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// queryPattern recognizes the call sites of one ORM or driver. A file is only
+// searched when it contains one of the markers, usually the library import.
+// The call is reported as the "call" group of the match when there is one,
+// and as the whole match otherwise.
+type queryPattern struct {
+	orm     string
+	markers []string
+	call    *regexp.Regexp
+}
+
+// goHandle matches a Go database handle and the calls chained onto it, e.g.
+// `s.db.Model(&User{}).Where("id = ?", id)`. Only receivers named like a
+// handle count, so `r.Header.Get(k)` or `scanner.Scan()` are not queries.
+const goHandle = `\b(?:db|dbx|dbpool|dbPool|tx|conn|stmt|\w*(?:DB|Db|Tx|Conn|Stmt))(?:\s*\.\w+\((?:[^()]|\([^()]*\))*\))*\s*`
+
+// queryPatterns are grouped by the languages whose files they apply to.
+// ActiveRecord has no import to look for; its pattern is built from the
+// project's models by activeRecordPattern.
+var queryPatterns = map[string][]queryPattern{
+	"Go": {
+		{"GORM", []string{"gorm.io/gorm", "github.com/jinzhu/gorm"},
+			regexp.MustCompile(goHandle + `\.(?P<call>Find|First|Last|Take|FirstOrCreate|FirstOrInit|Create|CreateInBatches|Save|Updates?|UpdateColumns?|Delete|Raw|Exec|Pluck|Count|Scan)\(`)},
+		{"database/sql", []string{`"database/sql"`, "github.com/jmoiron/sqlx", "github.com/jackc/pgx"},
+			regexp.MustCompile(goHandle + `\.(?P<call>Query|QueryRow|QueryContext|QueryRowContext|Queryx|QueryRowx|Exec|ExecContext|NamedExec|NamedExecContext|Get|GetContext|Select|SelectContext)\(`)},
+	},
+	"Python": {
+		{"Django ORM", []string{"django"},
+			regexp.MustCompile(`\.objects\.(all|filter|get|exclude|create|update|delete|get_or_create|update_or_create|count|aggregate|annotate|values|bulk_create)\(`)},
+		{"SQLAlchemy", []string{"sqlalchemy", "flask_sqlalchemy", "sqlmodel"},
+			regexp.MustCompile(`\b(?:session|db\.session|Session)\.(query|execute|get|scalars|scalar|add|merge|delete)\(|\.query\.(filter|filter_by|get|all|first)\(`)},
+	},
+	"JavaScript": {
+		{"Prisma", []string{"@prisma/client", "prisma."},
+			regexp.MustCompile(`\bprisma\.\w+\.(findMany|findUnique|findUniqueOrThrow|findFirst|create|createMany|update|updateMany|upsert|delete|deleteMany|count|aggregate|groupBy)\(|\bprisma\.\$(queryRaw|executeRaw)`)},
+		{"Sequelize", []string{"sequelize"},
+			regexp.MustCompile(`\b[A-Z]\w*\.(findAll|findOne|findByPk|findOrCreate|findAndCountAll|create|bulkCreate|update|destroy|count)\(|\bsequelize\.query\(`)},
+	},
+	"Java": {
+		{"JPA", []string{"javax.persistence", "jakarta.persistence", "org.springframework.data"},
+			regexp.MustCompile(`\b(?:entityManager|em)\.(find|persist|merge|remove|createQuery|createNativeQuery)\(|\b\w+Repository\.(find\w*|save\w*|delete\w*|count\w*|exists\w*|getReferenceById)\(`)},
+	},
+}
+
+// queryLanguages maps the extensions searched for query sites to a pattern group
+var queryLanguages = map[string]string{
+	".go":   "Go",
+	".py":   "Python",
+	".rb":   "Ruby",
+	".js":   "JavaScript",
+	".mjs":  "JavaScript",
+	".cjs":  "JavaScript",
+	".ts":   "JavaScript",
+	".java": "Java",
+	".kt":   "Java",
+}
+
+// activeRecordMethods are the ActiveRecord class methods that query
+const activeRecordMethods = `find|find_by|find_by!|where|all|create|create!|update|update_all|destroy|destroy_all|first|last|pluck|count|includes|joins|find_each|exists\?`
+
+// rubyModel matches a class declaration and its superclass
+var rubyModel = regexp.MustCompile(`^\s*class\s+([A-Z][\w:]*)\s*<\s*([A-Z][\w:]*)`)
+
+// activeRecordPattern returns the ActiveRecord pattern for a project's model
+// classes, or nil when it has none. superclasses maps each Ruby class of the
+// project to its superclass; models are the classes that descend from
+// ApplicationRecord or ActiveRecord::Base.
+func activeRecordPattern(superclasses map[string]string) *queryPattern {
+	var names []string
+	for class := range superclasses {
+		seen := map[string]bool{}
+		for c := class; c != "" && !seen[c]; c = superclasses[c] {
+			seen[c] = true
+			if parent := superclasses[c]; parent == "ApplicationRecord" || parent == "ActiveRecord::Base" {
+				names = append(names, regexp.QuoteMeta(class))
+				// Code inside the model's namespace refers to it unqualified
+				if i := strings.LastIndex(class, "::"); i >= 0 {
+					names = append(names, regexp.QuoteMeta(class[i+2:]))
+				}
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	return &queryPattern{
+		orm: "ActiveRecord",
+		call: regexp.MustCompile(`(?:^|[^\w:])(?P<call>(?:` + strings.Join(names, "|") + `)\.(?:` +
+			activeRecordMethods + `))(?:[^\w?!]|$)`),
+	}
+}
+
+// Loop openers. Iterator callbacks (forEach, map, each) count as loops since
+// they run the query once per item; only calls inside the callback do.
+// Predicates such as filter and a fixed Promise.all([...]) are not loops.
+var (
+	braceLoop  = regexp.MustCompile(`^\s*(?:for|while)\b|\.(?:forEach|map|flatMap|each)\s*\(`)
+	pythonLoop = regexp.MustCompile(`^\s*(?:async\s+)?(?:for|while)\b.*:\s*(?:#.*)?$`)
+	pythonComp = regexp.MustCompile(`[\[({].*\bfor\b.+\bin\b`)
+	rubyLoop   = regexp.MustCompile(`\.(?:each|each_with_index|each_with_object|map|flat_map|find_each|select|collect|times)\b.*(?:\bdo\b|\{)|^\s*(?:for|while|until)\b|\bloop\s+do\b`)
+)
+
+// Function declarations, used to decide whether a query is still inside the
+// handler of the route declared above it
+var functionStarts = map[string]*regexp.Regexp{
+	"Go":         regexp.MustCompile(`^func\b`),
+	"Python":     regexp.MustCompile(`^\s*(?:async\s+)?def\s`),
+	"Ruby":       regexp.MustCompile(`^\s*def\s`),
+	"JavaScript": regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\b|^(?:export\s+)?(?:const|let)\s+\w+\s*=\s*(?:async\s*)?(?:\(|function\b)`),
+	"Java":       regexp.MustCompile(`^\s*(?:public|protected|private)\s[^=;]*\)\s*(?:throws\s[^{]*)?\{?\s*$`),
+}
+
+// decoratedHandlers are languages whose route declaration (a decorator or
+// annotation) sits directly above the handler's own declaration
+var decoratedHandlers = map[string]bool{
+	"Python": true,
+	"Java":   true,
+}
+
+// FindQuerySites walks the project for ORM and database driver calls and marks
+// those made inside a loop as N+1 candidates. Each site is attributed to the
+// route whose handler encloses it, when that handler is declared inline.
+func FindQuerySites(root string, endpoints []types.Endpoint) ([]types.QuerySite, error) {
+	byFile := make(map[string][]types.Endpoint)
+	for _, e := range endpoints {
+		byFile[e.File] = append(byFile[e.File], e)
+	}
+
+	// Ruby files are searched once every model class is known
+	type rubyFile struct {
+		rel  string
+		data []byte
+	}
+	var rubyFiles []rubyFile
+	superclasses := make(map[string]string)
+
+	var sites []types.QuerySite
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		language, ok := queryLanguages[strings.ToLower(path.Ext(rel))]
+		if !ok || info.Size() > maxScannedFileBytes || isTestFile(rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+
+		if language == "Ruby" {
+			forEachLine(data, func(n int, line string) {
+				if m := rubyModel.FindStringSubmatch(line); m != nil {
+					superclasses[m[1]] = m[2]
+				}
+			})
+			rubyFiles = append(rubyFiles, rubyFile{rel, data})
+			return nil
+		}
+		sites = append(sites, findFileQuerySites(queryPatterns[language], language, rel, data, byFile[rel])...)
+		return nil
+	})
+
+	if activeRecord := activeRecordPattern(superclasses); activeRecord != nil {
+		patterns := []queryPattern{*activeRecord}
+		for _, f := range rubyFiles {
+			// ActiveRecord calls are only meaningful in a Rails app's code
+			if strings.HasPrefix(f.rel, "app/") || strings.HasPrefix(f.rel, "lib/") {
+				sites = append(sites, findFileQuerySites(patterns, "Ruby", f.rel, f.data, byFile[f.rel])...)
+			}
+		}
+	}
+
+	return sites, err
+}
+
+// findFileQuerySites finds the query sites of a single file. Patterns with
+// markers are only used when the file contains one of them.
+func findFileQuerySites(candidates []queryPattern, language, rel string, data []byte, endpoints []types.Endpoint) []types.QuerySite {
+	src := string(data)
+	var patterns []queryPattern
+	for _, qp := range candidates {
+		if len(qp.markers) == 0 {
+			patterns = append(patterns, qp)
+			continue
+		}
+		for _, marker := range qp.markers {
+			if strings.Contains(src, marker) {
+				patterns = append(patterns, qp)
+				break
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	loops := newLoopTracker(language)
+	var functionLines []int
+	var sites []types.QuerySite
+	// A call chain continued over several lines, as in
+	//   db.Where("active").
+	//       Find(&users)
+	// is matched as one line, ending with the current one
+	chain := ""
+
+	forEachLine(data, func(n int, line string) {
+		if start := functionStarts[language]; start != nil && start.MatchString(line) {
+			functionLines = append(functionLines, n)
+		}
+
+		loopFrom := loops.advance(line)
+		if isCommentLine(line) {
+			return
+		}
+
+		code := strings.TrimSpace(stripStrings(line))
+		prefix := ""
+		if strings.HasPrefix(code, ".") || strings.HasSuffix(chain, ".") {
+			prefix = chain
+		}
+		// Match against the line itself, not its blanked copy, so string
+		// arguments stay part of the call; offsets agree as stripStrings keeps
+		// lengths
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		logical := prefix + strings.TrimSpace(line)
+		chain = prefix + code
+
+		for _, qp := range patterns {
+			m := qp.call.FindStringSubmatchIndex(logical)
+			// Calls ending on an earlier line were reported there
+			if m == nil || m[1] <= len(prefix) {
+				continue
+			}
+			call := strings.TrimRight(strings.TrimLeft(logical[m[0]:m[1]], "."), "(")
+			if g := qp.call.SubexpIndex("call"); g > 0 && m[2*g] >= 0 {
+				call = logical[m[2*g]:m[2*g+1]]
+			}
+			column := indent + m[0] - len(prefix)
+			if column < indent {
+				column = indent
+			}
+			sites = append(sites, types.QuerySite{
+				ORM:    qp.orm,
+				Call:   call,
+				File:   rel,
+				Line:   n,
+				InLoop: loopFrom >= 0 && column >= loopFrom,
+			})
+			break // One site per line
+		}
+	})
+
+	for i := range sites {
+		sites[i].Endpoint = enclosingEndpoint(language, sites[i].Line, endpoints, functionLines)
+	}
+	return sites
+}
+
+// isCommentLine reports whether line is a whole-line comment
+func isCommentLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") ||
+		strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "/*")
+}
+
+// enclosingEndpoint returns "METHOD /path" for the nearest route declared above
+// line, provided no other function declaration lies between them
+func enclosingEndpoint(language string, line int, endpoints []types.Endpoint, functionLines []int) string {
+	var nearest *types.Endpoint
+	for i := range endpoints {
+		if endpoints[i].Line <= line && (nearest == nil || endpoints[i].Line > nearest.Line) {
+			nearest = &endpoints[i]
+		}
+	}
+	if nearest == nil {
+		return ""
+	}
+
+	between := 0
+	for _, fl := range functionLines {
+		if fl > nearest.Line && fl <= line {
+			between++
+		}
+	}
+	allowed := 0
+	if decoratedHandlers[language] {
+		allowed = 1 // The handler's own declaration
+	}
+	if between > allowed {
+		return ""
+	}
+
+	return nearest.Method + " " + nearest.Path
+}
+
+// loopTracker follows loop nesting line by line, by braces for C-like
+// languages and by indentation for Python and Ruby
+type loopTracker struct {
+	language string
+	depth    int   // Current brace depth
+	open     []int // Brace depth or indentation at which each open loop started
+}
+
+func newLoopTracker(language string) *loopTracker {
+	return &loopTracker{language: language}
+}
+
+// advance consumes line and returns the column from which it is inside a loop
+// body, or -1. The column is 0 inside a loop opened on an earlier line and
+// where the loop starts when the line is itself a loop header, as in
+// `items.map(i => db.find(i))`, so a call before the loop is not counted.
+func (t *loopTracker) advance(line string) int {
+	switch t.language {
+	case "Python", "Ruby":
+		return t.advanceIndented(line)
+	default:
+		return t.advanceBraced(line)
+	}
+}
+
+func (t *loopTracker) advanceBraced(line string) int {
+	code := stripStrings(line)
+	loc := braceLoop.FindStringIndex(code)
+	isLoop := loc != nil
+	from := -1
+	if len(t.open) > 0 {
+		from = 0
+	} else if isLoop {
+		from = loc[0]
+	}
+
+	if isLoop {
+		t.open = append(t.open, t.depth)
+	}
+	t.depth += strings.Count(code, "{") + strings.Count(code, "(") -
+		strings.Count(code, "}") - strings.Count(code, ")")
+	if t.depth < 0 {
+		t.depth = 0
+	}
+	// A loop ends once the depth falls back to where it started
+	for len(t.open) > 0 && t.depth <= t.open[len(t.open)-1] {
+		t.open = t.open[:len(t.open)-1]
+	}
+
+	return from
+}
+
+func (t *loopTracker) advanceIndented(line string) int {
+	if strings.TrimSpace(line) == "" {
+		if len(t.open) > 0 {
+			return 0
+		}
+		return -1
+	}
+
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	for len(t.open) > 0 && indent <= t.open[len(t.open)-1] {
+		t.open = t.open[:len(t.open)-1]
+	}
+
+	var loc []int
+	inline := false
+	if t.language == "Python" {
+		loc = pythonLoop.FindStringIndex(line)
+		if comp := pythonComp.FindStringIndex(line); comp != nil {
+			loc, inline = comp, true
+		}
+	} else {
+		loc = rubyLoop.FindStringIndex(line)
+		// A one-line block like `ids.map { |id| User.find(id) }`
+		inline = loc != nil && strings.Contains(line, "}")
+	}
+
+	from := -1
+	switch {
+	case len(t.open) > 0:
+		from = 0
+	case loc != nil:
+		from = loc[0]
+	}
+	if loc != nil && !inline {
+		t.open = append(t.open, indent)
+	}
+	return from
+}
+
+// stringLiteral matches simple single-line string literals
+var stringLiteral = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`[^`]*`")
+
+// stripStrings blanks out string literals and trailing comments so their
+// braces and parentheses are not counted. Literals keep their length so
+// offsets into the result are offsets into line.
+func stripStrings(line string) string {
+	code := stringLiteral.ReplaceAllStringFunc(line, func(lit string) string {
+		return lit[:1] + strings.Repeat(" ", len(lit)-2) + lit[len(lit)-1:]
+	})
+	if i := strings.Index(code, "//"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// maxFactsQuerySites bounds the query site list in the facts sheet
+const maxFactsQuerySites = 100
+
+// querySheet renders query sites for inclusion in LLM prompts
+func querySheet(sites []types.QuerySite) string {
+	if len(sites) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "- Database call sites (%d), from ORM and driver calls; database_calls must equal this count:\n", len(sites))
+	for i, s := range sites {
+		if i == maxFactsQuerySites {
+			fmt.Fprintf(&sb, "  ... and %d more\n", len(sites)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "  %s %s (%s:%d)", s.ORM, s.Call, s.File, s.Line)
+		if s.InLoop {
+			sb.WriteString(" inside a loop")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
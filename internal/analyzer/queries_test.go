@@ -0,0 +1,249 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// site is the part of a QuerySite the tests compare
+type site struct {
+	Call   string
+	Line   int
+	InLoop bool
+}
+
+func sitesOf(found []types.QuerySite) []site {
+	var sites []site
+	for _, s := range found {
+		sites = append(sites, site{s.Call, s.Line, s.InLoop})
+	}
+	return sites
+}
+
+func TestFindFileQuerySites(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		want     []site
+	}{
+		{
+			name:     "gorm call in a range loop",
+			language: "Go",
+			src: `import "gorm.io/gorm"
+
+func load(db *gorm.DB, ids []int) {
+	for _, id := range ids {
+		db.First(&u, id)
+	}
+}`,
+			want: []site{{"First", 5, true}},
+		},
+		{
+			name:     "gorm chain on one line and over two",
+			language: "Go",
+			src: `import "gorm.io/gorm"
+
+func list(s *Service) {
+	s.db.Model(&User{}).Where("active = ?", true).Find(&users)
+	s.db.Where("name LIKE ?", "%a%").
+		Order("name").
+		Find(&others)
+}`,
+			want: []site{{"Find", 4, false}, {"Find", 7, false}},
+		},
+		{
+			name:     "bufio scanner and os.Create are not gorm calls",
+			language: "Go",
+			src: `import "gorm.io/gorm"
+
+func read(f io.Reader) {
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		out, _ := os.Create(scanner.Text())
+		out.Close()
+	}
+}`,
+			want: nil,
+		},
+		{
+			name:     "header lookups in a loop are not database/sql calls",
+			language: "Go",
+			src: `import "database/sql"
+
+func copyHeaders(r *http.Request, keys []string) {
+	for _, k := range keys {
+		v := r.Header.Get(k)
+		cache.Get(v)
+		_ = v
+	}
+}`,
+			want: nil,
+		},
+		{
+			name:     "database/sql handles",
+			language: "Go",
+			src: `import "database/sql"
+
+func (s *Store) names(ids []int) {
+	rows, _ := s.db.QueryContext(ctx, "SELECT name FROM users")
+	for _, id := range ids {
+		tx.QueryRow("SELECT 1 WHERE id = $1", id)
+		readDB.Exec("UPDATE t SET x = 1")
+	}
+	rows.Close()
+}`,
+			want: []site{{"QueryContext", 4, false}, {"QueryRow", 6, true}, {"Exec", 7, true}},
+		},
+		{
+			name:     "no marker, no sites",
+			language: "Go",
+			src: `func f() {
+	db.Find(&users)
+}`,
+			want: nil,
+		},
+		{
+			name:     "query inside a map callback",
+			language: "JavaScript",
+			src: `import { PrismaClient } from '@prisma/client'
+const users = await Promise.all(ids.map(id => prisma.user.findUnique({ where: { id } })))`,
+			want: []site{{"prisma.user.findUnique", 2, true}},
+		},
+		{
+			name:     "query before a map call runs once",
+			language: "JavaScript",
+			src: `import { PrismaClient } from '@prisma/client'
+const names = (await prisma.user.findMany()).map(u => u.name)`,
+			want: []site{{"prisma.user.findMany", 2, false}},
+		},
+		{
+			name:     "filter and Promise.all are not loops",
+			language: "JavaScript",
+			src: `import { PrismaClient } from '@prisma/client'
+const [a, b] = await Promise.all([prisma.user.count(), prisma.post.count()])
+const active = users.filter(u => prisma.audit.count())`,
+			want: []site{{"prisma.user.count", 2, false}, {"prisma.audit.count", 3, false}},
+		},
+		{
+			name:     "prisma call continued on the next line",
+			language: "JavaScript",
+			src: `import { PrismaClient } from '@prisma/client'
+for (const id of ids) {
+  await prisma.user
+    .findUnique({ where: { id } })
+}`,
+			want: []site{{"prisma.user.findUnique", 4, true}},
+		},
+		{
+			name:     "django query in a for loop",
+			language: "Python",
+			src: `from django.db import models
+
+def view(request):
+    for order in orders:
+        Customer.objects.get(id=order.customer_id)
+    return Order.objects.all()`,
+			want: []site{{"objects.get", 5, true}, {"objects.all", 6, false}},
+		},
+		{
+			name:     "comprehension",
+			language: "Python",
+			src: `import sqlalchemy
+
+users = [session.get(User, i) for i in ids]`,
+			want: []site{{"session.get", 3, true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sitesOf(findFileQuerySites(queryPatterns[tt.language], tt.language, "app/file", []byte(tt.src), nil))
+			if !equalSites(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindQuerySitesActiveRecord(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/models/application_record.rb": "class ApplicationRecord < ActiveRecord::Base\nend\n",
+		"app/models/user.rb":               "class User < ApplicationRecord\nend\n",
+		"app/models/admin.rb":              "class Admin < User\nend\n",
+		"app/controllers/users_controller.rb": `class UsersController < ApplicationController
+  def index
+    @users = User.where(active: true)
+    @users.each do |user|
+      Admin.find_by(email: user.email)
+      File.exists?(user.avatar_path)
+      Rails.cache.fetch(user.id)
+    end
+  end
+end
+`,
+		"lib/tasks/cleanup.rb": "Dir.glob('*').each { |f| File.exists?(f) }\n",
+	})
+
+	found, err := FindQuerySites(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []site{{"User.where", 3, false}, {"Admin.find_by", 5, true}}
+	if got := sitesOf(found); !equalSites(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFindQuerySitesNoRubyModels(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"lib/tool.rb": "ARGV.each do |f|\n  File.exists?(f)\n  Config.find(f)\nend\n",
+	})
+
+	found, err := FindQuerySites(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Errorf("got %+v, want no sites without ActiveRecord models", sitesOf(found))
+	}
+}
+
+func TestStripStringsKeepsOffsets(t *testing.T) {
+	line := `db.Where("a = '{'", x).Find(&u) // trailing {`
+	got := stripStrings(line)
+	if want := `db.Where("       ", x).Find(&u) `; got != want {
+		t.Errorf("stripStrings = %q, want %q", got, want)
+	}
+}
+
+func equalSites(a, b []site) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFiles creates files under root from a map of relative path to content
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
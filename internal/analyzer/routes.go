@@ -0,0 +1,452 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// routeExtractor finds the routes declared in one source file
+type routeExtractor func(rel string, data []byte) []types.Endpoint
+
+// routeExtractors are keyed by file extension
+var routeExtractors = map[string]routeExtractor{
+	".js":   extractExpressRoutes,
+	".mjs":  extractExpressRoutes,
+	".cjs":  extractExpressRoutes,
+	".ts":   extractExpressRoutes,
+	".py":   extractPythonRoutes,
+	".go":   extractGoRoutes,
+	".java": extractSpringRoutes,
+	".kt":   extractSpringRoutes,
+	".rb":   extractRailsRoutes,
+}
+
+// ExtractRoutes walks the project and returns every API route the framework
+// extractors recognize, sorted by file and line
+func ExtractRoutes(root string) ([]types.Endpoint, error) {
+	var endpoints []types.Endpoint
+
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		extract, ok := routeExtractors[strings.ToLower(path.Ext(rel))]
+		if !ok || info.Size() > maxScannedFileBytes || isTestFile(rel) {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+
+		endpoints = append(endpoints, extract(rel, data)...)
+		return nil
+	})
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].File != endpoints[j].File {
+			return endpoints[i].File < endpoints[j].File
+		}
+		return endpoints[i].Line < endpoints[j].Line
+	})
+
+	return endpoints, err
+}
+
+// maxFactsEndpoints bounds the route list in the facts sheet
+const maxFactsEndpoints = 200
+
+// routesSheet renders extracted routes for inclusion in LLM prompts
+func routesSheet(endpoints []types.Endpoint) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "- API routes (%d), from route declarations; api_endpoints must equal this count:\n", len(endpoints))
+	for i, e := range endpoints {
+		if i == maxFactsEndpoints {
+			fmt.Fprintf(&sb, "  ... and %d more\n", len(endpoints)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "  %s %s (%s:%d)\n", e.Method, e.Path, e.File, e.Line)
+	}
+	return sb.String()
+}
+
+// isTestFile reports whether rel looks like a test, whose routes are fixtures
+func isTestFile(rel string) bool {
+	base := path.Base(rel)
+	return strings.HasSuffix(base, "_test.go") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_spec.rb") ||
+		strings.Contains("/"+rel, "/test/") || strings.Contains("/"+rel, "/tests/") ||
+		strings.Contains("/"+rel, "/spec/")
+}
+
+// forEachLine calls fn with each line of data and its 1-based number
+func forEachLine(data []byte, fn func(n int, line string)) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxScannedFileBytes)
+	n := 0
+	for scanner.Scan() {
+		n++
+		fn(n, scanner.Text())
+	}
+}
+
+// joinRoute joins a prefix and a route path into a single absolute path
+func joinRoute(prefix, p string) string {
+	joined := strings.TrimRight(prefix, "/") + "/" + strings.TrimLeft(p, "/")
+	if len(joined) > 1 {
+		joined = strings.TrimRight(joined, "/")
+	}
+	return joined
+}
+
+// Express (and Express-like routers: Koa Router, Fastify shorthand)
+var (
+	expressRoute = regexp.MustCompile(`\b(\w+)\.(get|post|put|patch|delete|options|head|all)\(\s*['"` + "`" + `](/[^'"` + "`" + `]*)['"` + "`" + `]`)
+	// An app or router created in the file, e.g. `const api = express.Router()`
+	expressBinding = regexp.MustCompile(`\b(?:const|let|var)\s+(\w+)\s*(?::\s*[\w.<>]+\s*)?=\s*(?:express(?:\.Router)?|Router|fastify|Fastify|new\s+(?:Router|KoaRouter))\s*\(`)
+	// Imports of the routers above; a file without one only makes requests
+	expressImport = regexp.MustCompile(`(?:require\(\s*|from\s+)['"](?:express|@koa/router|koa-router|fastify)['"]`)
+)
+
+// expressReceivers are the names apps and routers conventionally go by, for
+// those not created in the file itself
+var expressReceivers = map[string]bool{"app": true, "router": true}
+
+// httpClients are request libraries whose get/post calls take URL paths too
+var httpClients = map[string]bool{
+	"axios": true, "got": true, "ky": true, "superagent": true, "request": true,
+	"needle": true, "http": true, "https": true, "fetch": true, "client": true,
+	"httpClient": true, "supertest": true,
+}
+
+func extractExpressRoutes(rel string, data []byte) []types.Endpoint {
+	if !expressImport.Match(data) {
+		return nil
+	}
+
+	receivers := make(map[string]bool)
+	for name := range expressReceivers {
+		receivers[name] = true
+	}
+	for _, m := range expressBinding.FindAllSubmatch(data, -1) {
+		receivers[string(m[1])] = true
+	}
+
+	var endpoints []types.Endpoint
+	forEachLine(data, func(n int, line string) {
+		for _, m := range expressRoute.FindAllStringSubmatch(line, -1) {
+			if httpClients[m[1]] || !receivers[m[1]] {
+				continue
+			}
+			method := strings.ToUpper(m[2])
+			if method == "ALL" {
+				method = "ANY"
+			}
+			endpoints = append(endpoints, types.Endpoint{Method: method, Path: m[3], File: rel, Line: n, Framework: "Express"})
+		}
+	})
+	return endpoints
+}
+
+// Python: FastAPI decorators, Flask decorators and Django URLconfs
+var (
+	fastAPIRoute = regexp.MustCompile(`^\s*@(\w+)\.(get|post|put|patch|delete|options|head)\(\s*['"]([^'"]*)['"]`)
+	flaskRoute   = regexp.MustCompile(`^\s*@(\w+)\.route\(\s*['"]([^'"]*)['"](.*)`)
+	flaskMethods = regexp.MustCompile(`methods\s*=\s*[\[(]([^\])]*)[\])]`)
+	djangoRoute  = regexp.MustCompile(`\b(?:path|re_path|url)\(\s*r?['"]([^'"]*)['"]`)
+	routerPrefix = regexp.MustCompile(`(\w+)\s*=\s*APIRouter\(.*prefix\s*=\s*['"]([^'"]*)['"]`)
+	quotedWord   = regexp.MustCompile(`['"](\w+)['"]`)
+)
+
+func extractPythonRoutes(rel string, data []byte) []types.Endpoint {
+	var endpoints []types.Endpoint
+	prefixes := make(map[string]string)
+	isURLConf := path.Base(rel) == "urls.py"
+
+	forEachLine(data, func(n int, line string) {
+		if m := routerPrefix.FindStringSubmatch(line); m != nil {
+			prefixes[m[1]] = m[2]
+		}
+
+		if m := fastAPIRoute.FindStringSubmatch(line); m != nil {
+			endpoints = append(endpoints, types.Endpoint{
+				Method:    strings.ToUpper(m[2]),
+				Path:      joinRoute(prefixes[m[1]], m[3]),
+				File:      rel,
+				Line:      n,
+				Framework: "FastAPI",
+			})
+			return
+		}
+
+		if m := flaskRoute.FindStringSubmatch(line); m != nil {
+			methods := []string{"GET"}
+			if mm := flaskMethods.FindStringSubmatch(m[3]); mm != nil {
+				methods = nil
+				for _, q := range quotedWord.FindAllStringSubmatch(mm[1], -1) {
+					methods = append(methods, strings.ToUpper(q[1]))
+				}
+			}
+			for _, method := range methods {
+				endpoints = append(endpoints, types.Endpoint{Method: method, Path: joinRoute("", m[2]), File: rel, Line: n, Framework: "Flask"})
+			}
+			return
+		}
+
+		if isURLConf {
+			if m := djangoRoute.FindStringSubmatch(line); m != nil && !strings.Contains(line, "include(") {
+				p := strings.TrimSuffix(strings.TrimPrefix(m[1], "^"), "$")
+				endpoints = append(endpoints, types.Endpoint{Method: "ANY", Path: joinRoute("", p), File: rel, Line: n, Framework: "Django"})
+			}
+		}
+	})
+	return endpoints
+}
+
+// Go: Gin, Echo, Chi and net/http
+var (
+	goMethodRoute = regexp.MustCompile(`\b(\w+)\.(GET|POST|PUT|PATCH|DELETE|OPTIONS|HEAD|Any|Get|Post|Put|Patch|Delete|Options|Head)\(\s*"([^"]*)"`)
+	goGroup       = regexp.MustCompile(`(\w+)\s*:?=\s*(\w+)\.(?:Group|Route)\(\s*"([^"]*)"`)
+	goHandleFunc  = regexp.MustCompile(`\b(\w+)\.(?:HandleFunc|Handle)\(\s*"([^"]*)"`)
+	goMuxMethods  = regexp.MustCompile(`\.Methods\(([^)]*)\)`)
+)
+
+func extractGoRoutes(rel string, data []byte) []types.Endpoint {
+	src := string(data)
+	framework := "net/http"
+	switch {
+	case strings.Contains(src, "github.com/gin-gonic/gin"):
+		framework = "Gin"
+	case strings.Contains(src, "github.com/labstack/echo"):
+		framework = "Echo"
+	case strings.Contains(src, "github.com/go-chi/chi"):
+		framework = "Chi"
+	case strings.Contains(src, "github.com/gorilla/mux"):
+		framework = "Gorilla Mux"
+	case !strings.Contains(src, `"net/http"`):
+		return nil
+	}
+
+	var endpoints []types.Endpoint
+	prefixes := make(map[string]string)
+
+	forEachLine(data, func(n int, line string) {
+		// Route groups carry their prefix to the routes registered on them
+		if m := goGroup.FindStringSubmatch(line); m != nil {
+			prefixes[m[1]] = joinRoute(prefixes[m[2]], m[3])
+			return
+		}
+
+		// Grouped routes may be relative; top-level ones must be absolute paths
+		if m := goMethodRoute.FindStringSubmatch(line); m != nil && (strings.HasPrefix(m[3], "/") || prefixes[m[1]] != "") {
+			endpoints = append(endpoints, types.Endpoint{Method: strings.ToUpper(m[2]), Path: joinRoute(prefixes[m[1]], m[3]), File: rel, Line: n, Framework: framework})
+			return
+		}
+
+		if m := goHandleFunc.FindStringSubmatch(line); m != nil {
+			method, p := "ANY", m[2]
+			// Go 1.22 patterns: "GET /users/{id}"
+			if i := strings.Index(p, " "); i > 0 {
+				method, p = p[:i], strings.TrimSpace(p[i+1:])
+			}
+			if mm := goMuxMethods.FindStringSubmatch(line); mm != nil {
+				method = strings.ToUpper(strings.Trim(strings.Split(mm[1], ",")[0], `" `))
+			}
+			if strings.HasPrefix(p, "/") {
+				endpoints = append(endpoints, types.Endpoint{Method: method, Path: joinRoute(prefixes[m[1]], p), File: rel, Line: n, Framework: framework})
+			}
+		}
+	})
+	return endpoints
+}
+
+// Spring MVC / WebFlux annotations
+var (
+	springMapping = regexp.MustCompile(`@(Get|Post|Put|Patch|Delete|Request)Mapping\b(?:\(([^)]*)\))?`)
+	springPath    = regexp.MustCompile(`(?:^|value\s*=\s*|path\s*=\s*|\{)\s*"([^"]*)"`)
+	springMethod  = regexp.MustCompile(`RequestMethod\.(\w+)`)
+	javaClass     = regexp.MustCompile(`\b(?:class|interface)\s+\w+`)
+)
+
+func extractSpringRoutes(rel string, data []byte) []types.Endpoint {
+	var endpoints []types.Endpoint
+	classPrefix := ""
+	pendingPrefix := ""
+	seenClass := false
+
+	forEachLine(data, func(n int, line string) {
+		trimmed := strings.TrimSpace(line)
+		if javaClass.MatchString(trimmed) && !strings.HasPrefix(trimmed, "//") {
+			// A @RequestMapping directly above the class is the controller prefix
+			classPrefix = pendingPrefix
+			pendingPrefix = ""
+			seenClass = true
+			return
+		}
+
+		m := springMapping.FindStringSubmatch(trimmed)
+		if m == nil {
+			return
+		}
+
+		p := ""
+		if pm := springPath.FindStringSubmatch(strings.TrimSpace(m[2])); pm != nil {
+			p = pm[1]
+		}
+
+		if m[1] == "Request" && !seenClass {
+			pendingPrefix = p
+			return
+		}
+
+		method := strings.ToUpper(m[1])
+		if m[1] == "Request" {
+			method = "ANY"
+			if mm := springMethod.FindStringSubmatch(m[2]); mm != nil {
+				method = mm[1]
+			}
+		}
+		endpoints = append(endpoints, types.Endpoint{Method: method, Path: joinRoute(classPrefix, p), File: rel, Line: n, Framework: "Spring"})
+	})
+	return endpoints
+}
+
+// Rails config/routes.rb
+var (
+	railsVerb      = regexp.MustCompile(`^\s*(get|post|put|patch|delete|match)\s+['"]([^'"]+)['"]`)
+	railsResources = regexp.MustCompile(`^\s*(resources|resource)\s+:(\w+)(.*)`)
+	railsScope     = regexp.MustCompile(`^\s*(?:namespace\s+:(\w+)|scope\s+(?:path:\s*)?['"]([^'"]*)['"]).*\bdo\b`)
+	railsOnly      = regexp.MustCompile(`only:\s*\[([^\]]*)\]|only:\s*:(\w+)`)
+	railsExcept    = regexp.MustCompile(`except:\s*\[([^\]]*)\]|except:\s*:(\w+)`)
+	rubySymbol     = regexp.MustCompile(`:(\w+)`)
+)
+
+// railsActions are the routes generated by `resources`, in Rails' order
+var railsActions = []struct {
+	action, method, suffix string
+	member                 bool
+}{
+	{"index", "GET", "", false},
+	{"create", "POST", "", false},
+	{"new", "GET", "/new", false},
+	{"edit", "GET", "/edit", true},
+	{"show", "GET", "", true},
+	{"update", "PATCH", "", true},
+	{"destroy", "DELETE", "", true},
+}
+
+func extractRailsRoutes(rel string, data []byte) []types.Endpoint {
+	if path.Base(rel) != "routes.rb" {
+		return nil
+	}
+
+	var endpoints []types.Endpoint
+	var scopes []string // One entry per open `do` block; "" for non-scope blocks
+
+	prefix := func() string {
+		return joinRoute("", strings.Join(scopes, "/"))
+	}
+
+	forEachLine(data, func(n int, line string) {
+		trimmed := strings.TrimSpace(line)
+		opensBlock := strings.HasSuffix(trimmed, " do") || strings.Contains(trimmed, " do |")
+
+		switch {
+		case trimmed == "end":
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+			return
+
+		case railsScope.MatchString(trimmed):
+			m := railsScope.FindStringSubmatch(trimmed)
+			scopes = append(scopes, strings.Trim(m[1]+m[2], "/"))
+			return
+
+		case railsVerb.MatchString(trimmed):
+			m := railsVerb.FindStringSubmatch(trimmed)
+			method := strings.ToUpper(m[1])
+			if method == "MATCH" {
+				method = "ANY"
+			}
+			endpoints = append(endpoints, types.Endpoint{Method: method, Path: joinRoute(prefix(), m[2]), File: rel, Line: n, Framework: "Rails"})
+
+		case railsResources.MatchString(trimmed):
+			m := railsResources.FindStringSubmatch(trimmed)
+			singular := m[1] == "resource"
+			base := joinRoute(prefix(), m[2])
+			for _, action := range filterRailsActions(m[3]) {
+				if singular && action.action == "index" {
+					continue
+				}
+				p := base
+				if action.member && !singular {
+					p += "/:id"
+				}
+				p += action.suffix
+				endpoints = append(endpoints, types.Endpoint{Method: action.method, Path: p, File: rel, Line: n, Framework: "Rails"})
+			}
+			// Nested resources are scoped under the parent's member path
+			if opensBlock {
+				nested := strings.Trim(strings.TrimPrefix(base, prefix()), "/")
+				if !singular {
+					nested += "/:" + strings.TrimSuffix(m[2], "s") + "_id"
+				}
+				scopes = append(scopes, nested)
+				return
+			}
+		}
+
+		if opensBlock {
+			scopes = append(scopes, "")
+		}
+	})
+	return endpoints
+}
+
+// filterRailsActions applies `only:` and `except:` options to the default actions
+func filterRailsActions(options string) []struct {
+	action, method, suffix string
+	member                 bool
+} {
+	symbols := func(m []string) map[string]bool {
+		set := make(map[string]bool)
+		if m == nil {
+			return set
+		}
+		for _, s := range rubySymbol.FindAllStringSubmatch(":"+m[2]+" "+m[1], -1) {
+			set[s[1]] = true
+		}
+		return set
+	}
+
+	only := railsOnly.FindStringSubmatch(options)
+	onlySet := symbols(only)
+	exceptSet := symbols(railsExcept.FindStringSubmatch(options))
+
+	var actions []struct {
+		action, method, suffix string
+		member                 bool
+	}
+	for _, action := range railsActions {
+		if only != nil && !onlySet[action.action] {
+			continue
+		}
+		if exceptSet[action.action] {
+			continue
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
@@ -0,0 +1,215 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// route is the part of an Endpoint the tests compare
+type route struct {
+	Method string
+	Path   string
+	Line   int
+}
+
+func routesOf(endpoints []types.Endpoint) []route {
+	var routes []route
+	for _, e := range endpoints {
+		routes = append(routes, route{e.Method, e.Path, e.Line})
+	}
+	return routes
+}
+
+func equalRoutes(a, b []route) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExtractRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		extract routeExtractor
+		rel     string
+		src     string
+		want    []route
+	}{
+		{
+			name:    "express app and router",
+			extract: extractExpressRoutes,
+			rel:     "src/server.js",
+			src: `const express = require('express')
+const app = express()
+const users = express.Router()
+app.get('/health', (req, res) => res.send('ok'))
+users.post('/users', create)
+app.all("/legacy", legacy)`,
+			want: []route{{"GET", "/health", 4}, {"POST", "/users", 5}, {"ANY", "/legacy", 6}},
+		},
+		{
+			name:    "typed express app in TypeScript",
+			extract: extractExpressRoutes,
+			rel:     "src/app.ts",
+			src: `import express, { Router } from 'express'
+export const api: Router = Router()
+api.delete('/items/:id', remove)`,
+			want: []route{{"DELETE", "/items/:id", 3}},
+		},
+		{
+			name:    "router passed in by convention",
+			extract: extractExpressRoutes,
+			rel:     "routes/index.js",
+			src: `const { Router } = require('express')
+module.exports = (router) => {
+  router.put('/profile', update)
+}`,
+			want: []route{{"PUT", "/profile", 3}},
+		},
+		{
+			name:    "http clients in a client file are not routes",
+			extract: extractExpressRoutes,
+			rel:     "web/src/api.js",
+			src: `import axios from 'axios'
+const cache = new Map()
+axios.get('/api/users')
+cache.get('/cache/key')
+http.post('/api/login', body)`,
+			want: nil,
+		},
+		{
+			name:    "http clients next to an express app",
+			extract: extractExpressRoutes,
+			rel:     "src/proxy.js",
+			src: `const express = require('express')
+const app = express()
+const cache = new Map()
+app.get('/users', async (req, res) => {
+  const hit = cache.get('/users')
+  res.json(await axios.get('/upstream/users'))
+})`,
+			want: []route{{"GET", "/users", 4}},
+		},
+		{
+			name:    "fastify shorthand",
+			extract: extractExpressRoutes,
+			rel:     "server.mjs",
+			src: `import Fastify from 'fastify'
+const server = Fastify({ logger: true })
+server.get('/ping', async () => 'pong')`,
+			want: []route{{"GET", "/ping", 3}},
+		},
+		{
+			name:    "fastapi router prefix",
+			extract: extractPythonRoutes,
+			rel:     "app/users.py",
+			src: `router = APIRouter(prefix="/users")
+
+@router.get("/{user_id}")
+async def get_user(user_id: int): ...`,
+			want: []route{{"GET", "/users/{user_id}", 3}},
+		},
+		{
+			name:    "flask methods",
+			extract: extractPythonRoutes,
+			rel:     "app.py",
+			src: `@app.route("/login", methods=["GET", "POST"])
+def login(): ...`,
+			want: []route{{"GET", "/login", 1}, {"POST", "/login", 1}},
+		},
+		{
+			name:    "django urlconf skips includes",
+			extract: extractPythonRoutes,
+			rel:     "shop/urls.py",
+			src: `urlpatterns = [
+    path("orders/", views.orders),
+    path("api/", include("api.urls")),
+]`,
+			want: []route{{"ANY", "/orders", 2}},
+		},
+		{
+			name:    "gin groups",
+			extract: extractGoRoutes,
+			rel:     "main.go",
+			src: `import "github.com/gin-gonic/gin"
+
+v1 := r.Group("/v1")
+v1.GET("users", list)
+r.POST("/login", login)`,
+			want: []route{{"GET", "/v1/users", 4}, {"POST", "/login", 5}},
+		},
+		{
+			name:    "net/http patterns",
+			extract: extractGoRoutes,
+			rel:     "main.go",
+			src: `import "net/http"
+
+mux.HandleFunc("GET /items/{id}", item)
+http.Handle("/static/", files)`,
+			want: []route{{"GET", "/items/{id}", 3}, {"ANY", "/static", 4}},
+		},
+		{
+			name:    "go files without a router",
+			extract: extractGoRoutes,
+			rel:     "cache.go",
+			src:     `c.Get("/key")`,
+			want:    nil,
+		},
+		{
+			name:    "spring controller prefix",
+			extract: extractSpringRoutes,
+			rel:     "UserController.java",
+			src: `@RequestMapping("/api")
+public class UserController {
+    @GetMapping("/users")
+    public List<User> list() {}
+    @RequestMapping(value = "/users", method = RequestMethod.POST)
+    public User create() {}
+}`,
+			want: []route{{"GET", "/api/users", 3}, {"POST", "/api/users", 5}},
+		},
+		{
+			name:    "rails resources",
+			extract: extractRailsRoutes,
+			rel:     "config/routes.rb",
+			src: `Rails.application.routes.draw do
+  namespace :api do
+    resources :posts, only: [:index, :show]
+  end
+  get "/health", to: "health#show"
+end`,
+			want: []route{{"GET", "/api/posts", 3}, {"GET", "/api/posts/:id", 3}, {"GET", "/health", 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := routesOf(tt.extract(tt.rel, []byte(tt.src)))
+			if !equalRoutes(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"handlers/users_test.go":   true,
+		"src/app.spec.ts":          true,
+		"tests/test_api.py":        true,
+		"spec/models/user_spec.rb": true,
+		"src/contest.js":           false,
+		"internal/testing.go":      false,
+	}
+	for rel, want := range tests {
+		if got := isTestFile(rel); got != want {
+			t.Errorf("isTestFile(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
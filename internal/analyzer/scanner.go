@@ -0,0 +1,275 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// maxScannedFileBytes bounds the files read for line counting
+const maxScannedFileBytes = 2 * 1024 * 1024
+
+// languageByExtension maps file extensions to language names
+var languageByExtension = map[string]string{
+	".go":    "Go",
+	".js":    "JavaScript",
+	".jsx":   "JavaScript",
+	".mjs":   "JavaScript",
+	".cjs":   "JavaScript",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".php":   "PHP",
+	".java":  "Java",
+	".kt":    "Kotlin",
+	".scala": "Scala",
+	".cs":    "C#",
+	".rs":    "Rust",
+	".ex":    "Elixir",
+	".exs":   "Elixir",
+	".swift": "Swift",
+	".c":     "C",
+	".h":     "C",
+	".cpp":   "C++",
+	".cc":    "C++",
+	".sql":   "SQL",
+	".html":  "HTML",
+	".css":   "CSS",
+	".scss":  "CSS",
+	".vue":   "Vue",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+	".json":  "JSON",
+	".toml":  "TOML",
+	".tf":    "HCL",
+	".sh":    "Shell",
+	".md":    "Markdown",
+}
+
+// nonProgrammingLanguages never count as a project's primary language
+var nonProgrammingLanguages = map[string]bool{
+	"HTML": true, "CSS": true, "YAML": true, "JSON": true, "TOML": true,
+	"HCL": true, "Shell": true, "Markdown": true, "SQL": true,
+}
+
+// frameworkDependency maps a dependency name to the framework it implies.
+// Earlier entries win, so backend frameworks are listed before frontend ones.
+var frameworkDependencies = []struct {
+	dependency string
+	framework  string
+}{
+	{"@nestjs/core", "NestJS"},
+	{"next", "Next.js"},
+	{"nuxt", "Nuxt"},
+	{"express", "Express"},
+	{"fastify", "Fastify"},
+	{"koa", "Koa"},
+	{"@hapi/hapi", "Hapi"},
+	{"django", "Django"},
+	{"fastapi", "FastAPI"},
+	{"flask", "Flask"},
+	{"rails", "Rails"},
+	{"sinatra", "Sinatra"},
+	{"github.com/gin-gonic/gin", "Gin"},
+	{"github.com/labstack/echo/v4", "Echo"},
+	{"github.com/labstack/echo", "Echo"},
+	{"github.com/gofiber/fiber/v2", "Fiber"},
+	{"github.com/go-chi/chi/v5", "Chi"},
+	{"github.com/gorilla/mux", "Gorilla Mux"},
+	{"org.springframework.boot:spring-boot-starter-web", "Spring Boot"},
+	{"org.springframework.boot:spring-boot-starter-webflux", "Spring Boot"},
+	{"io.quarkus:quarkus-resteasy", "Quarkus"},
+	{"laravel/framework", "Laravel"},
+	{"symfony/framework-bundle", "Symfony"},
+	{"actix-web", "Actix Web"},
+	{"axum", "Axum"},
+	{"rocket", "Rocket"},
+	{"@angular/core", "Angular"},
+	{"vue", "Vue"},
+	{"react", "React"},
+}
+
+// Scan walks the project (respecting .gitignore) and derives facts about it
+// without an LLM: file and line counts per language, manifests and their
+// declared dependencies, and the primary language and framework.
+func Scan(root string) (*types.StaticFacts, error) {
+	facts := &types.StaticFacts{}
+	languages := make(map[string]*types.LanguageStats)
+	seen := make(map[string]bool)
+
+	err := walkProject(root, func(path, rel string, info os.FileInfo) error {
+		if deps, ok := manifestDependencies(path, rel); ok {
+			facts.Manifests = append(facts.Manifests, rel)
+			for _, dep := range deps {
+				key := dep.Ecosystem + "/" + dep.Name
+				if !seen[key] {
+					seen[key] = true
+					facts.Dependencies = append(facts.Dependencies, dep)
+				}
+			}
+		}
+
+		language, ok := languageByExtension[strings.ToLower(filepath.Ext(rel))]
+		if !ok || info.Size() > maxScannedFileBytes {
+			return nil
+		}
+
+		lines, err := countLines(path)
+		if err != nil {
+			return nil // Unreadable or binary
+		}
+
+		stats := languages[language]
+		if stats == nil {
+			stats = &types.LanguageStats{Language: language}
+			languages[language] = stats
+		}
+		stats.Files++
+		stats.Lines += lines
+		facts.Files++
+		facts.Lines += lines
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %v", root, err)
+	}
+
+	for _, stats := range languages {
+		facts.Languages = append(facts.Languages, *stats)
+	}
+	sort.Slice(facts.Languages, func(i, j int) bool {
+		if facts.Languages[i].Lines != facts.Languages[j].Lines {
+			return facts.Languages[i].Lines > facts.Languages[j].Lines
+		}
+		return facts.Languages[i].Language < facts.Languages[j].Language
+	})
+
+	facts.Language = primaryLanguage(facts.Languages)
+	facts.Framework = detectFramework(facts.Dependencies)
+
+	return facts, nil
+}
+
+func manifestDependencies(path, rel string) ([]types.Dependency, bool) {
+	if _, ok := manifestParsers[filepath.Base(rel)]; !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return parseManifest(rel, data)
+}
+
+// countLines counts the non-blank lines of a text file
+func countLines(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return 0, fmt.Errorf("binary file")
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxScannedFileBytes)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			lines++
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// primaryLanguage is the programming language with the most lines
+func primaryLanguage(languages []types.LanguageStats) string {
+	for _, stats := range languages {
+		if !nonProgrammingLanguages[stats.Language] {
+			return stats.Language
+		}
+	}
+	return ""
+}
+
+// detectFramework picks the highest priority framework among the
+// non-development dependencies
+func detectFramework(deps []types.Dependency) string {
+	declared := make(map[string]bool, len(deps))
+	for _, dep := range deps {
+		if !dep.Dev {
+			declared[dep.Name] = true
+		}
+	}
+
+	for _, fd := range frameworkDependencies {
+		if declared[fd.dependency] {
+			return fd.framework
+		}
+	}
+	return ""
+}
+
+// dependencyNames lists the non-development dependency names
+func dependencyNames(deps []types.Dependency) []string {
+	var names []string
+	for _, dep := range deps {
+		if !dep.Dev {
+			names = append(names, dep.Name)
+		}
+	}
+	return names
+}
+
+// maxFactsDependencies bounds the dependency list in the facts sheet
+const maxFactsDependencies = 100
+
+// factsSheet renders static facts for inclusion in LLM prompts
+func factsSheet(facts *types.StaticFacts) string {
+	if facts == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Static facts about this codebase, verified by parsing the repository. Use them as given; do not re-derive them.\n")
+	fmt.Fprintf(&sb, "- Files: %d, non-blank lines: %d\n", facts.Files, facts.Lines)
+
+	if len(facts.Languages) > 0 {
+		var langs []string
+		for _, l := range facts.Languages {
+			langs = append(langs, fmt.Sprintf("%s (%d files, %d lines)", l.Language, l.Files, l.Lines))
+		}
+		fmt.Fprintf(&sb, "- Languages: %s\n", strings.Join(langs, ", "))
+	}
+	if facts.Language != "" {
+		fmt.Fprintf(&sb, "- Primary language: %s\n", facts.Language)
+	}
+	if facts.Framework != "" {
+		fmt.Fprintf(&sb, "- Framework: %s\n", facts.Framework)
+	}
+	if len(facts.Manifests) > 0 {
+		fmt.Fprintf(&sb, "- Manifests: %s\n", strings.Join(facts.Manifests, ", "))
+	}
+
+	names := dependencyNames(facts.Dependencies)
+	if len(names) > 0 {
+		shown := names
+		if len(shown) > maxFactsDependencies {
+			shown = shown[:maxFactsDependencies]
+		}
+		fmt.Fprintf(&sb, "- Dependencies (%d): %s", len(names), strings.Join(shown, ", "))
+		if len(names) > len(shown) {
+			fmt.Fprintf(&sb, ", ... and %d more", len(names)-len(shown))
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,165 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+const (
+	// DefaultParallelism is how many passes run at once when none is set
+	DefaultParallelism = 3
+
+	// DefaultPassTimeout bounds one pass, including its repair attempts,
+	// when no timeout is set
+	DefaultPassTimeout = 10 * time.Minute
+)
+
+// pass is an LLM pass that does not depend on the others. It runs on an
+// analysis of its own, so that passes can run concurrently, and merge copies
+// the fields it fills in into the result.
+type pass struct {
+	name  string
+	run   func(a *Analyzer, ctx context.Context, analysis *types.CodeAnalysis) error
+	merge func(analysis, found *types.CodeAnalysis)
+}
+
+var basicStructurePass = pass{
+	name: "basic analysis",
+	run:  (*Analyzer).analyzeBasicStructure,
+	merge: func(analysis, found *types.CodeAnalysis) {
+		analysis.Language = found.Language
+		analysis.Framework = found.Framework
+		analysis.Dependencies = found.Dependencies
+		analysis.ApiEndpoints = found.ApiEndpoints
+		analysis.BackgroundJobs = found.BackgroundJobs
+		analysis.FileUploads = found.FileUploads
+	},
+}
+
+var databaseAPIPass = pass{
+	name: "database/API analysis",
+	run:  (*Analyzer).analyzeDatabaseAndAPI,
+	merge: func(analysis, found *types.CodeAnalysis) {
+		analysis.DatabaseCalls = found.DatabaseCalls
+		analysis.CacheUsage = found.CacheUsage
+		analysis.ComplexityScore = found.ComplexityScore
+		analysis.Performance.HasNPlusOneQuery = found.Performance.HasNPlusOneQuery
+	},
+}
+
+var performancePass = pass{
+	name: "performance analysis",
+	run:  (*Analyzer).analyzePerformanceAndScaling,
+	merge: func(analysis, found *types.CodeAnalysis) {
+		analysis.ScalingBottlenecks = found.ScalingBottlenecks
+		analysis.Performance.HasLargePayloads = found.Performance.HasLargePayloads
+	},
+}
+
+// SetParallelism sets how many independent passes run at once.
+// DefaultParallelism is used when none is set.
+func (a *Analyzer) SetParallelism(n int) {
+	a.parallelism = n
+}
+
+// SetPassTimeout sets how long one pass may take. DefaultPassTimeout is used
+// when none is set.
+func (a *Analyzer) SetPassTimeout(d time.Duration) {
+	a.passTimeout = d
+}
+
+// runConcurrently runs independent passes, at most the configured number at
+// a time, and merges their findings into analysis in the order given. The
+// first pass to fail cancels the others.
+func (a *Analyzer) runConcurrently(ctx context.Context, analysis *types.CodeAnalysis, passes ...pass) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := a.parallelism
+	if limit <= 0 {
+		limit = DefaultParallelism
+	}
+	slots := make(chan struct{}, limit)
+
+	found := make([]*types.CodeAnalysis, len(passes))
+	errs := make([]error, len(passes))
+	var wg sync.WaitGroup
+	for i, p := range passes {
+		wg.Add(1)
+		go func(i int, p pass) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			found[i] = &types.CodeAnalysis{}
+			errs[i] = a.runPass(ctx, p.name, func(ctx context.Context) error {
+				return p.run(a, ctx, found[i])
+			})
+			if errs[i] != nil {
+				cancel()
+				return
+			}
+			fmt.Print(".")
+		}(i, p)
+	}
+	wg.Wait()
+
+	// A failed pass cancels the others; report it rather than them
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, p := range passes {
+		p.merge(analysis, found[i])
+		for field, provenance := range found[i].Provenance {
+			if analysis.Provenance == nil {
+				analysis.Provenance = make(map[string]types.FieldProvenance)
+			}
+			analysis.Provenance[field] = provenance
+		}
+		analysis.LowConfidence = analysis.LowConfidence || found[i].LowConfidence
+		analysis.HeuristicPasses = append(analysis.HeuristicPasses, found[i].HeuristicPasses...)
+	}
+	return nil
+}
+
+// runPass runs one pass within the per-pass timeout. It returns ctx's error
+// unwrapped when the pass was cancelled from outside, so callers can tell an
+// interruption from a failure.
+func (a *Analyzer) runPass(ctx context.Context, name string, run func(ctx context.Context) error) error {
+	timeout := a.passTimeout
+	if timeout <= 0 {
+		timeout = DefaultPassTimeout
+	}
+	passCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := run(passCtx)
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case passCtx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("%s timed out after %s", name, timeout)
+	default:
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+}
@@ -0,0 +1,172 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// passReplies answers each pass by the schema its prompt asks for. A pass
+// without a reply blocks until it is cancelled.
+func passReplies(replies map[string]func() (string, error)) *stubBackend {
+	return &stubBackend{reply: func(ctx context.Context, prompt string, call int) (string, error) {
+		for schema, reply := range replies {
+			if strings.Contains(prompt, "cloudpork/"+schema+"/") {
+				return reply()
+			}
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+}
+
+// answer is a reply that always succeeds with output
+func answer(output string) func() (string, error) {
+	return func() (string, error) { return output, nil }
+}
+
+func TestRunConcurrentlyMergesResults(t *testing.T) {
+	backend := passReplies(map[string]func() (string, error){
+		"basic_structure": answer(`{"language": "Go", "framework": "gin", "dependencies": ["gorm"], "api_endpoints": 12,
+			"background_jobs": ["mailer"], "file_uploads": true}`),
+		"database_api": answer(`{"database_calls": 30, "connection_pattern": "pooled", "n_plus_one_queries": true,
+			"cache_usage": ["redis"], "complexity_score": 60}`),
+		"performance_scaling": answer("The database pool is a high bottleneck."),
+	})
+	a := New(t.TempDir(), "proj_test", backend)
+	analysis := a.newAnalysis()
+
+	if err := a.runConcurrently(context.Background(), analysis, basicStructurePass, databaseAPIPass, performancePass); err != nil {
+		t.Fatal(err)
+	}
+
+	type merged struct {
+		Language, Framework string
+		Endpoints, Calls    int
+		Complexity          int
+		Caches, Jobs        []string
+		NPlusOne            bool
+		Bottlenecks         int
+		Heuristic           []string
+		Low                 bool
+	}
+	got := merged{analysis.Language, analysis.Framework, analysis.ApiEndpoints, analysis.DatabaseCalls, analysis.ComplexityScore,
+		analysis.CacheUsage, analysis.BackgroundJobs, analysis.Performance.HasNPlusOneQuery, len(analysis.ScalingBottlenecks),
+		analysis.HeuristicPasses, analysis.LowConfidence}
+	want := merged{"Go", "gin", 12, 30, 60, []string{"redis"}, []string{"mailer"}, true, 1, []string{"performance_scaling"}, true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged %+v\nwant %+v", got, want)
+	}
+
+	for field, source := range map[string]string{
+		"language":            types.SourceLLMJSON,
+		"complexity_score":    types.SourceLLMJSON,
+		"scaling_bottlenecks": types.SourceLLMRegex,
+	} {
+		if p, _ := analysis.FieldSource(field); p.Source != source {
+			t.Errorf("%s source = %q, want %q", field, p.Source, source)
+		}
+	}
+}
+
+func TestRunConcurrentlyParallelism(t *testing.T) {
+	var running, peak atomic.Int32
+	backend := &stubBackend{reply: func(ctx context.Context, prompt string, call int) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			if p := peak.Load(); n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return "no JSON here", nil
+	}}
+
+	for _, parallelism := range []int{1, 2} {
+		peak.Store(0)
+		a := New(t.TempDir(), "proj_test", backend)
+		a.SetParallelism(parallelism)
+		if err := a.runConcurrently(context.Background(), a.newAnalysis(), basicStructurePass, databaseAPIPass, performancePass); err != nil {
+			t.Fatal(err)
+		}
+		if got := peak.Load(); got != int32(parallelism) {
+			t.Errorf("parallelism %d: at most %d prompts ran at once, want %d", parallelism, got, parallelism)
+		}
+	}
+}
+
+func TestRunConcurrentlyReportsFirstFailure(t *testing.T) {
+	// The other passes block until the failure cancels them
+	backend := passReplies(map[string]func() (string, error){
+		"database_api": func() (string, error) { return "", errors.New("model crashed") },
+	})
+	a := New(t.TempDir(), "proj_test", backend)
+	analysis := a.newAnalysis()
+
+	err := a.runConcurrently(context.Background(), analysis, basicStructurePass, databaseAPIPass, performancePass)
+	if err == nil || err.Error() != "database/API analysis failed: model crashed" {
+		t.Fatalf("error = %v, want the database/API failure", err)
+	}
+	if analysis.Language != "" || analysis.Provenance["language"].Source != "" {
+		t.Errorf("a failed run merged findings: %+v", analysis)
+	}
+}
+
+func TestRunConcurrentlyCancel(t *testing.T) {
+	started := make(chan struct{}, 3)
+	backend := &stubBackend{reply: func(ctx context.Context, prompt string, call int) (string, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+	a := New(t.TempDir(), "proj_test", backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	err := a.runConcurrently(ctx, a.newAnalysis(), basicStructurePass, databaseAPIPass, performancePass)
+	if err != context.Canceled {
+		t.Errorf("error = %v, want context.Canceled unwrapped", err)
+	}
+}
+
+func TestRunPass(t *testing.T) {
+	a := New(t.TempDir(), "proj_test", nil)
+	a.SetPassTimeout(20 * time.Millisecond)
+
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context) error
+		wantErr string
+	}{
+		{"success", func(context.Context) error { return nil }, ""},
+		{"failure", func(context.Context) error { return errors.New("boom") }, "resource estimation failed: boom"},
+		{"timeout", block, "resource estimation timed out after 20ms"},
+	}
+	for _, tt := range tests {
+		err := a.runPass(context.Background(), "resource estimation", tt.run)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("%s: runPass = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Cancelled from outside, the pass reports the interruption as it is
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.runPass(ctx, "resource estimation", block); err != context.Canceled {
+		t.Errorf("cancelled: runPass = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,180 @@
+package analyzer
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the version of the pass response schemas in schemas/
+const SchemaVersion = "v1"
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// passSchema is the subset of JSON Schema used to describe pass responses
+type passSchema struct {
+	ID          string                 `json:"$id,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Properties  map[string]*passSchema `json:"properties,omitempty"`
+	Items       *passSchema            `json:"items,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+
+	raw string
+}
+
+// loadSchema reads a pass schema such as "resources" at SchemaVersion
+func loadSchema(name string) (*passSchema, error) {
+	data, err := schemaFiles.ReadFile(fmt.Sprintf("schemas/%s.%s.json", name, SchemaVersion))
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema %s.%s: %v", name, SchemaVersion, err)
+	}
+
+	var s passSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema %s.%s: %v", name, SchemaVersion, err)
+	}
+	s.raw = string(data)
+
+	return &s, nil
+}
+
+// largestSchema is the size in bytes of the largest pass schema, which
+// structured prompts inline in full
+func largestSchema() int {
+	entries, _ := schemaFiles.ReadDir("schemas")
+	largest := 0
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && int(info.Size()) > largest {
+			largest = int(info.Size())
+		}
+	}
+	return largest
+}
+
+// Validate checks a decoded JSON value against the schema and returns every
+// violation found, in a stable order
+func (s *passSchema) Validate(value interface{}) []string {
+	var violations []string
+	s.validate("$", value, &violations)
+	return violations
+}
+
+func (s *passSchema) validate(path string, value interface{}, violations *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %s", jsonType(value))
+			return
+		}
+		for _, key := range s.Required {
+			if _, ok := obj[key]; !ok {
+				fail("missing required property %q", key)
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if v, ok := obj[key]; ok {
+				s.Properties[key].validate(path+"."+key, v, violations)
+			}
+		}
+
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			fail("expected array, got %s", jsonType(value))
+			return
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, violations)
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected string, got %s", jsonType(value))
+			return
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			fail("%q is not one of %s", str, strings.Join(s.Enum, ", "))
+		}
+
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			fail("expected %s, got %s", s.Type, jsonType(value))
+			return
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			fail("expected integer, got %v", num)
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			fail("%v is below the minimum of %v", num, *s.Minimum)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			fail("%v is above the maximum of %v", num, *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %s", jsonType(value))
+		}
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// extractJSON returns the outermost JSON object in a model response, which
+// may be wrapped in prose or a markdown code fence
+func extractJSON(output string) string {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return strings.TrimSpace(output)
+	}
+	return output[start : end+1]
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSchema(t *testing.T) {
+	for _, name := range []string{"basic_structure", "database_api", "performance_scaling", "resources"} {
+		s, err := loadSchema(name)
+		if err != nil {
+			t.Errorf("loadSchema(%s): %v", name, err)
+			continue
+		}
+		if want := "cloudpork/" + name + "/" + SchemaVersion; s.ID != want || s.raw == "" {
+			t.Errorf("loadSchema(%s) = %s, want %s with its source", name, s.ID, want)
+		}
+	}
+	if _, err := loadSchema("security"); err == nil {
+		t.Error("loadSchema(security) succeeded, want an unknown schema error")
+	}
+}
+
+func TestPassSchemaValidate(t *testing.T) {
+	tests := []struct {
+		schema   string
+		document string
+		want     []string
+	}{
+		{
+			schema:   "resources",
+			document: `{"memory_mb": 512, "cpu_cores": 0.5, "database_connections": 20, "network_mbps": 10, "storage_gb": 0}`,
+		},
+		{
+			schema:   "resources",
+			document: `{"memory_mb": 1.5, "cpu_cores": "two", "database_connections": -1}`,
+			want: []string{
+				`$: missing required property "network_mbps"`,
+				`$: missing required property "storage_gb"`,
+				`$.cpu_cores: expected number, got string`,
+				`$.database_connections: -1 is below the minimum of 0`,
+				`$.memory_mb: expected integer, got 1.5`,
+			},
+		},
+		{
+			schema:   "resources",
+			document: `[{"memory_mb": 512}]`,
+			want:     []string{`$: expected object, got array`},
+		},
+		{
+			schema: "performance_scaling",
+			document: `{"large_payloads": "yes", "bottlenecks": [
+				{"type": "database", "description": "Pool of 5", "severity": "high", "impact": "Timeouts"},
+				{"type": "disk", "description": null, "severity": "urgent"}
+			]}`,
+			want: []string{
+				`$.bottlenecks[1]: missing required property "impact"`,
+				`$.bottlenecks[1].description: expected string, got null`,
+				`$.bottlenecks[1].severity: "urgent" is not one of low, medium, high, critical`,
+				`$.bottlenecks[1].type: "disk" is not one of database, cpu, memory, network`,
+				`$.large_payloads: expected boolean, got string`,
+			},
+		},
+		{
+			schema:   "performance_scaling",
+			document: `{"bottlenecks": {}, "large_payloads": false}`,
+			want:     []string{`$.bottlenecks: expected array, got object`},
+		},
+	}
+	for _, tt := range tests {
+		s, err := loadSchema(tt.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(tt.document), &value); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Validate(value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s.Validate(%s)\n got %q\nwant %q", tt.schema, tt.document, got, tt.want)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name, output, want string
+	}{
+		{"bare", `{"a": 1}`, `{"a": 1}`},
+		{"fenced", "```json\n{\"a\": {\"b\": [1, 2]}}\n```", `{"a": {"b": [1, 2]}}`},
+		{"wrapped in prose", "Here is the analysis:\n{\"a\": 1}\nLet me know if you need more.", `{"a": 1}`},
+		{"no object", "  I could not analyze this project.\n", "I could not analyze this project."},
+		{"closing brace first", "} and then {", "} and then {"},
+	}
+	for _, tt := range tests {
+		if got := extractJSON(tt.output); got != tt.want {
+			t.Errorf("extractJSON(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,595 @@
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/cost"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the function settings, when a template leaves them out
+const (
+	serverlessMemoryMB      = 1024 // Serverless Framework
+	serverlessTimeout       = 6
+	lambdaMemoryMB          = 128 // SAM and CloudFormation
+	lambdaTimeout           = 3
+	minutesPerMonth         = 730 * 60
+	unknownSchedulePerMonth = 30 // Schedules that cannot be read are taken as daily
+)
+
+// serverlessProviders maps a Serverless Framework provider to a pricing
+// catalog ID
+var serverlessProviders = map[string]string{
+	"aws":    "aws",
+	"google": "gcp",
+	"azure":  "azure",
+}
+
+// serverlessConfig is the part of a serverless.yml the inventory reads
+type serverlessConfig struct {
+	Provider struct {
+		Name         string `yaml:"name"`
+		Runtime      string `yaml:"runtime"`
+		MemorySize   int    `yaml:"memorySize"`
+		Timeout      int    `yaml:"timeout"`
+		Architecture string `yaml:"architecture"`
+	} `yaml:"provider"`
+}
+
+// serverlessFunction is one entry of a serverless.yml's functions
+type serverlessFunction struct {
+	Handler                string      `yaml:"handler"`
+	Runtime                string      `yaml:"runtime"`
+	MemorySize             int         `yaml:"memorySize"`
+	Timeout                int         `yaml:"timeout"`
+	Architecture           string      `yaml:"architecture"`
+	ProvisionedConcurrency int         `yaml:"provisionedConcurrency"`
+	Events                 []yaml.Node `yaml:"events"`
+}
+
+// cfnFunction is the properties of an AWS::Serverless::Function or an
+// AWS::Lambda::Function
+type cfnFunction struct {
+	Handler                      string   `yaml:"Handler"`
+	Runtime                      string   `yaml:"Runtime"`
+	MemorySize                   int      `yaml:"MemorySize"`
+	Timeout                      int      `yaml:"Timeout"`
+	Architectures                []string `yaml:"Architectures"`
+	ProvisionedConcurrencyConfig struct {
+		ProvisionedConcurrentExecutions int `yaml:"ProvisionedConcurrentExecutions"`
+	} `yaml:"ProvisionedConcurrencyConfig"`
+	Events yaml.Node `yaml:"Events"`
+}
+
+// cdkInternalFunctions prefix the logical IDs of the functions CDK adds for
+// its own custom resources
+var cdkInternalFunctions = []string{"LogRetention", "BucketNotificationsHandler", "CustomResourceProvider", "AWS679f53fac002430cb0da5b7982bd2287", "CustomCDK", "CustomS3AutoDelete"}
+
+// FindServerlessFunctions inventories the functions declared in
+// serverless.yml files, SAM and CloudFormation templates, and the templates
+// CDK synthesizes into cdk.out: their memory, timeout, triggers and
+// provisioned concurrency.
+func FindServerlessFunctions(root string) ([]types.ServerlessFunction, error) {
+	var functions []types.ServerlessFunction
+	var cdkApps []string
+
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		name := path.Base(rel)
+		if name == "cdk.json" {
+			cdkApps = append(cdkApps, path.Dir(rel))
+			return nil
+		}
+		if strings.Contains("/"+rel, "/cdk.out/") || info.Size() > maxScannedFileBytes {
+			return nil // Read with their app below, even when gitignored
+		}
+		ext := path.Ext(name)
+		if ext != ".yml" && ext != ".yaml" && ext != ".json" {
+			return nil
+		}
+
+		if name == "serverless.yml" || name == "serverless.yaml" {
+			data, err := os.ReadFile(p)
+			if err == nil {
+				functions = append(functions, parseServerlessConfig(rel, data)...)
+			}
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || !bytes.Contains(data, []byte("AWS::Serverless::Function")) && !bytes.Contains(data, []byte("AWS::Lambda::Function")) {
+			return nil
+		}
+		source := "cloudformation"
+		if bytes.Contains(data, []byte("AWS::Serverless")) {
+			source = "sam"
+		}
+		functions = append(functions, parseCloudFormation(source, rel, data)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, app := range cdkApps {
+		out := filepath.Join(root, filepath.FromSlash(app), "cdk.out")
+		templates, _ := filepath.Glob(filepath.Join(out, "*.template.json"))
+		sort.Strings(templates)
+		for _, t := range templates {
+			data, err := os.ReadFile(t)
+			if err != nil {
+				continue
+			}
+			functions = append(functions, parseCloudFormation("cdk", path.Join(app, "cdk.out", filepath.Base(t)), data)...)
+		}
+	}
+	return functions, nil
+}
+
+// applyServerless prices each function per invocation and projects the
+// functions' monthly cost to each request rate. Invocations are assumed to
+// last the estimated response time.
+func (a *Analyzer) applyServerless(analysis *types.CodeAnalysis) {
+	if len(a.functions) == 0 {
+		return
+	}
+
+	duration := analysis.Performance.AvgResponseTime
+	durationSource, durationNote := types.SourceDefault, ""
+	if duration > 0 {
+		if p, ok := analysis.FieldSource("performance.avg_response_time_ms"); ok {
+			durationSource, durationNote = p.Source, "estimated response time"
+		}
+	} else {
+		duration = cost.DefaultDurationMs
+	}
+	for i := range a.functions {
+		a.functions[i].CostPerInvocation = a.pricing.InvocationCost(a.functions[i], duration)
+	}
+
+	rates := a.rates
+	if len(rates) == 0 {
+		rates = cost.DefaultRequestRates
+	}
+	analysis.Serverless = &types.ServerlessInfo{
+		Functions:   a.functions,
+		DurationMs:  duration,
+		Projections: cost.ProjectServerless(a.pricing, a.functions, duration, rates),
+	}
+	analysis.SetProvenance("serverless.functions", types.SourceStatic, "serverless templates")
+	analysis.SetProvenance("serverless.assumed_duration_ms", durationSource, durationNote)
+}
+
+// decodeLenient decodes a node, keeping the fields that decode when others
+// do not, such as numbers set from variables or intrinsic functions
+func decodeLenient(n *yaml.Node, v interface{}) bool {
+	err := n.Decode(v)
+	if _, partial := err.(*yaml.TypeError); partial {
+		return true
+	}
+	return err == nil
+}
+
+// parseServerlessConfig reads the functions of a serverless.yml
+func parseServerlessConfig(rel string, data []byte) []types.ServerlessFunction {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	var config serverlessConfig
+	if !decodeLenient(doc.Content[0], &config) {
+		return nil
+	}
+	provider, ok := serverlessProviders[config.Provider.Name]
+	if !ok {
+		provider = "aws"
+	}
+
+	var functions []types.ServerlessFunction
+	entries := mappingValue(doc.Content[0], "functions")
+	for i := 0; entries != nil && i+1 < len(entries.Content); i += 2 {
+		var fn serverlessFunction
+		if !decodeLenient(entries.Content[i+1], &fn) {
+			continue
+		}
+		f := types.ServerlessFunction{
+			Source:                 "serverless",
+			Name:                   entries.Content[i].Value,
+			Provider:               provider,
+			Handler:                fn.Handler,
+			Runtime:                firstNonEmpty(fn.Runtime, config.Provider.Runtime),
+			Architecture:           firstNonEmpty(fn.Architecture, config.Provider.Architecture, "x86_64"),
+			MemoryMB:               firstPositive(fn.MemorySize, config.Provider.MemorySize, serverlessMemoryMB),
+			TimeoutSeconds:         firstPositive(fn.Timeout, config.Provider.Timeout, serverlessTimeout),
+			ProvisionedConcurrency: fn.ProvisionedConcurrency,
+			File:                   rel,
+			Line:                   entries.Content[i].Line,
+		}
+		for _, event := range fn.Events {
+			if event.Kind != yaml.MappingNode || len(event.Content) < 2 {
+				continue
+			}
+			kind, value := event.Content[0].Value, event.Content[1]
+			switch kind {
+			case "http", "httpApi":
+				f.Triggers = append(f.Triggers, "http "+serverlessRoute(value))
+			case "schedule":
+				for _, expr := range serverlessSchedules(value) {
+					f.Triggers = append(f.Triggers, "schedule "+expr)
+					f.ScheduledPerMonth += schedulePerMonth(expr)
+				}
+			default:
+				f.Triggers = append(f.Triggers, strings.ToLower(kind))
+			}
+		}
+		functions = append(functions, f)
+	}
+	return functions
+}
+
+// serverlessRoute formats an http event, "GET users" or a path and method,
+// as "GET /users"
+func serverlessRoute(n *yaml.Node) string {
+	var method, route string
+	switch n.Kind {
+	case yaml.ScalarNode:
+		fields := strings.Fields(n.Value)
+		if len(fields) == 2 {
+			method, route = fields[0], fields[1]
+		} else {
+			route = n.Value
+		}
+	case yaml.MappingNode:
+		var v struct {
+			Method string `yaml:"method"`
+			Path   string `yaml:"path"`
+		}
+		decodeLenient(n, &v)
+		method, route = v.Method, v.Path
+	}
+	if method == "" || method == "*" {
+		method = "ANY"
+	}
+	if !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	return strings.ToUpper(method) + " " + route
+}
+
+// serverlessSchedules reads a schedule event: an expression, or a mapping
+// with one or more under rate
+func serverlessSchedules(n *yaml.Node) []string {
+	if n.Kind == yaml.ScalarNode {
+		return []string{n.Value}
+	}
+	rate := mappingNode(n, "rate")
+	if rate == nil {
+		return nil
+	}
+	if rate.Kind == yaml.ScalarNode {
+		return []string{rate.Value}
+	}
+	var exprs []string
+	for _, item := range rate.Content {
+		exprs = append(exprs, item.Value)
+	}
+	return exprs
+}
+
+// parseCloudFormation reads the functions of a SAM or CloudFormation template,
+// in YAML or JSON, with the triggers and provisioned concurrency other
+// resources attach to them
+func parseCloudFormation(source, rel string, data []byte) []types.ServerlessFunction {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	var globals cfnFunction
+	if g := mappingNode(mappingNode(doc.Content[0], "Globals"), "Function"); g != nil {
+		decodeLenient(g, &globals)
+	}
+
+	resources := mappingNode(doc.Content[0], "Resources")
+	if resources == nil {
+		return nil
+	}
+
+	var functions []types.ServerlessFunction
+	index := make(map[string]int) // By logical ID
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		id, resource := resources.Content[i].Value, resources.Content[i+1]
+		kind := scalarValue(mappingNode(resource, "Type"))
+		if kind != "AWS::Serverless::Function" && kind != "AWS::Lambda::Function" || isCDKInternal(source, id) {
+			continue
+		}
+
+		var props cfnFunction
+		if p := mappingNode(resource, "Properties"); p != nil {
+			decodeLenient(p, &props)
+		}
+		memory, timeout := lambdaMemoryMB, lambdaTimeout
+		architectures := props.Architectures
+		if kind == "AWS::Serverless::Function" {
+			memory = firstPositive(globals.MemorySize, memory)
+			timeout = firstPositive(globals.Timeout, timeout)
+			if len(architectures) == 0 {
+				architectures = globals.Architectures
+			}
+		}
+		f := types.ServerlessFunction{
+			Source:                 source,
+			Name:                   id,
+			Provider:               "aws",
+			Handler:                firstNonEmpty(props.Handler, globals.Handler),
+			Runtime:                firstNonEmpty(props.Runtime, globals.Runtime),
+			Architecture:           "x86_64",
+			MemoryMB:               firstPositive(props.MemorySize, memory),
+			TimeoutSeconds:         firstPositive(props.Timeout, timeout),
+			ProvisionedConcurrency: props.ProvisionedConcurrencyConfig.ProvisionedConcurrentExecutions,
+			File:                   rel,
+			Line:                   resources.Content[i].Line,
+		}
+		if len(architectures) > 0 {
+			f.Architecture = architectures[0]
+		}
+		f.Triggers, f.ScheduledPerMonth = samEvents(&props.Events)
+
+		index[id] = len(functions)
+		functions = append(functions, f)
+	}
+
+	// Plain CloudFormation attaches triggers with separate resources
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		resource := resources.Content[i+1]
+		props := mappingNode(resource, "Properties")
+		if props == nil {
+			continue
+		}
+		switch scalarValue(mappingNode(resource, "Type")) {
+		case "AWS::Lambda::EventSourceMapping":
+			if j, ok := index[cfnRef(mappingNode(props, "FunctionName"))]; ok {
+				functions[j].Triggers = append(functions[j].Triggers, eventSourceKind(mappingNode(props, "EventSourceArn")))
+			}
+		case "AWS::Lambda::Permission":
+			if j, ok := index[cfnRef(mappingNode(props, "FunctionName"))]; ok {
+				principal := scalarValue(mappingNode(props, "Principal"))
+				if principal != "" && principal != "events.amazonaws.com" {
+					functions[j].Triggers = mergeNames(functions[j].Triggers, []string{strings.TrimSuffix(principal, ".amazonaws.com")})
+				}
+			}
+		case "AWS::Lambda::Alias", "AWS::Lambda::Version":
+			if j, ok := index[cfnRef(mappingNode(props, "FunctionName"))]; ok {
+				var config struct {
+					ProvisionedConcurrencyConfig struct {
+						ProvisionedConcurrentExecutions int `yaml:"ProvisionedConcurrentExecutions"`
+					} `yaml:"ProvisionedConcurrencyConfig"`
+				}
+				decodeLenient(props, &config)
+				functions[j].ProvisionedConcurrency += config.ProvisionedConcurrencyConfig.ProvisionedConcurrentExecutions
+			}
+		case "AWS::Events::Rule":
+			expr := scalarValue(mappingNode(props, "ScheduleExpression"))
+			targets := mappingNode(props, "Targets")
+			for k := 0; expr != "" && targets != nil && k < len(targets.Content); k++ {
+				if j, ok := index[cfnRef(mappingNode(targets.Content[k], "Arn"))]; ok {
+					functions[j].Triggers = append(functions[j].Triggers, "schedule "+expr)
+					functions[j].ScheduledPerMonth += schedulePerMonth(expr)
+				}
+			}
+		}
+	}
+	return functions
+}
+
+// samEvents reads the Events of a SAM function as triggers, with the
+// invocations per month of its schedules
+func samEvents(events *yaml.Node) ([]string, int) {
+	var triggers []string
+	scheduled := 0
+	for i := 0; events.Kind == yaml.MappingNode && i+1 < len(events.Content); i += 2 {
+		event := events.Content[i+1]
+		kind := scalarValue(mappingNode(event, "Type"))
+		props := mappingNode(event, "Properties")
+		switch kind {
+		case "Api", "HttpApi":
+			method := strings.ToUpper(scalarValue(mappingNode(props, "Method")))
+			if method == "" || method == "ANY" || method == "*" {
+				method = "ANY"
+			}
+			triggers = append(triggers, fmt.Sprintf("http %s %s", method, firstNonEmpty(scalarValue(mappingNode(props, "Path")), "/")))
+		case "Schedule", "ScheduleV2":
+			expr := firstNonEmpty(scalarValue(mappingNode(props, "Schedule")), scalarValue(mappingNode(props, "ScheduleExpression")))
+			triggers = append(triggers, "schedule "+expr)
+			scheduled += schedulePerMonth(expr)
+		case "":
+		default:
+			triggers = append(triggers, strings.ToLower(kind))
+		}
+	}
+	return triggers, scheduled
+}
+
+// scalarValue is the value of a scalar node, or "" for any other node
+func scalarValue(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+// cfnRef returns the logical ID a CloudFormation reference points to, from
+// Ref or Fn::GetAtt in either their JSON or YAML short form
+func cfnRef(n *yaml.Node) string {
+	if n == nil {
+		return ""
+	}
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag == "!Ref" || n.Tag == "!GetAtt" {
+			return strings.SplitN(n.Value, ".", 2)[0]
+		}
+	case yaml.SequenceNode:
+		if n.Tag == "!GetAtt" && len(n.Content) > 0 {
+			return n.Content[0].Value
+		}
+	case yaml.MappingNode:
+		if ref := scalarValue(mappingNode(n, "Ref")); ref != "" {
+			return ref
+		}
+		if att := mappingNode(n, "Fn::GetAtt"); att != nil {
+			if att.Kind == yaml.SequenceNode && len(att.Content) > 0 {
+				return att.Content[0].Value
+			}
+			return strings.SplitN(scalarValue(att), ".", 2)[0]
+		}
+	}
+	return ""
+}
+
+// eventSourceKind names the service behind an event source mapping from its
+// ARN or the logical ID it references, e.g. "sqs" or "dynamodb"
+func eventSourceKind(n *yaml.Node) string {
+	text := strings.ToLower(scalarValue(n) + cfnRef(n))
+	if arn := mappingNode(n, "Fn::GetAtt"); arn != nil {
+		for _, item := range arn.Content {
+			text += strings.ToLower(item.Value)
+		}
+	}
+	for _, kind := range []string{"sqs", "queue", "kinesis", "dynamodb", "table", "kafka"} {
+		if strings.Contains(text, kind) {
+			switch kind {
+			case "queue":
+				return "sqs"
+			case "table":
+				return "dynamodb"
+			}
+			return kind
+		}
+	}
+	return "event source"
+}
+
+// isCDKInternal reports whether a function is one CDK adds for itself
+func isCDKInternal(source, id string) bool {
+	if source != "cdk" {
+		return false
+	}
+	for _, prefix := range cdkInternalFunctions {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulePerMonth estimates how often a rate() or cron() schedule runs in a
+// month
+func schedulePerMonth(expr string) int {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(expr, "rate(") && strings.HasSuffix(expr, ")"):
+		fields := strings.Fields(expr[len("rate(") : len(expr)-1])
+		if len(fields) != 2 {
+			return unknownSchedulePerMonth
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n <= 0 {
+			return unknownSchedulePerMonth
+		}
+		minutes := map[string]int{"minute": 1, "hour": 60, "day": 24 * 60}[strings.TrimSuffix(fields[1], "s")]
+		if minutes == 0 {
+			return unknownSchedulePerMonth
+		}
+		return minutesPerMonth / (minutes * n)
+
+	case strings.HasPrefix(expr, "cron(") && strings.HasSuffix(expr, ")"):
+		fields := strings.Fields(expr[len("cron(") : len(expr)-1])
+		if len(fields) < 5 {
+			return unknownSchedulePerMonth
+		}
+		minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+		days := 30.4
+		switch {
+		case !isCronWildcard(dom):
+			days = cronCount(dom, 1, 31)
+		case !isCronWildcard(dow):
+			days = cronCount(dow, 1, 7) * 30.4 / 7
+		}
+		if !isCronWildcard(month) {
+			days *= cronCount(month, 1, 12) / 12
+		}
+		runs := cronCount(minute, 0, 59) * cronCount(hour, 0, 23) * days
+		if runs < 1 {
+			return 1
+		}
+		return int(runs)
+	}
+	return unknownSchedulePerMonth
+}
+
+// isCronWildcard reports whether a cron field matches every value
+func isCronWildcard(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// cronDays are the names a cron day-of-week field may use
+var cronDays = map[string]int{"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7}
+
+// cronCount is how many values in [lo, hi] a cron field matches
+func cronCount(field string, lo, hi int) float64 {
+	if isCronWildcard(field) {
+		return float64(hi - lo + 1)
+	}
+	value := func(s string) int {
+		if n, ok := cronDays[strings.ToUpper(s)]; ok {
+			return n
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return lo
+		}
+		return n
+	}
+
+	total := 0.0
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, s, found := strings.Cut(part, "/"); found {
+			if n, err := strconv.Atoi(s); err == nil && n > 0 {
+				step = n
+			}
+			part = base
+		}
+		from, to := lo, hi
+		if part != "*" {
+			if a, b, found := strings.Cut(part, "-"); found {
+				from, to = value(a), value(b)
+			} else {
+				from = value(strings.TrimSuffix(part, "L"))
+				if step == 1 {
+					to = from
+				}
+			}
+		}
+		if to >= from {
+			total += float64((to-from)/step + 1)
+		}
+	}
+	return total
+}
+
+// firstPositive returns the first of its arguments above zero
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
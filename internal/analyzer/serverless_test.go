@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func TestSchedulePerMonth(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int
+	}{
+		{"rate(5 minutes)", 8760},
+		{"rate(1 minute)", 43800},
+		{"rate(1 hour)", 730},
+		{"rate(2 days)", 15},
+		{"rate(1 week)", unknownSchedulePerMonth},
+		{"rate(0 minutes)", unknownSchedulePerMonth},
+		{"rate(five minutes)", unknownSchedulePerMonth},
+		{"cron(0 12 * * ? *)", 30},
+		{"cron(0/15 * * * ? *)", 2918},
+		{"cron(0,30 9-17 * * ? *)", 547},
+		{"cron(0 9 ? * MON-FRI *)", 21},
+		{"cron(0 9 ? * 2,4,6 *)", 13},
+		{"cron(0 8,20 * * ? *)", 60},
+		{"cron(0 0 1 * ? *)", 1},
+		{"cron(0 0 L * ? *)", 1},
+		{"cron(0 0 1 1 ? *)", 1}, // Yearly, rounded up to once
+		{"cron(0 0 * 1-6 ? *)", 15},
+		{"cron(0 12 *)", unknownSchedulePerMonth},
+		{"every day", unknownSchedulePerMonth},
+		{"", unknownSchedulePerMonth},
+	}
+	for _, tt := range tests {
+		if got := schedulePerMonth(tt.expr); got != tt.want {
+			t.Errorf("schedulePerMonth(%q) = %d, want %d", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronCount(t *testing.T) {
+	tests := []struct {
+		field  string
+		lo, hi int
+		want   float64
+	}{
+		{"*", 0, 59, 60},
+		{"?", 1, 7, 7},
+		{"5", 0, 59, 1},
+		{"*/10", 0, 59, 6},
+		{"10-20/5", 0, 59, 3},
+		{"1,15", 1, 31, 2},
+		{"MON-FRI", 1, 7, 5},
+		{"sat,sun", 1, 7, 2},
+		{"20-10", 0, 23, 0},
+	}
+	for _, tt := range tests {
+		if got := cronCount(tt.field, tt.lo, tt.hi); got != tt.want {
+			t.Errorf("cronCount(%q, %d, %d) = %v, want %v", tt.field, tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
+
+func TestParseServerlessConfig(t *testing.T) {
+	data := `service: api
+provider:
+  name: aws
+  runtime: nodejs20.x
+  memorySize: 512
+  architecture: arm64
+functions:
+  users:
+    handler: src/users.handler
+    events:
+      - httpApi: GET /users
+      - http:
+          path: users/{id}
+          method: delete
+      - http: "*"
+  nightly:
+    handler: src/nightly.handler
+    runtime: python3.12
+    memorySize: ${self:custom.memory}
+    timeout: 900
+    provisionedConcurrency: 2
+    events:
+      - schedule: rate(1 day)
+      - schedule:
+          rate:
+            - cron(0 12 * * ? *)
+            - rate(1 hour)
+      - sqs:
+          arn: arn:aws:sqs:us-east-1:123:jobs
+`
+	want := []types.ServerlessFunction{
+		{Source: "serverless", Name: "users", Provider: "aws", Handler: "src/users.handler", Runtime: "nodejs20.x", Architecture: "arm64",
+			MemoryMB: 512, TimeoutSeconds: serverlessTimeout, Triggers: []string{"http GET /users", "http DELETE /users/{id}", "http ANY /*"},
+			File: "serverless.yml", Line: 8},
+		{Source: "serverless", Name: "nightly", Provider: "aws", Handler: "src/nightly.handler", Runtime: "python3.12", Architecture: "arm64",
+			MemoryMB: 512, TimeoutSeconds: 900, ProvisionedConcurrency: 2,
+			Triggers:          []string{"schedule rate(1 day)", "schedule cron(0 12 * * ? *)", "schedule rate(1 hour)", "sqs"},
+			ScheduledPerMonth: 30 + 30 + 730, File: "serverless.yml", Line: 16},
+	}
+
+	got := parseServerlessConfig("serverless.yml", []byte(data))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseCloudFormation(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		data   string
+		want   []types.ServerlessFunction
+	}{
+		{
+			name:   "SAM with globals and events",
+			source: "sam",
+			data: `Transform: AWS::Serverless-2016-10-31
+Globals:
+  Function:
+    Runtime: python3.12
+    MemorySize: 256
+    Architectures: [arm64]
+Resources:
+  Api:
+    Type: AWS::Serverless::Function
+    Properties:
+      Handler: app.handler
+      Timeout: !Ref TimeoutParam
+      Events:
+        List:
+          Type: HttpApi
+          Properties: {Path: /items, Method: get}
+        Hourly:
+          Type: Schedule
+          Properties: {Schedule: rate(1 hour)}
+        Queue:
+          Type: SQS
+          Properties: {Queue: !GetAtt Jobs.Arn}
+  Jobs:
+    Type: AWS::SQS::Queue
+`,
+			want: []types.ServerlessFunction{
+				{Source: "sam", Name: "Api", Provider: "aws", Handler: "app.handler", Runtime: "python3.12", Architecture: "arm64",
+					MemoryMB: 256, TimeoutSeconds: lambdaTimeout, Triggers: []string{"http GET /items", "schedule rate(1 hour)", "sqs"},
+					ScheduledPerMonth: 730, File: "template.yaml", Line: 8},
+			},
+		},
+		{
+			name:   "CloudFormation JSON with separate trigger resources",
+			source: "cdk",
+			data: `{
+  "Resources": {
+    "Worker": {
+      "Type": "AWS::Lambda::Function",
+      "Properties": {"Handler": "index.handler", "Runtime": "nodejs20.x", "MemorySize": 1024}
+    },
+    "LogRetentionaae0aa3c": {"Type": "AWS::Lambda::Function", "Properties": {}},
+    "Mapping": {
+      "Type": "AWS::Lambda::EventSourceMapping",
+      "Properties": {"FunctionName": {"Ref": "Worker"}, "EventSourceArn": {"Fn::GetAtt": ["OrdersTable", "StreamArn"]}}
+    },
+    "Invoke": {
+      "Type": "AWS::Lambda::Permission",
+      "Properties": {"FunctionName": {"Fn::GetAtt": ["Worker", "Arn"]}, "Principal": "s3.amazonaws.com"}
+    },
+    "Live": {
+      "Type": "AWS::Lambda::Alias",
+      "Properties": {"FunctionName": {"Ref": "Worker"}, "ProvisionedConcurrencyConfig": {"ProvisionedConcurrentExecutions": 5}}
+    },
+    "Nightly": {
+      "Type": "AWS::Events::Rule",
+      "Properties": {"ScheduleExpression": "cron(0 3 * * ? *)", "Targets": [{"Arn": {"Fn::GetAtt": ["Worker", "Arn"]}, "Id": "t"}]}
+    }
+  }
+}`,
+			want: []types.ServerlessFunction{
+				{Source: "cdk", Name: "Worker", Provider: "aws", Handler: "index.handler", Runtime: "nodejs20.x", Architecture: "x86_64",
+					MemoryMB: 1024, TimeoutSeconds: lambdaTimeout, ProvisionedConcurrency: 5,
+					Triggers: []string{"dynamodb", "s3", "schedule cron(0 3 * * ? *)"}, ScheduledPerMonth: 30, File: "template.yaml", Line: 3},
+			},
+		},
+		{name: "no resources", source: "cloudformation", data: "AWSTemplateFormatVersion: '2010-09-09'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCloudFormation(tt.source, "template.yaml", []byte(tt.data))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package analyzer
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// terraformResource names the attributes that size one resource type. Paths
+// with dots go through nested blocks.
+type terraformResource struct {
+	role         string
+	instanceType string
+	count        string
+	minSize      string
+	maxSize      string
+	desiredSize  string
+	storageClass string
+	storageGB    string
+}
+
+// terraformResources are the resource types inventoried, by type name
+var terraformResources = map[string]terraformResource{
+	// AWS
+	"aws_instance":                                    {role: "compute", instanceType: "instance_type", count: "count", storageClass: "root_block_device.volume_type", storageGB: "root_block_device.volume_size"},
+	"aws_launch_template":                             {role: "compute", instanceType: "instance_type"},
+	"aws_launch_configuration":                        {role: "compute", instanceType: "instance_type", storageClass: "root_block_device.volume_type", storageGB: "root_block_device.volume_size"},
+	"aws_autoscaling_group":                           {role: "autoscaling", minSize: "min_size", maxSize: "max_size", desiredSize: "desired_capacity"},
+	"aws_eks_node_group":                              {role: "compute", instanceType: "instance_types", minSize: "scaling_config.min_size", maxSize: "scaling_config.max_size", desiredSize: "scaling_config.desired_size", storageGB: "disk_size"},
+	"aws_ecs_service":                                 {role: "autoscaling", desiredSize: "desired_count"},
+	"aws_appautoscaling_target":                       {role: "autoscaling", minSize: "min_capacity", maxSize: "max_capacity"},
+	"aws_db_instance":                                 {role: "database", instanceType: "instance_class", count: "count", storageClass: "storage_type", storageGB: "allocated_storage"},
+	"aws_rds_cluster_instance":                        {role: "database", instanceType: "instance_class", count: "count"},
+	"aws_elasticache_cluster":                         {role: "cache", instanceType: "node_type", count: "num_cache_nodes"},
+	"aws_elasticache_replication_group":               {role: "cache", instanceType: "node_type", count: "num_cache_clusters"},
+	"aws_ebs_volume":                                  {role: "storage", storageClass: "type", storageGB: "size"},
+	"aws_s3_bucket_lifecycle_configuration":           {role: "storage", storageClass: "rule.transition.storage_class"},
+	"aws_s3_bucket_intelligent_tiering_configuration": {role: "storage", storageClass: "tiering.access_tier"},
+
+	// Google Cloud
+	"google_compute_instance":          {role: "compute", instanceType: "machine_type", storageClass: "boot_disk.initialize_params.type", storageGB: "boot_disk.initialize_params.size"},
+	"google_compute_instance_template": {role: "compute", instanceType: "machine_type"},
+	"google_compute_autoscaler":        {role: "autoscaling", minSize: "autoscaling_policy.min_replicas", maxSize: "autoscaling_policy.max_replicas"},
+	"google_container_node_pool":       {role: "compute", instanceType: "node_config.machine_type", count: "node_count", minSize: "autoscaling.min_node_count", maxSize: "autoscaling.max_node_count", storageGB: "node_config.disk_size_gb"},
+	"google_sql_database_instance":     {role: "database", instanceType: "settings.tier", storageClass: "settings.disk_type", storageGB: "settings.disk_size"},
+	"google_redis_instance":            {role: "cache", storageGB: "memory_size_gb"},
+	"google_compute_disk":              {role: "storage", storageClass: "type", storageGB: "size"},
+	"google_storage_bucket":            {role: "storage", storageClass: "storage_class"},
+
+	// Azure
+	"azurerm_linux_virtual_machine":           {role: "compute", instanceType: "size", storageClass: "os_disk.storage_account_type", storageGB: "os_disk.disk_size_gb"},
+	"azurerm_windows_virtual_machine":         {role: "compute", instanceType: "size", storageClass: "os_disk.storage_account_type", storageGB: "os_disk.disk_size_gb"},
+	"azurerm_linux_virtual_machine_scale_set": {role: "compute", instanceType: "sku", desiredSize: "instances"},
+	"azurerm_kubernetes_cluster":              {role: "compute", instanceType: "default_node_pool.vm_size", count: "default_node_pool.node_count", minSize: "default_node_pool.min_count", maxSize: "default_node_pool.max_count"},
+	"azurerm_kubernetes_cluster_node_pool":    {role: "compute", instanceType: "vm_size", count: "node_count", minSize: "min_count", maxSize: "max_count"},
+	"azurerm_managed_disk":                    {role: "storage", storageClass: "storage_account_type", storageGB: "disk_size_gb"},
+	"azurerm_storage_account":                 {role: "storage", storageClass: "access_tier"},
+}
+
+// terraformProviders maps a resource type prefix to a pricing catalog ID
+var terraformProviders = map[string]string{
+	"aws":     "aws",
+	"google":  "gcp",
+	"azurerm": "azure",
+}
+
+// tfvarsFile reports whether a file sets Terraform variables
+func tfvarsFile(name string) bool {
+	return name == "terraform.tfvars" || strings.HasSuffix(name, ".auto.tfvars")
+}
+
+// terraformModule is the files of one directory, which Terraform treats as
+// one module sharing variables and providers
+type terraformModule struct {
+	files     map[string]*hclBlock // By path relative to the project root
+	tfvars    map[string]hclValue
+	variables map[string]hclValue // Defaults
+	locals    map[string]hclValue
+	regions   map[string]string // By catalog provider ID
+}
+
+// FindTerraformResources parses the project's Terraform and OpenTofu files
+// and inventories the resources that cost money: instances with their types,
+// autoscaling bounds, databases, caches and storage with its class. Variables
+// are resolved from their defaults, locals and *.tfvars files.
+func FindTerraformResources(root string) ([]types.InfraResource, error) {
+	modules := make(map[string]*terraformModule)
+	module := func(dir string) *terraformModule {
+		m := modules[dir]
+		if m == nil {
+			m = &terraformModule{
+				files:     make(map[string]*hclBlock),
+				tfvars:    make(map[string]hclValue),
+				variables: make(map[string]hclValue),
+				locals:    make(map[string]hclValue),
+				regions:   make(map[string]string),
+			}
+			modules[dir] = m
+		}
+		return m
+	}
+
+	err := walkProject(root, func(p, rel string, info os.FileInfo) error {
+		name := path.Base(rel)
+		isTF := strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tofu")
+		if !isTF && !tfvarsFile(name) || info.Size() > maxScannedFileBytes {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		file := parseHCL(data)
+		m := module(path.Dir(rel))
+
+		if !isTF {
+			for k, v := range file.Attrs {
+				m.tfvars[k] = v
+			}
+			return nil
+		}
+		m.files[rel] = file
+
+		for _, b := range file.Blocks {
+			switch {
+			case b.Type == "variable" && len(b.Labels) == 1:
+				if def, ok := b.Attrs["default"]; ok {
+					m.variables[b.Labels[0]] = def
+				}
+			case b.Type == "locals":
+				for k, v := range b.Attrs {
+					m.locals[k] = v
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []types.InfraResource
+	for _, m := range modules {
+		for _, b := range allBlocks(m.files) {
+			if b.block.Type != "provider" || len(b.block.Labels) != 1 {
+				continue
+			}
+			if id, ok := terraformProviders[b.block.Labels[0]]; ok && m.regions[id] == "" {
+				if v, ok := b.block.Attrs["region"]; ok {
+					m.regions[id] = m.resolve(v)
+				}
+			}
+		}
+
+		for _, b := range allBlocks(m.files) {
+			if b.block.Type != "resource" || len(b.block.Labels) != 2 {
+				continue
+			}
+			spec, ok := terraformResources[b.block.Labels[0]]
+			if !ok {
+				continue
+			}
+			resources = append(resources, m.resource(b.file, b.block, spec))
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].File != resources[j].File {
+			return resources[i].File < resources[j].File
+		}
+		return resources[i].Line < resources[j].Line
+	})
+	return resources, nil
+}
+
+// fileBlock is a top-level block with the file it is declared in
+type fileBlock struct {
+	file  string
+	block *hclBlock
+}
+
+// allBlocks lists the top-level blocks of a module's files, in file order
+func allBlocks(files map[string]*hclBlock) []fileBlock {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var blocks []fileBlock
+	for _, name := range names {
+		for _, b := range files[name].Blocks {
+			blocks = append(blocks, fileBlock{file: name, block: b})
+		}
+	}
+	return blocks
+}
+
+// resource inventories one resource block
+func (m *terraformModule) resource(file string, b *hclBlock, spec terraformResource) types.InfraResource {
+	resourceType := b.Labels[0]
+	r := types.InfraResource{
+		Source: "terraform",
+		Type:   resourceType,
+		Name:   b.Labels[1],
+		Role:   spec.role,
+		File:   file,
+		Line:   b.Line,
+	}
+
+	prefix := resourceType[:strings.Index(resourceType, "_")]
+	r.Provider = terraformProviders[prefix]
+	r.Region = m.regions[r.Provider]
+	for _, key := range []string{"region", "location"} {
+		if v, ok := b.Attrs[key]; ok {
+			if region := m.resolve(v); region != "" {
+				r.Region = region
+			}
+		}
+	}
+	if v, ok := b.Attrs["availability_zone"]; ok && r.Region == "" {
+		if zone := m.resolve(v); len(zone) > 1 {
+			r.Region = zone[:len(zone)-1] // us-east-1a -> us-east-1
+		}
+	}
+
+	r.InstanceType = m.string(b, spec.instanceType)
+	r.Count = m.int(b, spec.count)
+	r.MinSize = m.int(b, spec.minSize)
+	r.MaxSize = m.int(b, spec.maxSize)
+	r.DesiredSize = m.int(b, spec.desiredSize)
+	r.StorageClass = m.string(b, spec.storageClass)
+	r.StorageGB = m.int(b, spec.storageGB)
+	return r
+}
+
+// string resolves the attribute at path to a string, or the first element of
+// a list. Unresolvable values are "".
+func (m *terraformModule) string(b *hclBlock, attrPath string) string {
+	if attrPath == "" {
+		return ""
+	}
+	v, ok := b.attr(attrPath)
+	if !ok {
+		return ""
+	}
+	return m.resolve(v)
+}
+
+// int resolves the attribute at path to a whole number, or 0
+func (m *terraformModule) int(b *hclBlock, attrPath string) int {
+	s := m.string(b, attrPath)
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// maxRefDepth bounds how many references are followed, in case of cycles
+const maxRefDepth = 8
+
+// resolve follows var.* and local.* references to a literal
+func (m *terraformModule) resolve(v hclValue) string {
+	for depth := 0; depth < maxRefDepth; depth++ {
+		if v.IsSet {
+			return v.Literal
+		}
+		if len(v.List) > 0 {
+			return v.List[0]
+		}
+
+		var next hclValue
+		var ok bool
+		switch {
+		case strings.HasPrefix(v.Ref, "var."):
+			// Values from tfvars files take precedence over defaults
+			name := strings.TrimPrefix(v.Ref, "var.")
+			if next, ok = m.tfvars[name]; !ok {
+				next, ok = m.variables[name]
+			}
+		case strings.HasPrefix(v.Ref, "local."):
+			next, ok = m.locals[strings.TrimPrefix(v.Ref, "local.")]
+		}
+		if !ok {
+			return ""
+		}
+		v = next
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func TestFindTerraformResources(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []types.InfraResource
+	}{
+		{
+			name: "literals and the provider region",
+			files: map[string]string{"main.tf": `provider "aws" {
+  region = "us-west-2"
+}
+
+resource "aws_instance" "api" {
+  instance_type = "t3.large"
+  count         = 2
+  root_block_device {
+    volume_type = "gp3"
+    volume_size = 50
+  }
+}
+
+resource "aws_iam_role" "api" {
+  name = "api"
+}
+`},
+			want: []types.InfraResource{
+				{Source: "terraform", Type: "aws_instance", Name: "api", Role: "compute", Provider: "aws", Region: "us-west-2",
+					InstanceType: "t3.large", Count: 2, StorageClass: "gp3", StorageGB: 50, File: "main.tf", Line: 5},
+			},
+		},
+		{
+			name: "variables, tfvars and locals across the files of a module",
+			files: map[string]string{
+				"infra/variables.tf": `variable "db_class" {
+  default = "db.t3.micro"
+}
+variable "region" {
+  default = "eu-west-1"
+}
+variable "node_types" {
+  default = ["m5.large"]
+}
+locals {
+  storage = var.storage_gb
+}
+`,
+				"infra/terraform.tfvars": `db_class = "db.r6g.large"
+storage_gb = 100
+`,
+				"infra/main.tf": `resource "aws_db_instance" "main" {
+  instance_class    = var.db_class
+  allocated_storage = local.storage
+  availability_zone = "eu-central-1b"
+}
+
+resource "aws_eks_node_group" "workers" {
+  instance_types = var.node_types
+  region         = var.region
+  scaling_config {
+    min_size     = 1
+    max_size     = 5
+    desired_size = 2
+  }
+}
+`,
+			},
+			want: []types.InfraResource{
+				{Source: "terraform", Type: "aws_db_instance", Name: "main", Role: "database", Provider: "aws", Region: "eu-central-1",
+					InstanceType: "db.r6g.large", StorageGB: 100, File: "infra/main.tf", Line: 1},
+				{Source: "terraform", Type: "aws_eks_node_group", Name: "workers", Role: "compute", Provider: "aws", Region: "eu-west-1",
+					InstanceType: "m5.large", MinSize: 1, MaxSize: 5, DesiredSize: 2, File: "infra/main.tf", Line: 7},
+			},
+		},
+		{
+			name: "modules in other directories do not share variables",
+			files: map[string]string{
+				"a/main.tf": `variable "tier" {
+  default = "db-custom-2-7680"
+}
+`,
+				"b/main.tf": `resource "google_sql_database_instance" "db" {
+  region = "europe-west1"
+  settings {
+    tier      = var.tier
+    disk_size = 20
+  }
+}
+`,
+			},
+			want: []types.InfraResource{
+				{Source: "terraform", Type: "google_sql_database_instance", Name: "db", Role: "database", Provider: "gcp", Region: "europe-west1",
+					StorageGB: 20, File: "b/main.tf", Line: 1},
+			},
+		},
+		{
+			name: "reference cycles resolve to nothing",
+			files: map[string]string{"main.tf": `locals {
+  a = local.b
+  b = local.a
+}
+
+resource "azurerm_managed_disk" "data" {
+  location             = "westeurope"
+  storage_account_type = local.a
+  disk_size_gb         = "64"
+}
+`},
+			want: []types.InfraResource{
+				{Source: "terraform", Type: "azurerm_managed_disk", Name: "data", Role: "storage", Provider: "azure", Region: "westeurope",
+					StorageGB: 64, File: "main.tf", Line: 6},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			got, err := FindTerraformResources(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
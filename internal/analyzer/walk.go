@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// skippedDirs are never walked, whether or not they are gitignored
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	"__pycache__":  true,
+	".venv":        true,
+	"venv":         true,
+	".next":        true,
+	"coverage":     true,
+}
+
+// ignoreRule is a single .gitignore pattern
+type ignoreRule struct {
+	base     string // Directory of the .gitignore, relative to the root
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreRules holds the .gitignore patterns seen so far during a walk
+type ignoreRules struct {
+	rules []ignoreRule
+}
+
+// load reads the .gitignore in dir (relative to root), if there is one
+func (r *ignoreRules) load(root, dir string) {
+	f, err := os.Open(filepath.Join(root, dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// A slash anywhere but the end anchors the pattern to the .gitignore's directory
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		rule.pattern = line
+		r.rules = append(r.rules, rule)
+	}
+}
+
+// ignored reports whether rel (slash-separated, relative to root) is ignored.
+// Later rules override earlier ones, as in git.
+func (r *ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if rule.base != "." && rule.base != "" {
+			prefix := rule.base + "/"
+			if !strings.HasPrefix(rel, prefix) {
+				continue
+			}
+			target = strings.TrimPrefix(rel, prefix)
+		}
+
+		if rule.matches(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (rule ignoreRule) matches(target string) bool {
+	if rule.anchored {
+		return globMatch(rule.pattern, target)
+	}
+	// Unanchored patterns match the name at any depth
+	return globMatch(rule.pattern, path.Base(target))
+}
+
+// globMatch extends path.Match with "**", which matches any number of directories
+func globMatch(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+
+	parts := strings.SplitN(pattern, "**", 2)
+	prefix, suffix := strings.TrimSuffix(parts[0], "/"), strings.TrimPrefix(parts[1], "/")
+	if prefix != "" {
+		if !strings.HasPrefix(name, prefix+"/") && name != prefix {
+			return false
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, prefix), "/")
+	}
+	if suffix == "" {
+		return true
+	}
+
+	segments := strings.Split(name, "/")
+	for i := range segments {
+		if globMatch(suffix, strings.Join(segments[i:], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkProject calls fn for every regular file under root that is not inside a
+// skipped directory or excluded by a .gitignore. rel is slash-separated.
+func walkProject(root string, fn func(path, rel string, info os.FileInfo) error) error {
+	rules := &ignoreRules{}
+	rules.load(root, ".")
+
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if p == root {
+				return nil
+			}
+			if skippedDirs[info.Name()] || rules.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			rules.load(root, rel)
+			return nil
+		}
+
+		if !info.Mode().IsRegular() || rules.ignored(rel, false) {
+			return nil
+		}
+
+		return fn(p, rel, info)
+	})
+}
@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalkProject(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "skipped directories",
+			files: map[string]string{
+				"main.go":                     "",
+				"node_modules/express/app.js": "",
+				"vendor/lib/lib.go":           "",
+				"web/.next/page.js":           "",
+				".git/HEAD":                   "",
+			},
+			want: []string{"main.go"},
+		},
+		{
+			name: "unanchored patterns match at any depth",
+			files: map[string]string{
+				".gitignore":      "*.log\ntmp\n",
+				"app.log":         "",
+				"api/debug.log":   "",
+				"api/tmp/x.go":    "",
+				"api/server.go":   "",
+				"tmpfile/keep.go": "",
+			},
+			want: []string{".gitignore", "api/server.go", "tmpfile/keep.go"},
+		},
+		{
+			name: "anchored patterns match from the .gitignore directory",
+			files: map[string]string{
+				".gitignore":         "/generated\ndocs/*.html\n",
+				"generated/api.go":   "",
+				"api/generated/a.go": "",
+				"docs/index.html":    "",
+				"docs/guide/a.html":  "",
+			},
+			want: []string{".gitignore", "api/generated/a.go", "docs/guide/a.html"},
+		},
+		{
+			name: "directory-only patterns leave files alone",
+			files: map[string]string{
+				".gitignore":    "cache/\n",
+				"cache/data.go": "",
+				"lib/cache":     "",
+			},
+			want: []string{".gitignore", "lib/cache"},
+		},
+		{
+			name: "negation re-includes a file",
+			files: map[string]string{
+				".gitignore":     "# Secrets\n*.env\n!example.env\n",
+				"prod.env":       "",
+				"example.env":    "",
+				"config/dev.env": "",
+			},
+			want: []string{".gitignore", "example.env"},
+		},
+		{
+			name: "nested .gitignore applies below its directory",
+			files: map[string]string{
+				"api/.gitignore":   "*.gen.go\n",
+				"api/model.gen.go": "",
+				"api/model.go":     "",
+				"web/model.gen.go": "",
+			},
+			want: []string{"api/.gitignore", "api/model.go", "web/model.gen.go"},
+		},
+		{
+			name: "double star",
+			files: map[string]string{
+				".gitignore":               "**/fixtures\nassets/**/*.min.js\n",
+				"fixtures/a.json":          "",
+				"api/test/fixtures/b.json": "",
+				"assets/app.min.js":        "",
+				"assets/vendor/x.min.js":   "",
+				"assets/app.js":            "",
+			},
+			want: []string{".gitignore", "assets/app.js"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			var got []string
+			err := walkProject(root, func(path, rel string, info os.FileInfo) error {
+				got = append(got, rel)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("walked %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "main.js", false},
+		{"docs/*.md", "docs/a/b.md", false},
+		{"**/testdata", "testdata", true},
+		{"**/testdata", "a/b/testdata", true},
+		{"src/**", "src/a/b.go", true},
+		{"src/**", "lib/a.go", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/c", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,723 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/cost"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspaceFile lists the services of a monorepo, when detecting them from
+// their manifests is not enough
+const WorkspaceFile = "cloudpork.yaml"
+
+// workspaceConfig is the content of a WorkspaceFile
+type workspaceConfig struct {
+	Services []struct {
+		Name      string   `yaml:"name"`
+		Path      string   `yaml:"path"`
+		DependsOn []string `yaml:"depends_on"`
+	} `yaml:"services"`
+}
+
+// serviceManifests mark the root of a service, in order of preference when a
+// directory has several
+var serviceManifests = []string{
+	"go.mod", "package.json", "pyproject.toml", "Cargo.toml", "pom.xml", "build.gradle",
+	"build.gradle.kts", "Gemfile", "composer.json", "requirements.txt",
+}
+
+// workspaceMarkers are the files of a workspace root that declare its
+// members, which makes a manifest next to them tooling rather than a service
+var workspaceMarkers = []string{"go.work", "pnpm-workspace.yaml", "lerna.json", "nx.json", "turbo.json"}
+
+// nonServiceDirs hold manifests that are not deployed, such as test fixtures
+var nonServiceDirs = map[string]bool{
+	"testdata": true,
+	"fixtures": true,
+	"examples": true,
+	"example":  true,
+	"docs":     true,
+}
+
+// DetectWorkspace finds the services of a monorepo: those listed in its
+// WorkspaceFile or, without one, the outermost directories below the root
+// with a manifest of their own. A root with a manifest that does not declare
+// workspace members is one app. It returns no services when fewer than two
+// are found.
+func DetectWorkspace(root string) ([]types.WorkspaceService, string, error) {
+	services, err := readWorkspaceFile(root)
+	if err != nil || len(services) > 0 {
+		return services, WorkspaceFile, err
+	}
+
+	manifests := make(map[string]string) // Directory to manifest
+	err = walkProject(root, func(p, rel string, info os.FileInfo) error {
+		dir, name := path.Dir(rel), path.Base(rel)
+		if !containsString(serviceManifests, name) || isNonServiceDir(dir) {
+			return nil
+		}
+		if current, ok := manifests[dir]; !ok || manifestRank(name) < manifestRank(path.Base(current)) {
+			manifests[dir] = rel
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if _, ok := manifests["."]; ok && !isWorkspaceRoot(root) {
+		return nil, "", nil
+	}
+
+	dirs := make([]string, 0, len(manifests))
+	for dir := range manifests {
+		if dir != "." {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	// Manifests inside a service belong to it
+	for _, dir := range dirs {
+		if len(services) > 0 && strings.HasPrefix(dir, services[len(services)-1].Path+"/") {
+			continue
+		}
+		rel := manifests[dir]
+		services = append(services, types.WorkspaceService{
+			Name:     path.Base(dir),
+			Path:     dir,
+			Manifest: rel,
+			Module:   manifestModule(rel, readProjectFile(root, rel)),
+		})
+	}
+	if len(services) < 2 {
+		return nil, "", nil
+	}
+
+	// Services in different directories may share a name
+	count := make(map[string]int)
+	for _, s := range services {
+		count[s.Name]++
+	}
+	for i, s := range services {
+		if count[s.Name] > 1 {
+			services[i].Name = s.Path
+		}
+	}
+	return services, "manifests", nil
+}
+
+// readWorkspaceFile reads the services listed in the root's WorkspaceFile
+func readWorkspaceFile(root string) ([]types.WorkspaceService, error) {
+	data, err := os.ReadFile(filepath.Join(root, WorkspaceFile))
+	if err != nil {
+		return nil, nil
+	}
+	var config workspaceConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", WorkspaceFile, err)
+	}
+
+	var services []types.WorkspaceService
+	names := make(map[string]bool)
+	for _, s := range config.Services {
+		dir := path.Clean(filepath.ToSlash(s.Path))
+		if s.Path == "" || dir == "." || path.IsAbs(dir) || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("invalid %s: service %q needs a path inside the workspace", WorkspaceFile, s.Name)
+		}
+		if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir))); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid %s: %s is not a directory", WorkspaceFile, dir)
+		}
+
+		svc := types.WorkspaceService{Name: firstNonEmpty(s.Name, path.Base(dir)), Path: dir, DependsOn: s.DependsOn}
+		if names[svc.Name] {
+			return nil, fmt.Errorf("invalid %s: service %s is listed twice", WorkspaceFile, svc.Name)
+		}
+		names[svc.Name] = true
+		for _, manifest := range serviceManifests {
+			rel := path.Join(dir, manifest)
+			if data := readProjectFile(root, rel); data != nil {
+				svc.Manifest, svc.Module = rel, manifestModule(rel, data)
+				break
+			}
+		}
+		services = append(services, svc)
+	}
+
+	for _, s := range services {
+		for _, dep := range s.DependsOn {
+			if !names[dep] {
+				return nil, fmt.Errorf("invalid %s: service %s depends on unknown service %s", WorkspaceFile, s.Name, dep)
+			}
+		}
+	}
+	return services, nil
+}
+
+// readProjectFile returns the content of a file below root, or nil
+func readProjectFile(root, rel string) []byte {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// manifestRank orders the manifests of a directory by preference
+func manifestRank(name string) int {
+	for i, m := range serviceManifests {
+		if m == name {
+			return i
+		}
+	}
+	return len(serviceManifests)
+}
+
+// isNonServiceDir reports whether dir is inside a directory of fixtures or
+// examples
+func isNonServiceDir(dir string) bool {
+	for _, segment := range strings.Split(dir, "/") {
+		if nonServiceDirs[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// isWorkspaceRoot reports whether the root declares workspace members, with
+// a workspace marker file, npm or Yarn workspaces or a Cargo workspace
+func isWorkspaceRoot(root string) bool {
+	for _, marker := range workspaceMarkers {
+		if _, err := os.Stat(filepath.Join(root, marker)); err == nil {
+			return true
+		}
+	}
+
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if data := readProjectFile(root, "package.json"); data != nil && json.Unmarshal(data, &pkg) == nil && len(pkg.Workspaces) > 0 {
+		return true
+	}
+	data := readProjectFile(root, "Cargo.toml")
+	return bytes.Contains(data, []byte("[workspace]"))
+}
+
+// manifestModule returns the module or package name a manifest declares,
+// which other services depend on it by
+func manifestModule(rel string, data []byte) string {
+	switch path.Base(rel) {
+	case "go.mod":
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+				return strings.Trim(fields[1], `"`)
+			}
+		}
+	case "package.json", "composer.json":
+		var manifest struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(data, &manifest) == nil {
+			return manifest.Name
+		}
+	case "pyproject.toml", "Cargo.toml":
+		var manifest struct {
+			Project struct {
+				Name string `toml:"name"`
+			} `toml:"project"`
+			Package struct {
+				Name string `toml:"name"`
+			} `toml:"package"`
+			Tool struct {
+				Poetry struct {
+					Name string `toml:"name"`
+				} `toml:"poetry"`
+			} `toml:"tool"`
+		}
+		if toml.Unmarshal(data, &manifest) == nil {
+			return firstNonEmpty(manifest.Project.Name, manifest.Package.Name, manifest.Tool.Poetry.Name)
+		}
+	}
+	return ""
+}
+
+// analyzeWorkspace analyzes each service on its own and aggregates the results
+func (a *Analyzer) analyzeWorkspace(ctx context.Context, source string, services []types.WorkspaceService) (*types.CodeAnalysis, error) {
+	if a.pricing == nil {
+		a.pricing = cost.DefaultCatalog()
+	}
+
+	results := make([]*types.CodeAnalysis, len(services))
+	for i, svc := range services {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Progress goes to stderr so reports on stdout stay machine-readable
+		fmt.Fprintf(os.Stderr, "📦 Service %d/%d: %s (%s)\n", i+1, len(services), svc.Name, svc.Path)
+
+		service := New(filepath.Join(a.projectDir, filepath.FromSlash(svc.Path)), a.projectID, a.backend)
+		service.pricing, service.tiers, service.rates = a.pricing, a.tiers, a.rates
+		service.parallelism, service.passTimeout = a.parallelism, a.passTimeout
+		result, err := service.Analyze(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %v", svc.Name, err)
+		}
+		results[i] = result
+	}
+
+	// Infrastructure declared at the root is shared by the services, and its
+	// compose files tell which services talk to each other
+	var resources []types.InfraResource
+	for _, find := range []func(string) ([]types.InfraResource, error){FindTerraformResources, FindKubernetesResources, FindComposeServices} {
+		found, err := find(a.projectDir)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, found...)
+	}
+
+	return a.aggregate(source, services, results, resources), nil
+}
+
+// aggregate combines the service analyses into one for the workspace.
+// Resources, cost and counts are summed; lists are merged, with paths made
+// relative to the workspace root.
+func (a *Analyzer) aggregate(source string, services []types.WorkspaceService, results []*types.CodeAnalysis, resources []types.InfraResource) *types.CodeAnalysis {
+	analysis := a.newAnalysis()
+	facts := &types.StaticFacts{}
+	var functions []types.ServerlessFunction
+	duration := 0
+
+	for i, r := range results {
+		svc := &services[i]
+		dir := svc.Path
+		svc.Language, svc.Framework = r.Language, r.Framework
+		svc.ApiEndpoints, svc.DatabaseCalls = r.ApiEndpoints, r.DatabaseCalls
+		svc.ResourceUsage, svc.CostEstimates = r.ResourceUsage, r.CostEstimates
+		svc.Confidence = r.Confidence
+
+		analysis.Dependencies = mergeNames(analysis.Dependencies, r.Dependencies)
+		analysis.BackgroundJobs = mergeNames(analysis.BackgroundJobs, r.BackgroundJobs)
+		analysis.CacheUsage = mergeNames(analysis.CacheUsage, r.CacheUsage)
+		analysis.Datastores = mergeNames(analysis.Datastores, r.Datastores)
+		analysis.DatabaseCalls += r.DatabaseCalls
+		analysis.ApiEndpoints += r.ApiEndpoints
+		analysis.StatelessFuncs += r.StatelessFuncs
+		analysis.FileUploads = analysis.FileUploads || r.FileUploads
+		analysis.ComplexityScore = max(analysis.ComplexityScore, r.ComplexityScore)
+		analysis.EstimatedUsers = max(analysis.EstimatedUsers, r.EstimatedUsers) // The same users reach every service
+		analysis.LowConfidence = analysis.LowConfidence || r.LowConfidence
+		for _, pass := range r.HeuristicPasses {
+			analysis.HeuristicPasses = append(analysis.HeuristicPasses, svc.Name+": "+pass)
+		}
+
+		for _, e := range r.Endpoints {
+			e.File = inService(dir, e.File)
+			analysis.Endpoints = append(analysis.Endpoints, e)
+		}
+		for _, q := range r.QuerySites {
+			q.File = inService(dir, q.File)
+			analysis.QuerySites = append(analysis.QuerySites, q)
+		}
+		for _, b := range r.ScalingBottlenecks {
+			b.File = inService(dir, b.File)
+			analysis.ScalingBottlenecks = append(analysis.ScalingBottlenecks, b)
+		}
+		for _, rec := range r.Recommendations {
+			rec.File = inService(dir, rec.File)
+			analysis.Recommendations = append(analysis.Recommendations, rec)
+		}
+		for _, s := range r.SecurityIssues {
+			s.File = inService(dir, s.File)
+			analysis.SecurityIssues = append(analysis.SecurityIssues, s)
+		}
+		for _, res := range r.Infrastructure {
+			res.File = inService(dir, res.File)
+			analysis.Infrastructure = append(analysis.Infrastructure, res)
+		}
+		for _, c := range r.Containers {
+			c.File = inService(dir, c.File)
+			analysis.Containers = append(analysis.Containers, c)
+		}
+		if r.Serverless != nil {
+			for _, f := range r.Serverless.Functions {
+				f.File = inService(dir, f.File)
+				functions = append(functions, f)
+			}
+			duration = max(duration, r.Serverless.DurationMs)
+		}
+
+		p := &analysis.Performance
+		p.AvgResponseTime = max(p.AvgResponseTime, r.Performance.AvgResponseTime)
+		p.DatabaseQueries = max(p.DatabaseQueries, r.Performance.DatabaseQueries)
+		p.HasNPlusOneQuery = p.HasNPlusOneQuery || r.Performance.HasNPlusOneQuery
+		p.HasLargePayloads = p.HasLargePayloads || r.Performance.HasLargePayloads
+
+		u := &analysis.ResourceUsage
+		u.MemoryMB += r.ResourceUsage.MemoryMB
+		u.CPUCores += r.ResourceUsage.CPUCores
+		u.DatabaseConns += r.ResourceUsage.DatabaseConns
+		u.NetworkMbps += r.ResourceUsage.NetworkMbps
+		u.StorageGB += r.ResourceUsage.StorageGB
+
+		analysis.CostEstimates = sumCostEstimates(analysis.CostEstimates, r.CostEstimates)
+		analysis.Projections = sumProjections(analysis.Projections, r.Projections, svc.Name)
+		for _, l := range r.ScalingLimits {
+			l.Constraint = svc.Name + ": " + l.Constraint
+			analysis.ScalingLimits = append(analysis.ScalingLimits, l)
+		}
+
+		mergeFacts(facts, r.StaticFacts, dir)
+		mergeProvenance(analysis, r, svc.Name)
+	}
+	describeServiceCosts(analysis.CostEstimates, len(services))
+	for _, p := range analysis.Projections {
+		describeServiceCosts(p.CostEstimates, len(services))
+	}
+	for i := range analysis.Projections {
+		analysis.Projections[i].Binding = groupBindings(analysis.Projections[i].Binding)
+	}
+	analysis.ScalingLimits = groupLimits(analysis.ScalingLimits)
+
+	// Only the infrastructure outside every service is left to add, less the
+	// compose entries that run the services themselves
+	shared := 0
+	names := make(map[string]bool, len(services))
+	for _, s := range services {
+		names[strings.ToLower(s.Name)] = true
+	}
+	for _, r := range resources {
+		if r.Source == "docker-compose" && r.Type == "service" && names[strings.ToLower(r.Name)] {
+			continue
+		}
+		if serviceOf(services, r.File) == "" {
+			analysis.Infrastructure = append(analysis.Infrastructure, r)
+			shared++
+		}
+	}
+
+	if len(functions) > 0 {
+		rates := a.rates
+		if len(rates) == 0 {
+			rates = cost.DefaultRequestRates
+		}
+		analysis.Serverless = &types.ServerlessInfo{
+			Functions:   functions,
+			DurationMs:  duration,
+			Projections: cost.ProjectServerless(a.pricing, functions, duration, rates),
+		}
+	}
+
+	sort.SliceStable(facts.Languages, func(i, j int) bool { return facts.Languages[i].Lines > facts.Languages[j].Lines })
+	facts.Language = primaryLanguage(facts.Languages)
+	analysis.StaticFacts = facts
+
+	analysis.Language = serviceSummary(services, func(s types.WorkspaceService) string { return s.Language })
+	analysis.Framework = serviceSummary(services, func(s types.WorkspaceService) string { return s.Framework })
+	analysis.SetProvenance("language", types.SourceStatic, "languages of the services")
+	if analysis.Framework == "" {
+		analysis.Framework = "Unknown"
+	}
+
+	dependencies := workspaceDependencies(services, results, resources)
+	for _, d := range dependencies {
+		for i := range services {
+			if services[i].Name == d.From {
+				services[i].DependsOn = mergeNames(services[i].DependsOn, []string{d.To})
+			}
+		}
+	}
+	analysis.Workspace = &types.WorkspaceInfo{
+		Source:       source,
+		Services:     services,
+		Dependencies: dependencies,
+		Shared:       shared,
+	}
+	analysis.SetProvenance("workspace.services", types.SourceStatic, source)
+
+	analysis.Confidence = analysis.OverallConfidence()
+	return analysis
+}
+
+// inService makes a path relative to a service relative to the workspace root
+func inService(dir, file string) string {
+	if file == "" || path.IsAbs(file) || filepath.IsAbs(file) {
+		return file
+	}
+	return path.Join(dir, filepath.ToSlash(file))
+}
+
+// serviceOf returns the path of the service a file belongs to, or "" when it
+// is outside every service
+func serviceOf(services []types.WorkspaceService, file string) string {
+	for _, s := range services {
+		if strings.HasPrefix(file, s.Path+"/") {
+			return s.Path
+		}
+	}
+	return ""
+}
+
+// serviceSummary describes a property of the services, such as their
+// language, listing the most common value first: "Go (9), TypeScript (5)".
+// A property all services share is given once.
+func serviceSummary(services []types.WorkspaceService, value func(types.WorkspaceService) string) string {
+	counts := make(map[string]int)
+	var values []string
+	for _, s := range services {
+		v := value(s)
+		if v == "" || v == "Unknown" {
+			continue
+		}
+		if counts[v] == 0 {
+			values = append(values, v)
+		}
+		counts[v]++
+	}
+	if len(values) == 1 && counts[values[0]] == len(services) {
+		return values[0]
+	}
+
+	sort.SliceStable(values, func(i, j int) bool { return counts[values[i]] > counts[values[j]] })
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%s (%d)", v, counts[v])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sumCostEstimates adds a service's estimates to the workspace totals,
+// provider by provider
+func sumCostEstimates(totals, estimates []types.CostEstimate) []types.CostEstimate {
+	for _, e := range estimates {
+		i := 0
+		for i < len(totals) && totals[i].Provider != e.Provider {
+			i++
+		}
+		if i == len(totals) {
+			totals = append(totals, types.CostEstimate{
+				Provider:       e.Provider,
+				Region:         e.Region,
+				Currency:       e.Currency,
+				CatalogVersion: e.CatalogVersion,
+			})
+		}
+		t := &totals[i]
+		for _, item := range []struct{ total, add *types.CostItem }{
+			{&t.Compute, &e.Compute}, {&t.Database, &e.Database}, {&t.Storage, &e.Storage}, {&t.Network, &e.Network},
+		} {
+			item.total.Quantity += item.add.Quantity
+			item.total.Monthly = roundUSD(item.total.Monthly + item.add.Monthly)
+		}
+		t.MonthlyTotal = roundUSD(t.MonthlyTotal + e.MonthlyTotal)
+	}
+	return totals
+}
+
+// describeServiceCosts labels summed estimates, whose lines no longer
+// describe a single server or database
+func describeServiceCosts(estimates []types.CostEstimate, services int) {
+	for i := range estimates {
+		estimates[i].Compute.Description = fmt.Sprintf("compute of %d services", services)
+		estimates[i].Database.Description = fmt.Sprintf("databases of %d services", services)
+	}
+}
+
+// sumProjections adds a service's projections to the workspace totals, tier
+// by tier. Every service is projected to the same tiers.
+func sumProjections(totals, projections []types.ScaleProjection, service string) []types.ScaleProjection {
+	for i, p := range projections {
+		if i == len(totals) {
+			totals = append(totals, types.ScaleProjection{Users: p.Users, Concurrent: p.Concurrent})
+		}
+		t := &totals[i]
+		t.Resources.MemoryMB += p.Resources.MemoryMB
+		t.Resources.CPUCores += p.Resources.CPUCores
+		t.Resources.DatabaseConns += p.Resources.DatabaseConns
+		t.Resources.NetworkMbps += p.Resources.NetworkMbps
+		t.Resources.StorageGB += p.Resources.StorageGB
+		t.CostEstimates = sumCostEstimates(t.CostEstimates, p.CostEstimates)
+		for _, b := range p.Binding {
+			t.Binding = append(t.Binding, service+": "+b)
+		}
+	}
+	return totals
+}
+
+// maxListedServices bounds the services named in a grouped limit
+const maxListedServices = 3
+
+// serviceGroup names a limit and the services that run into it, e.g.
+// "Single-node CPU (api, users)" or "Single-node CPU (9 services)"
+func serviceGroup(constraint string, services []string) string {
+	if len(services) > maxListedServices {
+		return fmt.Sprintf("%s (%d services)", constraint, len(services))
+	}
+	return fmt.Sprintf("%s (%s)", constraint, strings.Join(services, ", "))
+}
+
+// groupBindings turns the "service: constraint" bindings of a tier into one
+// entry per constraint
+func groupBindings(bindings []string) []string {
+	var constraints []string
+	services := make(map[string][]string)
+	for _, b := range bindings {
+		service, constraint, _ := strings.Cut(b, ": ")
+		if services[constraint] == nil {
+			constraints = append(constraints, constraint)
+		}
+		services[constraint] = append(services[constraint], service)
+	}
+
+	grouped := make([]string, len(constraints))
+	for i, c := range constraints {
+		grouped[i] = serviceGroup(c, services[c])
+	}
+	return grouped
+}
+
+// groupLimits merges the "service: constraint" limits of the services into
+// one per constraint, reached at the lowest threshold of any of them
+func groupLimits(limits []types.ScalingLimit) []types.ScalingLimit {
+	var grouped []types.ScalingLimit
+	services := make(map[string][]string)
+	for _, l := range limits {
+		service, constraint, _ := strings.Cut(l.Constraint, ": ")
+		if services[constraint] == nil {
+			l.Constraint = constraint
+			grouped = append(grouped, l)
+		}
+		services[constraint] = append(services[constraint], service)
+
+		for i := range grouped {
+			g := &grouped[i]
+			if g.Constraint != constraint {
+				continue
+			}
+			g.ThresholdUsers = min(g.ThresholdUsers, l.ThresholdUsers)
+			if l.BindingAtUsers > 0 && (g.BindingAtUsers == 0 || l.BindingAtUsers < g.BindingAtUsers) {
+				g.BindingAtUsers = l.BindingAtUsers
+			}
+		}
+	}
+
+	for i := range grouped {
+		grouped[i].Constraint = serviceGroup(grouped[i].Constraint, services[grouped[i].Constraint])
+	}
+	return grouped
+}
+
+// roundUSD rounds an amount to cents
+func roundUSD(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// mergeFacts adds a service's static facts to the workspace's
+func mergeFacts(facts, service *types.StaticFacts, dir string) {
+	if service == nil {
+		return
+	}
+	facts.Files += service.Files
+	facts.Lines += service.Lines
+	for _, l := range service.Languages {
+		i := 0
+		for i < len(facts.Languages) && facts.Languages[i].Language != l.Language {
+			i++
+		}
+		if i == len(facts.Languages) {
+			facts.Languages = append(facts.Languages, types.LanguageStats{Language: l.Language})
+		}
+		facts.Languages[i].Files += l.Files
+		facts.Languages[i].Lines += l.Lines
+	}
+	for _, m := range service.Manifests {
+		facts.Manifests = append(facts.Manifests, inService(dir, m))
+	}
+	for _, d := range service.Dependencies {
+		d.Manifest = inService(dir, d.Manifest)
+		facts.Dependencies = append(facts.Dependencies, d)
+	}
+}
+
+// mergeProvenance gives each aggregated field the provenance of its least
+// trustworthy service, since a sum is only as good as its weakest term
+func mergeProvenance(analysis, service *types.CodeAnalysis, name string) {
+	for field, p := range service.Provenance {
+		if current, ok := analysis.FieldSource(field); ok && current.Confidence <= p.Confidence {
+			continue
+		}
+		p.Note = strings.TrimSuffix(name+": "+p.Note, ": ")
+		if analysis.Provenance == nil {
+			analysis.Provenance = make(map[string]types.FieldProvenance)
+		}
+		analysis.Provenance[field] = p
+	}
+}
+
+// workspaceDependencies finds which services depend on which: as listed in
+// the WorkspaceFile, by depending on the module another service's manifest
+// declares, or through depends_on between compose services named after them
+func workspaceDependencies(services []types.WorkspaceService, results []*types.CodeAnalysis, resources []types.InfraResource) []types.ServiceDependency {
+	var deps []types.ServiceDependency
+	seen := make(map[string]bool)
+	add := func(from, to, via string) {
+		if key := from + "\x00" + to; from != to && !seen[key] {
+			seen[key] = true
+			deps = append(deps, types.ServiceDependency{From: from, To: to, Via: via})
+		}
+	}
+
+	byName := make(map[string]string)   // Lower-case service name to name
+	byModule := make(map[string]string) // Lower-case module to service name
+	for _, s := range services {
+		byName[strings.ToLower(s.Name)] = s.Name
+		if s.Module != "" {
+			byModule[strings.ToLower(s.Module)] = s.Name
+		}
+	}
+
+	for i, s := range services {
+		for _, to := range s.DependsOn {
+			add(s.Name, to, WorkspaceFile)
+		}
+		if facts := results[i].StaticFacts; facts != nil {
+			for _, d := range facts.Dependencies {
+				if to, ok := byModule[strings.ToLower(d.Name)]; ok && !d.Dev {
+					add(s.Name, to, "manifest")
+				}
+			}
+		}
+	}
+
+	for _, r := range resources {
+		from, ok := byName[strings.ToLower(r.Name)]
+		if r.Source != "docker-compose" || r.Type != "service" || !ok {
+			continue
+		}
+		for _, dep := range r.DependsOn {
+			if to, ok := byName[strings.ToLower(dep)]; ok {
+				add(from, to, "docker-compose")
+			}
+		}
+	}
+
+	sort.SliceStable(deps, func(i, j int) bool {
+		if deps[i].From != deps[j].From {
+			return deps[i].From < deps[j].From
+		}
+		return deps[i].To < deps[j].To
+	})
+	return deps
+}
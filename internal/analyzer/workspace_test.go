@@ -0,0 +1,165 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// service is the part of a WorkspaceService the tests compare
+type service struct {
+	Name, Path, Manifest, Module string
+}
+
+func TestDetectWorkspace(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		source string
+		want   []service
+	}{
+		{
+			name: "service manifests below the root",
+			files: map[string]string{
+				"services/api/go.mod":                "module github.com/acme/api\n",
+				"services/api/tools/go.mod":          "module github.com/acme/api/tools\n",
+				"services/web/package.json":          `{"name": "@acme/web"}`,
+				"services/web/requirements.txt":      "",
+				"services/worker/pyproject.toml":     "[project]\nname = \"worker\"\n",
+				"services/api/testdata/go.mod":       "module fixture\n",
+				"examples/demo/package.json":         `{"name": "demo"}`,
+				"node_modules/left-pad/package.json": `{"name": "left-pad"}`,
+			},
+			source: "manifests",
+			want: []service{
+				{"api", "services/api", "services/api/go.mod", "github.com/acme/api"},
+				{"web", "services/web", "services/web/package.json", "@acme/web"},
+				{"worker", "services/worker", "services/worker/pyproject.toml", "worker"},
+			},
+		},
+		{
+			name: "a root manifest makes one app",
+			files: map[string]string{
+				"package.json":           `{"name": "app"}`,
+				"client/package.json":    `{"name": "client"}`,
+				"functions/package.json": `{"name": "functions"}`,
+			},
+		},
+		{
+			name: "a workspace root declares its members",
+			files: map[string]string{
+				"package.json":          `{"name": "root", "workspaces": ["apps/*"]}`,
+				"apps/web/package.json": `{"name": "web"}`,
+				"apps/api/Cargo.toml":   "[package]\nname = \"api\"\n",
+			},
+			source: "manifests",
+			want: []service{
+				{"api", "apps/api", "apps/api/Cargo.toml", "api"},
+				{"web", "apps/web", "apps/web/package.json", "web"},
+			},
+		},
+		{
+			name: "shared names are replaced by paths",
+			files: map[string]string{
+				"go.work":              "go 1.21\n",
+				"go.mod":               "module tools\n",
+				"billing/api/go.mod":   "module billing\n",
+				"shipping/api/go.mod":  "module shipping\n",
+				"shipping/web/pom.xml": "<project/>",
+			},
+			source: "manifests",
+			want: []service{
+				{"billing/api", "billing/api", "billing/api/go.mod", "billing"},
+				{"shipping/api", "shipping/api", "shipping/api/go.mod", "shipping"},
+				{"web", "shipping/web", "shipping/web/pom.xml", ""},
+			},
+		},
+		{
+			name:  "one service is not a workspace",
+			files: map[string]string{"api/go.mod": "module api\n", "README.md": ""},
+		},
+		{
+			name: "the workspace file wins",
+			files: map[string]string{
+				WorkspaceFile: `services:
+  - name: gateway
+    path: edge/
+    depends_on: [users]
+  - path: ./users
+`,
+				"edge/main.go":       "",
+				"users/go.mod":       "module example.com/users\n",
+				"other/package.json": `{"name": "other"}`,
+			},
+			source: WorkspaceFile,
+			want: []service{
+				{"gateway", "edge", "", ""},
+				{"users", "users", "users/go.mod", "example.com/users"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			services, source, err := DetectWorkspace(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []service
+			for _, s := range services {
+				got = append(got, service{s.Name, s.Path, s.Manifest, s.Module})
+			}
+			if source != tt.source || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v from %q\nwant %+v from %q", got, source, tt.want, tt.source)
+			}
+		})
+	}
+}
+
+func TestDetectWorkspaceFileErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		workspace string
+		wantErr   string
+	}{
+		{"not yaml", "services: [", "invalid " + WorkspaceFile},
+		{"no path", "services:\n  - name: api\n", `service "api" needs a path`},
+		{"the root itself", "services:\n  - name: api\n    path: .\n", "needs a path inside the workspace"},
+		{"outside the root", "services:\n  - path: ../api\n", "needs a path inside the workspace"},
+		{"missing directory", "services:\n  - path: missing\n", "missing is not a directory"},
+		{"listed twice", "services:\n  - path: api\n  - name: api\n    path: web\n", "service api is listed twice"},
+		{"unknown dependency", "services:\n  - path: api\n    depends_on: [db]\n", "depends on unknown service db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{WorkspaceFile: tt.workspace, "api/main.go": "", "web/main.go": ""})
+
+			_, _, err := DetectWorkspace(root)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DetectWorkspace error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManifestModule(t *testing.T) {
+	tests := []struct {
+		rel, data, want string
+	}{
+		{"go.mod", "// Comment\nmodule \"example.com/api\"\n", "example.com/api"},
+		{"api/package.json", `{"name": "@acme/api", "version": "1.0.0"}`, "@acme/api"},
+		{"composer.json", `{"name": "acme/shop"}`, "acme/shop"},
+		{"pyproject.toml", "[tool.poetry]\nname = \"billing\"\n", "billing"},
+		{"Cargo.toml", "[package]\nname = \"engine\"\n", "engine"},
+		{"Gemfile", "gem 'rails'\n", ""},
+		{"package.json", "{", ""},
+	}
+	for _, tt := range tests {
+		if got := manifestModule(tt.rel, []byte(tt.data)); got != tt.want {
+			t.Errorf("manifestModule(%s) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/Cloudpork/cloudpork-agent/internal/config"
@@ -15,23 +17,23 @@ import (
 const (
 	defaultBaseURL = "https://api.cloudpork.com"
 	defaultTimeout = 30 * time.Second
-	userAgent      = "CloudPork-Agent/1.0"
+	defaultVersion = "dev"
 )
 
 // Client handles communication with CloudPork API
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	maxAttempts int
+	version     string
+	
+	// OnRetry, if set, is called before each retry of a failed request
+	OnRetry RetryFunc
 }
 
 // NewClient creates a new API client
 func NewClient() *Client {
-	return &Client{
-		baseURL: defaultBaseURL,
-		httpClient: &http.Client{
-			Timeout: defaultTimeout,
-		},
-	}
+	return NewClientWithURL(defaultBaseURL)
 }
 
 // NewClientWithURL creates a new API client with custom base URL
@@ -41,15 +43,29 @@ func NewClientWithURL(baseURL string) *Client {
 		httpClient: &http.Client{
 			Timeout: defaultTimeout,
 		},
+		maxAttempts: defaultMaxAttempts,
+		version:     defaultVersion,
 	}
 }
 
-// SendAnalysis sends analysis results to CloudPork API
-func (c *Client) SendAnalysis(analysis *types.CodeAnalysis) error {
+// BaseURL returns the API server the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// SetMaxAttempts sets how many times a request is tried before giving up
+func (c *Client) SetMaxAttempts(n int) {
+	c.maxAttempts = n
+}
+
+// SendAnalysis sends analysis results to CloudPork API. Network errors, 5xx
+// and 429 responses are retried with backoff; every attempt carries the
+// analysis' idempotency key so it is counted once. Failures are *Error.
+func (c *Client) SendAnalysis(ctx context.Context, analysis *types.CodeAnalysis) error {
 	// Get API key from config
 	apiKey, err := config.GetAPIKey()
 	if err != nil {
-		return fmt.Errorf("no API key found: %v", err)
+		return &Error{Kind: ErrAuth, Message: "no API key found", Err: err}
 	}
 	
 	// Prepare request payload
@@ -59,7 +75,7 @@ func (c *Client) SendAnalysis(analysis *types.CodeAnalysis) error {
 		Platform     string `json:"platform"`
 	}{
 		CodeAnalysis: analysis,
-		AgentVersion: "1.0.0", // TODO: Get from build info
+		AgentVersion: c.version,
 		Platform:     "cli",
 	}
 	
@@ -68,92 +84,84 @@ func (c *Client) SendAnalysis(analysis *types.CodeAnalysis) error {
 		return fmt.Errorf("failed to marshal analysis: %v", err)
 	}
 	
-	// Create HTTP request
-	url := fmt.Sprintf("%s/v1/analysis", c.baseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	header := c.header(apiKey)
+	header.Set("Content-Type", "application/json")
+	header.Set("Idempotency-Key", IdempotencyKey(analysis))
+	
+	resp, err := c.doWithRetry(ctx, jsonRequest("POST", c.url("/v1/analysis"), jsonData, header))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return err
 	}
+	resp.Body.Close()
 	
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	req.Header.Set("User-Agent", userAgent)
+	return nil
+}
+
+// ValidateAPIKey checks if the API key is valid
+func (c *Client) ValidateAPIKey(ctx context.Context, apiKey string) error {
+	resp, err := c.do(ctx, "GET", "/v1/auth/validate", nil, c.header(apiKey))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
 	
-	// Send request
-	resp, err := c.httpClient.Do(req)
+	return nil
+}
+
+// GetSubscription retrieves the plan and usage of the account an API key
+// belongs to
+func (c *Client) GetSubscription(ctx context.Context, apiKey string) (*types.SubscriptionInfo, error) {
+	resp, err := c.do(ctx, "GET", "/v1/subscription", nil, c.header(apiKey))
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	
-	// Handle response
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	var subscription types.SubscriptionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&subscription); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 	
-	return nil
+	return &subscription, nil
 }
 
-// ValidateAPIKey checks if the API key is valid
-func (c *Client) ValidateAPIKey(apiKey string) error {
-	url := fmt.Sprintf("%s/v1/auth/validate", c.baseURL)
-	req, err := http.NewRequest("GET", url, nil)
+// CreateTrial signs up for a trial account
+func (c *Client) CreateTrial(ctx context.Context, signup TrialSignup) (*TrialInfo, error) {
+	jsonData, err := json.Marshal(signup)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
 	
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	req.Header.Set("User-Agent", userAgent)
+	header := c.header("")
+	header.Set("Content-Type", "application/json")
 	
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, "POST", "/v1/auth/trial", jsonData, header)
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	
-	if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("invalid API key")
-	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("validation failed with status %d", resp.StatusCode)
+	var trialInfo TrialInfo
+	if err := json.NewDecoder(resp.Body).Decode(&trialInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 	
-	return nil
+	return &trialInfo, nil
 }
 
 // GetProjectInfo retrieves project information
-func (c *Client) GetProjectInfo(projectID string) (*ProjectInfo, error) {
+func (c *Client) GetProjectInfo(ctx context.Context, projectID string) (*ProjectInfo, error) {
 	apiKey, err := config.GetAPIKey()
 	if err != nil {
-		return nil, fmt.Errorf("no API key found: %v", err)
+		return nil, &Error{Kind: ErrAuth, Message: "no API key found", Err: err}
 	}
 	
-	url := fmt.Sprintf("%s/v1/projects/%s", c.baseURL, projectID)
-	req, err := http.NewRequest("GET", url, nil)
+	resp, err := c.do(ctx, "GET", "/v1/projects/"+url.PathEscape(projectID), nil, c.header(apiKey))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	req.Header.Set("User-Agent", userAgent)
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("project not found")
-	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
-	}
-	
 	var projectInfo ProjectInfo
 	if err := json.NewDecoder(resp.Body).Decode(&projectInfo); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %v", err)
@@ -162,6 +170,60 @@ func (c *Client) GetProjectInfo(projectID string) (*ProjectInfo, error) {
 	return &projectInfo, nil
 }
 
+// header returns the headers every request carries, authenticated with
+// apiKey unless it is empty
+func (c *Client) header(apiKey string) http.Header {
+	header := make(http.Header)
+	header.Set("User-Agent", c.userAgent())
+	header.Set("Accept", "application/json")
+	if apiKey != "" {
+		header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+	return header
+}
+
+// url joins an API path to the base URL
+func (c *Client) url(path string) string {
+	return c.baseURL + path
+}
+
+// do sends a request once. Failures are *Error.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header = header
+	
+	resp, apiErr, _ := c.attempt(req)
+	if apiErr != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// TrialSignup is the sign-up form of a trial account
+type TrialSignup struct {
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Company string `json:"company"`
+}
+
+// TrialInfo is a newly created trial account
+type TrialInfo struct {
+	APIKey             string    `json:"api_key"`
+	ProjectID          string    `json:"project_id"`
+	TrialEndsAt        time.Time `json:"trial_ends_at"`
+	AnalysesRemaining  int       `json:"analyses_remaining"`
+}
+
 // ProjectInfo represents project information from the API
 type ProjectInfo struct {
 	ID          string    `json:"id"`
@@ -171,4 +233,4 @@ type ProjectInfo struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	AnalysisCount int     `json:"analysis_count"`
 	LastAnalysis  *time.Time `json:"last_analysis,omitempty"`
-}
\ No newline at end of file
+}
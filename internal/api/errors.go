@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorKind classifies a failed API request by what the caller can do about it
+type ErrorKind int
+
+const (
+	// ErrTransient is a network error, a 5xx or a 429: retrying later may succeed
+	ErrTransient ErrorKind = iota
+	// ErrAuth is a missing, invalid or revoked API key
+	ErrAuth
+	// ErrQuota is a subscription whose analyses are used up
+	ErrQuota
+	// ErrValidation is a request the API rejected as malformed
+	ErrValidation
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrAuth:
+		return "auth"
+	case ErrQuota:
+		return "quota"
+	case ErrValidation:
+		return "validation"
+	}
+	return "transient"
+}
+
+// Error is a failed API request
+type Error struct {
+	Kind       ErrorKind
+	StatusCode int // 0 for network errors
+	Message    string
+	Attempts   int // Requests made before giving up
+	Err        error
+}
+
+func (e *Error) Error() string {
+	var msg string
+	switch {
+	case e.StatusCode != 0 && e.Message != "":
+		msg = fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+	case e.StatusCode != 0:
+		msg = fmt.Sprintf("API request failed with status %d", e.StatusCode)
+	case e.Message != "":
+		msg = fmt.Sprintf("%s: %v", e.Message, e.Err)
+	default:
+		msg = fmt.Sprintf("request failed: %v", e.Err)
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" (after %d attempts)", e.Attempts)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether retrying the request later may succeed
+func (e *Error) Temporary() bool {
+	return e.Kind == ErrTransient
+}
+
+// statusKind classifies an HTTP error status
+func statusKind(status int) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusPaymentRequired:
+		return ErrQuota
+	case status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500:
+		return ErrTransient
+	}
+	return ErrValidation
+}
+
+// maxErrorMessage bounds how much of an error body ends up in a message
+const maxErrorMessage = 300
+
+// errorMessage extracts the message of an error response: the "error" or
+// "message" field of a JSON body, or the body itself
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		if parsed.Error != "" {
+			return parsed.Error
+		}
+		if parsed.Message != "" {
+			return parsed.Message
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > maxErrorMessage {
+		msg = msg[:maxErrorMessage] + "..."
+	}
+	return msg
+}
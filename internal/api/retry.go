@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+const (
+	defaultMaxAttempts = 5
+	baseBackoff        = time.Second
+	maxBackoff         = 30 * time.Second
+	// maxRetryAfter is the longest Retry-After the client waits for; a server
+	// asking for more gets an error instead of a hung command
+	maxRetryAfter = 2 * time.Minute
+)
+
+// RetryFunc is told about each retry before the client waits for it
+type RetryFunc func(attempt, maxAttempts int, wait time.Duration, err error)
+
+// IdempotencyKey identifies an analysis across retries and re-sends, so the
+// API counts it once against the subscription's analyses
+func IdempotencyKey(analysis *types.CodeAnalysis) string {
+	sum := sha256.Sum256([]byte(analysis.ProjectID + "\x00" + analysis.Timestamp.UTC().Format(time.RFC3339Nano)))
+	return "cpa_" + hex.EncodeToString(sum[:16])
+}
+
+// doWithRetry sends a request built by newRequest, retrying transient
+// failures with exponential backoff and full jitter. A Retry-After header
+// replaces the backoff. The response of a successful request is returned
+// open; failures are *Error.
+func (c *Client) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	maxAttempts := c.maxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, apiErr, wait := c.attempt(req.WithContext(ctx))
+		if apiErr == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !apiErr.Temporary() || attempt == maxAttempts || wait > maxRetryAfter {
+			apiErr.Attempts = attempt
+			return nil, apiErr
+		}
+
+		if wait == 0 {
+			wait = backoff(attempt)
+		}
+		if c.OnRetry != nil {
+			c.OnRetry(attempt, maxAttempts, wait, apiErr)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends a request once. On failure it returns the error and how long
+// the server asked the client to wait, if it did.
+func (c *Client) attempt(req *http.Request) (*http.Response, *Error, time.Duration) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &Error{Kind: ErrTransient, Err: err}, 0
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil, 0
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return nil, &Error{
+		Kind:       statusKind(resp.StatusCode),
+		StatusCode: resp.StatusCode,
+		Message:    errorMessage(body),
+	}, retryAfter(resp.Header.Get("Retry-After"), time.Now())
+}
+
+// backoff is the wait before retry n: a random duration up to
+// baseBackoff·2^(n-1), capped at maxBackoff
+func backoff(n int) time.Duration {
+	ceiling := maxBackoff
+	if n < 16 {
+		ceiling = min(baseBackoff<<(n-1), maxBackoff)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + 1
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// jsonRequest builds requests that send the same JSON body on every attempt
+func jsonRequest(method, url string, body []byte, header http.Header) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header = header.Clone()
+		return req, nil
+	}
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Config selects the API server and how to reach it. The zero value talks to
+// api.cloudpork.com through the proxy set in the environment, if any.
+type Config struct {
+	BaseURL    string        // Defaults to https://api.cloudpork.com
+	Version    string        // Agent version, sent in the User-Agent and with analyses
+	Proxy      string        // HTTP(S) proxy URL; HTTPS_PROXY, HTTP_PROXY and NO_PROXY apply when empty
+	CACert     string        // PEM bundle trusted in addition to the system roots
+	ClientCert string        // PEM client certificate for mutual TLS
+	ClientKey  string        // PEM private key of ClientCert
+	Timeout    time.Duration // Per request; defaults to 30s
+}
+
+// New creates an API client from cfg
+func New(cfg Config) (*Client, error) {
+	baseURL := defaultBaseURL
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid API URL %q: expected http(s)://host[:port][/path]", cfg.BaseURL)
+		}
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	version := cfg.Version
+	if version == "" {
+		version = defaultVersion
+	}
+
+	return &Client{
+		baseURL:     baseURL,
+		httpClient:  &http.Client{Timeout: timeout, Transport: transport},
+		maxAttempts: defaultMaxAttempts,
+		version:     version,
+	}, nil
+}
+
+// newTransport builds the HTTP transport for a proxy, extra CA certificates
+// and a client certificate
+func newTransport(cfg Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.CACert == "" && cfg.ClientCert == "" && cfg.ClientKey == "" {
+		return transport, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, fmt.Errorf("mutual TLS needs both a client certificate and its key")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// userAgent identifies the agent build and platform to the API
+func (c *Client) userAgent() string {
+	return fmt.Sprintf("CloudPork-Agent/%s (%s/%s)", c.version, runtime.GOOS, runtime.GOARCH)
+}
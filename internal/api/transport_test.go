@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPKI is a CA with a server certificate for 127.0.0.1 and a client
+// certificate, the CA and client written out as PEM files
+type testPKI struct {
+	pool       *x509.CertPool
+	server     tls.Certificate
+	caFile     string
+	clientCert string
+	clientKey  string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	ca, caKey, caPEM := issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	_, serverKey, serverPEM := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "api.test"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	_, clientKey, clientPEM := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "agent"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	server, err := tls.X509KeyPair(serverPEM, keyPEM(t, serverKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki := &testPKI{
+		pool:       x509.NewCertPool(),
+		server:     server,
+		caFile:     writeFile(t, dir, "ca.pem", caPEM),
+		clientCert: writeFile(t, dir, "client.pem", clientPEM),
+		clientKey:  writeFile(t, dir, "client-key.pem", keyPEM(t, clientKey)),
+	}
+	pki.pool.AddCert(ca)
+	return pki
+}
+
+// issue creates a certificate from template, signed by parent, or
+// self-signed when parent is nil
+func issue(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func keyPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// subscriptionHandler answers /v1/subscription and records the User-Agent
+func subscriptionHandler(userAgent *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(`{"plan": "trial"}`))
+	}
+}
+
+func TestNewMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	var userAgent string
+	ts := httptest.NewUnstartedServer(subscriptionHandler(&userAgent))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientCAs:    pki.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"trusted CA and client certificate", Config{CACert: pki.caFile, ClientCert: pki.clientCert, ClientKey: pki.clientKey}, ""},
+		{"server not trusted", Config{ClientCert: pki.clientCert, ClientKey: pki.clientKey}, "certificate"},
+		{"no client certificate", Config{CACert: pki.caFile}, "certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.BaseURL = ts.URL
+			tt.cfg.Version = "1.2.3"
+			client, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.SetMaxAttempts(1)
+
+			userAgent = ""
+			_, err = client.GetSubscription(context.Background(), "cp_test")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "CloudPork-Agent/1.2.3 (" + runtime.GOOS + "/" + runtime.GOARCH + ")"; userAgent != want {
+					t.Errorf("User-Agent = %q, want %q", userAgent, want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GetSubscription error = %v, want a TLS %s error", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewProxy(t *testing.T) {
+	// A plain HTTP proxy receives the absolute URL of the API
+	var proxied, userAgent string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		subscriptionHandler(&userAgent)(w, r)
+	}))
+	defer proxy.Close()
+
+	client, err := New(Config{BaseURL: "http://api.cloudpork.invalid/", Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMaxAttempts(1)
+	if _, err := client.GetSubscription(context.Background(), "cp_test"); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://api.cloudpork.invalid/v1/subscription" {
+		t.Errorf("proxy got %q, want the API URL", proxied)
+	}
+	if !strings.HasPrefix(userAgent, "CloudPork-Agent/"+defaultVersion+" ") {
+		t.Errorf("User-Agent = %q, want the default version", userAgent)
+	}
+}
+
+func TestNewConfigErrors(t *testing.T) {
+	pki := newTestPKI(t)
+	notPEM := writeFile(t, t.TempDir(), "ca.txt", []byte("not a certificate"))
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"relative API URL", Config{BaseURL: "api.cloudpork.com"}, `invalid API URL "api.cloudpork.com"`},
+		{"unsupported scheme", Config{BaseURL: "ftp://api.cloudpork.com"}, "invalid API URL"},
+		{"proxy without host", Config{Proxy: "proxy:3128"}, `invalid proxy URL "proxy:3128"`},
+		{"missing CA bundle", Config{CACert: filepath.Join(t.TempDir(), "missing.pem")}, "failed to read CA bundle"},
+		{"CA bundle without certificates", Config{CACert: notPEM}, "no certificates found in CA bundle " + notPEM},
+		{"certificate without key", Config{ClientCert: pki.clientCert}, "mutual TLS needs both a client certificate and its key"},
+		{"key without certificate", Config{ClientKey: pki.clientKey}, "mutual TLS needs both"},
+		{"mismatched key", Config{ClientCert: pki.clientCert, ClientKey: pki.caFile}, "failed to load client certificate"},
+	}
+	for _, tt := range tests {
+		client, err := New(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("New(%s) = %v, %v; want error %q", tt.name, client, err, tt.wantErr)
+		}
+	}
+
+	if client, err := New(Config{}); err != nil || client.BaseURL() != defaultBaseURL {
+		t.Errorf("New(zero Config) = %v, %v; want a client for %s", client, err, defaultBaseURL)
+	}
+}
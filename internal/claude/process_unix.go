@@ -0,0 +1,19 @@
+//go:build !windows
+
+package claude
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startProcessGroup runs cmd in a process group of its own, so the tools it
+// spawns can be killed with it
+func startProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills cmd and every process in its group
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
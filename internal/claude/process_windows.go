@@ -0,0 +1,13 @@
+//go:build windows
+
+package claude
+
+import "os/exec"
+
+// startProcessGroup is a no-op on Windows, where the process is killed alone
+func startProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/Cloudpork/cloudpork-agent/internal/credentials"
 	"github.com/spf13/viper"
 )
 
@@ -14,6 +16,19 @@ const (
 	configFileName = ".cloudpork"
 	apiKeyEnvVar   = "CLOUDPORK_API_KEY"
 	projectIDEnvVar = "CLOUDPORK_PROJECT_ID"
+	apiKeyAccount  = "api_key" // Account name of the API key in the credential store
+)
+
+var (
+	credentialStore     credentials.Store
+	credentialStoreErr  error
+	credentialStoreOnce sync.Once
+	
+	// The API key as read from the credential store, looked up once per run
+	storedKeyMu     sync.Mutex
+	storedKeyLoaded bool
+	storedKey       string
+	storedKeyErr    error
 )
 
 // Config represents the application configuration
@@ -22,25 +37,125 @@ type Config struct {
 	ProjectID string `yaml:"project_id"`
 }
 
-// GetAPIKey retrieves the API key from config or environment
+// GetAPIKey retrieves the API key from the environment or the credential store
 func GetAPIKey() (string, error) {
 	// Check environment variable first
 	if key := os.Getenv(apiKeyEnvVar); key != "" {
 		return key, nil
 	}
 	
-	// Check config file
-	if key := viper.GetString("api_key"); key != "" {
+	// Check credential store
+	key, storeErr := storedAPIKey()
+	if storeErr == nil && key != "" {
+		return key, nil
+	}
+	
+	// Keys saved by earlier versions stay in the config file until
+	// MigrateAPIKey moves them
+	if key := plaintextAPIKey(); key != "" {
 		return key, nil
 	}
 	
+	// A store that cannot be read explains the missing key better
+	if storeErr != nil && storeErr != credentials.ErrNotFound {
+		return "", storeErr
+	}
 	return "", fmt.Errorf("no API key found. Run 'cloudpork auth login' to authenticate")
 }
 
-// SetAPIKey stores the API key in the config file
+// SetAPIKey stores the API key in the credential store
 func SetAPIKey(apiKey string) error {
-	viper.Set("api_key", apiKey)
-	return saveConfig()
+	store, err := CredentialStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Set(apiKeyAccount, apiKey); err != nil {
+		return err
+	}
+	cacheStoredAPIKey(apiKey, nil)
+	
+	// Drop a plaintext copy left by an earlier version
+	if plaintextAPIKey() != "" {
+		viper.Set("api_key", "")
+		return saveConfig()
+	}
+	return nil
+}
+
+// CredentialStore returns the store API keys are kept in: the backend named by
+// credentials.store, by default the operating system's
+func CredentialStore() (credentials.Store, error) {
+	credentialStoreOnce.Do(func() {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			credentialStoreErr = fmt.Errorf("failed to get home directory: %v", err)
+			return
+		}
+		path := filepath.Join(home, ".cloudpork", "credentials")
+		credentialStore, credentialStoreErr = credentials.Open(viper.GetString("credentials.store"), path)
+	})
+	return credentialStore, credentialStoreErr
+}
+
+// storedAPIKey returns the API key in the credential store. The lookup is made
+// once per run: the platform stores are reached through helper processes, and
+// most commands need the key more than once.
+func storedAPIKey() (string, error) {
+	storedKeyMu.Lock()
+	defer storedKeyMu.Unlock()
+	
+	if !storedKeyLoaded {
+		if store, err := CredentialStore(); err != nil {
+			storedKeyErr = err
+		} else {
+			storedKey, storedKeyErr = store.Get(apiKeyAccount)
+		}
+		storedKeyLoaded = true
+	}
+	return storedKey, storedKeyErr
+}
+
+// cacheStoredAPIKey records a change made to the key in the credential store
+func cacheStoredAPIKey(key string, err error) {
+	storedKeyMu.Lock()
+	defer storedKeyMu.Unlock()
+	storedKey, storedKeyErr, storedKeyLoaded = key, err, true
+}
+
+// MigrateAPIKey moves an API key saved in plaintext in the config file by an
+// earlier version to the credential store. It returns the store when it did.
+func MigrateAPIKey() (credentials.Store, error) {
+	apiKey := plaintextAPIKey()
+	if apiKey == "" {
+		return nil, nil
+	}
+	
+	store, err := CredentialStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Set(apiKeyAccount, apiKey); err != nil {
+		return nil, err
+	}
+	// Only remove the plaintext key once the store gives it back
+	if stored, err := store.Get(apiKeyAccount); err != nil || stored != apiKey {
+		return nil, fmt.Errorf("API key did not read back from the %s", store.Name())
+	}
+	cacheStoredAPIKey(apiKey, nil)
+	
+	viper.Set("api_key", "")
+	if err := saveConfig(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// plaintextAPIKey returns the API key written in the config file, if any
+func plaintextAPIKey() string {
+	if !viper.InConfig("api_key") {
+		return ""
+	}
+	return viper.GetString("api_key")
 }
 
 // GetProjectID retrieves the project ID from config or environment
@@ -76,6 +191,12 @@ func GenerateProjectID() string {
 
 // ClearCredentials removes stored credentials
 func ClearCredentials() error {
+	if store, err := CredentialStore(); err == nil {
+		if err := store.Delete(apiKeyAccount); err != nil {
+			return err
+		}
+		cacheStoredAPIKey("", credentials.ErrNotFound)
+	}
 	viper.Set("api_key", "")
 	viper.Set("project_id", "")
 	return saveConfig()
@@ -92,9 +213,13 @@ func GetConfigPath() (string, error) {
 
 // saveConfig writes the current configuration to disk
 func saveConfig() error {
-	configPath, err := GetConfigPath()
-	if err != nil {
-		return fmt.Errorf("failed to get config path: %v", err)
+	// Write back to the file in use, e.g. one given with --config
+	configPath := viper.ConfigFileUsed()
+	if configPath == "" {
+		var err error
+		if configPath, err = GetConfigPath(); err != nil {
+			return fmt.Errorf("failed to get config path: %v", err)
+		}
 	}
 	
 	// Ensure directory exists
@@ -137,12 +262,11 @@ func LoadConfig() (*Config, error) {
 	
 	// Build config struct
 	cfg := &Config{
-		APIKey:    viper.GetString("api_key"),
 		ProjectID: viper.GetString("project_id"),
 	}
 	
-	// Check environment variables
-	if key := os.Getenv(apiKeyEnvVar); key != "" {
+	// Check environment variables and the credential store
+	if key, err := GetAPIKey(); err == nil {
 		cfg.APIKey = key
 	}
 	if id := os.Getenv(projectIDEnvVar); id != "" {
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/credentials"
+)
+
+// countingStore is an in-memory credential store that counts reads
+type countingStore struct {
+	secrets map[string]string
+	gets    int
+}
+
+func (s *countingStore) Name() string { return "test store" }
+
+func (s *countingStore) Get(account string) (string, error) {
+	s.gets++
+	if secret, ok := s.secrets[account]; ok {
+		return secret, nil
+	}
+	return "", credentials.ErrNotFound
+}
+
+func (s *countingStore) Set(account, secret string) error {
+	s.secrets[account] = secret
+	return nil
+}
+
+func (s *countingStore) Delete(account string) error {
+	delete(s.secrets, account)
+	return nil
+}
+
+// useStore makes store the credential store for the rest of the test
+func useStore(t *testing.T, store credentials.Store) {
+	t.Helper()
+	t.Setenv(apiKeyEnvVar, "")
+	credentialStoreOnce.Do(func() {})
+	credentialStore, credentialStoreErr = store, nil
+	storedKeyLoaded = false
+	t.Cleanup(func() {
+		credentialStore = nil
+		storedKeyLoaded = false
+	})
+}
+
+func TestGetAPIKeyReadsTheStoreOnce(t *testing.T) {
+	store := &countingStore{secrets: map[string]string{apiKeyAccount: "cp_stored"}}
+	useStore(t, store)
+
+	for i := 0; i < 3; i++ {
+		if key, err := GetAPIKey(); err != nil || key != "cp_stored" {
+			t.Fatalf("GetAPIKey = %q, %v; want the stored key", key, err)
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("the store was read %d times, want 1", store.gets)
+	}
+
+	if err := SetAPIKey("cp_new"); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := GetAPIKey(); err != nil || key != "cp_new" {
+		t.Errorf("GetAPIKey after SetAPIKey = %q, %v; want the new key", key, err)
+	}
+	if store.gets != 1 {
+		t.Errorf("the store was read %d times, want 1", store.gets)
+	}
+
+	t.Setenv(apiKeyEnvVar, "cp_env")
+	if key, _ := GetAPIKey(); key != "cp_env" {
+		t.Errorf("GetAPIKey = %q, want the environment's key first", key)
+	}
+}
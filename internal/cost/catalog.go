@@ -0,0 +1,198 @@
+package cost
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+//go:embed pricing.json
+var embeddedCatalog []byte
+
+// catalogFileName is the catalog override written by `cloudpork pricing update`
+const catalogFileName = "pricing.json"
+
+// Catalog is a snapshot of on-demand cloud prices
+type Catalog struct {
+	Version            string     `json:"version"` // Date the prices were collected
+	Currency           string     `json:"currency"`
+	HoursPerMonth      float64    `json:"hours_per_month"`
+	NetworkUtilization float64    `json:"network_utilization"` // Average fraction of peak bandwidth in use
+	Providers          []Provider `json:"providers"`
+}
+
+// Provider holds the prices of one cloud in one region
+type Provider struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	Region         string         `json:"region"`
+	Instances      []Instance     `json:"instances"`
+	Databases      []DatabaseTier `json:"databases"`
+	StorageGBMonth float64        `json:"storage_gb_month"`
+	EgressGB       float64        `json:"egress_gb"`
+	FreeEgressGB   float64        `json:"free_egress_gb"`
+	Functions      *Functions     `json:"functions,omitempty"`
+}
+
+// Instance is a compute instance type
+type Instance struct {
+	Type     string  `json:"type"`
+	VCPUs    float64 `json:"vcpus"`
+	MemoryGB float64 `json:"memory_gb"`
+	Hourly   float64 `json:"hourly"`
+
+	// Estimated is set on instances sized and priced by LookupInstance
+	// rather than read from the catalog
+	Estimated bool `json:"-"`
+}
+
+// DatabaseTier is a managed database instance size
+type DatabaseTier struct {
+	Type           string  `json:"type"`
+	MaxConnections int     `json:"max_connections"`
+	Hourly         float64 `json:"hourly"`
+}
+
+// Functions are the prices of a provider's functions-as-a-service platform
+type Functions struct {
+	Service                string  `json:"service"`
+	PerMillionRequests     float64 `json:"per_million_requests"`
+	PerGBSecond            float64 `json:"per_gb_second"`
+	PerGBSecondARM         float64 `json:"per_gb_second_arm,omitempty"`
+	ProvisionedPerGBSecond float64 `json:"provisioned_per_gb_second,omitempty"` // Charged while provisioned, used or not
+	FreeRequests           float64 `json:"free_requests"`                       // Per month
+	FreeGBSeconds          float64 `json:"free_gb_seconds"`                     // Per month
+}
+
+// DefaultCatalog returns the catalog built into the binary
+func DefaultCatalog() *Catalog {
+	catalog, err := ParseCatalog(embeddedCatalog)
+	if err != nil {
+		panic(fmt.Sprintf("embedded pricing catalog is invalid: %v", err))
+	}
+	return catalog
+}
+
+// CatalogPath returns where the updated catalog is stored
+func CatalogPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cloudpork", catalogFileName), nil
+}
+
+// LoadCatalog reads the catalog at path. An empty path means the updated
+// catalog if there is one, and the embedded catalog otherwise.
+func LoadCatalog(path string) (*Catalog, error) {
+	if path == "" {
+		defaultPath, err := CatalogPath()
+		if err != nil {
+			return DefaultCatalog(), nil
+		}
+		if _, err := os.Stat(defaultPath); os.IsNotExist(err) {
+			return DefaultCatalog(), nil
+		}
+		path = defaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing catalog: %v", err)
+	}
+
+	catalog, err := ParseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pricing catalog %s: %v", path, err)
+	}
+	return catalog, nil
+}
+
+// ParseCatalog decodes and validates a catalog
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, err
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+// Validate checks the catalog has the prices an estimate needs
+func (c *Catalog) Validate() error {
+	if c.Version == "" {
+		return fmt.Errorf("missing version")
+	}
+	if c.HoursPerMonth <= 0 {
+		return fmt.Errorf("hours_per_month must be positive")
+	}
+	if len(c.Providers) == 0 {
+		return fmt.Errorf("no providers")
+	}
+
+	for _, p := range c.Providers {
+		if p.ID == "" {
+			return fmt.Errorf("provider without id")
+		}
+		if len(p.Instances) == 0 {
+			return fmt.Errorf("provider %s has no instance types", p.ID)
+		}
+		for _, inst := range p.Instances {
+			if inst.VCPUs <= 0 || inst.MemoryGB <= 0 || inst.Hourly <= 0 {
+				return fmt.Errorf("provider %s: instance %s needs positive vcpus, memory_gb and hourly", p.ID, inst.Type)
+			}
+		}
+		for _, db := range p.Databases {
+			if db.MaxConnections <= 0 || db.Hourly <= 0 {
+				return fmt.Errorf("provider %s: database %s needs positive max_connections and hourly", p.ID, db.Type)
+			}
+		}
+		if p.Functions != nil && (p.Functions.PerGBSecond <= 0 || p.Functions.PerMillionRequests < 0) {
+			return fmt.Errorf("provider %s: functions need a positive per_gb_second", p.ID)
+		}
+	}
+
+	return nil
+}
+
+// Install validates the catalog file at src and makes it the updated catalog
+func Install(src string) (*Catalog, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", src, err)
+	}
+
+	catalog, err := ParseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pricing catalog %s: %v", src, err)
+	}
+
+	dest, err := CatalogPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get catalog path: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", filepath.Dir(dest), err)
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", dest, err)
+	}
+
+	return catalog, nil
+}
+
+// Reset removes the updated catalog, reverting to the embedded one
+func Reset() error {
+	path, err := CatalogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// secondsPerHour converts a sustained Mbps rate into transferred data
+const secondsPerHour = 3600
+
+// Estimate prices the resource requirements on every provider in the catalog
+func Estimate(c *Catalog, usage types.ResourceMetrics) []types.CostEstimate {
+	estimates := make([]types.CostEstimate, 0, len(c.Providers))
+	for _, p := range c.Providers {
+		estimates = append(estimates, c.estimateProvider(p, usage))
+	}
+	return estimates
+}
+
+func (c *Catalog) estimateProvider(p Provider, usage types.ResourceMetrics) types.CostEstimate {
+	estimate := types.CostEstimate{
+		Provider:       p.Name,
+		Region:         p.Region,
+		Currency:       c.Currency,
+		CatalogVersion: c.Version,
+	}
+
+	estimate.Compute = c.computeCost(p, usage.CPUCores, float64(usage.MemoryMB)/1024)
+	if usage.DatabaseConns > 0 && len(p.Databases) > 0 {
+		estimate.Database = c.databaseCost(p, usage.DatabaseConns)
+	}
+
+	if usage.StorageGB > 0 {
+		estimate.Storage = types.CostItem{
+			Description: fmt.Sprintf("%d GB block storage", usage.StorageGB),
+			Monthly:     round(float64(usage.StorageGB) * p.StorageGBMonth),
+		}
+	}
+
+	if usage.NetworkMbps > 0 {
+		// Mbps -> GB/month at the catalog's average utilization
+		egressGB := float64(usage.NetworkMbps) / 8 / 1000 * secondsPerHour * c.HoursPerMonth * c.NetworkUtilization
+		billable := math.Max(0, egressGB-p.FreeEgressGB)
+		estimate.Network = types.CostItem{
+			Description: fmt.Sprintf("%.0f GB egress", egressGB),
+			Monthly:     round(billable * p.EgressGB),
+		}
+	}
+
+	estimate.MonthlyTotal = round(estimate.Compute.Monthly + estimate.Database.Monthly +
+		estimate.Storage.Monthly + estimate.Network.Monthly)
+	return estimate
+}
+
+// computeCost picks the instance type and count that meet the CPU and memory
+// requirements at the lowest monthly price
+func (c *Catalog) computeCost(p Provider, cpu, memoryGB float64) types.CostItem {
+	var best Instance
+	bestCount := 0
+	bestCost := math.Inf(1)
+
+	for _, inst := range p.Instances {
+		count := int(math.Max(math.Ceil(cpu/inst.VCPUs), math.Ceil(memoryGB/inst.MemoryGB)))
+		if count < 1 {
+			count = 1
+		}
+		cost := float64(count) * inst.Hourly
+		// Prefer fewer, larger instances when the price is the same
+		if cost < bestCost || cost == bestCost && count < bestCount {
+			best, bestCount, bestCost = inst, count, cost
+		}
+	}
+
+	return types.CostItem{
+		Description: fmt.Sprintf("%d× %s", bestCount, best.Type),
+		Quantity:    bestCount,
+		Monthly:     round(bestCost * c.HoursPerMonth),
+	}
+}
+
+// databaseCost picks the smallest managed database tier that accepts the
+// connections, adding instances of the largest tier beyond its limit
+func (c *Catalog) databaseCost(p Provider, connections int) types.CostItem {
+	var tier *DatabaseTier
+	largest := p.Databases[0]
+	for i, t := range p.Databases {
+		if t.MaxConnections >= connections && (tier == nil || t.Hourly < tier.Hourly) {
+			tier = &p.Databases[i]
+		}
+		if t.MaxConnections > largest.MaxConnections {
+			largest = t
+		}
+	}
+	if tier == nil {
+		tier = &largest
+	}
+
+	count := int(math.Ceil(float64(connections) / float64(tier.MaxConnections)))
+	if count < 1 {
+		count = 1
+	}
+
+	return types.CostItem{
+		Description: fmt.Sprintf("%d× %s", count, tier.Type),
+		Quantity:    count,
+		Monthly:     round(float64(count) * tier.Hourly * c.HoursPerMonth),
+	}
+}
+
+// round rounds to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
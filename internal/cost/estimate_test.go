@@ -0,0 +1,196 @@
+package cost
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// testCatalog is a small catalog with round prices and 100 hours a month
+func testCatalog() *Catalog {
+	return &Catalog{
+		Version:            "test",
+		Currency:           "USD",
+		HoursPerMonth:      100,
+		NetworkUtilization: 0.5,
+		Providers: []Provider{
+			{
+				ID: "aws", Name: "AWS", Region: "us-east-1",
+				Instances: []Instance{
+					{Type: "small", VCPUs: 2, MemoryGB: 4, Hourly: 0.1},
+					{Type: "medium", VCPUs: 4, MemoryGB: 8, Hourly: 0.2},
+					{Type: "large", VCPUs: 16, MemoryGB: 64, Hourly: 0.9},
+				},
+				Databases: []DatabaseTier{
+					{Type: "db.small", MaxConnections: 100, Hourly: 0.2},
+					{Type: "db.large", MaxConnections: 500, Hourly: 0.6},
+					{Type: "db.medium", MaxConnections: 200, Hourly: 0.3},
+				},
+				StorageGBMonth: 0.1,
+				EgressGB:       0.09,
+				FreeEgressGB:   100,
+			},
+			{
+				ID: "gcp", Name: "GCP", Region: "us-central1",
+				Instances: []Instance{
+					{Type: "n2-standard-4", VCPUs: 4, MemoryGB: 16, Hourly: 0.2},
+				},
+				StorageGBMonth: 0.04,
+				EgressGB:       0.12,
+			},
+		},
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	usage := types.ResourceMetrics{MemoryMB: 6144, CPUCores: 3, DatabaseConns: 150, NetworkMbps: 10, StorageGB: 50}
+
+	// 10 Mbps at half utilization for 100 hours is 225 GB of egress
+	want := []types.CostEstimate{
+		{
+			Provider: "AWS", Region: "us-east-1", Currency: "USD", CatalogVersion: "test",
+			Compute:      types.CostItem{Description: "1× medium", Quantity: 1, Monthly: 20},
+			Database:     types.CostItem{Description: "1× db.medium", Quantity: 1, Monthly: 30},
+			Storage:      types.CostItem{Description: "50 GB block storage", Monthly: 5},
+			Network:      types.CostItem{Description: "225 GB egress", Monthly: 11.25},
+			MonthlyTotal: 66.25,
+		},
+		{
+			Provider: "GCP", Region: "us-central1", Currency: "USD", CatalogVersion: "test",
+			Compute:      types.CostItem{Description: "1× n2-standard-4", Quantity: 1, Monthly: 20},
+			Storage:      types.CostItem{Description: "50 GB block storage", Monthly: 2},
+			Network:      types.CostItem{Description: "225 GB egress", Monthly: 27},
+			MonthlyTotal: 49,
+		},
+	}
+	if got := Estimate(testCatalog(), usage); !reflect.DeepEqual(got, want) {
+		t.Errorf("Estimate()\n got %+v\nwant %+v", got, want)
+	}
+
+	// Nothing but compute when only CPU and memory are needed
+	got := Estimate(testCatalog(), types.ResourceMetrics{MemoryMB: 512, CPUCores: 0.5})
+	if got[0].MonthlyTotal != 10 || got[0].Database != (types.CostItem{}) || got[0].Network != (types.CostItem{}) {
+		t.Errorf("compute-only AWS estimate = %+v, want 1× small for 10", got[0])
+	}
+}
+
+func TestComputeCost(t *testing.T) {
+	c := testCatalog()
+	tests := []struct {
+		name     string
+		cpu      float64
+		memoryGB float64
+		want     types.CostItem
+	}{
+		{"nothing needed still runs one", 0, 0, types.CostItem{Description: "1× small", Quantity: 1, Monthly: 10}},
+		{"fits the smallest", 1.5, 3, types.CostItem{Description: "1× small", Quantity: 1, Monthly: 10}},
+		{"same price, fewer instances", 3, 6, types.CostItem{Description: "1× medium", Quantity: 1, Monthly: 20}},
+		{"memory bound", 2, 20, types.CostItem{Description: "5× small", Quantity: 5, Monthly: 50}},
+		{"cheaper than the largest", 32, 64, types.CostItem{Description: "8× medium", Quantity: 8, Monthly: 160}},
+	}
+	for _, tt := range tests {
+		if got := c.computeCost(c.Providers[0], tt.cpu, tt.memoryGB); got != tt.want {
+			t.Errorf("computeCost(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDatabaseCost(t *testing.T) {
+	c := testCatalog()
+	tests := []struct {
+		connections int
+		want        types.CostItem
+	}{
+		{1, types.CostItem{Description: "1× db.small", Quantity: 1, Monthly: 20}},
+		{100, types.CostItem{Description: "1× db.small", Quantity: 1, Monthly: 20}},
+		{101, types.CostItem{Description: "1× db.medium", Quantity: 1, Monthly: 30}},
+		{500, types.CostItem{Description: "1× db.large", Quantity: 1, Monthly: 60}},
+		{1200, types.CostItem{Description: "3× db.large", Quantity: 3, Monthly: 180}},
+	}
+	for _, tt := range tests {
+		if got := c.databaseCost(c.Providers[0], tt.connections); got != tt.want {
+			t.Errorf("databaseCost(%d) = %+v, want %+v", tt.connections, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Catalog)
+		wantErr string
+	}{
+		{"valid", func(c *Catalog) {}, ""},
+		{"no version", func(c *Catalog) { c.Version = "" }, "missing version"},
+		{"no hours", func(c *Catalog) { c.HoursPerMonth = 0 }, "hours_per_month must be positive"},
+		{"no providers", func(c *Catalog) { c.Providers = nil }, "no providers"},
+		{"provider without id", func(c *Catalog) { c.Providers[1].ID = "" }, "provider without id"},
+		{"no instances", func(c *Catalog) { c.Providers[1].Instances = nil }, "provider gcp has no instance types"},
+		{"free instance", func(c *Catalog) { c.Providers[0].Instances[1].Hourly = 0 }, "provider aws: instance medium needs positive vcpus, memory_gb and hourly"},
+		{"database without connections", func(c *Catalog) { c.Providers[0].Databases[2].MaxConnections = 0 }, "provider aws: database db.medium needs positive max_connections and hourly"},
+		{"free functions", func(c *Catalog) { c.Providers[1].Functions = &Functions{PerMillionRequests: 0.4} }, "provider gcp: functions need a positive per_gb_second"},
+	}
+	for _, tt := range tests {
+		c := testCatalog()
+		tt.change(c)
+		err := c.Validate()
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("Validate(%s) = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	if _, err := ParseCatalog([]byte(`{"version": `)); err == nil {
+		t.Error("ParseCatalog accepted truncated JSON")
+	}
+	if _, err := ParseCatalog([]byte(`{"version": "2024-01", "hours_per_month": 730}`)); err == nil || err.Error() != "no providers" {
+		t.Errorf("ParseCatalog without providers = %v, want the validation error", err)
+	}
+	if err := DefaultCatalog().Validate(); err != nil {
+		t.Errorf("embedded catalog: %v", err)
+	}
+}
+
+func TestInstallAndReset(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	if c, err := LoadCatalog(""); err != nil || c.Version != DefaultCatalog().Version {
+		t.Fatalf("LoadCatalog() without an update = %v, %v; want the embedded catalog", c, err)
+	}
+
+	src := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(src, []byte(`{"version": "bad", "hours_per_month": 730, "providers": [{"id": "aws"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(src); err == nil || !strings.Contains(err.Error(), "provider aws has no instance types") {
+		t.Errorf("Install(invalid) = %v, want the validation error", err)
+	}
+
+	if err := os.WriteFile(src, []byte(`{"version": "2099-01", "hours_per_month": 730, "providers": [
+		{"id": "aws", "instances": [{"type": "t", "vcpus": 2, "memory_gb": 4, "hourly": 0.1}]}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(src); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := LoadCatalog(""); err != nil || c.Version != "2099-01" {
+		t.Errorf("LoadCatalog() after Install = %v, %v; want the installed catalog", c, err)
+	}
+
+	if err := Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := LoadCatalog(""); c.Version != DefaultCatalog().Version {
+		t.Errorf("LoadCatalog() after Reset = %s, want the embedded catalog", c.Version)
+	}
+	if _, err := LoadCatalog(filepath.Join(home, "missing.json")); err == nil {
+		t.Error("LoadCatalog(missing file) succeeded")
+	}
+}
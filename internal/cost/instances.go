@@ -0,0 +1,192 @@
+package cost
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// Instance type naming schemes, used to size types missing from the catalog
+var (
+	awsInstanceName   = regexp.MustCompile(`^([a-z]+)(\d+)[a-z-]*\.(nano|micro|small|medium|large|xlarge|(\d+)xlarge)$`)
+	gcpInstanceName   = regexp.MustCompile(`^([a-z]\d[a-z]?)-(standard|highmem|highcpu)-(\d+)$`)
+	gcpCustomName     = regexp.MustCompile(`^(?:[a-z]\d[a-z]?-)?custom-(\d+)-(\d+)(?:-ext)?$`)
+	azureInstanceName = regexp.MustCompile(`^([A-Z]+)(\d+)[a-z]*(?:_v\d+)?$`)
+)
+
+// awsMemoryPerVCPU is the GB of memory per vCPU of each AWS family letter
+var awsMemoryPerVCPU = map[string]float64{
+	"c": 2, "m": 4, "r": 8, "x": 16, "z": 8, "i": 8, "d": 8, "g": 4, "p": 8, "a": 2,
+}
+
+// awsBurstableSizes are the vCPUs and GB of memory of the t family sizes
+var awsBurstableSizes = map[string][2]float64{
+	"nano": {2, 0.5}, "micro": {2, 1}, "small": {2, 2}, "medium": {2, 4},
+	"large": {2, 8}, "xlarge": {4, 16}, "2xlarge": {8, 32},
+}
+
+// gcpMemoryPerVCPU is the GB of memory per vCPU of each GCP machine type
+var gcpMemoryPerVCPU = map[string]float64{
+	"standard": 4, "highmem": 8, "highcpu": 1,
+}
+
+// azureMemoryPerVCPU is the GB of memory per vCPU of each Azure series
+var azureMemoryPerVCPU = map[string]float64{
+	"A": 2, "B": 4, "D": 4, "DC": 4, "E": 8, "F": 2, "L": 8, "M": 28, "NC": 7,
+}
+
+// LookupInstance returns the size and hourly price of an instance type on a
+// provider. Types missing from the catalog are sized from their name and
+// priced at the provider's average rate; Estimated is then true.
+func (c *Catalog) LookupInstance(providerID, instanceType string) (Instance, bool) {
+	p, ok := c.provider(providerID)
+	if !ok {
+		return Instance{}, false
+	}
+
+	name := normalizeInstanceType(providerID, instanceType)
+	for _, inst := range p.Instances {
+		if strings.EqualFold(normalizeInstanceType(providerID, inst.Type), name) {
+			return inst, true
+		}
+	}
+
+	vcpus, memoryGB, ok := instanceSpec(providerID, name)
+	if !ok {
+		return Instance{}, false
+	}
+	return Instance{
+		Type:      instanceType,
+		VCPUs:     vcpus,
+		MemoryGB:  memoryGB,
+		Hourly:    math.Round(averageRate(p)*(vcpus+memoryGB/4)*10000) / 10000,
+		Estimated: true,
+	}, true
+}
+
+// Rightsize picks the instance type and count of a provider that meet the CPU
+// and memory requirements at the lowest monthly price
+func (c *Catalog) Rightsize(providerID string, cpu, memoryGB float64) (types.CostItem, bool) {
+	p, ok := c.provider(providerID)
+	if !ok {
+		return types.CostItem{}, false
+	}
+	return c.computeCost(p, cpu, memoryGB), true
+}
+
+// CapacityCost is the monthly price of a slice of CPU and memory, such as a
+// pod's requests, at a provider's average rate
+func (c *Catalog) CapacityCost(providerID string, cpu, memoryGB float64) (float64, bool) {
+	p, ok := c.provider(providerID)
+	if !ok {
+		return 0, false
+	}
+	return round(averageRate(p) * (cpu + memoryGB/4) * c.HoursPerMonth), true
+}
+
+// Monthly converts an hourly price to a monthly one
+func (c *Catalog) Monthly(hourly float64) float64 {
+	return round(hourly * c.HoursPerMonth)
+}
+
+func (c *Catalog) provider(id string) (Provider, bool) {
+	for _, p := range c.Providers {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// normalizeInstanceType strips the prefixes managed services put on instance
+// types, such as "db." for RDS and "Standard_" for Azure
+func normalizeInstanceType(providerID, instanceType string) string {
+	name := strings.TrimSpace(instanceType)
+	switch providerID {
+	case "aws":
+		name = strings.ToLower(name)
+		name = strings.TrimPrefix(name, "db.")
+		name = strings.TrimPrefix(name, "cache.")
+		name = strings.TrimPrefix(name, "search.")
+	case "gcp":
+		name = strings.ToLower(name)
+		name = strings.TrimPrefix(name, "db-")
+	case "azure":
+		name = strings.TrimPrefix(name, "Standard_")
+		name = strings.TrimPrefix(name, "Basic_")
+	}
+	return name
+}
+
+// instanceSpec sizes an instance type from its name
+func instanceSpec(providerID, name string) (vcpus, memoryGB float64, ok bool) {
+	switch providerID {
+	case "aws":
+		m := awsInstanceName.FindStringSubmatch(name)
+		if m == nil {
+			return 0, 0, false
+		}
+		family, size := m[1], m[3]
+		if family == "t" {
+			spec, ok := awsBurstableSizes[size]
+			return spec[0], spec[1], ok
+		}
+		switch {
+		case size == "medium":
+			vcpus = 1
+		case size == "large":
+			vcpus = 2
+		case size == "xlarge":
+			vcpus = 4
+		case m[4] != "":
+			n, _ := strconv.Atoi(m[4])
+			vcpus = float64(4 * n)
+		default:
+			return 0, 0, false // nano, micro and small only exist in the t family
+		}
+		perVCPU, ok := awsMemoryPerVCPU[family[:1]]
+		if !ok {
+			perVCPU = 4
+		}
+		return vcpus, vcpus * perVCPU, true
+
+	case "gcp":
+		if m := gcpCustomName.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			mb, _ := strconv.Atoi(m[2])
+			return float64(n), float64(mb) / 1024, n > 0 && mb > 0
+		}
+		m := gcpInstanceName.FindStringSubmatch(name)
+		if m == nil {
+			return 0, 0, false
+		}
+		n, _ := strconv.Atoi(m[3])
+		return float64(n), float64(n) * gcpMemoryPerVCPU[m[2]], n > 0
+
+	case "azure":
+		m := azureInstanceName.FindStringSubmatch(name)
+		if m == nil {
+			return 0, 0, false
+		}
+		n, _ := strconv.Atoi(m[2])
+		perVCPU, ok := azureMemoryPerVCPU[m[1]]
+		if !ok {
+			perVCPU = 4
+		}
+		return float64(n), float64(n) * perVCPU, n > 0
+	}
+	return 0, 0, false
+}
+
+// averageRate is a provider's mean hourly price per vCPU, counting four GB of
+// memory as one more vCPU
+func averageRate(p Provider) float64 {
+	total := 0.0
+	for _, inst := range p.Instances {
+		total += inst.Hourly / (inst.VCPUs + inst.MemoryGB/4)
+	}
+	return total / float64(len(p.Instances))
+}
@@ -0,0 +1,189 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// DefaultTiers are the user counts projected when none are configured
+var DefaultTiers = []int{1000, 10000, 100000, 1000000, 10000000, 100000000}
+
+// Assumptions of the scaling model
+const (
+	baselineConcurrent   = 1000  // Resource estimates are made for this many concurrent users
+	concurrencyRatio     = 0.1   // Fraction of users active at the same time
+	requestsPerUserSec   = 0.1   // Requests per second from each active user
+	defaultQueriesPerReq = 3     // Used when the analysis has no per-request query count
+	nPlusOneFanout       = 10    // Extra queries per request when N+1 patterns were found
+	maxPrimaryQPS        = 20000 // Queries per second a single primary database sustains
+	maxNodeNetworkMbps   = 10000 // A 10 Gbps network interface
+	fixedMemoryShare     = 0.5   // Share of memory that does not grow with load
+)
+
+// Project scales the analysis' resource estimates to each user tier and prices
+// them. It also returns the limits the app runs into, with the first tier at
+// which each becomes binding.
+func Project(c *Catalog, analysis *types.CodeAnalysis, tiers []int) ([]types.ScaleProjection, []types.ScalingLimit) {
+	limits := c.scalingLimits(analysis)
+
+	projections := make([]types.ScaleProjection, 0, len(tiers))
+	for _, users := range tiers {
+		resources := scaleResources(analysis.ResourceUsage, users)
+
+		projection := types.ScaleProjection{
+			Users:         users,
+			Concurrent:    concurrentUsers(users),
+			Resources:     resources,
+			CostEstimates: Estimate(c, resources),
+		}
+		for i := range limits {
+			if limits[i].ThresholdUsers > 0 && users >= limits[i].ThresholdUsers {
+				if limits[i].BindingAtUsers == 0 {
+					limits[i].BindingAtUsers = users
+				}
+				projection.Binding = append(projection.Binding, limits[i].Constraint)
+			}
+		}
+		projections = append(projections, projection)
+	}
+
+	return projections, limits
+}
+
+// BindBottlenecks records on each bottleneck the tier at which the limit of the
+// same type becomes binding
+func BindBottlenecks(bottlenecks []types.Bottleneck, limits []types.ScalingLimit) {
+	for i := range bottlenecks {
+		for _, limit := range limits {
+			if limit.Type != bottlenecks[i].Type || limit.BindingAtUsers == 0 {
+				continue
+			}
+			if bottlenecks[i].BindingAtUsers == 0 || limit.BindingAtUsers < bottlenecks[i].BindingAtUsers {
+				bottlenecks[i].BindingAtUsers = limit.BindingAtUsers
+			}
+		}
+	}
+}
+
+// concurrentUsers is the number of users active at the same time
+func concurrentUsers(users int) int {
+	return int(math.Max(1, math.Round(float64(users)*concurrencyRatio)))
+}
+
+// loadFactor is the load at users relative to the baseline estimate
+func loadFactor(users int) float64 {
+	return float64(concurrentUsers(users)) / baselineConcurrent
+}
+
+// scaleResources projects the baseline estimate to users. CPU, connections and
+// bandwidth grow linearly with load; part of the memory is fixed per process.
+func scaleResources(base types.ResourceMetrics, users int) types.ResourceMetrics {
+	f := loadFactor(users)
+
+	memory := float64(base.MemoryMB) * (fixedMemoryShare + (1-fixedMemoryShare)*f)
+	return types.ResourceMetrics{
+		MemoryMB:      int(math.Max(128, math.Ceil(memory))),
+		CPUCores:      math.Max(0.25, math.Round(base.CPUCores*f*100)/100),
+		DatabaseConns: int(math.Max(1, math.Ceil(float64(base.DatabaseConns)*f))),
+		NetworkMbps:   int(math.Max(1, math.Ceil(float64(base.NetworkMbps)*f))),
+		StorageGB:     int(math.Max(float64(base.StorageGB), math.Ceil(float64(base.StorageGB)*f))),
+	}
+}
+
+// usersForFactor inverts loadFactor: the user count at which load reaches f
+func usersForFactor(f float64) int {
+	if f <= 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0
+	}
+	users := f * baselineConcurrent / concurrencyRatio
+	if users > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(math.Ceil(users))
+}
+
+// scalingLimits works out the user count at which each single-node or
+// single-database limit is exceeded
+func (c *Catalog) scalingLimits(analysis *types.CodeAnalysis) []types.ScalingLimit {
+	base := analysis.ResourceUsage
+	var largestMemoryGB, largestVCPUs float64
+	largestConns := 0
+	for _, p := range c.Providers {
+		for _, inst := range p.Instances {
+			largestMemoryGB = math.Max(largestMemoryGB, inst.MemoryGB)
+			largestVCPUs = math.Max(largestVCPUs, inst.VCPUs)
+		}
+		for _, db := range p.Databases {
+			if db.MaxConnections > largestConns {
+				largestConns = db.MaxConnections
+			}
+		}
+	}
+
+	var limits []types.ScalingLimit
+
+	if base.DatabaseConns > 0 && largestConns > 0 {
+		limits = append(limits, types.ScalingLimit{
+			Constraint:     "DB connections",
+			Type:           "database",
+			Limit:          fmt.Sprintf("%d connections on the largest managed database", largestConns),
+			ThresholdUsers: usersForFactor(float64(largestConns) / float64(base.DatabaseConns)),
+			Mitigation:     "Pool connections (PgBouncer, RDS Proxy) and add read replicas",
+		})
+	}
+
+	queries := float64(analysis.Performance.DatabaseQueries)
+	if queries <= 0 {
+		queries = defaultQueriesPerReq
+	}
+	if analysis.Performance.HasNPlusOneQuery {
+		queries += nPlusOneFanout
+	}
+	if analysis.DatabaseCalls > 0 || base.DatabaseConns > 0 {
+		qpsAtBaseline := baselineConcurrent * requestsPerUserSec * queries
+		limits = append(limits, types.ScalingLimit{
+			Constraint:     "DB query throughput",
+			Type:           "database",
+			Limit:          fmt.Sprintf("~%d queries/s on a single primary at %.0f queries per request", maxPrimaryQPS, queries),
+			ThresholdUsers: usersForFactor(maxPrimaryQPS / qpsAtBaseline),
+			Mitigation:     "Fix N+1 queries, cache hot reads and shard or partition writes",
+		})
+	}
+
+	if base.MemoryMB > 0 && largestMemoryGB > 0 {
+		// Solve fixed + variable*f = largest for f
+		variable := float64(base.MemoryMB) * (1 - fixedMemoryShare)
+		f := (largestMemoryGB*1024 - float64(base.MemoryMB)*fixedMemoryShare) / variable
+		limits = append(limits, types.ScalingLimit{
+			Constraint:     "Single-node memory",
+			Type:           "memory",
+			Limit:          fmt.Sprintf("%.0f GB on the largest instance", largestMemoryGB),
+			ThresholdUsers: usersForFactor(f),
+			Mitigation:     "Move in-process state to a shared cache and scale horizontally",
+		})
+	}
+
+	if base.CPUCores > 0 && largestVCPUs > 0 {
+		limits = append(limits, types.ScalingLimit{
+			Constraint:     "Single-node CPU",
+			Type:           "cpu",
+			Limit:          fmt.Sprintf("%.0f vCPUs on the largest instance", largestVCPUs),
+			ThresholdUsers: usersForFactor(largestVCPUs / base.CPUCores),
+			Mitigation:     "Run stateless replicas behind a load balancer",
+		})
+	}
+
+	if base.NetworkMbps > 0 {
+		limits = append(limits, types.ScalingLimit{
+			Constraint:     "Network bandwidth",
+			Type:           "network",
+			Limit:          fmt.Sprintf("%d Mbps per node", maxNodeNetworkMbps),
+			ThresholdUsers: usersForFactor(float64(maxNodeNetworkMbps) / float64(base.NetworkMbps)),
+			Mitigation:     "Serve static and large responses from a CDN",
+		})
+	}
+
+	return limits
+}
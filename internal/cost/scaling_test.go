@@ -0,0 +1,155 @@
+package cost
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// scalingAnalysis needs 2 GB, 2 cores, 50 connections and 100 Mbps for 1000
+// concurrent users, and runs 13 queries per request with its N+1 fan-out
+func scalingAnalysis() *types.CodeAnalysis {
+	return &types.CodeAnalysis{
+		DatabaseCalls: 5,
+		ResourceUsage: types.ResourceMetrics{MemoryMB: 2048, CPUCores: 2, DatabaseConns: 50, NetworkMbps: 100, StorageGB: 10},
+		Performance:   types.PerformanceMetrics{HasNPlusOneQuery: true},
+	}
+}
+
+func TestProject(t *testing.T) {
+	c := testCatalog()
+	projections, limits := Project(c, scalingAnalysis(), []int{1000, 100000, 1000000})
+
+	// Against the largest catalog entries: 500 connections, 16 vCPUs, 64 GB
+	wantLimits := []types.ScalingLimit{
+		{Constraint: "DB connections", Type: "database", Limit: "500 connections on the largest managed database",
+			ThresholdUsers: 100000, BindingAtUsers: 100000, Mitigation: "Pool connections (PgBouncer, RDS Proxy) and add read replicas"},
+		{Constraint: "DB query throughput", Type: "database", Limit: "~20000 queries/s on a single primary at 13 queries per request",
+			ThresholdUsers: 153847, BindingAtUsers: 1000000, Mitigation: "Fix N+1 queries, cache hot reads and shard or partition writes"},
+		{Constraint: "Single-node memory", Type: "memory", Limit: "64 GB on the largest instance",
+			ThresholdUsers: 630000, BindingAtUsers: 1000000, Mitigation: "Move in-process state to a shared cache and scale horizontally"},
+		{Constraint: "Single-node CPU", Type: "cpu", Limit: "16 vCPUs on the largest instance",
+			ThresholdUsers: 80000, BindingAtUsers: 100000, Mitigation: "Run stateless replicas behind a load balancer"},
+		{Constraint: "Network bandwidth", Type: "network", Limit: "10000 Mbps per node",
+			ThresholdUsers: 1000000, BindingAtUsers: 1000000, Mitigation: "Serve static and large responses from a CDN"},
+	}
+	if !reflect.DeepEqual(limits, wantLimits) {
+		t.Errorf("limits\n got %+v\nwant %+v", limits, wantLimits)
+	}
+
+	want := []types.ScaleProjection{
+		{
+			Users: 1000, Concurrent: 100,
+			Resources: types.ResourceMetrics{MemoryMB: 1127, CPUCores: 0.25, DatabaseConns: 5, NetworkMbps: 10, StorageGB: 10},
+		},
+		{
+			Users: 100000, Concurrent: 10000,
+			Resources: types.ResourceMetrics{MemoryMB: 11264, CPUCores: 20, DatabaseConns: 500, NetworkMbps: 1000, StorageGB: 100},
+			Binding:   []string{"DB connections", "Single-node CPU"},
+		},
+		{
+			Users: 1000000, Concurrent: 100000,
+			Resources: types.ResourceMetrics{MemoryMB: 103424, CPUCores: 200, DatabaseConns: 5000, NetworkMbps: 10000, StorageGB: 1000},
+			Binding:   []string{"DB connections", "DB query throughput", "Single-node memory", "Single-node CPU", "Network bandwidth"},
+		},
+	}
+	if len(projections) != len(want) {
+		t.Fatalf("got %d projections, want %d", len(projections), len(want))
+	}
+	for i, p := range projections {
+		if !reflect.DeepEqual(p.CostEstimates, Estimate(c, p.Resources)) {
+			t.Errorf("tier %d: cost estimates are not those of its resources", p.Users)
+		}
+		p.CostEstimates = nil
+		if !reflect.DeepEqual(p, want[i]) {
+			t.Errorf("tier %d\n got %+v\nwant %+v", want[i].Users, p, want[i])
+		}
+	}
+}
+
+func TestProjectWithoutEstimates(t *testing.T) {
+	projections, limits := Project(testCatalog(), &types.CodeAnalysis{}, []int{10, 1000})
+	if len(limits) != 0 {
+		t.Errorf("limits = %+v, want none without resource estimates", limits)
+	}
+
+	// Floors keep the smallest tiers runnable
+	floor := types.ResourceMetrics{MemoryMB: 128, CPUCores: 0.25, DatabaseConns: 1, NetworkMbps: 1}
+	for _, p := range projections {
+		if p.Concurrent != int(math.Max(1, float64(p.Users)/10)) || p.Resources != floor || p.Binding != nil {
+			t.Errorf("tier %d = %+v, want the resource floors and no binding limits", p.Users, p)
+		}
+	}
+}
+
+func TestScalingLimitsQueriesPerRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		queries     int
+		nPlusOne    bool
+		wantLimit   string
+		wantAtUsers int
+	}{
+		{"default", 0, false, "~20000 queries/s on a single primary at 3 queries per request", 666667},
+		{"measured", 8, false, "~20000 queries/s on a single primary at 8 queries per request", 250000},
+		{"measured with N+1", 8, true, "~20000 queries/s on a single primary at 18 queries per request", 111112},
+	}
+	for _, tt := range tests {
+		analysis := &types.CodeAnalysis{DatabaseCalls: 1}
+		analysis.Performance.DatabaseQueries = tt.queries
+		analysis.Performance.HasNPlusOneQuery = tt.nPlusOne
+
+		limits := testCatalog().scalingLimits(analysis)
+		if len(limits) != 1 || limits[0].Limit != tt.wantLimit || limits[0].ThresholdUsers != tt.wantAtUsers {
+			t.Errorf("%s: limits = %+v, want %q at %d users", tt.name, limits, tt.wantLimit, tt.wantAtUsers)
+		}
+	}
+}
+
+func TestBindBottlenecks(t *testing.T) {
+	_, limits := Project(testCatalog(), scalingAnalysis(), []int{1000, 100000, 1000000})
+	bottlenecks := []types.Bottleneck{
+		{Type: "database"}, // Binding through connections before query throughput
+		{Type: "memory"},
+		{Type: "cpu", BindingAtUsers: 50000}, // An earlier tier is kept
+		{Type: "disk"},
+	}
+
+	BindBottlenecks(bottlenecks, limits)
+
+	var got []int
+	for _, b := range bottlenecks {
+		got = append(got, b.BindingAtUsers)
+	}
+	if want := []int{100000, 1000000, 50000, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BindingAtUsers = %v, want %v", got, want)
+	}
+
+	// Limits no projected tier reaches bind nothing
+	_, limits = Project(testCatalog(), scalingAnalysis(), []int{1000})
+	unbound := []types.Bottleneck{{Type: "database"}}
+	BindBottlenecks(unbound, limits)
+	if unbound[0].BindingAtUsers != 0 {
+		t.Errorf("BindingAtUsers = %d below every threshold, want 0", unbound[0].BindingAtUsers)
+	}
+}
+
+func TestUsersForFactor(t *testing.T) {
+	tests := map[float64]int{
+		1:            10000,
+		0.25:         2500,
+		0:            0,
+		-1:           0,
+		math.Inf(1):  0,
+		math.NaN():   0,
+		1e9:          math.MaxInt32,
+		15.384615385: 153847,
+	}
+	for f, want := range tests {
+		if got := usersForFactor(f); got != want {
+			t.Errorf("usersForFactor(%v) = %d, want %d", f, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package cost
+
+import (
+	"math"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// DefaultRequestRates are the requests per second serverless functions are
+// projected to when none are configured
+var DefaultRequestRates = []float64{1, 10, 100, 1000}
+
+// DefaultDurationMs is the billed duration assumed for an invocation when the
+// analysis has no response time estimate
+const DefaultDurationMs = 100
+
+// functionUsage is what the functions on one provider use in a month
+type functionUsage struct {
+	invocations    float64
+	gbSeconds      float64
+	gbSecondsARM   float64
+	provisionedGBs float64
+}
+
+// InvocationCost is the price of one invocation of a function billed for
+// durationMs, before the free tier. It is 0 when the function's provider has
+// no function prices in the catalog.
+func (c *Catalog) InvocationCost(f types.ServerlessFunction, durationMs int) float64 {
+	p, ok := c.provider(f.Provider)
+	if !ok || p.Functions == nil {
+		return 0
+	}
+	gbSeconds := float64(f.MemoryMB) / 1024 * float64(durationMs) / 1000
+	return p.Functions.PerMillionRequests/1e6 + gbSeconds*gbSecondRate(p.Functions, f.Architecture)
+}
+
+// ProjectServerless prices the functions at each request rate. The requests
+// are spread evenly over the functions without a schedule trigger; scheduled
+// functions run as often as their schedule says at every rate.
+func ProjectServerless(c *Catalog, functions []types.ServerlessFunction, durationMs int, rates []float64) []types.ServerlessProjection {
+	requestDriven := 0
+	for _, f := range functions {
+		if f.ScheduledPerMonth == 0 {
+			requestDriven++
+		}
+	}
+
+	secondsPerMonth := c.HoursPerMonth * secondsPerHour
+	projections := make([]types.ServerlessProjection, 0, len(rates))
+	for _, rate := range rates {
+		usage := make(map[string]*functionUsage)
+		for _, f := range functions {
+			u := usage[f.Provider]
+			if u == nil {
+				u = &functionUsage{}
+				usage[f.Provider] = u
+			}
+
+			invocations := float64(f.ScheduledPerMonth)
+			if f.ScheduledPerMonth == 0 {
+				invocations = rate * secondsPerMonth / float64(requestDriven)
+			}
+			memoryGB := float64(f.MemoryMB) / 1024
+			u.invocations += invocations
+			if f.Architecture == "arm64" {
+				u.gbSecondsARM += invocations * memoryGB * float64(durationMs) / 1000
+			} else {
+				u.gbSeconds += invocations * memoryGB * float64(durationMs) / 1000
+			}
+			u.provisionedGBs += float64(f.ProvisionedConcurrency) * memoryGB * secondsPerMonth
+		}
+
+		projection := types.ServerlessProjection{RequestsPerSecond: rate}
+		for _, p := range c.Providers {
+			u, ok := usage[p.ID]
+			if !ok || p.Functions == nil {
+				continue
+			}
+			prices := p.Functions
+			projection.MonthlyInvocations += int64(math.Round(u.invocations))
+			projection.Requests += math.Max(0, u.invocations-prices.FreeRequests) / 1e6 * prices.PerMillionRequests
+
+			// The free GB-seconds go to the x86 usage first
+			free := prices.FreeGBSeconds
+			x86 := math.Max(0, u.gbSeconds-free)
+			free = math.Max(0, free-u.gbSeconds)
+			arm := math.Max(0, u.gbSecondsARM-free)
+			projection.Compute += x86*prices.PerGBSecond + arm*gbSecondRate(prices, "arm64")
+			projection.Provisioned += u.provisionedGBs * prices.ProvisionedPerGBSecond
+		}
+		projection.Requests = round(projection.Requests)
+		projection.Compute = round(projection.Compute)
+		projection.Provisioned = round(projection.Provisioned)
+		projection.MonthlyTotal = round(projection.Requests + projection.Compute + projection.Provisioned)
+		projections = append(projections, projection)
+	}
+	return projections
+}
+
+// gbSecondRate is the price of a GB-second on an architecture
+func gbSecondRate(prices *Functions, architecture string) float64 {
+	if architecture == "arm64" && prices.PerGBSecondARM > 0 {
+		return prices.PerGBSecondARM
+	}
+	return prices.PerGBSecond
+}
@@ -0,0 +1,236 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PassphraseEnvVar holds the passphrase of the encrypted credentials file.
+// Without one, the file's key is derived from this machine and user.
+const PassphraseEnvVar = "CLOUDPORK_CREDENTIALS_PASSPHRASE"
+
+const (
+	fileVersion    = 1
+	kdfIterations  = 210000
+	minIterations  = 10000    // Fewer would make the key cheap to guess
+	maxIterations  = 10000000 // More would stall every command
+	saltBytes      = 16
+	derivedKeySize = 32 // AES-256
+)
+
+// encryptedFile is the on-disk form of the file store: the JSON map of
+// account to secret, sealed with AES-256-GCM under a PBKDF2-SHA256 key
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Passphrase bool   `json:"passphrase"` // Whether the key came from PassphraseEnvVar
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileStore keeps secrets in an encrypted file, for hosts without a
+// credential store. Without a passphrase the key only ties the file to this
+// machine and user: it keeps the secrets out of backups and dotfile
+// repositories, not from other programs running as the user.
+type fileStore struct {
+	path string
+
+	mu      sync.Mutex
+	secrets map[string]string // Decrypted contents, once read
+}
+
+func newFileStore(path string) *fileStore {
+	return &fileStore{path: path}
+}
+
+func (s *fileStore) Name() string {
+	return "encrypted file " + s.path
+}
+
+func (s *fileStore) Get(account string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	secret, ok := s.secrets[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (s *fileStore) Set(account, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.secrets[account] = secret
+	return s.write()
+}
+
+func (s *fileStore) Delete(account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.secrets[account]; !ok {
+		return nil
+	}
+	delete(s.secrets, account)
+	if len(s.secrets) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove credentials file: %v", err)
+		}
+		return nil
+	}
+	return s.write()
+}
+
+// load decrypts the file into secrets, unless it was already
+func (s *fileStore) load() error {
+	if s.secrets != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.secrets = make(map[string]string)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read credentials file: %v", err)
+	}
+
+	var f encryptedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("corrupt credentials file %s: %v", s.path, err)
+	}
+	if f.Version != fileVersion || f.KDF != "pbkdf2-sha256" {
+		return fmt.Errorf("unsupported credentials file %s: version %d, %s", s.path, f.Version, f.KDF)
+	}
+	if f.Iterations < minIterations || f.Iterations > maxIterations {
+		return fmt.Errorf("corrupt credentials file %s: %d PBKDF2 iterations, want %d to %d", s.path, f.Iterations, minIterations, maxIterations)
+	}
+	if f.Passphrase && os.Getenv(PassphraseEnvVar) == "" {
+		return fmt.Errorf("credentials file %s is passphrase-protected: set %s", s.path, PassphraseEnvVar)
+	}
+
+	gcm, err := newGCM(fileSecret(f.Passphrase), f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	if len(f.Nonce) != gcm.NonceSize() {
+		return fmt.Errorf("corrupt credentials file %s: bad nonce", s.path)
+	}
+	plaintext, err := gcm.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt credentials file %s: wrong passphrase, or it was written on another machine or by another user; remove it and run 'cloudpork auth login' again", s.path)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("corrupt credentials file %s: %v", s.path, err)
+	}
+	s.secrets = secrets
+	return nil
+}
+
+// write encrypts secrets with a fresh salt and nonce and replaces the file
+func (s *fileStore) write() error {
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %v", err)
+	}
+
+	f := encryptedFile{
+		Version:    fileVersion,
+		KDF:        "pbkdf2-sha256",
+		Iterations: kdfIterations,
+		Passphrase: os.Getenv(PassphraseEnvVar) != "",
+		Salt:       make([]byte, saltBytes),
+	}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+	gcm, err := newGCM(fileSecret(f.Passphrase), f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	f.Ciphertext = gcm.Seal(nil, f.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %v", err)
+	}
+
+	// Write then rename so a crash never leaves a partial file behind
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write credentials file: %v", err)
+	}
+	return nil
+}
+
+// fileSecret is what the file's key is derived from: the passphrase, or the
+// machine ID and user. The hostname is left out since it changes far more
+// often than either and would lock the user out of their credentials.
+func fileSecret(passphrase bool) []byte {
+	if passphrase {
+		return []byte(os.Getenv(PassphraseEnvVar))
+	}
+
+	var parts []string
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if id, err := os.ReadFile(path); err == nil {
+			parts = append(parts, strings.TrimSpace(string(id)))
+			break
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid, u.Username)
+	}
+	return []byte("cloudpork-credentials\x00" + strings.Join(parts, "\x00"))
+}
+
+func newGCM(secret, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(secret, salt, iterations))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the file's AES key with PBKDF2-SHA256 (RFC 8018)
+func deriveKey(secret, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(secret, salt, iterations, derivedKeySize, sha256.New)
+}
@@ -0,0 +1,170 @@
+package credentials
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// PBKDF2-HMAC-SHA256 vectors from RFC 7914, section 11
+func TestDeriveKeyVectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(deriveKey([]byte(tt.password), []byte(tt.salt), tt.iterations))
+		if got != tt.want {
+			t.Errorf("deriveKey(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	t.Setenv(PassphraseEnvVar, "")
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	store := newFileStore(path)
+	if _, err := store.Get("api_key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on a new store = %v, want ErrNotFound", err)
+	}
+	secrets := map[string]string{
+		"api_key": "cp_live_0123456789",
+		"other":   `with "quotes", spaces and ünïcode`,
+	}
+	for account, secret := range secrets {
+		if err := store.Set(account, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("file mode = %o, want 600", perm)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "cp_live_") {
+		t.Error("the file contains the secret in plaintext")
+	}
+
+	// A fresh store decrypts what the first one wrote
+	reopened := newFileStore(path)
+	for account, want := range secrets {
+		if got, err := reopened.Get(account); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v; want %q", account, got, err, want)
+		}
+	}
+
+	if err := reopened.Delete("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileStore(path).Get("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := reopened.Delete("api_key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the file is left behind once empty: %v", err)
+	}
+}
+
+func TestFileStorePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	t.Setenv(PassphraseEnvVar, "correct horse")
+	if err := newFileStore(path).Set("api_key", "cp_secret"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := newFileStore(path).Get("api_key"); err != nil || got != "cp_secret" {
+		t.Fatalf("Get = %q, %v; want the secret", got, err)
+	}
+
+	t.Setenv(PassphraseEnvVar, "wrong horse")
+	if _, err := newFileStore(path).Get("api_key"); err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Errorf("Get with the wrong passphrase = %v, want a decryption error", err)
+	}
+
+	t.Setenv(PassphraseEnvVar, "")
+	if _, err := newFileStore(path).Get("api_key"); err == nil || !strings.Contains(err.Error(), PassphraseEnvVar) {
+		t.Errorf("Get without the passphrase = %v, want an error naming %s", err, PassphraseEnvVar)
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	t.Setenv(PassphraseEnvVar, "")
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid")
+	if err := newFileStore(valid).Set("api_key", "cp_secret"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f encryptedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	tamper := func(edit func(*encryptedFile)) string {
+		g := f
+		g.Ciphertext = append([]byte(nil), f.Ciphertext...)
+		edit(&g)
+		out, _ := json.Marshal(g)
+		return string(out)
+	}
+	tests := map[string]string{
+		"not json":        "{not json",
+		"flipped bit":     tamper(func(g *encryptedFile) { g.Ciphertext[0] ^= 1 }),
+		"other salt":      tamper(func(g *encryptedFile) { g.Salt = make([]byte, saltBytes) }),
+		"future version":  tamper(func(g *encryptedFile) { g.Version = fileVersion + 1 }),
+		"unknown kdf":     tamper(func(g *encryptedFile) { g.KDF = "md5" }),
+		"truncated nonce": tamper(func(g *encryptedFile) { g.Nonce = g.Nonce[:4] }),
+		"no iterations":   tamper(func(g *encryptedFile) { g.Iterations = 0 }),
+		"few iterations":  tamper(func(g *encryptedFile) { g.Iterations = minIterations - 1 }),
+		"huge iterations": tamper(func(g *encryptedFile) { g.Iterations = 1 << 40 }),
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-"))
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			store := newFileStore(path)
+			if _, err := store.Get("api_key"); err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("Get = %v, want an error", err)
+			}
+			// A corrupt file is never silently replaced
+			if err := store.Set("api_key", "cp_new"); err == nil {
+				t.Error("Set over a corrupt file succeeded")
+			}
+		})
+	}
+}
+
+// Renaming the host must not lock the user out of the file
+func TestFileSecretIgnoresHostname(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		t.Skip("no hostname")
+	}
+	secret := string(fileSecret(false))
+	for _, part := range strings.Split(secret, "\x00")[1:] {
+		if part == host {
+			t.Errorf("machine secret %q includes the hostname", secret)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of security(1) for a missing item
+const errSecItemNotFound = 44
+
+// keychainHexPrefix marks a secret stored hex-encoded. security -i splits its
+// input with its own quoting rules, so secrets are written as hex digits it
+// has no way to misread.
+const keychainHexPrefix = "cloudpork-hex:"
+
+// keychainAccount is what account names may contain, so they need no quoting
+var keychainAccount = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// keychainStore keeps secrets in the user's macOS login keychain through
+// security(1)
+type keychainStore struct{}
+
+func (keychainStore) Name() string {
+	return "macOS Keychain"
+}
+
+func (keychainStore) Get(account string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", Service, "-a", account, "-w")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", keychainError("read", err, stderr.String())
+	}
+
+	secret := strings.TrimSuffix(stdout.String(), "\n")
+	// Secrets stored by earlier versions are plain
+	if encoded, ok := strings.CutPrefix(secret, keychainHexPrefix); ok {
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("corrupt keychain entry for %s: %v", account, err)
+		}
+		return string(decoded), nil
+	}
+	return secret, nil
+}
+
+func (keychainStore) Set(account, secret string) error {
+	if !keychainAccount.MatchString(account) {
+		return fmt.Errorf("invalid keychain account name %q", account)
+	}
+
+	// Commands read from stdin keep the secret off the command line, where
+	// other users could see it
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -l \"CloudPork %s\" -w %s%s\n",
+		Service, account, account, keychainHexPrefix, hex.EncodeToString([]byte(secret))))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return keychainError("store", err, stderr.String())
+	}
+	// security -i reports failures on stderr but exits 0
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("failed to store secret in the keychain: %s", msg)
+	}
+	return nil
+}
+
+func (keychainStore) Delete(account string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "delete-generic-password", "-s", Service, "-a", account)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if err := keychainError("delete", err, stderr.String()); err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func keychainError(action string, err error, stderr string) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+		return ErrNotFound
+	}
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("failed to %s secret in the keychain: %s", action, msg)
+	}
+	return fmt.Errorf("failed to %s secret in the keychain: %v", action, err)
+}
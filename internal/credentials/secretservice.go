@@ -0,0 +1,64 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretServiceStore keeps secrets in the freedesktop.org Secret Service
+// (GNOME Keyring, KWallet, KeePassXC) over D-Bus, through libsecret's
+// secret-tool
+type secretServiceStore struct{}
+
+func (secretServiceStore) Name() string {
+	return "Secret Service"
+}
+
+func (s secretServiceStore) Get(account string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", Service, "account", account)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	// secret-tool exits 1 with no output when nothing matches
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && stdout.Len() == 0 && strings.TrimSpace(stderr.String()) == "" {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", secretToolError("read", err, stderr.String())
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func (s secretServiceStore) Set(account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store", "--label", "CloudPork "+account, "service", Service, "account", account)
+	cmd.Stdin = strings.NewReader(secret) // Kept off the command line, where other users could see it
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError("store", err, stderr.String())
+	}
+	return nil
+}
+
+func (s secretServiceStore) Delete(account string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "clear", "service", Service, "account", account)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil && strings.TrimSpace(stderr.String()) != "" {
+		return secretToolError("delete", err, stderr.String())
+	}
+	return nil
+}
+
+func secretToolError(action string, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("failed to %s secret in the Secret Service: %s", action, msg)
+	}
+	return fmt.Errorf("failed to %s secret in the Secret Service: %v", action, err)
+}
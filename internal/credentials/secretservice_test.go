@@ -0,0 +1,134 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSecretTool is a secret-tool that keeps each secret in a file named by
+// its attributes under $FAKE_SECRETS, and logs its arguments to
+// $FAKE_SECRETS/calls
+const fakeSecretTool = `#!/bin/sh
+echo "$@" >> "$FAKE_SECRETS/calls"
+cmd=$1; shift
+[ "$cmd" = store ] && shift 2
+[ "$1" = service ] && [ "$3" = account ] || { echo "bad arguments: $*" >&2; exit 2; }
+entry="$FAKE_SECRETS/$2.$4"
+case $cmd in
+lookup) [ -f "$entry" ] || exit 1; cat "$entry"; echo ;;
+store) cat > "$entry" ;;
+clear) [ -f "$entry" ] || exit 1; rm "$entry" ;;
+esac
+`
+
+// installFakeSecretTool puts fakeSecretTool first on PATH with a session bus
+// set, and returns the directory it keeps secrets in
+func installFakeSecretTool(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake secret-tool is a shell script")
+	}
+	bin, secrets := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "secret-tool"), []byte(fakeSecretTool), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/nonexistent")
+	t.Setenv("FAKE_SECRETS", secrets)
+	return secrets
+}
+
+func TestSecretServiceStore(t *testing.T) {
+	secrets := installFakeSecretTool(t)
+	store := secretServiceStore{}
+
+	if _, err := store.Get("api_key"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on an empty store = %v, want ErrNotFound", err)
+	}
+	secret := `cp_live_$(echo x) "quoted" 'single' \n`
+	if err := store.Set("api_key", secret); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Get("api_key"); err != nil || got != secret {
+		t.Errorf("Get = %q, %v; want %q", got, err, secret)
+	}
+
+	// The secret goes over stdin, never on the command line
+	calls, err := os.ReadFile(filepath.Join(secrets, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(calls), "cp_live_") {
+		t.Errorf("the secret was passed as an argument:\n%s", calls)
+	}
+
+	if err := store.Delete("api_key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("api_key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete("api_key"); err != nil {
+		t.Errorf("deleting a missing entry = %v, want nil", err)
+	}
+}
+
+func TestSecretServiceErrors(t *testing.T) {
+	installFakeSecretTool(t)
+	broken := "#!/bin/sh\necho 'Cannot autolaunch D-Bus without X11 $DISPLAY' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(filepath.SplitList(os.Getenv("PATH"))[0], "secret-tool"), []byte(broken), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failing secret-tool is an error, not a missing entry
+	store := secretServiceStore{}
+	if _, err := store.Get("api_key"); err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "X11") {
+		t.Errorf("Get = %v, want secret-tool's error", err)
+	}
+	if err := store.Set("api_key", "cp_secret"); err == nil || !strings.Contains(err.Error(), "X11") {
+		t.Errorf("Set = %v, want secret-tool's error", err)
+	}
+}
+
+func TestOpenPicksSecretService(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the platform has its own store")
+	}
+	filePath := filepath.Join(t.TempDir(), "credentials")
+
+	installFakeSecretTool(t)
+	store, err := Open(BackendAuto, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(secretServiceStore); !ok {
+		t.Errorf("Open picked the %s, want the Secret Service", store.Name())
+	}
+
+	// Without a session bus there is no Secret Service to reach
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if store, err = Open(BackendAuto, filePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*fileStore); !ok {
+		t.Errorf("Open picked the %s, want the encrypted file", store.Name())
+	}
+	if _, err := Open(BackendSecretService, filePath); err == nil {
+		t.Error("Open(secret-service) succeeded without a session bus")
+	}
+
+	// Nor without secret-tool
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/nonexistent")
+	t.Setenv("PATH", t.TempDir())
+	if store, err = Open(BackendAuto, filePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*fileStore); !ok {
+		t.Errorf("Open picked the %s, want the encrypted file", store.Name())
+	}
+}
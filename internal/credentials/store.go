@@ -0,0 +1,101 @@
+// Package credentials keeps secrets such as the API key in the operating
+// system's credential store, or in an encrypted file where there is none.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Service names the agent's entries in the credential stores
+const Service = "cloudpork"
+
+// Backend names, as accepted by Open and the credentials.store config key
+const (
+	BackendAuto          = "auto"
+	BackendSecretService = "secret-service"
+	BackendKeychain      = "keychain"
+	BackendWinCred       = "wincred"
+	BackendFile          = "file"
+)
+
+// ErrNotFound is returned by Get when the store has no such entry
+var ErrNotFound = errors.New("credential not found")
+
+// Store saves secrets by account name under Service
+type Store interface {
+	// Name describes the backend, e.g. "macOS Keychain"
+	Name() string
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+// Open returns the store of a backend. BackendAuto, or an empty name, picks
+// the platform's store and falls back to the encrypted file at filePath when
+// it is unavailable, e.g. on a headless Linux host without a session bus.
+func Open(backend, filePath string) (Store, error) {
+	switch backend {
+	case "", BackendAuto:
+		if s := platformStore(); s != nil {
+			return s, nil
+		}
+		return newFileStore(filePath), nil
+	case BackendSecretService:
+		if err := secretServiceAvailable(); err != nil {
+			return nil, err
+		}
+		return secretServiceStore{}, nil
+	case BackendKeychain:
+		if runtime.GOOS != "darwin" {
+			return nil, fmt.Errorf("the macOS Keychain is not available on %s", runtime.GOOS)
+		}
+		return keychainStore{}, nil
+	case BackendWinCred:
+		if runtime.GOOS != "windows" {
+			return nil, fmt.Errorf("the Windows Credential Manager is not available on %s", runtime.GOOS)
+		}
+		return winCredStore{}, nil
+	case BackendFile:
+		return newFileStore(filePath), nil
+	}
+	return nil, fmt.Errorf("unknown credential store %q: expected %s, %s, %s, %s or %s",
+		backend, BackendAuto, BackendSecretService, BackendKeychain, BackendWinCred, BackendFile)
+}
+
+// platformStore returns the operating system's store, or nil when there is
+// none to use
+func platformStore() Store {
+	switch runtime.GOOS {
+	case "darwin":
+		return keychainStore{}
+	case "windows":
+		return winCredStore{}
+	}
+	if secretServiceAvailable() == nil {
+		return secretServiceStore{}
+	}
+	return nil
+}
+
+// secretServiceAvailable checks for secret-tool and a session bus to reach
+// the Secret Service on
+func secretServiceAvailable() error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return fmt.Errorf("the Secret Service needs secret-tool (libsecret-tools): %v", err)
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return nil
+	}
+	// systemd starts the user's session bus at a well-known socket
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, "bus")); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("the Secret Service needs a D-Bus session bus: DBUS_SESSION_BUS_ADDRESS is not set")
+}
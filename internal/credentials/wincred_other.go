@@ -0,0 +1,24 @@
+//go:build !windows
+
+package credentials
+
+import "fmt"
+
+// winCredStore is only available on Windows; Open never returns it elsewhere
+type winCredStore struct{}
+
+func (winCredStore) Name() string {
+	return "Windows Credential Manager"
+}
+
+func (winCredStore) Get(account string) (string, error) {
+	return "", fmt.Errorf("the Windows Credential Manager is only available on Windows")
+}
+
+func (winCredStore) Set(account, secret string) error {
+	return fmt.Errorf("the Windows Credential Manager is only available on Windows")
+}
+
+func (winCredStore) Delete(account string) error {
+	return fmt.Errorf("the Windows Credential Manager is only available on Windows")
+}
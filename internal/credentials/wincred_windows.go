@@ -0,0 +1,107 @@
+package credentials
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential mirrors the Win32 CREDENTIALW structure
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// winCredStore keeps secrets as generic credentials of the Windows
+// Credential Manager, named "cloudpork:<account>"
+type winCredStore struct{}
+
+func (winCredStore) Name() string {
+	return "Windows Credential Manager"
+}
+
+func (winCredStore) Get(account string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(Service + ":" + account)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	r, _, callErr := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if callErr == errorNotFound {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to read secret from the Credential Manager: %v", callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return string(blob), nil
+}
+
+func (winCredStore) Set(account, secret string) error {
+	target, err := syscall.UTF16PtrFromString(Service + ":" + account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	r, _, callErr := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return fmt.Errorf("failed to store secret in the Credential Manager: %v", callErr)
+	}
+	return nil
+}
+
+func (winCredStore) Delete(account string) error {
+	target, err := syscall.UTF16PtrFromString(Service + ":" + account)
+	if err != nil {
+		return err
+	}
+
+	r, _, callErr := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if r == 0 && callErr != errorNotFound {
+		return fmt.Errorf("failed to delete secret from the Credential Manager: %v", callErr)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package diff compares two analyses of the same project: what was added or
+// removed, and how resource requirements and projected cost moved.
+package diff
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// Delta is a numeric value before and after
+type Delta struct {
+	Name   string  `json:"name"`
+	Unit   string  `json:"unit,omitempty"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// Change returns After - Before
+func (d Delta) Change() float64 {
+	return d.After - d.Before
+}
+
+// Percent returns the relative change in percent. ok is false when Before is
+// zero and the change cannot be expressed as a percentage.
+func (d Delta) Percent() (pct float64, ok bool) {
+	if d.Before == 0 {
+		return 0, false
+	}
+	return d.Change() / d.Before * 100, true
+}
+
+// CostDelta is the monthly cost on one provider before and after, at the
+// baseline or at a projected user tier
+type CostDelta struct {
+	Provider string `json:"provider"`
+	Users    int    `json:"users,omitempty"` // Zero for the baseline estimate
+	Delta
+}
+
+// Result is the difference between two analyses
+type Result struct {
+	Before *types.CodeAnalysis `json:"-"`
+	After  *types.CodeAnalysis `json:"-"`
+
+	Metrics     []Delta     `json:"metrics"`
+	Costs       []CostDelta `json:"costs"`
+	Projections []CostDelta `json:"projections"`
+
+	EndpointsAdded      []types.Endpoint   `json:"endpoints_added"`
+	EndpointsRemoved    []types.Endpoint   `json:"endpoints_removed"`
+	DependenciesAdded   []string           `json:"dependencies_added"`
+	DependenciesRemoved []string           `json:"dependencies_removed"`
+	BottlenecksAdded    []types.Bottleneck `json:"bottlenecks_added"`
+	BottlenecksResolved []types.Bottleneck `json:"bottlenecks_resolved"`
+}
+
+// Compare diffs two analyses
+func Compare(before, after *types.CodeAnalysis) *Result {
+	r := &Result{Before: before, After: after}
+
+	r.Metrics = []Delta{
+		{Name: "Memory", Unit: "MB", Before: float64(before.ResourceUsage.MemoryMB), After: float64(after.ResourceUsage.MemoryMB)},
+		{Name: "CPU", Unit: "cores", Before: before.ResourceUsage.CPUCores, After: after.ResourceUsage.CPUCores},
+		{Name: "DB Connections", Before: float64(before.ResourceUsage.DatabaseConns), After: float64(after.ResourceUsage.DatabaseConns)},
+		{Name: "Network", Unit: "Mbps", Before: float64(before.ResourceUsage.NetworkMbps), After: float64(after.ResourceUsage.NetworkMbps)},
+		{Name: "Storage", Unit: "GB", Before: float64(before.ResourceUsage.StorageGB), After: float64(after.ResourceUsage.StorageGB)},
+		{Name: "API Endpoints", Before: float64(before.ApiEndpoints), After: float64(after.ApiEndpoints)},
+		{Name: "Database Calls", Before: float64(before.DatabaseCalls), After: float64(after.DatabaseCalls)},
+		{Name: "Dependencies", Before: float64(len(before.Dependencies)), After: float64(len(after.Dependencies))},
+		{Name: "Complexity Score", Before: float64(before.ComplexityScore), After: float64(after.ComplexityScore)},
+	}
+
+	r.Costs = compareCosts(before.CostEstimates, after.CostEstimates, 0)
+
+	afterTiers := make(map[int]types.ScaleProjection)
+	for _, p := range after.Projections {
+		afterTiers[p.Users] = p
+	}
+	for _, p := range before.Projections {
+		if q, ok := afterTiers[p.Users]; ok {
+			r.Projections = append(r.Projections, compareCosts(p.CostEstimates, q.CostEstimates, p.Users)...)
+		}
+	}
+
+	r.EndpointsAdded, r.EndpointsRemoved = diffSets(before.Endpoints, after.Endpoints, endpointKey)
+	r.DependenciesAdded, r.DependenciesRemoved = diffSets(before.Dependencies, after.Dependencies, strings.ToLower)
+	r.BottlenecksAdded, r.BottlenecksResolved = diffSets(before.ScalingBottlenecks, after.ScalingBottlenecks, types.Bottleneck.Key)
+
+	sort.Strings(r.DependenciesAdded)
+	sort.Strings(r.DependenciesRemoved)
+	return r
+}
+
+// CostIncrease returns the largest baseline cost increase across providers,
+// or nil if no provider got more expensive
+func (r *Result) CostIncrease() *CostDelta {
+	var worst *CostDelta
+	for i := range r.Costs {
+		c := &r.Costs[i]
+		if c.Change() > 0 && (worst == nil || c.Change() > worst.Change()) {
+			worst = c
+		}
+	}
+	return worst
+}
+
+// compareCosts pairs estimates by provider; providers missing on either side
+// are left out
+func compareCosts(before, after []types.CostEstimate, users int) []CostDelta {
+	var deltas []CostDelta
+	for _, b := range before {
+		for _, a := range after {
+			if a.Provider == b.Provider {
+				deltas = append(deltas, CostDelta{
+					Provider: b.Provider,
+					Users:    users,
+					Delta:    Delta{Name: b.Provider, Unit: b.Currency, Before: b.MonthlyTotal, After: a.MonthlyTotal},
+				})
+				break
+			}
+		}
+	}
+	return deltas
+}
+
+// endpointKey identifies a route regardless of where it is declared
+func endpointKey(e types.Endpoint) string {
+	return e.Method + " " + e.Path
+}
+
+// diffSets returns the items only in after (added) and only in before (removed)
+func diffSets[T any](before, after []T, key func(T) string) (added, removed []T) {
+	inBefore := make(map[string]bool, len(before))
+	for _, item := range before {
+		inBefore[key(item)] = true
+	}
+	inAfter := make(map[string]bool, len(after))
+	for _, item := range after {
+		inAfter[key(item)] = true
+	}
+
+	for _, item := range after {
+		if !inBefore[key(item)] {
+			added = append(added, item)
+		}
+	}
+	for _, item := range before {
+		if !inAfter[key(item)] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
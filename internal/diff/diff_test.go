@@ -0,0 +1,167 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func TestDiffSets(t *testing.T) {
+	tests := []struct {
+		name           string
+		before, after  []string
+		added, removed []string
+	}{
+		{"identical", []string{"a", "b"}, []string{"b", "a"}, nil, nil},
+		{"added and removed", []string{"a", "b"}, []string{"b", "c"}, []string{"c"}, []string{"a"}},
+		{"from nothing", nil, []string{"a"}, []string{"a"}, nil},
+		{"to nothing", []string{"a"}, nil, nil, []string{"a"}},
+		{"keyed case-insensitively", []string{"Gin"}, []string{"gin", "GORM"}, []string{"GORM"}, nil},
+		{"duplicates keep their order", []string{"a"}, []string{"c", "a", "c"}, []string{"c", "c"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := diffSets(tt.before, tt.after, strings.ToLower)
+			if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("diffSets = %q, %q; want %q, %q", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestBottleneckKey(t *testing.T) {
+	base := types.Bottleneck{Type: "database", Severity: "high", File: "handlers/users.go", Line: 20, Endpoint: "GET /users", Description: "Unbounded query of 500 rows in a hot path"}
+	same := func(edit func(*types.Bottleneck)) types.Bottleneck {
+		b := base
+		edit(&b)
+		return b
+	}
+
+	tests := []struct {
+		name  string
+		other types.Bottleneck
+		equal bool
+	}{
+		{"moved within the file", same(func(b *types.Bottleneck) { b.Line = 42 }), true},
+		{"case and punctuation", same(func(b *types.Bottleneck) { b.Description = "unbounded query of 500 rows, in a hot path." }), true},
+		{"measured numbers changed", same(func(b *types.Bottleneck) { b.Description = "Unbounded query of 2000 rows in a hot path" }), true},
+		{"other severity", same(func(b *types.Bottleneck) { b.Severity = "critical" }), false},
+		{"other description", same(func(b *types.Bottleneck) { b.Description = "Connection pool of 5 is exhausted" }), false},
+		{"other file", same(func(b *types.Bottleneck) { b.File = "handlers/orders.go" }), false},
+		{"other endpoint", same(func(b *types.Bottleneck) { b.Endpoint = "POST /users" }), false},
+		{"other type", same(func(b *types.Bottleneck) { b.Type = "memory" }), false},
+		{"static finding in the same place", same(func(b *types.Bottleneck) { b.Description = types.NPlusOnePrefix + " GORM First inside a loop" }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := base.Key() == tt.other.Key(); got != tt.equal {
+				t.Errorf("keys %q and %q equal = %v, want %v", base.Key(), tt.other.Key(), got, tt.equal)
+			}
+		})
+	}
+}
+
+func estimate(provider string, total float64) types.CostEstimate {
+	return types.CostEstimate{Provider: provider, Currency: "USD", MonthlyTotal: total}
+}
+
+func TestCompare(t *testing.T) {
+	before := &types.CodeAnalysis{
+		ResourceUsage: types.ResourceMetrics{MemoryMB: 1024, CPUCores: 1},
+		ApiEndpoints:  2,
+		Dependencies:  []string{"gin", "gorm"},
+		Endpoints: []types.Endpoint{
+			{Method: "GET", Path: "/users", File: "users.go", Line: 10},
+			{Method: "DELETE", Path: "/users/:id", File: "users.go", Line: 20},
+		},
+		ScalingBottlenecks: []types.Bottleneck{
+			{Type: "database", Severity: "high", File: "users.go", Endpoint: "GET /users", Description: "N+1 lookups of the user's roles"},
+			{Type: "database", Severity: "medium", Description: "Reports scan the orders table"},
+			{Type: "memory", Severity: "medium", Description: "Whole exports are built in memory"},
+		},
+		CostEstimates: []types.CostEstimate{estimate("aws", 200), estimate("gcp", 180)},
+		Projections: []types.ScaleProjection{
+			{Users: 1000, CostEstimates: []types.CostEstimate{estimate("aws", 200)}},
+			{Users: 10000, CostEstimates: []types.CostEstimate{estimate("aws", 800)}},
+		},
+	}
+	after := &types.CodeAnalysis{
+		ResourceUsage: types.ResourceMetrics{MemoryMB: 2048, CPUCores: 1},
+		ApiEndpoints:  2,
+		Dependencies:  []string{"Gin", "redis"},
+		Endpoints: []types.Endpoint{
+			{Method: "GET", Path: "/users", File: "api/users.go", Line: 5}, // Moved, not new
+			{Method: "POST", Path: "/orders", File: "orders.go", Line: 8},
+		},
+		ScalingBottlenecks: []types.Bottleneck{
+			{Type: "database", Severity: "high", File: "users.go", Endpoint: "GET /users", Description: "N+1 lookups of the user's roles."},
+			{Type: "database", Severity: "medium", Description: "Reports scan the orders table"},
+			// Of a type found before, but a new finding
+			{Type: "database", Severity: "critical", Description: "Every request opens a new connection"},
+			{Type: "cpu", Severity: "low", File: "images.go", Description: "Images are resized on the request path"},
+		},
+		CostEstimates: []types.CostEstimate{estimate("aws", 260), estimate("azure", 150)},
+		Projections: []types.ScaleProjection{
+			{Users: 1000, CostEstimates: []types.CostEstimate{estimate("aws", 260)}},
+			{Users: 100000, CostEstimates: []types.CostEstimate{estimate("aws", 5000)}},
+		},
+	}
+
+	r := Compare(before, after)
+
+	if r.Metrics[0].Name != "Memory" || r.Metrics[0].Change() != 1024 {
+		t.Errorf("memory delta = %+v, want +1024", r.Metrics[0])
+	}
+	if pct, ok := r.Metrics[0].Percent(); !ok || pct != 100 {
+		t.Errorf("memory change = %v%% (%v), want 100%%", pct, ok)
+	}
+	if _, ok := (Delta{Before: 0, After: 3}).Percent(); ok {
+		t.Error("a change from zero has a percentage")
+	}
+
+	// Providers and tiers on one side only are left out
+	if len(r.Costs) != 1 || r.Costs[0].Provider != "aws" || r.Costs[0].Change() != 60 {
+		t.Errorf("costs = %+v, want aws +60", r.Costs)
+	}
+	if len(r.Projections) != 1 || r.Projections[0].Users != 1000 || r.Projections[0].Change() != 60 {
+		t.Errorf("projections = %+v, want aws +60 at 1000 users", r.Projections)
+	}
+	if worst := r.CostIncrease(); worst == nil || worst.Provider != "aws" {
+		t.Errorf("CostIncrease = %+v, want aws", worst)
+	}
+
+	endpoints := func(es []types.Endpoint) []string {
+		var keys []string
+		for _, e := range es {
+			keys = append(keys, endpointKey(e))
+		}
+		return keys
+	}
+	if got := endpoints(r.EndpointsAdded); !reflect.DeepEqual(got, []string{"POST /orders"}) {
+		t.Errorf("endpoints added = %q", got)
+	}
+	if got := endpoints(r.EndpointsRemoved); !reflect.DeepEqual(got, []string{"DELETE /users/:id"}) {
+		t.Errorf("endpoints removed = %q", got)
+	}
+
+	if !reflect.DeepEqual(r.DependenciesAdded, []string{"redis"}) || !reflect.DeepEqual(r.DependenciesRemoved, []string{"gorm"}) {
+		t.Errorf("dependencies = +%q -%q, want +redis -gorm", r.DependenciesAdded, r.DependenciesRemoved)
+	}
+
+	if len(r.BottlenecksAdded) != 2 || r.BottlenecksAdded[0].Severity != "critical" || r.BottlenecksAdded[1].Type != "cpu" {
+		t.Errorf("bottlenecks added = %+v, want the critical database one and the cpu one", r.BottlenecksAdded)
+	}
+	if len(r.BottlenecksResolved) != 1 || r.BottlenecksResolved[0].Type != "memory" {
+		t.Errorf("bottlenecks resolved = %+v, want the memory one", r.BottlenecksResolved)
+	}
+}
+
+func TestCompareNoCostIncrease(t *testing.T) {
+	a := &types.CodeAnalysis{CostEstimates: []types.CostEstimate{estimate("aws", 200)}}
+	b := &types.CodeAnalysis{CostEstimates: []types.CostEstimate{estimate("aws", 150)}}
+	if worst := Compare(a, b).CostIncrease(); worst != nil {
+		t.Errorf("CostIncrease = %+v, want nil", worst)
+	}
+}
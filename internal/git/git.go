@@ -0,0 +1,84 @@
+// Package git reads repository metadata by running the git CLI.
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// IsInstalled checks whether the git CLI is available
+func IsInstalled() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// run executes git in dir and returns its trimmed output
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Describe returns the commit, branch and dirty state of the repository
+// containing dir, or nil when dir is not in a git repository
+func Describe(dir string) *types.GitInfo {
+	if !IsInstalled() {
+		return nil
+	}
+
+	commit, err := run(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil
+	}
+
+	info := &types.GitInfo{Commit: commit}
+	if branch, err := run(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		info.Branch = branch
+	}
+	if status, err := run(dir, "status", "--porcelain", "--untracked-files=no"); err == nil {
+		info.Dirty = status != ""
+	}
+	return info
+}
+
+// ChangedFiles lists the files under dir that differ from ref, including
+// uncommitted and untracked ones. Paths are slash-separated and relative to
+// dir. Deleted files are included.
+func ChangedFiles(dir, ref string) ([]string, error) {
+	if !IsInstalled() {
+		return nil, fmt.Errorf("git is not installed")
+	}
+	if _, err := run(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown git ref %q", ref)
+	}
+
+	changed, err := run(dir, "-c", "core.quotepath=off", "diff", "--name-only", "--relative", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := run(dir, "-c", "core.quotepath=off", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, file := range strings.Split(changed+"\n"+untracked, "\n") {
+		if file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
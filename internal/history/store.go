@@ -0,0 +1,318 @@
+// Package history keeps past analyses on disk, one JSON file per run under
+// <dir>/<project_id>/.
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// idTimeFormat makes entry IDs sort chronologically
+const idTimeFormat = "20060102T150405Z"
+
+// Entry is a stored analysis
+type Entry struct {
+	ID       string              `json:"id"`
+	Analysis *types.CodeAnalysis `json:"analysis"`
+}
+
+// Store is a directory of analyses grouped by project
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns ~/.cloudpork/history
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cloudpork", "history"), nil
+}
+
+// Open returns the store rooted at dir; an empty dir means DefaultDir
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, fmt.Errorf("failed to get history directory: %v", err)
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the store's root directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// ValidateProjectID rejects project IDs that cannot name a directory of the
+// store
+func ValidateProjectID(projectID string) error {
+	if projectID == "" || strings.ContainsAny(projectID, "/\\\x00") || strings.HasPrefix(projectID, ".") {
+		return fmt.Errorf("invalid project ID %q", projectID)
+	}
+	return nil
+}
+
+// projectDir returns the directory of a project's entries. IDs that are not
+// valid, or would resolve outside the store, are refused so a project ID
+// given on the command line can never reach other files.
+func (s *Store) projectDir(projectID string) (string, error) {
+	if err := ValidateProjectID(projectID); err != nil {
+		return "", err
+	}
+	root := filepath.Clean(s.dir)
+	dir := filepath.Join(root, projectID)
+	if filepath.Dir(dir) != root {
+		return "", fmt.Errorf("invalid project ID %q", projectID)
+	}
+	return dir, nil
+}
+
+// Save stores the analysis and returns its entry ID
+func (s *Store) Save(analysis *types.CodeAnalysis) (string, error) {
+	projectDir, err := s.projectDir(analysis.ProjectID)
+	if err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate history ID: %v", err)
+	}
+	id := analysis.Timestamp.UTC().Format(idTimeFormat) + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(projectDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal analysis: %v", err)
+	}
+
+	// Write then rename so readers never see a partial entry
+	path := filepath.Join(projectDir, id+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write history entry: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write history entry: %v", err)
+	}
+
+	return id, nil
+}
+
+// Projects lists the project IDs with stored analyses
+func (s *Store) Projects() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	var projects []string
+	for _, e := range entries {
+		if e.IsDir() && ValidateProjectID(e.Name()) == nil {
+			projects = append(projects, e.Name())
+		}
+	}
+	return projects, nil
+}
+
+// List returns the entries of a project, newest first with later IDs first
+// among equal timestamps. An empty projectID lists every project.
+func (s *Store) List(projectID string) ([]Entry, error) {
+	projects := []string{projectID}
+	if projectID != "" {
+		if err := ValidateProjectID(projectID); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if projects, err = s.Projects(); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []Entry
+	for _, project := range projects {
+		ids, err := s.ids(project)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			analysis, err := s.read(project, id)
+			if err != nil {
+				continue // Skip unreadable entries rather than failing the listing
+			}
+			entries = append(entries, Entry{ID: id, Analysis: analysis})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		ti, tj := entries[i].Analysis.Timestamp, entries[j].Analysis.Timestamp
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	return entries, nil
+}
+
+// Get returns the entry whose ID is id or starts with it. The prefix must
+// identify a single entry.
+func (s *Store) Get(id string) (*Entry, error) {
+	project, fullID, err := s.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, err := s.read(project, fullID)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{ID: fullID, Analysis: analysis}, nil
+}
+
+// Latest returns the newest entry of a project by analysis timestamp. IDs
+// only have second precision and a random suffix, so they break ties only.
+func (s *Store) Latest(projectID string) (*Entry, error) {
+	if err := ValidateProjectID(projectID); err != nil {
+		return nil, err
+	}
+	entries, err := s.List(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no analyses in history for project %s", projectID)
+	}
+	return &entries[0], nil
+}
+
+// Remove deletes the entry whose ID is id or starts with it
+func (s *Store) Remove(id string) (string, error) {
+	project, fullID, err := s.resolve(id)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := s.projectDir(project)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(filepath.Join(dir, fullID+".json")); err != nil {
+		return "", fmt.Errorf("failed to remove %s: %v", fullID, err)
+	}
+	// Drop the project directory once it is empty
+	os.Remove(dir)
+	return fullID, nil
+}
+
+// RemoveProject deletes every entry of a project and returns how many there were
+func (s *Store) RemoveProject(projectID string) (int, error) {
+	dir, err := s.projectDir(projectID)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := s.ids(projectID)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, fmt.Errorf("failed to remove history of %s: %v", projectID, err)
+	}
+	return len(ids), nil
+}
+
+// ids lists a project's entry IDs in chronological order
+func (s *Store) ids(projectID string) ([]string, error) {
+	dir, err := s.projectDir(projectID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %v", projectID, err)
+	}
+
+	var ids []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// resolve finds the project and full ID of an entry from an ID prefix
+func (s *Store) resolve(prefix string) (project, id string, err error) {
+	if prefix == "" {
+		return "", "", fmt.Errorf("empty history ID")
+	}
+
+	projects, err := s.Projects()
+	if err != nil {
+		return "", "", err
+	}
+
+	var matches [][2]string
+	for _, p := range projects {
+		ids, err := s.ids(p)
+		if err != nil {
+			return "", "", err
+		}
+		for _, candidate := range ids {
+			if candidate == prefix {
+				return p, candidate, nil
+			}
+			if strings.HasPrefix(candidate, prefix) {
+				matches = append(matches, [2]string{p, candidate})
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("no analysis with ID %s in history", prefix)
+	case 1:
+		return matches[0][0], matches[0][1], nil
+	default:
+		return "", "", fmt.Errorf("ID %s is ambiguous: it matches %d analyses", prefix, len(matches))
+	}
+}
+
+func (s *Store) read(projectID, id string) (*types.CodeAnalysis, error) {
+	dir, err := s.projectDir(projectID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history entry %s: %v", id, err)
+	}
+
+	var analysis types.CodeAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("corrupt history entry %s: %v", id, err)
+	}
+	return &analysis, nil
+}
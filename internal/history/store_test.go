@@ -0,0 +1,188 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func newAnalysis(projectID string, at time.Time) *types.CodeAnalysis {
+	return &types.CodeAnalysis{ProjectID: projectID, Timestamp: at}
+}
+
+func TestValidateProjectID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"proj_abc123", true},
+		{"my-service", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../..", false},
+		{".hidden", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"/etc", false},
+		{"a\x00b", false},
+	}
+	for _, tt := range tests {
+		err := ValidateProjectID(tt.id)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateProjectID(%q) = %v, want valid %v", tt.id, err, tt.valid)
+		}
+	}
+}
+
+func TestSaveListLatestRemove(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	first, err := store.Save(newAnalysis("proj_a", base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Save(newAnalysis("proj_a", base.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Save(newAnalysis("proj_b", base)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.List("proj_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != second || entries[1].ID != first {
+		t.Fatalf("List(proj_a) = %v, want [%s %s]", entryIDs(entries), second, first)
+	}
+	if all, _ := store.List(""); len(all) != 3 {
+		t.Errorf("List(\"\") returned %d entries, want 3", len(all))
+	}
+
+	latest, err := store.Latest("proj_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != second {
+		t.Errorf("Latest = %s, want %s", latest.ID, second)
+	}
+
+	removed, err := store.Remove(first[:len(first)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != first {
+		t.Errorf("Remove by prefix removed %s, want %s", removed, first)
+	}
+	count, err := store.RemoveProject("proj_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("RemoveProject returned %d, want 1", count)
+	}
+	if projects, _ := store.Projects(); len(projects) != 1 || projects[0] != "proj_b" {
+		t.Errorf("Projects = %v, want [proj_b]", projects)
+	}
+}
+
+func TestLatestOrdersByTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Within one second the ID's random suffix says nothing about order
+	write := func(id string, at time.Time) {
+		saved, err := store.Save(newAnalysis("proj_a", at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		projectDir := filepath.Join(dir, "proj_a")
+		if err := os.Rename(filepath.Join(projectDir, saved+".json"), filepath.Join(projectDir, id+".json")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("20261001T120000Z-ffffff", base.Add(100*time.Millisecond))
+	write("20261001T120000Z-000000", base.Add(900*time.Millisecond))
+
+	latest, err := store.Latest("proj_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != "20261001T120000Z-000000" {
+		t.Errorf("Latest = %s, want the entry with the later timestamp", latest.ID)
+	}
+
+	// Equal timestamps fall back to the ID
+	write("20261001T120000Z-aaaaaa", base.Add(900*time.Millisecond))
+	if latest, err = store.Latest("proj_a"); err != nil || latest.ID != "20261001T120000Z-aaaaaa" {
+		t.Errorf("Latest = %v, %v; want the later ID among equal timestamps", latest, err)
+	}
+
+	if _, err := store.Latest(""); err == nil {
+		t.Error("Latest without a project succeeded")
+	}
+	if _, err := store.Latest("proj_none"); err == nil {
+		t.Error("Latest of a project without analyses succeeded")
+	}
+}
+
+// A project ID from the command line must never reach files outside the
+// store: `history rm --all -p ../..` once deleted the user's home directory.
+func TestProjectIDCannotEscapeStore(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, ".cloudpork", "history")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	keep := filepath.Join(home, "keep.txt")
+	if err := os.WriteFile(keep, []byte("precious"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"..", "../..", "../../..", "/", home, ".", "a/../../.."} {
+		if _, err := store.RemoveProject(id); err == nil {
+			t.Errorf("RemoveProject(%q) succeeded", id)
+		}
+		if _, err := store.List(id); err == nil {
+			t.Errorf("List(%q) succeeded", id)
+		}
+		if _, err := store.Latest(id); err == nil {
+			t.Errorf("Latest(%q) succeeded", id)
+		}
+		if _, err := store.Save(newAnalysis(id, time.Now())); err == nil {
+			t.Errorf("Save with project %q succeeded", id)
+		}
+	}
+
+	if _, err := os.Stat(keep); err != nil {
+		t.Fatalf("file outside the store was removed: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("store directory was removed: %v", err)
+	}
+}
+
+func entryIDs(entries []Entry) []string {
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/claude"
+)
+
+// Supported LLM providers
+const (
+	ProviderClaude = "claude" // Claude Code CLI (cloud)
+	ProviderOllama = "ollama" // Ollama HTTP API
+	ProviderOpenAI = "openai" // Any OpenAI-compatible HTTP API
+)
+
+const defaultOllamaURL = "http://localhost:11434"
+
+// Backend is a language model the analyzer sends its analysis prompts to
+type Backend interface {
+	// Name returns the provider name of the backend
+	Name() string
+	// Local reports whether prompts stay on this machine or private network
+	Local() bool
+	// Check verifies the backend is installed and reachable
+	Check() error
+	// Complete sends a prompt and returns the raw model response. It gives
+	// up when ctx is done.
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// JSONCompleter is implemented by backends that can constrain a response
+// to a valid JSON document
+type JSONCompleter interface {
+	CompleteJSON(ctx context.Context, prompt string) (string, error)
+}
+
+// ContextWindowed is implemented by backends that cannot read the project
+// themselves. Source code has to be inlined into their prompts, within the
+// returned context window (in tokens).
+type ContextWindowed interface {
+	ContextWindow() int
+}
+
+// CompleteJSON uses the backend's JSON mode when it has one and falls back
+// to a plain completion otherwise
+func CompleteJSON(ctx context.Context, b Backend, prompt string) (string, error) {
+	if jc, ok := b.(JSONCompleter); ok {
+		return jc.CompleteJSON(ctx, prompt)
+	}
+	return b.Complete(ctx, prompt)
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Provider      string
+	BaseURL       string
+	Model         string
+	APIKey        string
+	ContextWindow int // tokens; 0 detects or uses the provider default
+	ProjectDir    string
+}
+
+// New creates the backend described by cfg
+func New(cfg Config) (Backend, error) {
+	switch cfg.Provider {
+	case ProviderClaude, "":
+		return claude.New(cfg.ProjectDir), nil
+	case ProviderOllama:
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = defaultOllamaURL
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("no local model configured. Run 'cloudpork setup --mode=local'")
+		}
+		return NewOllamaClient(baseURL, cfg.Model, cfg.ContextWindow), nil
+	case ProviderOpenAI:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("llm.local_url is required for the openai provider")
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("llm.local_model is required for the openai provider")
+		}
+		return NewOpenAIClient(cfg.BaseURL, cfg.Model, cfg.APIKey, cfg.ContextWindow), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s (must be: claude, ollama, openai)", cfg.Provider)
+	}
+}
+
+// lookupIP resolves host names; tests replace it
+var lookupIP = net.LookupIP
+
+// IsLocalURL reports whether rawURL points at this machine or a private network
+func IsLocalURL(rawURL string) bool {
+	_, local := resolveLocal(rawURL)
+	return local
+}
+
+// resolveLocal resolves the host of rawURL and reports whether every address
+// is on this machine or a private network
+func resolveLocal(rawURL string) ([]net.IP, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return nil, false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}, true
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		resolved, err := lookupIP(host)
+		if err != nil || len(resolved) == 0 {
+			return nil, false
+		}
+		ips = resolved
+	}
+
+	// Every address must be private, otherwise DNS could route us to the cloud
+	for _, ip := range ips {
+		if !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() {
+			return nil, false
+		}
+	}
+
+	return ips, true
+}
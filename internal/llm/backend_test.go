@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeDNS replaces lookupIP with a fixed table for the duration of a test
+func fakeDNS(t *testing.T, hosts map[string][]string) {
+	t.Helper()
+	t.Cleanup(func() { lookupIP = net.LookupIP })
+	lookupIP = func(host string) ([]net.IP, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, fmt.Errorf("no such host %s", host)
+		}
+		var ips []net.IP
+		for _, addr := range addrs {
+			ips = append(ips, net.ParseIP(addr))
+		}
+		return ips, nil
+	}
+}
+
+func TestIsLocalURL(t *testing.T) {
+	fakeDNS(t, map[string][]string{
+		"gpu.internal":   {"10.0.4.2"},
+		"llm.example":    {"93.184.216.34"},
+		"split.internal": {"192.168.1.20", "93.184.216.34"},
+		"v6.internal":    {"fd12:3456::7"},
+	})
+
+	tests := map[string]bool{
+		// Loopback
+		"http://localhost:11434":  true,
+		"http://LocalHost":        true,
+		"http://127.0.0.1:8000":   true,
+		"http://127.8.9.10":       true,
+		"http://[::1]:11434/v1":   true,
+		"https://[::1]/api/chat":  true,
+		"http://0.0.0.0:11434":    false,
+		"http://[::]:11434":       false,
+		"http://localhost.evil.x": false,
+
+		// RFC 1918 and IPv6 unique local
+		"http://10.1.2.3:8080":   true,
+		"http://172.16.0.1":      true,
+		"http://172.31.255.254":  true,
+		"http://172.32.0.1":      false,
+		"http://192.168.0.10":    true,
+		"http://[fd00::1]:11434": true,
+
+		// Link-local
+		"http://169.254.10.20":   true,
+		"http://[fe80::1]:11434": true,
+
+		// Public
+		"http://8.8.8.8":              false,
+		"https://[2001:4860::8888]/":  false,
+		"http://100.64.0.1":           false,
+		"https://api.openai.com/v1":   false, // Not in the fake DNS
+		"http://llm.example:8000":     false,
+		"http://split.internal:11434": false, // One public address is enough
+		"http://gpu.internal:8000":    true,
+		"http://v6.internal":          true,
+
+		// Not URLs of a host
+		"":                false,
+		"localhost:11434": false,
+		"http://":         false,
+		"://bad":          false,
+	}
+	for rawURL, want := range tests {
+		if got := IsLocalURL(rawURL); got != want {
+			t.Errorf("IsLocalURL(%q) = %v, want %v", rawURL, got, want)
+		}
+	}
+}
+
+// chatServer answers OpenAI chat completions with a fixed reply
+func chatServer(t *testing.T, reply string) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"choices": [{"message": {"content": %q}}]}`, reply)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClientsRefuseRedirectsToOtherHosts(t *testing.T) {
+	elsewhere := chatServer(t, "leaked")
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(elsewhere.URL, "http://"))
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{"another host", "http://localhost:" + port, true},
+		{"the same host", elsewhere.URL, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, tt.target+r.URL.Path, http.StatusTemporaryRedirect)
+			}))
+			defer ts.Close()
+
+			client := NewOpenAIClient(ts.URL, "model", "secret", 0)
+			reply, err := client.Complete(context.Background(), "prompt")
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "refusing redirect") {
+					t.Errorf("Complete() = %q, %v; want the redirect refused", reply, err)
+				}
+				return
+			}
+			if err != nil || reply != "leaked" {
+				t.Errorf("Complete() = %q, %v; want the redirect followed", reply, err)
+			}
+		})
+	}
+}
+
+func TestClientsDialTheCheckedAddress(t *testing.T) {
+	ts := chatServer(t, "ok")
+	u, _ := url.Parse(ts.URL)
+	hosts := map[string][]string{"gpu.internal": {"127.0.0.1"}}
+	fakeDNS(t, hosts)
+
+	client := NewOpenAIClient("http://gpu.internal:"+u.Port(), "model", "", 0)
+	if !client.Local() {
+		t.Fatal("Local() = false for a host resolving to loopback")
+	}
+
+	// The name now resolves to a public address, or not at all; the
+	// connection still goes to the address that was checked
+	hosts["gpu.internal"] = []string{"93.184.216.34"}
+	if reply, err := client.Complete(context.Background(), "prompt"); err != nil || reply != "ok" {
+		t.Errorf("Complete() = %q, %v; want the checked server to answer", reply, err)
+	}
+
+	// A client whose server was found public is not pinned
+	public := NewOpenAIClient("http://gpu.internal:"+u.Port(), "model", "", 0)
+	if public.Local() {
+		t.Fatal("Local() = true for a host resolving to a public address")
+	}
+	if ips := public.pin.pinned(); ips != nil {
+		t.Errorf("pinned to %v, want no pin", ips)
+	}
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ollamaTimeout = 10 * time.Minute
+
+	// defaultOllamaContextWindow is used when the model does not report its own
+	defaultOllamaContextWindow = 4096
+
+	// maxDetectedContextWindow caps detected windows, since num_ctx is
+	// allocated up front and 128K-token models would exhaust laptop memory
+	maxDetectedContextWindow = 16384
+
+	// progressEvery is how many streamed chunks are received per progress tick
+	progressEvery = 50
+)
+
+// OllamaClient sends prompts to a local Ollama server
+type OllamaClient struct {
+	baseURL    string
+	model      string
+	pin        *hostPin
+	httpClient *http.Client
+
+	// contextWindow is detected once, on first use, when not configured
+	contextWindow     int
+	contextWindowOnce sync.Once
+
+	// Progress receives a tick while a response is streaming in. Defaults to
+	// stderr so it never pollutes JSON output; set to nil to disable.
+	Progress io.Writer
+}
+
+// NewOllamaClient creates a new Ollama client. A zero contextWindow is
+// detected from the model on first use.
+func NewOllamaClient(baseURL, model string, contextWindow int) *OllamaClient {
+	pin := newHostPin(baseURL)
+	return &OllamaClient{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		model:         model,
+		contextWindow: contextWindow,
+		pin:           pin,
+		httpClient:    pin.client(ollamaTimeout),
+		Progress:      os.Stderr,
+	}
+}
+
+// Name returns the provider name
+func (c *OllamaClient) Name() string {
+	return ProviderOllama
+}
+
+// Local reports whether the Ollama server is on this machine or private network
+func (c *OllamaClient) Local() bool {
+	return c.pin.local(c.baseURL)
+}
+
+// Check verifies Ollama is running and the model is pulled
+func (c *OllamaClient) Check() error {
+	if !IsOllamaHealthy(c.baseURL) {
+		return fmt.Errorf("Ollama is not responding at %s (run 'ollama serve')", c.baseURL)
+	}
+	if !IsModelAvailable(c.baseURL, c.model) {
+		return fmt.Errorf("model %s is not installed (run 'ollama pull %s')", c.model, c.model)
+	}
+	return nil
+}
+
+// ContextWindow returns the model's context length in tokens. It is safe to
+// call from concurrent passes.
+func (c *OllamaClient) ContextWindow() int {
+	c.contextWindowOnce.Do(func() {
+		if c.contextWindow == 0 {
+			c.contextWindow = c.detectContextWindow()
+		}
+	})
+	return c.contextWindow
+}
+
+// Complete sends a prompt and returns the response text
+func (c *OllamaClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return c.chat(ctx, prompt, false)
+}
+
+// CompleteJSON sends a prompt with Ollama's JSON mode enabled, so the
+// response is guaranteed to be a JSON document
+func (c *OllamaClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	return c.chat(ctx, prompt, true)
+}
+
+// chat streams a single-turn conversation from /api/chat
+func (c *OllamaClient) chat(ctx context.Context, prompt string, jsonMode bool) (string, error) {
+	payload := map[string]interface{}{
+		"model": c.model,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"stream": true,
+		"options": map[string]interface{}{
+			// Ollama silently truncates prompts to its 2048 token default otherwise
+			"num_ctx":     c.ContextWindow(),
+			"temperature": 0,
+		},
+	}
+	if jsonMode {
+		payload["format"] = "json"
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// Each line of the stream is a JSON object carrying the next piece of the reply
+	var sb strings.Builder
+	chunks := 0
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var chunk struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Done  bool   `json:"done"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", fmt.Errorf("failed to decode ollama stream: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		sb.WriteString(chunk.Message.Content)
+		chunks++
+		if c.Progress != nil && chunks%progressEvery == 0 {
+			fmt.Fprint(c.Progress, "·")
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read ollama stream: %v", err)
+	}
+
+	return sb.String(), nil
+}
+
+// detectContextWindow asks /api/show for the model's trained context length
+func (c *OllamaClient) detectContextWindow() int {
+	jsonData, err := json.Marshal(map[string]string{"model": c.model})
+	if err != nil {
+		return defaultOllamaContextWindow
+	}
+
+	client := c.pin.client(10 * time.Second)
+	resp, err := client.Post(c.baseURL+"/api/show", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return defaultOllamaContextWindow
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return defaultOllamaContextWindow
+	}
+
+	var response struct {
+		ModelInfo map[string]interface{} `json:"model_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return defaultOllamaContextWindow
+	}
+
+	// The key is prefixed with the architecture, e.g. "llama.context_length"
+	for key, value := range response.ModelInfo {
+		if strings.HasSuffix(key, ".context_length") {
+			if n, ok := value.(float64); ok && n > 0 {
+				if n > maxDetectedContextWindow {
+					return maxDetectedContextWindow
+				}
+				return int(n)
+			}
+		}
+	}
+
+	return defaultOllamaContextWindow
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeOllama serves /api/show with a model's context length and answers
+// /api/chat with the num_ctx it was sent
+func fakeOllama(t *testing.T, contextLength int, shows *atomic.Int32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/show", func(w http.ResponseWriter, r *http.Request) {
+		shows.Add(1)
+		fmt.Fprintf(w, `{"model_info": {"llama.context_length": %d}}`, contextLength)
+	})
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Options struct {
+				NumCtx int `json:"num_ctx"`
+			} `json:"options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "{\"message\": {\"content\": \"%d\"}}\n{\"done\": true}\n", req.Options.NumCtx)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// Analysis passes share one client and run concurrently; the window is
+// detected once and every pass sends the same num_ctx
+func TestOllamaContextWindowConcurrentPasses(t *testing.T) {
+	var shows atomic.Int32
+	ts := fakeOllama(t, 8192, &shows)
+	client := NewOllamaClient(ts.URL, "llama3", 0)
+	client.Progress = nil
+
+	const passes = 8
+	replies := make([]string, passes)
+	errs := make([]error, passes)
+	var wg sync.WaitGroup
+	for i := 0; i < passes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			replies[i], errs[i] = client.CompleteJSON(context.Background(), "prompt")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range replies {
+		if errs[i] != nil || replies[i] != "8192" {
+			t.Errorf("pass %d: num_ctx %q, %v; want 8192", i, replies[i], errs[i])
+		}
+	}
+	if n := shows.Load(); n != 1 {
+		t.Errorf("/api/show was called %d times, want 1", n)
+	}
+}
+
+func TestOllamaContextWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured int
+		reported   int
+		want       int
+		wantShows  int32
+	}{
+		{"configured", 2048, 8192, 2048, 0},
+		{"detected", 0, 8192, 8192, 1},
+		{"capped", 0, 131072, maxDetectedContextWindow, 1},
+		{"not reported", 0, 0, defaultOllamaContextWindow, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shows atomic.Int32
+			ts := fakeOllama(t, tt.reported, &shows)
+			client := NewOllamaClient(ts.URL, "llama3", tt.configured)
+			for i := 0; i < 2; i++ {
+				if got := client.ContextWindow(); got != tt.want {
+					t.Errorf("ContextWindow = %d, want %d", got, tt.want)
+				}
+			}
+			if n := shows.Load(); n != tt.wantShows {
+				t.Errorf("/api/show was called %d times, want %d", n, tt.wantShows)
+			}
+		})
+	}
+}
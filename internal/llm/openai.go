@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	openAITimeout = 10 * time.Minute
+
+	// defaultOpenAIContextWindow is a conservative window most served models exceed
+	defaultOpenAIContextWindow = 8192
+)
+
+// OpenAIClient sends prompts to an OpenAI-compatible chat completions API
+// (vLLM, llama.cpp server, LM Studio, LocalAI, ...)
+type OpenAIClient struct {
+	baseURL       string
+	model         string
+	apiKey        string
+	contextWindow int
+	pin           *hostPin
+	httpClient    *http.Client
+}
+
+// NewOpenAIClient creates a new OpenAI-compatible client
+func NewOpenAIClient(baseURL, model, apiKey string, contextWindow int) *OpenAIClient {
+	if contextWindow == 0 {
+		contextWindow = defaultOpenAIContextWindow
+	}
+	pin := newHostPin(baseURL)
+	return &OpenAIClient{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		model:         model,
+		apiKey:        apiKey,
+		contextWindow: contextWindow,
+		pin:           pin,
+		httpClient:    pin.client(openAITimeout),
+	}
+}
+
+// Name returns the provider name
+func (c *OpenAIClient) Name() string {
+	return ProviderOpenAI
+}
+
+// Local reports whether the server is on this machine or private network
+func (c *OpenAIClient) Local() bool {
+	return c.pin.local(c.baseURL)
+}
+
+// Check verifies the server answers on /v1/models
+func (c *OpenAIClient) Check() error {
+	req, err := http.NewRequest("GET", c.baseURL+"/v1/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	c.setHeaders(req)
+
+	client := c.pin.client(5 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("server is not responding at %s: %v", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server at %s returned status %d", c.baseURL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ContextWindow returns the configured context length in tokens
+func (c *OpenAIClient) ContextWindow() int {
+	return c.contextWindow
+}
+
+// Complete sends a prompt to /v1/chat/completions and returns the reply
+func (c *OpenAIClient) Complete(ctx context.Context, prompt string) (string, error) {
+	return c.chat(ctx, prompt, false)
+}
+
+// CompleteJSON sends a prompt with JSON response format enabled
+func (c *OpenAIClient) CompleteJSON(ctx context.Context, prompt string) (string, error) {
+	return c.chat(ctx, prompt, true)
+}
+
+func (c *OpenAIClient) chat(ctx context.Context, prompt string, jsonMode bool) (string, error) {
+	payload := map[string]interface{}{
+		"model": c.model,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0,
+	}
+	if jsonMode {
+		payload["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("response contained no choices")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+func (c *OpenAIClient) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRedirects is how many redirects within the server's host are followed
+const maxRedirects = 10
+
+// hostPin keeps a client's requests on the model server it was created for.
+// Redirects to another host are refused, and once Local has found the server
+// on this machine or a private network, connections go to the addresses it
+// checked, not to whatever the name resolves to later.
+type hostPin struct {
+	host      string
+	transport *http.Transport
+
+	mu  sync.Mutex
+	ips []net.IP
+}
+
+// newHostPin creates the pin for a server at baseURL
+func newHostPin(baseURL string) *hostPin {
+	p := &hostPin{}
+	if u, err := url.Parse(baseURL); err == nil {
+		p.host = u.Hostname()
+	}
+
+	p.transport = http.DefaultTransport.(*http.Transport).Clone()
+	p.transport.DialContext = p.dial
+	p.transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		// A proxy would carry prompts off the checked addresses
+		if p.pinned() != nil {
+			return nil, nil
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+	return p
+}
+
+// client returns an HTTP client that goes through the pin
+func (p *hostPin) client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     p.transport,
+		CheckRedirect: p.checkRedirect,
+	}
+}
+
+// local resolves the server's addresses and reports whether they are all
+// local. If they are, later connections are pinned to them.
+func (p *hostPin) local(baseURL string) bool {
+	ips, local := resolveLocal(baseURL)
+	if local {
+		p.mu.Lock()
+		p.ips = ips
+		p.mu.Unlock()
+	}
+	return local
+}
+
+// pinned returns the addresses connections are pinned to, if any
+func (p *hostPin) pinned() []net.IP {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ips
+}
+
+// dial connects to the pinned addresses, in order, when addr is the server's
+// host, and to addr itself otherwise
+func (p *hostPin) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	ips := p.pinned()
+	if err != nil || len(ips) == 0 || !strings.EqualFold(host, p.host) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	var errs []error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// checkRedirect refuses redirects that leave the server's host
+func (p *hostPin) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !strings.EqualFold(req.URL.Hostname(), p.host) {
+		return fmt.Errorf("refusing redirect from %s to %s", p.host, req.URL.Host)
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
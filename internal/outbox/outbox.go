@@ -0,0 +1,160 @@
+// Package outbox queues analyses whose upload failed, one JSON file per
+// analysis under <dir>/, until they can be sent.
+package outbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// idTimeFormat makes item IDs sort in the order the analyses ran
+const idTimeFormat = "20060102T150405.000Z"
+
+// Item is a queued analysis and the outcome of its last upload attempt
+type Item struct {
+	ID        string              `json:"id"`
+	QueuedAt  time.Time           `json:"queued_at"`
+	Attempts  int                 `json:"attempts"`             // Failed uploads, including the one that queued it
+	LastError string              `json:"last_error,omitempty"` // Why the last upload failed
+	Rejected  bool                `json:"rejected,omitempty"`   // The API refused the analysis itself; only an explicit sync retries it
+	Analysis  *types.CodeAnalysis `json:"analysis"`
+}
+
+// Outbox is a directory of analyses waiting to be uploaded
+type Outbox struct {
+	dir string
+}
+
+// DefaultDir returns ~/.cloudpork/outbox
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cloudpork", "outbox"), nil
+}
+
+// Open returns the outbox rooted at dir; an empty dir means DefaultDir
+func Open(dir string) (*Outbox, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, fmt.Errorf("failed to get outbox directory: %v", err)
+		}
+	}
+	return &Outbox{dir: dir}, nil
+}
+
+// Dir returns the outbox's directory
+func (o *Outbox) Dir() string {
+	return o.dir
+}
+
+// Add queues an analysis whose upload failed with uploadErr. Queueing the
+// same analysis again replaces its item and counts one more attempt.
+func (o *Outbox) Add(analysis *types.CodeAnalysis, uploadErr error) (*Item, error) {
+	id := itemID(analysis)
+	item, err := o.read(id)
+	if err != nil {
+		item = &Item{ID: id, QueuedAt: time.Now().UTC(), Analysis: analysis}
+	}
+	item.Attempts++
+	if uploadErr != nil {
+		item.LastError = uploadErr.Error()
+	}
+	if err := o.Save(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Save writes an item back, e.g. after a failed upload
+func (o *Outbox) Save(item *Item) error {
+	if err := os.MkdirAll(o.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox item: %v", err)
+	}
+
+	// Write then rename so a crash never leaves a partial item behind
+	path := filepath.Join(o.dir, item.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write outbox item: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write outbox item: %v", err)
+	}
+	return nil
+}
+
+// List returns the queued items, oldest analysis first
+func (o *Outbox) List() ([]*Item, error) {
+	files, err := os.ReadDir(o.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %v", err)
+	}
+
+	var ids []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(f.Name(), ".json"))
+		}
+	}
+	sort.Strings(ids)
+
+	var items []*Item
+	for _, id := range ids {
+		item, err := o.read(id)
+		if err != nil {
+			continue // Skip unreadable items rather than blocking the queue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Remove drops an uploaded item
+func (o *Outbox) Remove(id string) error {
+	if err := os.Remove(filepath.Join(o.dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove outbox item %s: %v", id, err)
+	}
+	return nil
+}
+
+func (o *Outbox) read(id string) (*Item, error) {
+	data, err := os.ReadFile(filepath.Join(o.dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox item %s: %v", id, err)
+	}
+
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil || item.Analysis == nil {
+		return nil, fmt.Errorf("corrupt outbox item %s", id)
+	}
+	item.ID = id
+	return &item, nil
+}
+
+// itemID identifies an analysis by when it ran and its project, so IDs sort
+// in upload order and the same analysis is queued once
+func itemID(analysis *types.CodeAnalysis) string {
+	sum := sha256.Sum256([]byte(analysis.ProjectID))
+	return analysis.Timestamp.UTC().Format(idTimeFormat) + "-" + hex.EncodeToString(sum[:3])
+}
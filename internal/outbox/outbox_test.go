@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func newAnalysis(projectID string, at time.Time) *types.CodeAnalysis {
+	return &types.CodeAnalysis{ProjectID: projectID, Timestamp: at}
+}
+
+func TestAddAndList(t *testing.T) {
+	box, err := Open(filepath.Join(t.TempDir(), "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items, err := box.List(); err != nil || len(items) != 0 {
+		t.Fatalf("List on a new outbox = %v, %v; want nothing", items, err)
+	}
+
+	// Queued out of order, listed in the order the analyses ran
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later, err := box.Add(newAnalysis("proj_a", base.Add(time.Hour)), errors.New("connection refused"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier, err := box.Add(newAnalysis("proj_b", base), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := box.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != earlier.ID || items[1].ID != later.ID {
+		t.Fatalf("List = %+v, want %s then %s", items, earlier.ID, later.ID)
+	}
+	if got := items[1]; got.Attempts != 1 || got.LastError != "connection refused" || got.Analysis.ProjectID != "proj_a" {
+		t.Errorf("item = %+v, want one attempt that failed with the upload error", got)
+	}
+	if info, err := os.Stat(box.Dir()); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("outbox directory = %v, %v; want mode 700", info, err)
+	}
+}
+
+func TestAddDedupesByID(t *testing.T) {
+	box, _ := Open(t.TempDir())
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	first, err := box.Add(newAnalysis("proj_a", at), errors.New("timeout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same analysis queued again, e.g. by a second failed upload
+	again, err := box.Add(newAnalysis("proj_a", at.In(time.FixedZone("CEST", 2*3600))), errors.New("503"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another project's analysis at the same moment is a separate item
+	other, err := box.Add(newAnalysis("proj_b", at), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != first.ID || other.ID == first.ID {
+		t.Errorf("IDs = %s, %s, %s; want the first two equal and the third different", first.ID, again.ID, other.ID)
+	}
+	if again.Attempts != 2 || again.LastError != "503" || !again.QueuedAt.Equal(first.QueuedAt) {
+		t.Errorf("requeued item = %+v, want two attempts, the latest error and the first queue time", again)
+	}
+	if items, _ := box.List(); len(items) != 2 {
+		t.Errorf("List has %d items, want 2", len(items))
+	}
+}
+
+func TestSaveAndRemove(t *testing.T) {
+	box, _ := Open(t.TempDir())
+	item, err := box.Add(newAnalysis("proj_a", time.Now()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item.Rejected = true
+	item.LastError = "invalid project"
+	if err := box.Save(item); err != nil {
+		t.Fatal(err)
+	}
+	items, _ := box.List()
+	if len(items) != 1 || !items[0].Rejected || items[0].LastError != "invalid project" {
+		t.Fatalf("List = %+v, want the saved item", items)
+	}
+
+	if err := box.Remove(item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := box.Remove(item.ID); err != nil {
+		t.Errorf("removing a missing item = %v, want nil", err)
+	}
+	if items, _ := box.List(); len(items) != 0 {
+		t.Errorf("List after Remove = %+v, want nothing", items)
+	}
+}
+
+func TestListSkipsUnreadableItems(t *testing.T) {
+	dir := t.TempDir()
+	box, _ := Open(dir)
+	item, err := box.Add(newAnalysis("proj_a", time.Now()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"00000000T000000.000Z-aaaaaa.json":     "{not json",
+		"00000000T000000.000Z-bbbbbb.json":     `{"id": "x"}`,
+		"00000000T000000.000Z-cccccc.json.tmp": "partial",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := box.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != item.ID {
+		t.Errorf("List = %+v, want only %s", items, item.ID)
+	}
+}
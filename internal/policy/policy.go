@@ -0,0 +1,300 @@
+// Package policy checks an analysis against the thresholds of a project's
+// policy file, so that CI can fail or warn on a bad result.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/diff"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is where the policy file lives, relative to the project root
+var DefaultPath = filepath.Join(".cloudpork", "policy.yaml")
+
+// Check outcomes, from best to worst
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Policy is the content of a policy file. Thresholds under Fail fail the
+// check; the same thresholds under Warn only warn.
+type Policy struct {
+	Baseline string     `yaml:"baseline"` // History ID, "latest" or a JSON file
+	Warn     Thresholds `yaml:"warn"`
+	Fail     Thresholds `yaml:"fail"`
+}
+
+// Thresholds are the limits of one level. Zero values are not checked.
+type Thresholds struct {
+	MaxMemoryMB             int         `yaml:"max_memory_mb"`
+	MaxCPUCores             float64     `yaml:"max_cpu_cores"`
+	MaxDatabaseConns        int         `yaml:"max_database_connections"`
+	MaxComplexityScore      int         `yaml:"max_complexity_score"`
+	MaxMonthlyCost          []CostLimit `yaml:"max_monthly_cost"`
+	BottleneckSeverities    []string    `yaml:"bottleneck_severities"`     // Any bottleneck of these severities
+	NewBottleneckSeverities []string    `yaml:"new_bottleneck_severities"` // Only bottlenecks not in the baseline
+	MaxCostIncreasePercent  *float64    `yaml:"max_cost_increase_percent"` // Versus the baseline; 0 allows no increase
+}
+
+// CostLimit is a monthly budget at a number of users
+type CostLimit struct {
+	Users    int     `yaml:"users"`    // Zero for the current estimate
+	Provider string  `yaml:"provider"` // Empty for the cheapest provider
+	USD      float64 `yaml:"usd"`
+}
+
+// Violation is a threshold the analysis exceeds
+type Violation struct {
+	Level   string `json:"level"` // StatusWarn or StatusFail
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	key     string // Identifies the rule and subject, so a failure hides the matching warning
+}
+
+// Result is the outcome of checking an analysis
+type Result struct {
+	Violations []Violation `json:"violations"`
+	Notes      []string    `json:"notes,omitempty"` // Rules that could not be checked
+}
+
+// Default fails on critical bottlenecks. It applies when a project has no
+// policy file.
+func Default() *Policy {
+	return &Policy{Fail: Thresholds{BottleneckSeverities: []string{"critical"}}}
+}
+
+// Load reads a policy file. Unknown keys are errors so that a typo cannot
+// silently disable a check.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	for _, level := range []Thresholds{p.Warn, p.Fail} {
+		for _, limit := range level.MaxMonthlyCost {
+			if limit.Users < 0 || limit.USD <= 0 {
+				return fmt.Errorf("max_monthly_cost needs a positive usd and users of 0 or more")
+			}
+		}
+		for _, severities := range [][]string{level.BottleneckSeverities, level.NewBottleneckSeverities} {
+			for _, severity := range severities {
+				switch severity {
+				case "low", "medium", "high", "critical":
+				default:
+					return fmt.Errorf("unknown bottleneck severity %q", severity)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Tiers returns the user counts the cost limits need projections for
+func (p *Policy) Tiers() []int {
+	var tiers []int
+	for _, level := range []Thresholds{p.Warn, p.Fail} {
+		for _, limit := range level.MaxMonthlyCost {
+			if limit.Users > 0 {
+				tiers = append(tiers, limit.Users)
+			}
+		}
+	}
+	return tiers
+}
+
+// UsesBaseline reports whether any threshold compares against a baseline
+func (p *Policy) UsesBaseline() bool {
+	return len(p.Warn.NewBottleneckSeverities) > 0 || len(p.Fail.NewBottleneckSeverities) > 0 ||
+		p.Warn.MaxCostIncreasePercent != nil || p.Fail.MaxCostIncreasePercent != nil
+}
+
+// Check evaluates the analysis. baseline may be nil, in which case the
+// baseline thresholds are skipped with a note.
+func (p *Policy) Check(analysis, baseline *types.CodeAnalysis) *Result {
+	var changes *diff.Result
+	if baseline != nil {
+		changes = diff.Compare(baseline, analysis)
+	}
+
+	result := &Result{}
+	failed := make(map[string]bool)
+	for _, v := range check(p.Fail, StatusFail, analysis, changes, result) {
+		failed[v.key] = true
+		result.Violations = append(result.Violations, v)
+	}
+	for _, v := range check(p.Warn, StatusWarn, analysis, changes, result) {
+		if !failed[v.key] {
+			result.Violations = append(result.Violations, v)
+		}
+	}
+
+	if baseline == nil && p.UsesBaseline() {
+		result.Notes = append(result.Notes, "No baseline analysis: skipped new_bottleneck_severities and max_cost_increase_percent")
+	}
+	return result
+}
+
+// Status is StatusFail if any threshold failed, StatusWarn if any warned,
+// and StatusPass otherwise
+func (r *Result) Status() string {
+	status := StatusPass
+	for _, v := range r.Violations {
+		if v.Level == StatusFail {
+			return StatusFail
+		}
+		status = StatusWarn
+	}
+	return status
+}
+
+// Count returns the number of violations at a level
+func (r *Result) Count(level string) int {
+	count := 0
+	for _, v := range r.Violations {
+		if v.Level == level {
+			count++
+		}
+	}
+	return count
+}
+
+// check evaluates the thresholds of one level
+func check(t Thresholds, level string, a *types.CodeAnalysis, changes *diff.Result, result *Result) []Violation {
+	var violations []Violation
+	add := func(rule, key, format string, args ...interface{}) {
+		violations = append(violations, Violation{Level: level, Rule: rule, Message: fmt.Sprintf(format, args...), key: rule + "|" + key})
+	}
+
+	if t.MaxMemoryMB > 0 && a.ResourceUsage.MemoryMB > t.MaxMemoryMB {
+		add("max_memory_mb", "", "Memory %d MB exceeds %d MB", a.ResourceUsage.MemoryMB, t.MaxMemoryMB)
+	}
+	if t.MaxCPUCores > 0 && a.ResourceUsage.CPUCores > t.MaxCPUCores {
+		add("max_cpu_cores", "", "CPU %.1f cores exceeds %.1f cores", a.ResourceUsage.CPUCores, t.MaxCPUCores)
+	}
+	if t.MaxDatabaseConns > 0 && a.ResourceUsage.DatabaseConns > t.MaxDatabaseConns {
+		add("max_database_connections", "", "%d database connections exceed %d", a.ResourceUsage.DatabaseConns, t.MaxDatabaseConns)
+	}
+	if t.MaxComplexityScore > 0 && a.ComplexityScore > t.MaxComplexityScore {
+		add("max_complexity_score", "", "Complexity score %d exceeds %d", a.ComplexityScore, t.MaxComplexityScore)
+	}
+
+	for _, limit := range t.MaxMonthlyCost {
+		estimates := a.CostEstimates
+		at := "Monthly cost"
+		if limit.Users > 0 {
+			estimates = nil
+			for _, p := range a.Projections {
+				if p.Users == limit.Users {
+					estimates = p.CostEstimates
+				}
+			}
+			at = fmt.Sprintf("Monthly cost at %s users", types.FormatUsers(limit.Users))
+		}
+
+		estimate := pickEstimate(estimates, limit.Provider)
+		if estimate == nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("%s: no estimate for provider %q, skipped max_monthly_cost", at, limit.Provider))
+			continue
+		}
+		if estimate.MonthlyTotal > limit.USD {
+			add("max_monthly_cost", fmt.Sprintf("%d|%s", limit.Users, strings.ToLower(limit.Provider)),
+				"%s is $%.2f on %s, over the $%.2f budget", at, estimate.MonthlyTotal, estimate.Provider, limit.USD)
+		}
+	}
+
+	for _, b := range a.ScalingBottlenecks {
+		if contains(t.BottleneckSeverities, b.Severity) {
+			add("bottleneck_severities", bottleneckLabel(b), "%s %s", b.Severity, bottleneckLabel(b))
+		}
+	}
+
+	if changes == nil {
+		return violations
+	}
+
+	for _, b := range changes.BottlenecksAdded {
+		if contains(t.NewBottleneckSeverities, b.Severity) {
+			add("new_bottleneck_severities", bottleneckLabel(b), "New %s %s", b.Severity, bottleneckLabel(b))
+		}
+	}
+
+	if t.MaxCostIncreasePercent != nil {
+		// Report the provider with the largest relative increase
+		costs := append([]diff.CostDelta(nil), changes.Costs...)
+		sort.SliceStable(costs, func(i, j int) bool {
+			pi, _ := costs[i].Percent()
+			pj, _ := costs[j].Percent()
+			return pi > pj
+		})
+		if len(costs) > 0 {
+			if pct, ok := costs[0].Percent(); ok && pct > *t.MaxCostIncreasePercent {
+				add("max_cost_increase_percent", "", "Monthly cost on %s is up %.1f%% ($%.2f → $%.2f) versus the baseline, over the %.1f%% limit",
+					costs[0].Provider, pct, costs[0].Before, costs[0].After, *t.MaxCostIncreasePercent)
+			}
+		}
+	}
+
+	return violations
+}
+
+// pickEstimate returns the estimate of a provider, or the cheapest one when
+// provider is empty
+func pickEstimate(estimates []types.CostEstimate, provider string) *types.CostEstimate {
+	var picked *types.CostEstimate
+	for i := range estimates {
+		e := &estimates[i]
+		if provider != "" {
+			if strings.EqualFold(e.Provider, provider) {
+				return e
+			}
+			continue
+		}
+		if picked == nil || e.MonthlyTotal < picked.MonthlyTotal {
+			picked = e
+		}
+	}
+	return picked
+}
+
+// bottleneckLabel describes a bottleneck on one line
+func bottleneckLabel(b types.Bottleneck) string {
+	label := fmt.Sprintf("%s bottleneck: %s", b.Type, b.Description)
+	if b.File != "" {
+		label += fmt.Sprintf(" (%s:%d)", b.File, b.Line)
+	}
+	return label
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
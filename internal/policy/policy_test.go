@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    *Policy
+		wantErr string
+	}{
+		{
+			name: "full policy",
+			yaml: `baseline: latest
+warn:
+  max_memory_mb: 2048
+  max_cost_increase_percent: 0
+fail:
+  bottleneck_severities: [critical]
+  max_monthly_cost:
+    - users: 10000
+      provider: aws
+      usd: 500
+`,
+			want: &Policy{
+				Baseline: "latest",
+				Warn:     Thresholds{MaxMemoryMB: 2048, MaxCostIncreasePercent: new(float64)},
+				Fail: Thresholds{
+					BottleneckSeverities: []string{"critical"},
+					MaxMonthlyCost:       []CostLimit{{Users: 10000, Provider: "aws", USD: 500}},
+				},
+			},
+		},
+		{name: "empty file", yaml: "", want: &Policy{}},
+		{name: "unknown key", yaml: "fail:\n  max_memory: 512\n", wantErr: "field max_memory not found"},
+		{name: "unknown severity", yaml: "warn:\n  new_bottleneck_severities: [severe]\n", wantErr: `unknown bottleneck severity "severe"`},
+		{name: "cost limit without usd", yaml: "fail:\n  max_monthly_cost:\n    - users: 1000\n", wantErr: "needs a positive usd"},
+		{name: "negative users", yaml: "fail:\n  max_monthly_cost:\n    - users: -1\n      usd: 10\n", wantErr: "needs a positive usd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// estimates returns an aws and a gcp estimate
+func estimates(aws, gcp float64) []types.CostEstimate {
+	return []types.CostEstimate{
+		{Provider: "aws", Currency: "USD", MonthlyTotal: aws},
+		{Provider: "gcp", Currency: "USD", MonthlyTotal: gcp},
+	}
+}
+
+func percent(p float64) *float64 { return &p }
+
+func TestCheck(t *testing.T) {
+	analysis := &types.CodeAnalysis{
+		ResourceUsage:   types.ResourceMetrics{MemoryMB: 1024, CPUCores: 2, DatabaseConns: 50},
+		ComplexityScore: 7,
+		CostEstimates:   estimates(120, 90),
+		Projections: []types.ScaleProjection{
+			{Users: 10000, CostEstimates: estimates(900, 700)},
+		},
+		ScalingBottlenecks: []types.Bottleneck{
+			{Type: "database", Severity: "critical", File: "users.go", Line: 12, Description: "Unbounded query"},
+			{Type: "cpu", Severity: "medium", Description: "Synchronous resizing"},
+		},
+	}
+	baseline := &types.CodeAnalysis{
+		CostEstimates: estimates(100, 90),
+		ScalingBottlenecks: []types.Bottleneck{
+			{Type: "cpu", Severity: "medium", Description: "Synchronous resizing"},
+		},
+	}
+
+	type violation struct{ Level, Rule string }
+	tests := []struct {
+		name     string
+		policy   Policy
+		baseline *types.CodeAnalysis
+		want     []violation
+		notes    int
+	}{
+		{name: "nothing exceeded", policy: Policy{Fail: Thresholds{MaxMemoryMB: 2048, MaxCPUCores: 4, MaxComplexityScore: 8}}},
+		{
+			name:   "resource limits",
+			policy: Policy{Fail: Thresholds{MaxMemoryMB: 512, MaxDatabaseConns: 20}, Warn: Thresholds{MaxCPUCores: 1, MaxComplexityScore: 5}},
+			want: []violation{
+				{StatusFail, "max_memory_mb"}, {StatusFail, "max_database_connections"},
+				{StatusWarn, "max_cpu_cores"}, {StatusWarn, "max_complexity_score"},
+			},
+		},
+		{
+			name:   "a failure hides the same warning",
+			policy: Policy{Fail: Thresholds{MaxMemoryMB: 512}, Warn: Thresholds{MaxMemoryMB: 256}},
+			want:   []violation{{StatusFail, "max_memory_mb"}},
+		},
+		{
+			name:   "default policy fails on critical bottlenecks",
+			policy: *Default(),
+			want:   []violation{{StatusFail, "bottleneck_severities"}},
+		},
+		{
+			name: "monthly cost against the cheapest provider and a tier",
+			policy: Policy{Fail: Thresholds{MaxMonthlyCost: []CostLimit{
+				{USD: 100},                                // gcp at 90 passes
+				{Provider: "AWS", USD: 100},               // aws at 120 fails
+				{Users: 10000, USD: 500},                  // gcp at 700 fails
+				{Users: 50000, USD: 500},                  // No projection: noted
+				{Provider: "azure", USD: 100},             // No estimate: noted
+				{Users: 10000, Provider: "gcp", USD: 800}, // Passes
+			}}},
+			want:  []violation{{StatusFail, "max_monthly_cost"}, {StatusFail, "max_monthly_cost"}},
+			notes: 2,
+		},
+		{
+			name:     "baseline rules",
+			policy:   Policy{Fail: Thresholds{NewBottleneckSeverities: []string{"critical", "medium"}}, Warn: Thresholds{MaxCostIncreasePercent: percent(10)}},
+			baseline: baseline,
+			want:     []violation{{StatusFail, "new_bottleneck_severities"}, {StatusWarn, "max_cost_increase_percent"}},
+		},
+		{
+			name:   "new bottleneck of a type found before",
+			policy: Policy{Fail: Thresholds{NewBottleneckSeverities: []string{"critical"}}},
+			baseline: &types.CodeAnalysis{ScalingBottlenecks: []types.Bottleneck{
+				{Type: "database", Severity: "medium", Description: "Reports scan the orders table"},
+			}},
+			want: []violation{{StatusFail, "new_bottleneck_severities"}},
+		},
+		{
+			name:     "cost increase within the limit",
+			policy:   Policy{Fail: Thresholds{MaxCostIncreasePercent: percent(25)}},
+			baseline: baseline,
+		},
+		{
+			name:   "baseline rules without a baseline are noted",
+			policy: Policy{Fail: Thresholds{NewBottleneckSeverities: []string{"critical"}}},
+			notes:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.policy.Check(analysis, tt.baseline)
+
+			var got []violation
+			for _, v := range result.Violations {
+				got = append(got, violation{v.Level, v.Rule})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %+v, want %+v", got, tt.want)
+			}
+			if len(result.Notes) != tt.notes {
+				t.Errorf("notes = %q, want %d", result.Notes, tt.notes)
+			}
+		})
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	tests := []struct {
+		levels []string
+		want   string
+	}{
+		{nil, StatusPass},
+		{[]string{StatusWarn}, StatusWarn},
+		{[]string{StatusWarn, StatusFail, StatusWarn}, StatusFail},
+	}
+	for _, tt := range tests {
+		r := &Result{}
+		for _, level := range tt.levels {
+			r.Violations = append(r.Violations, Violation{Level: level})
+		}
+		if got := r.Status(); got != tt.want {
+			t.Errorf("Status of %q = %s, want %s", tt.levels, got, tt.want)
+		}
+		if got := r.Count(StatusWarn) + r.Count(StatusFail); got != len(tt.levels) {
+			t.Errorf("Count of %q = %d", tt.levels, got)
+		}
+	}
+}
+
+func TestTiersAndUsesBaseline(t *testing.T) {
+	p := &Policy{
+		Warn: Thresholds{MaxMonthlyCost: []CostLimit{{Users: 1000, USD: 10}, {USD: 5}}},
+		Fail: Thresholds{MaxMonthlyCost: []CostLimit{{Users: 100000, USD: 100}}},
+	}
+	if got := p.Tiers(); !reflect.DeepEqual(got, []int{1000, 100000}) {
+		t.Errorf("Tiers = %v, want [1000 100000]", got)
+	}
+	if p.UsesBaseline() {
+		t.Error("UsesBaseline without baseline rules")
+	}
+	p.Fail.MaxCostIncreasePercent = percent(0)
+	if !p.UsesBaseline() {
+		t.Error("UsesBaseline is false with max_cost_increase_percent")
+	}
+}
@@ -0,0 +1,109 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// csvHeader is a long format: one row per metric, cost line or finding
+var csvHeader = []string{"category", "name", "value", "detail", "file", "line"}
+
+func renderCSV(w io.Writer, a *types.CodeAnalysis) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{csvHeader}
+	add := func(category, name, value, detail, file string, line int) {
+		lineStr := ""
+		if line > 0 {
+			lineStr = strconv.Itoa(line)
+		}
+		rows = append(rows, []string{category, name, value, detail, file, lineStr})
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	add("summary", "language", a.Language, "", "", 0)
+	add("summary", "framework", a.Framework, "", "", 0)
+	add("summary", "dependencies", strconv.Itoa(len(a.Dependencies)), "", "", 0)
+	add("summary", "api_endpoints", strconv.Itoa(a.ApiEndpoints), "", "", 0)
+	add("summary", "database_calls", strconv.Itoa(a.DatabaseCalls), "", "", 0)
+	add("summary", "complexity_score", strconv.Itoa(a.ComplexityScore), "", "", 0)
+	add("summary", "confidence", strconv.FormatFloat(a.Confidence, 'f', 2, 64), "", "", 0)
+
+	if ws := a.Workspace; ws != nil {
+		for _, s := range ws.Services {
+			add("service", s.Name, s.Language, fmt.Sprintf("%s, %d endpoints, %g cores, %d MB", s.Framework, s.ApiEndpoints, s.ResourceUsage.CPUCores, s.ResourceUsage.MemoryMB), s.Path, 0)
+			for _, e := range s.CostEstimates {
+				add("service_cost", s.Name+" "+e.Provider, num(e.MonthlyTotal), e.Currency+" "+e.Region+"/month", s.Path, 0)
+			}
+		}
+		for _, d := range ws.Dependencies {
+			add("service_dependency", d.From, d.To, d.Via, "", 0)
+		}
+	}
+
+	add("resources", "memory_mb", strconv.Itoa(a.ResourceUsage.MemoryMB), "", "", 0)
+	add("resources", "cpu_cores", num(a.ResourceUsage.CPUCores), "", "", 0)
+	add("resources", "database_connections", strconv.Itoa(a.ResourceUsage.DatabaseConns), "", "", 0)
+	add("resources", "network_mbps", strconv.Itoa(a.ResourceUsage.NetworkMbps), "", "", 0)
+	add("resources", "storage_gb", strconv.Itoa(a.ResourceUsage.StorageGB), "", "", 0)
+
+	for _, e := range a.CostEstimates {
+		add("cost", e.Provider, num(e.MonthlyTotal), fmt.Sprintf("%s %s/month, %s, %s", e.Currency, e.Region, e.Compute.Description, e.Database.Description), "", 0)
+	}
+
+	for _, p := range a.Projections {
+		for _, e := range p.CostEstimates {
+			add("projection", fmt.Sprintf("%d users %s", p.Users, e.Provider), num(e.MonthlyTotal), e.Compute.Description, "", 0)
+		}
+	}
+
+	for _, l := range a.ScalingLimits {
+		add("scaling_limit", l.Constraint, strconv.Itoa(l.ThresholdUsers), l.Limit, "", 0)
+	}
+
+	if a.Serverless != nil {
+		for _, f := range a.Serverless.Functions {
+			add("function", f.Name, num(f.CostPerInvocation), fmt.Sprintf("%d MB, %ds timeout, %s, %s", f.MemoryMB, f.TimeoutSeconds, f.Architecture, strings.Join(f.Triggers, " ")), f.File, f.Line)
+		}
+		for _, p := range a.Serverless.Projections {
+			add("serverless_projection", fmt.Sprintf("%g req/s", p.RequestsPerSecond), num(p.MonthlyTotal), fmt.Sprintf("%d invocations/month", p.MonthlyInvocations), "", 0)
+		}
+	}
+
+	for _, b := range a.ScalingBottlenecks {
+		add("bottleneck", b.Type, b.Severity, b.Description, b.File, b.Line)
+	}
+
+	for _, r := range a.Recommendations {
+		add("recommendation", r.Type, num(r.MonthlySavings), r.Description, r.File, r.Line)
+	}
+
+	for _, r := range a.Infrastructure {
+		detail := fmt.Sprintf("%s, %s instances", r.Role, instanceRange(r))
+		if s := storage(r); s != "" {
+			detail += ", " + s
+		}
+		add("infrastructure", r.Address(), r.InstanceType, detail, r.File, r.Line)
+	}
+
+	for _, c := range a.Containers {
+		add("container", c.File, c.BaseImage, fmt.Sprintf("%d stages, %d layers, %d packages", c.Stages, c.Layers, c.Packages), c.File, c.Line)
+	}
+
+	for _, s := range a.SecurityIssues {
+		add("security", s.Type, s.Severity, s.Description, s.File, s.Line)
+	}
+
+	for _, e := range a.Endpoints {
+		add("endpoint", e.Method, e.Path, e.Framework, e.File, e.Line)
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	return nil
+}
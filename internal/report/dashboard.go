@@ -0,0 +1,414 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+)
+
+// maxPrintedEndpoints bounds the route list in the terminal summary
+const maxPrintedEndpoints = 15
+
+// renderDashboard prints the analysis summary shown in the terminal
+func renderDashboard(w io.Writer, ca *types.CodeAnalysis) error {
+	fmt.Fprintf(w, "%s\n", color.New(color.FgCyan, color.Bold).Sprint("📊 Analysis Summary"))
+	fmt.Fprintf(w, "%s\n\n", color.New(color.Faint).Sprint(strings.Repeat("=", 50)))
+
+	// Provenance labels are faint so the values stay readable
+	src := func(field string) string {
+		return color.New(color.Faint).Sprint(provenanceLabel(ca, field))
+	}
+
+	// Incremental runs only re-analyzed part of the project
+	if ca.Incremental != nil {
+		fmt.Fprintf(w, "🔁 %s\n\n", color.New(color.Faint).Sprintf("Incremental since %s: %d changed files and %d dependents re-analyzed, the rest carried over",
+			ca.Incremental.Since, len(ca.Incremental.ChangedFiles), len(ca.Incremental.Dependents)))
+	}
+
+	// Basic Info
+	fmt.Fprintf(w, "🏗️  %s: %s%s\n", color.New(color.Bold).Sprint("Framework"), ca.Framework, src("framework"))
+	fmt.Fprintf(w, "💾  %s: %s%s\n", color.New(color.Bold).Sprint("Language"), ca.Language, src("language"))
+	fmt.Fprintf(w, "📦  %s: %d%s\n", color.New(color.Bold).Sprint("Dependencies"), len(ca.Dependencies), src("dependencies"))
+	fmt.Fprintf(w, "🔌  %s: %d%s\n", color.New(color.Bold).Sprint("API Endpoints"), ca.ApiEndpoints, src("api_endpoints"))
+	fmt.Fprintf(w, "⚡  %s: %d%s\n", color.New(color.Bold).Sprint("Background Jobs"), len(ca.BackgroundJobs), src("background_jobs"))
+	if len(ca.Datastores) > 0 {
+		fmt.Fprintf(w, "🗄️  %s: %s%s\n", color.New(color.Bold).Sprint("Datastores"), strings.Join(ca.Datastores, ", "), src("datastores"))
+	}
+	if len(ca.CacheUsage) > 0 {
+		fmt.Fprintf(w, "🧊  %s: %s%s\n", color.New(color.Bold).Sprint("Caches"), strings.Join(ca.CacheUsage, ", "), src("cache_usage"))
+	}
+	fmt.Fprintln(w)
+
+	// Services of a monorepo
+	if ca.Workspace != nil {
+		printWorkspace(w, ca)
+	}
+
+	// Routes
+	if len(ca.Endpoints) > 0 {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgBlue, color.Bold).Sprint("🛣️  API Routes"))
+		for i, endpoint := range ca.Endpoints {
+			if i == maxPrintedEndpoints {
+				fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("... and %d more", len(ca.Endpoints)-i))
+				break
+			}
+			fmt.Fprintf(w, "  %-7s %s %s\n",
+				endpoint.Method,
+				endpoint.Path,
+				color.New(color.Faint).Sprintf("%s:%d", endpoint.File, endpoint.Line))
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Declared infrastructure
+	if len(ca.Infrastructure) > 0 {
+		printInfrastructure(w, ca)
+	}
+
+	// Built images
+	if len(ca.Containers) > 0 {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgBlue, color.Bold).Sprint("🐳 Container Images"))
+		for _, c := range ca.Containers {
+			base := c.BaseImage
+			if c.BaseSizeMB > 0 {
+				base += fmt.Sprintf(" (~%d MB)", c.BaseSizeMB)
+			}
+			fmt.Fprintf(w, "  %s · %d stages · %d layers · %d packages %s\n",
+				base, c.Stages, c.Layers, c.Packages,
+				color.New(color.Faint).Sprintf("%s:%d", c.File, c.Line))
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Resource Usage
+	fmt.Fprintf(w, "%s\n", color.New(color.FgGreen, color.Bold).Sprint("💻 Resource Requirements"))
+	fmt.Fprintf(w, "  Memory: %d MB%s\n", ca.ResourceUsage.MemoryMB, src("resource_usage.memory_mb"))
+	fmt.Fprintf(w, "  CPU: %.1f cores%s\n", ca.ResourceUsage.CPUCores, src("resource_usage.cpu_cores"))
+	fmt.Fprintf(w, "  DB Connections: %d%s\n", ca.ResourceUsage.DatabaseConns, src("resource_usage.database_connections"))
+	fmt.Fprintf(w, "  Network: %d Mbps%s\n", ca.ResourceUsage.NetworkMbps, src("resource_usage.network_mbps"))
+	fmt.Fprintln(w)
+
+	// Monthly cost per provider
+	if len(ca.CostEstimates) > 0 {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgGreen, color.Bold).Sprint("💰 Estimated Monthly Cost"))
+		for _, estimate := range ca.CostEstimates {
+			fmt.Fprintf(w, "  %-6s %s  %s\n",
+				estimate.Provider,
+				color.New(color.Bold).Sprintf("$%9.2f", estimate.MonthlyTotal),
+				color.New(color.Faint).Sprintf("%s · %s · %s", estimate.Region, estimate.Compute.Description, estimate.Database.Description))
+		}
+		fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("On-demand %s prices from pricing catalog %s",
+			ca.CostEstimates[0].Currency, ca.CostEstimates[0].CatalogVersion))
+		if ca.Serverless != nil {
+			fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprint("Priced as long-running servers; see Serverless Functions for the per-invocation cost"))
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Scaling projection
+	if len(ca.Projections) > 0 {
+		printProjections(w, ca)
+	}
+
+	// Functions priced per invocation
+	if ca.Serverless != nil {
+		printServerless(w, ca)
+	}
+
+	// Bottlenecks
+	if len(ca.ScalingBottlenecks) > 0 {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgYellow, color.Bold).Sprint("⚠️  Scaling Bottlenecks"))
+		for _, bottleneck := range ca.ScalingBottlenecks {
+			severity := SeverityColor(bottleneck.Severity)
+			fmt.Fprintf(w, "  %s %s: %s\n",
+				severity.Sprint("●"),
+				color.New(color.Bold).Sprint(bottleneck.Type),
+				bottleneck.Description)
+			if bottleneck.BindingAtUsers > 0 {
+				fmt.Fprintf(w, "    %s\n", color.New(color.Faint).Sprintf("binding at %s users", types.FormatUsers(bottleneck.BindingAtUsers)))
+			}
+			if bottleneck.File != "" {
+				location := fmt.Sprintf("%s:%d", bottleneck.File, bottleneck.Line)
+				if bottleneck.Endpoint != "" {
+					location += " in " + bottleneck.Endpoint
+				}
+				fmt.Fprintf(w, "    %s\n", color.New(color.Faint).Sprint(location))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Recommendations
+	if len(ca.Recommendations) > 0 {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgGreen, color.Bold).Sprint("💡 Recommendations"))
+		for _, rec := range ca.Recommendations {
+			fmt.Fprintf(w, "  %s %s: %s\n",
+				color.New(color.FgGreen).Sprint("●"),
+				color.New(color.Bold).Sprint(rec.Type),
+				rec.Description)
+			detail := ""
+			if rec.Suggested != "" {
+				detail = "suggested: " + rec.Suggested
+			}
+			if rec.MonthlySavings > 0 {
+				detail += fmt.Sprintf(", saves ~$%.2f/month", rec.MonthlySavings)
+			}
+			if rec.File != "" {
+				detail += fmt.Sprintf(" (%s:%d)", rec.File, rec.Line)
+			}
+			if detail = strings.TrimPrefix(detail, ", "); detail != "" {
+				fmt.Fprintf(w, "    %s\n", color.New(color.Faint).Sprint(detail))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Performance Issues
+	if ca.Performance.HasNPlusOneQuery || ca.Performance.HasLargePayloads {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgRed, color.Bold).Sprint("🐌 Performance Issues"))
+		if ca.Performance.HasNPlusOneQuery {
+			fmt.Fprintln(w, "  • N+1 query patterns detected")
+		}
+		if ca.Performance.HasLargePayloads {
+			fmt.Fprintln(w, "  • Large payload responses found")
+		}
+		fmt.Fprintln(w)
+	}
+
+	// Confidence
+	if ca.LowConfidence {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgYellow, color.Bold).Sprint("⚠️  Low Confidence"))
+		fmt.Fprintf(w, "  Heuristic fallback used for: %s\n", strings.Join(ca.HeuristicPasses, ", "))
+		fmt.Fprintln(w, "  Treat these numbers as rough estimates")
+		fmt.Fprintln(w)
+	}
+
+	// Complexity Score
+	scoreColor := complexityColor(ca.ComplexityScore)
+	fmt.Fprintf(w, "🎯 %s: %s%s\n",
+		color.New(color.Bold).Sprint("Complexity Score"),
+		scoreColor.Sprintf("%d/100", ca.ComplexityScore),
+		src("complexity_score"))
+
+	// Overall confidence
+	fmt.Fprintf(w, "🔎 %s: %.0f%%\n", color.New(color.Bold).Sprint("Confidence"), ca.Confidence*100)
+	return nil
+}
+
+// printProjections prints the scaling projection as a table
+func printProjections(w io.Writer, ca *types.CodeAnalysis) {
+	fmt.Fprintf(w, "%s\n", color.New(color.FgMagenta, color.Bold).Sprint("📈 Scaling Projection"))
+
+	header := fmt.Sprintf("  %-7s %8s %9s %9s %10s", "Users", "CPU", "Memory", "DB Conns", "Network")
+	for _, estimate := range ca.Projections[0].CostEstimates {
+		header += fmt.Sprintf(" %12s", estimate.Provider)
+	}
+	fmt.Fprintln(w, color.New(color.Bold).Sprint(header))
+
+	for _, p := range ca.Projections {
+		row := fmt.Sprintf("  %-7s %8.1f %9s %9d %10s",
+			types.FormatUsers(p.Users),
+			p.Resources.CPUCores,
+			fmt.Sprintf("%.1f GB", float64(p.Resources.MemoryMB)/1024),
+			p.Resources.DatabaseConns,
+			fmt.Sprintf("%d Mbps", p.Resources.NetworkMbps))
+		for _, estimate := range p.CostEstimates {
+			row += fmt.Sprintf(" %12s", fmt.Sprintf("$%.0f", estimate.MonthlyTotal))
+		}
+		if len(p.Binding) > 0 {
+			row += "  " + color.New(color.FgYellow).Sprint("⚠ "+strings.Join(p.Binding, ", "))
+		}
+		fmt.Fprintln(w, row)
+	}
+
+	for _, limit := range ca.ScalingLimits {
+		if limit.BindingAtUsers == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s %s binding at %s users (%s): %s\n",
+			color.New(color.FgYellow).Sprint("●"),
+			color.New(color.Bold).Sprint(limit.Constraint),
+			types.FormatUsers(limit.BindingAtUsers),
+			limit.Limit,
+			limit.Mitigation)
+	}
+	fmt.Fprintln(w)
+}
+
+// printServerless lists the serverless functions and their monthly cost at
+// each request rate
+func printServerless(w io.Writer, ca *types.CodeAnalysis) {
+	fmt.Fprintf(w, "%s\n", color.New(color.FgMagenta, color.Bold).Sprint("λ  Serverless Functions"))
+	for i, f := range ca.Serverless.Functions {
+		if i == maxPrintedResources {
+			fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("... and %d more", len(ca.Serverless.Functions)-i))
+			break
+		}
+		details := []string{fmt.Sprintf("%d MB", f.MemoryMB), fmt.Sprintf("%ds timeout", f.TimeoutSeconds), f.Architecture}
+		if f.ProvisionedConcurrency > 0 {
+			details = append(details, fmt.Sprintf("%d provisioned", f.ProvisionedConcurrency))
+		}
+		if len(f.Triggers) > 0 {
+			details = append(details, strings.Join(f.Triggers, ", "))
+		}
+		fmt.Fprintf(w, "  %-24s %s $%.7f/call %s\n",
+			f.Name,
+			strings.Join(details, " · "),
+			f.CostPerInvocation,
+			color.New(color.Faint).Sprintf("%s:%d", f.File, f.Line))
+	}
+
+	fmt.Fprintln(w, color.New(color.Bold).Sprintf("  %-9s %14s %10s %10s %12s %10s", "Req/s", "Invocations", "Requests", "Compute", "Provisioned", "Total"))
+	for _, p := range ca.Serverless.Projections {
+		fmt.Fprintf(w, "  %-9s %14d %10s %10s %12s %10s\n",
+			trimZero(p.RequestsPerSecond),
+			p.MonthlyInvocations,
+			fmt.Sprintf("$%.2f", p.Requests),
+			fmt.Sprintf("$%.2f", p.Compute),
+			fmt.Sprintf("$%.2f", p.Provisioned),
+			fmt.Sprintf("$%.2f", p.MonthlyTotal))
+	}
+	fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("Monthly, after the free tier, assuming %d ms per invocation%s",
+		ca.Serverless.DurationMs, provenanceLabel(ca, "serverless.assumed_duration_ms")))
+	fmt.Fprintln(w)
+}
+
+// printWorkspace lists the services of a monorepo with their own numbers,
+// and which services depend on which
+func printWorkspace(w io.Writer, ca *types.CodeAnalysis) {
+	ws := ca.Workspace
+	fmt.Fprintf(w, "%s\n", color.New(color.FgBlue, color.Bold).Sprintf("🧩 Services (%d, from %s)", len(ws.Services), ws.Source))
+
+	provider := ""
+	if len(ca.CostEstimates) > 0 {
+		provider = ca.CostEstimates[0].Provider
+	}
+	for _, s := range ws.Services {
+		details := []string{s.Language}
+		if s.Framework != "" && s.Framework != "Unknown" {
+			details = append(details, s.Framework)
+		}
+		details = append(details,
+			fmt.Sprintf("%d endpoints", s.ApiEndpoints),
+			fmt.Sprintf("%.1f cores, %d MB", s.ResourceUsage.CPUCores, s.ResourceUsage.MemoryMB))
+		if provider != "" {
+			details = append(details, fmt.Sprintf("$%.2f/month on %s", s.MonthlyCost(provider), provider))
+		}
+		fmt.Fprintf(w, "  %-20s %s %s\n", s.Name, strings.Join(details, " · "), color.New(color.Faint).Sprint(s.Path))
+		if len(s.DependsOn) > 0 {
+			fmt.Fprintf(w, "  %-20s %s\n", "", color.New(color.Faint).Sprint("→ "+strings.Join(s.DependsOn, ", ")))
+		}
+	}
+	if ws.Shared > 0 {
+		fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("%d shared infrastructure resources declared outside the services", ws.Shared))
+	}
+	fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprint("Resources and cost below are summed over the services"))
+	fmt.Fprintln(w)
+}
+
+// maxPrintedResources bounds the infrastructure list in the terminal summary
+const maxPrintedResources = 15
+
+// printInfrastructure lists the resources declared in infrastructure as code
+func printInfrastructure(w io.Writer, ca *types.CodeAnalysis) {
+	fmt.Fprintf(w, "%s\n", color.New(color.FgBlue, color.Bold).Sprint("☁️  Declared Infrastructure"))
+	for i, r := range ca.Infrastructure {
+		if i == maxPrintedResources {
+			fmt.Fprintf(w, "  %s\n", color.New(color.Faint).Sprintf("... and %d more", len(ca.Infrastructure)-i))
+			break
+		}
+
+		var details []string
+		if r.InstanceType != "" {
+			size := r.InstanceType
+			if r.MemoryGB > 0 {
+				size += fmt.Sprintf(" (%g vCPU, %g GB)", r.VCPUs, r.MemoryGB)
+			}
+			details = append(details, size)
+		}
+		if r.Image != "" && r.Source == "docker-compose" {
+			details = append(details, r.Image)
+		}
+		if r.CPURequest > 0 || r.MemoryRequestMB > 0 {
+			details = append(details, fmt.Sprintf("requests %gm CPU, %d MB", r.CPURequest*1000, r.MemoryRequestMB))
+		} else if r.CPULimit > 0 || r.MemoryLimitMB > 0 {
+			details = append(details, fmt.Sprintf("limits %gm CPU, %d MB", r.CPULimit*1000, r.MemoryLimitMB))
+		}
+		if r.Schedule != "" {
+			details = append(details, r.Schedule)
+		}
+		if r.MinSize > 0 || r.MaxSize > 0 {
+			details = append(details, fmt.Sprintf("%d-%d instances", r.MinSize, r.MaxSize))
+		} else if n := r.Instances(); n > 1 {
+			details = append(details, fmt.Sprintf("%d instances", n))
+		}
+		if r.StorageClass != "" {
+			details = append(details, r.StorageClass)
+		}
+		if r.StorageGB > 0 {
+			details = append(details, fmt.Sprintf("%d GB", r.StorageGB))
+		}
+		if r.Region != "" {
+			details = append(details, r.Region)
+		}
+		if len(r.DependsOn) > 0 {
+			details = append(details, "depends on "+strings.Join(r.DependsOn, ", "))
+		}
+
+		location := r.File
+		if r.Line > 0 {
+			location += fmt.Sprintf(":%d", r.Line)
+		}
+		fmt.Fprintf(w, "  %-11s %s %s %s\n",
+			r.Role,
+			r.Address(),
+			strings.Join(details, " · "),
+			color.New(color.Faint).Sprint(location))
+	}
+	fmt.Fprintln(w)
+}
+
+func trimZero(v float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", v), ".0")
+}
+
+// SeverityColor returns the terminal colour of a severity level
+func SeverityColor(severity string) *color.Color {
+	switch severity {
+	case "critical":
+		return color.New(color.FgRed, color.Bold)
+	case "high":
+		return color.New(color.FgRed)
+	case "medium":
+		return color.New(color.FgYellow)
+	case "low":
+		return color.New(color.FgGreen)
+	default:
+		return color.New(color.FgWhite)
+	}
+}
+
+func complexityColor(score int) *color.Color {
+	switch {
+	case score >= 80:
+		return color.New(color.FgRed, color.Bold)
+	case score >= 60:
+		return color.New(color.FgYellow, color.Bold)
+	case score >= 40:
+		return color.New(color.FgCyan, color.Bold)
+	default:
+		return color.New(color.FgGreen, color.Bold)
+	}
+}
+
+// provenanceLabel formats a field's provenance for the terminal summary
+func provenanceLabel(ca *types.CodeAnalysis, field string) string {
+	p, ok := ca.Provenance[field]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (%s, %.0f%%)", p.Source, p.Confidence*100)
+}
@@ -0,0 +1,165 @@
+package report
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// htmlTemplate is a self-contained report: inline styles, no external assets
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"usd":       formatUSD,
+	"users":     types.FormatUsers,
+	"location":  location,
+	"gb":        func(mb int) float64 { return float64(mb) / 1024 },
+	"percent":   func(v float64) float64 { return v * 100 },
+	"instances": instanceRange,
+	"base":      baseImage,
+	"storage":   storage,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>CloudPork Analysis: {{.ProjectID}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 2rem auto; max-width: 72rem; color: #1f2328; padding: 0 1rem; }
+h1 { margin-bottom: 0.25rem; }
+.meta { color: #656d76; margin-top: 0; }
+.warn { background: #fff8c5; border: 1px solid #d4a72c; padding: 0.5rem 1rem; border-radius: 6px; }
+table { border-collapse: collapse; width: 100%; margin: 0.5rem 0 1.5rem; font-size: 0.9rem; }
+th, td { border: 1px solid #d0d7de; padding: 0.35rem 0.6rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.sev-critical, .sev-high { color: #cf222e; font-weight: 600; }
+.sev-medium { color: #9a6700; font-weight: 600; }
+.sev-low { color: #1a7f37; }
+code { background: #f6f8fa; padding: 0 0.25rem; border-radius: 4px; }
+</style>
+</head>
+<body>
+<h1>🐷 CloudPork Analysis</h1>
+<p class="meta">{{.ProjectID}} · {{.Directory}} · {{.Timestamp.Format "2006-01-02 15:04 MST"}} · confidence {{printf "%.0f" (percent .Confidence)}}%</p>
+{{if .LowConfidence}}<p class="warn">⚠️ Low confidence: heuristic fallback used for {{range $i, $p := .HeuristicPasses}}{{if $i}}, {{end}}{{$p}}{{end}}.</p>{{end}}
+
+<h2>Summary</h2>
+<table>
+<tr><th>Language</th><td>{{.Language}}</td></tr>
+<tr><th>Framework</th><td>{{.Framework}}</td></tr>
+<tr><th>Dependencies</th><td>{{len .Dependencies}}</td></tr>
+<tr><th>API endpoints</th><td>{{.ApiEndpoints}}</td></tr>
+<tr><th>Database calls</th><td>{{.DatabaseCalls}}</td></tr>
+<tr><th>Complexity</th><td>{{.ComplexityScore}}/100</td></tr>
+</table>
+
+{{with .Workspace}}
+<h2>Services</h2>
+<p class="meta">{{len .Services}} services from {{.Source}}; resources and cost below are summed over them.</p>
+<table>
+<tr><th>Service</th><th>Path</th><th>Language</th><th>Framework</th><th>Endpoints</th><th>CPU</th><th>Memory</th>{{range $.CostEstimates}}<th>{{.Provider}}</th>{{end}}<th>Depends on</th></tr>
+{{range $s := .Services}}<tr><td>{{.Name}}</td><td><code>{{.Path}}</code></td><td>{{.Language}}</td><td>{{.Framework}}</td><td class="num">{{.ApiEndpoints}}</td><td class="num">{{printf "%.1f" .ResourceUsage.CPUCores}} cores</td><td class="num">{{.ResourceUsage.MemoryMB}} MB</td>{{range $.CostEstimates}}<td class="num">{{usd ($s.MonthlyCost .Provider)}}</td>{{end}}<td>{{range $i, $d := .DependsOn}}{{if $i}}, {{end}}{{$d}}{{end}}</td></tr>
+{{end}}</table>
+{{with .Dependencies}}<ul>
+{{range .}}<li><strong>{{.From}}</strong> → <strong>{{.To}}</strong> ({{.Via}})</li>
+{{end}}</ul>{{end}}
+{{if .Shared}}<p>{{.Shared}} shared infrastructure resources are declared outside the services.</p>{{end}}
+{{end}}
+
+<h2>Resource Requirements</h2>
+<table>
+<tr><th>Memory</th><th>CPU</th><th>DB connections</th><th>Network</th><th>Storage</th></tr>
+<tr><td class="num">{{.ResourceUsage.MemoryMB}} MB</td><td class="num">{{printf "%.1f" .ResourceUsage.CPUCores}} cores</td><td class="num">{{.ResourceUsage.DatabaseConns}}</td><td class="num">{{.ResourceUsage.NetworkMbps}} Mbps</td><td class="num">{{.ResourceUsage.StorageGB}} GB</td></tr>
+</table>
+
+{{with .CostEstimates}}
+<h2>Estimated Monthly Cost</h2>
+<table>
+<tr><th>Provider</th><th>Region</th><th>Compute</th><th>Database</th><th>Storage</th><th>Network</th><th>Total</th></tr>
+{{range .}}<tr><td>{{.Provider}}</td><td>{{.Region}}</td><td class="num">{{usd .Compute.Monthly}} <small>{{.Compute.Description}}</small></td><td class="num">{{usd .Database.Monthly}} <small>{{.Database.Description}}</small></td><td class="num">{{usd .Storage.Monthly}}</td><td class="num">{{usd .Network.Monthly}}</td><td class="num"><strong>{{usd .MonthlyTotal}}</strong></td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Projections}}
+<h2>Scaling Projection</h2>
+<table>
+<tr><th>Users</th><th>CPU</th><th>Memory</th><th>DB connections</th><th>Network</th>{{range (index .Projections 0).CostEstimates}}<th>{{.Provider}}</th>{{end}}<th>Binding</th></tr>
+{{range .Projections}}<tr><td>{{users .Users}}</td><td class="num">{{printf "%.1f" .Resources.CPUCores}}</td><td class="num">{{printf "%.1f" (gb .Resources.MemoryMB)}} GB</td><td class="num">{{.Resources.DatabaseConns}}</td><td class="num">{{.Resources.NetworkMbps}} Mbps</td>{{range .CostEstimates}}<td class="num">{{usd .MonthlyTotal}}</td>{{end}}<td>{{range $i, $b := .Binding}}{{if $i}}, {{end}}{{$b}}{{end}}</td></tr>
+{{end}}</table>
+<ul>
+{{range .ScalingLimits}}{{if .BindingAtUsers}}<li><strong>{{.Constraint}}</strong> binding at {{users .BindingAtUsers}} users ({{.Limit}}): {{.Mitigation}}</li>
+{{end}}{{end}}</ul>
+{{end}}
+
+{{with .Serverless}}
+<h2>Serverless Functions</h2>
+<table>
+<tr><th>Function</th><th>Memory</th><th>Timeout</th><th>Architecture</th><th>Provisioned</th><th>Triggers</th><th>Per call</th><th>Location</th></tr>
+{{range .Functions}}<tr><td><code>{{.Name}}</code></td><td class="num">{{.MemoryMB}} MB</td><td class="num">{{.TimeoutSeconds}}s</td><td>{{.Architecture}}</td><td class="num">{{.ProvisionedConcurrency}}</td><td>{{range $i, $t := .Triggers}}{{if $i}}, {{end}}{{$t}}{{end}}</td><td class="num">${{printf "%.7f" .CostPerInvocation}}</td><td><code>{{location .File .Line}}</code></td></tr>
+{{end}}</table>
+<table>
+<tr><th>Requests/s</th><th>Invocations/month</th><th>Requests</th><th>Compute</th><th>Provisioned</th><th>Total</th></tr>
+{{range .Projections}}<tr><td class="num">{{.RequestsPerSecond}}</td><td class="num">{{.MonthlyInvocations}}</td><td class="num">{{usd .Requests}}</td><td class="num">{{usd .Compute}}</td><td class="num">{{usd .Provisioned}}</td><td class="num"><strong>{{usd .MonthlyTotal}}</strong></td></tr>
+{{end}}</table>
+<p><small>Monthly, after the free tier, assuming {{.DurationMs}} ms per invocation.</small></p>
+{{end}}
+
+{{with .ScalingBottlenecks}}
+<h2>Scaling Bottlenecks</h2>
+<table>
+<tr><th>Severity</th><th>Type</th><th>Description</th><th>Location</th><th>Binding at</th></tr>
+{{range .}}<tr><td class="sev-{{.Severity}}">{{.Severity}}</td><td>{{.Type}}</td><td>{{.Description}}</td><td>{{with location .File .Line}}<code>{{.}}</code>{{end}}{{with .Endpoint}} {{.}}{{end}}</td><td>{{if .BindingAtUsers}}{{users .BindingAtUsers}} users{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .Recommendations}}
+<h2>Recommendations</h2>
+<table>
+<tr><th>Type</th><th>Description</th><th>Suggested</th><th>Savings/month</th><th>Location</th></tr>
+{{range .}}<tr><td>{{.Type}}</td><td>{{.Description}}</td><td>{{.Suggested}}</td><td class="num">{{if .MonthlySavings}}{{usd .MonthlySavings}}{{end}}</td><td>{{with location .File .Line}}<code>{{.}}</code>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .SecurityIssues}}
+<h2>Security Issues</h2>
+<table>
+<tr><th>Severity</th><th>Type</th><th>Description</th><th>Location</th></tr>
+{{range .}}<tr><td class="sev-{{.Severity}}">{{.Severity}}</td><td>{{.Type}}</td><td>{{.Description}}</td><td>{{with location .File .Line}}<code>{{.}}</code>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .Infrastructure}}
+<details>
+<summary>Declared infrastructure ({{len .}})</summary>
+<table>
+<tr><th>Resource</th><th>Role</th><th>Instance type</th><th>Instances</th><th>Storage</th><th>Region</th><th>Location</th></tr>
+{{range .}}<tr><td><code>{{.Address}}</code></td><td>{{.Role}}</td><td>{{.InstanceType}}</td><td class="num">{{instances .}}</td><td>{{storage .}}</td><td>{{.Region}}</td><td><code>{{location .File .Line}}</code></td></tr>
+{{end}}</table>
+</details>
+{{end}}
+
+{{with .Containers}}
+<details>
+<summary>Container images ({{len .}})</summary>
+<table>
+<tr><th>Dockerfile</th><th>Base image</th><th>Stages</th><th>Layers</th><th>Packages</th><th>Location</th></tr>
+{{range .}}<tr><td><code>{{.File}}</code></td><td>{{base .}}</td><td class="num">{{.Stages}}</td><td class="num">{{.Layers}}</td><td class="num">{{.Packages}}</td><td><code>{{location .File .Line}}</code></td></tr>
+{{end}}</table>
+</details>
+{{end}}
+
+{{with .Endpoints}}
+<details>
+<summary>API routes ({{len .}})</summary>
+<table>
+<tr><th>Method</th><th>Path</th><th>Location</th></tr>
+{{range .}}<tr><td>{{.Method}}</td><td><code>{{.Path}}</code></td><td><code>{{location .File .Line}}</code></td></tr>
+{{end}}</table>
+</details>
+{{end}}
+</body>
+</html>
+`))
+
+func renderHTML(w io.Writer, analysis *types.CodeAnalysis) error {
+	return htmlTemplate.Execute(w, analysis)
+}
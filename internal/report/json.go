@@ -0,0 +1,14 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+func renderJSON(w io.Writer, analysis *types.CodeAnalysis) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(analysis)
+}
@@ -0,0 +1,181 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// markdownEscaper keeps values from breaking table cells
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+func renderMarkdown(w io.Writer, a *types.CodeAnalysis) error {
+	var sb strings.Builder
+	cell := markdownEscaper.Replace
+
+	fmt.Fprintf(&sb, "# CloudPork Analysis: %s\n\n", a.ProjectID)
+	fmt.Fprintf(&sb, "_Analyzed %s on %s · confidence %.0f%%_\n\n",
+		a.Directory, a.Timestamp.Format("2006-01-02 15:04 MST"), a.Confidence*100)
+	if a.LowConfidence {
+		fmt.Fprintf(&sb, "> ⚠️ **Low confidence:** heuristic fallback used for %s.\n\n", strings.Join(a.HeuristicPasses, ", "))
+	}
+
+	sb.WriteString("## Summary\n\n| | |\n|---|---|\n")
+	fmt.Fprintf(&sb, "| Language | %s |\n", cell(a.Language))
+	fmt.Fprintf(&sb, "| Framework | %s |\n", cell(a.Framework))
+	fmt.Fprintf(&sb, "| Dependencies | %d |\n", len(a.Dependencies))
+	fmt.Fprintf(&sb, "| API endpoints | %d |\n", a.ApiEndpoints)
+	fmt.Fprintf(&sb, "| Database calls | %d |\n", a.DatabaseCalls)
+	fmt.Fprintf(&sb, "| Background jobs | %s |\n", cell(joinOrNone(a.BackgroundJobs)))
+	fmt.Fprintf(&sb, "| Datastores | %s |\n", cell(joinOrNone(a.Datastores)))
+	fmt.Fprintf(&sb, "| Caches | %s |\n", cell(joinOrNone(a.CacheUsage)))
+	fmt.Fprintf(&sb, "| Complexity | %d/100 |\n\n", a.ComplexityScore)
+
+	if ws := a.Workspace; ws != nil {
+		fmt.Fprintf(&sb, "## Services\n\n_%d services from %s; resources and cost below are summed over them._\n\n", len(ws.Services), ws.Source)
+		sb.WriteString("| Service | Path | Language | Framework | Endpoints | CPU | Memory |")
+		for _, e := range a.CostEstimates {
+			fmt.Fprintf(&sb, " %s |", e.Provider)
+		}
+		sb.WriteString(" Depends on |\n|---|---|---|---|---|---|---|" + strings.Repeat("---|", len(a.CostEstimates)+1) + "\n")
+		for _, s := range ws.Services {
+			fmt.Fprintf(&sb, "| %s | `%s` | %s | %s | %d | %.1f cores | %d MB |", cell(s.Name), cell(s.Path), cell(s.Language),
+				cell(s.Framework), s.ApiEndpoints, s.ResourceUsage.CPUCores, s.ResourceUsage.MemoryMB)
+			for _, e := range a.CostEstimates {
+				fmt.Fprintf(&sb, " %s |", formatUSD(s.MonthlyCost(e.Provider)))
+			}
+			fmt.Fprintf(&sb, " %s |\n", cell(strings.Join(s.DependsOn, ", ")))
+		}
+		sb.WriteString("\n")
+		for _, d := range ws.Dependencies {
+			fmt.Fprintf(&sb, "- **%s** → **%s** (%s)\n", d.From, d.To, d.Via)
+		}
+		if ws.Shared > 0 {
+			fmt.Fprintf(&sb, "\n%d shared infrastructure resources are declared outside the services.\n", ws.Shared)
+		}
+		sb.WriteString("\n")
+	}
+
+	r := a.ResourceUsage
+	sb.WriteString("## Resource Requirements\n\n| Memory | CPU | DB connections | Network | Storage |\n|---|---|---|---|---|\n")
+	fmt.Fprintf(&sb, "| %d MB | %.1f cores | %d | %d Mbps | %d GB |\n\n", r.MemoryMB, r.CPUCores, r.DatabaseConns, r.NetworkMbps, r.StorageGB)
+
+	if len(a.CostEstimates) > 0 {
+		sb.WriteString("## Estimated Monthly Cost\n\n| Provider | Region | Compute | Database | Storage | Network | Total |\n|---|---|---|---|---|---|---|\n")
+		for _, e := range a.CostEstimates {
+			fmt.Fprintf(&sb, "| %s | %s | %s (%s) | %s (%s) | %s | %s | **%s** |\n",
+				e.Provider, e.Region,
+				formatUSD(e.Compute.Monthly), cell(e.Compute.Description),
+				formatUSD(e.Database.Monthly), cell(e.Database.Description),
+				formatUSD(e.Storage.Monthly), formatUSD(e.Network.Monthly), formatUSD(e.MonthlyTotal))
+		}
+		fmt.Fprintf(&sb, "\n_On-demand %s prices from pricing catalog %s._\n\n", a.CostEstimates[0].Currency, a.CostEstimates[0].CatalogVersion)
+	}
+
+	if len(a.Projections) > 0 {
+		sb.WriteString("## Scaling Projection\n\n| Users | CPU | Memory | DB connections | Network |")
+		for _, e := range a.Projections[0].CostEstimates {
+			fmt.Fprintf(&sb, " %s |", e.Provider)
+		}
+		sb.WriteString(" Binding |\n|---|---|---|---|---|")
+		sb.WriteString(strings.Repeat("---|", len(a.Projections[0].CostEstimates)+1) + "\n")
+		for _, p := range a.Projections {
+			fmt.Fprintf(&sb, "| %s | %.1f | %.1f GB | %d | %d Mbps |",
+				types.FormatUsers(p.Users), p.Resources.CPUCores, float64(p.Resources.MemoryMB)/1024,
+				p.Resources.DatabaseConns, p.Resources.NetworkMbps)
+			for _, e := range p.CostEstimates {
+				fmt.Fprintf(&sb, " %s |", formatUSD(e.MonthlyTotal))
+			}
+			fmt.Fprintf(&sb, " %s |\n", cell(strings.Join(p.Binding, ", ")))
+		}
+		sb.WriteString("\n")
+
+		for _, l := range a.ScalingLimits {
+			if l.BindingAtUsers > 0 {
+				fmt.Fprintf(&sb, "- **%s** binding at %s users (%s): %s\n",
+					l.Constraint, types.FormatUsers(l.BindingAtUsers), l.Limit, l.Mitigation)
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if a.Serverless != nil {
+		sb.WriteString("## Serverless Functions\n\n| Function | Memory | Timeout | Architecture | Provisioned | Triggers | Per call | Location |\n|---|---|---|---|---|---|---|---|\n")
+		for _, f := range a.Serverless.Functions {
+			fmt.Fprintf(&sb, "| `%s` | %d MB | %ds | %s | %d | %s | $%.7f | %s |\n", cell(f.Name), f.MemoryMB, f.TimeoutSeconds,
+				f.Architecture, f.ProvisionedConcurrency, cell(strings.Join(f.Triggers, ", ")), f.CostPerInvocation, location(f.File, f.Line))
+		}
+		sb.WriteString("\n| Requests/s | Invocations/month | Requests | Compute | Provisioned | Total |\n|---|---|---|---|---|---|\n")
+		for _, p := range a.Serverless.Projections {
+			fmt.Fprintf(&sb, "| %g | %d | %s | %s | %s | %s |\n", p.RequestsPerSecond, p.MonthlyInvocations,
+				formatUSD(p.Requests), formatUSD(p.Compute), formatUSD(p.Provisioned), formatUSD(p.MonthlyTotal))
+		}
+		fmt.Fprintf(&sb, "\nMonthly, after the free tier, assuming %d ms per invocation.\n\n", a.Serverless.DurationMs)
+	}
+
+	if len(a.ScalingBottlenecks) > 0 {
+		sb.WriteString("## Scaling Bottlenecks\n\n| Severity | Type | Description | Location | Binding at |\n|---|---|---|---|---|\n")
+		for _, b := range a.ScalingBottlenecks {
+			loc := location(b.File, b.Line)
+			if b.Endpoint != "" {
+				loc += " (" + b.Endpoint + ")"
+			}
+			binding := ""
+			if b.BindingAtUsers > 0 {
+				binding = types.FormatUsers(b.BindingAtUsers) + " users"
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", b.Severity, b.Type, cell(b.Description), cell(loc), binding)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(a.Recommendations) > 0 {
+		sb.WriteString("## Recommendations\n\n| Type | Description | Suggested | Savings/month | Location |\n|---|---|---|---|---|\n")
+		for _, r := range a.Recommendations {
+			savings := ""
+			if r.MonthlySavings > 0 {
+				savings = formatUSD(r.MonthlySavings)
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", r.Type, cell(r.Description), cell(r.Suggested), savings, cell(location(r.File, r.Line)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(a.SecurityIssues) > 0 {
+		sb.WriteString("## Security Issues\n\n| Severity | Type | Description | Location |\n|---|---|---|---|\n")
+		for _, s := range a.SecurityIssues {
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", s.Severity, cell(s.Type), cell(s.Description), cell(location(s.File, s.Line)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(a.Infrastructure) > 0 {
+		sb.WriteString("<details>\n<summary>Declared infrastructure (" + fmt.Sprint(len(a.Infrastructure)) + ")</summary>\n\n| Resource | Role | Instance type | Instances | Storage | Region | Location |\n|---|---|---|---|---|---|---|\n")
+		for _, r := range a.Infrastructure {
+			fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s | %s | %s |\n", cell(r.Address()), r.Role, cell(r.InstanceType),
+				instanceRange(r), cell(storage(r)), cell(r.Region), location(r.File, r.Line))
+		}
+		sb.WriteString("\n</details>\n\n")
+	}
+
+	if len(a.Containers) > 0 {
+		sb.WriteString("<details>\n<summary>Container images (" + fmt.Sprint(len(a.Containers)) + ")</summary>\n\n| Dockerfile | Base image | Stages | Layers | Packages | Location |\n|---|---|---|---|---|---|\n")
+		for _, c := range a.Containers {
+			fmt.Fprintf(&sb, "| `%s` | %s | %d | %d | %d | %s |\n", cell(c.File), cell(baseImage(c)), c.Stages, c.Layers, c.Packages, location(c.File, c.Line))
+		}
+		sb.WriteString("\n</details>\n\n")
+	}
+
+	if len(a.Endpoints) > 0 {
+		sb.WriteString("<details>\n<summary>API routes (" + fmt.Sprint(len(a.Endpoints)) + ")</summary>\n\n| Method | Path | Location |\n|---|---|---|\n")
+		for _, e := range a.Endpoints {
+			fmt.Fprintf(&sb, "| %s | `%s` | %s |\n", e.Method, cell(e.Path), location(e.File, e.Line))
+		}
+		sb.WriteString("\n</details>\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
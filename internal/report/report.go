@@ -0,0 +1,109 @@
+// Package report renders analysis results: the terminal dashboard, and files
+// in JSON, Markdown, HTML, SARIF and CSV.
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// Renderer writes an analysis in one output format
+type Renderer func(w io.Writer, analysis *types.CodeAnalysis) error
+
+// Dashboard names the terminal summary. It is registered like the report
+// formats but is not one: Names leaves it out.
+const Dashboard = "dashboard"
+
+// ToolVersion is the agent version recorded in reports that carry one
+var ToolVersion = "dev"
+
+var renderers = map[string]Renderer{}
+
+// Register adds a renderer under name, replacing any existing one
+func Register(name string, r Renderer) {
+	renderers[name] = r
+}
+
+// Get returns the renderer registered under name
+func Get(name string) (Renderer, bool) {
+	r, ok := renderers[name]
+	return r, ok
+}
+
+// Names lists the registered report formats in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		if name != Dashboard {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(Dashboard, renderDashboard)
+	Register("json", renderJSON)
+	Register("markdown", renderMarkdown)
+	Register("html", renderHTML)
+	Register("sarif", renderSARIF)
+	Register("csv", renderCSV)
+}
+
+// formatUSD formats a monthly amount for display
+func formatUSD(amount float64) string {
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+// location formats a file and line as "file:line"
+func location(file string, line int) string {
+	if file == "" {
+		return ""
+	}
+	if line > 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return file
+}
+
+// joinOrNone joins items, or returns "none" when there are none
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+// instanceRange describes how many instances a resource runs, e.g. "2-10"
+func instanceRange(r types.InfraResource) string {
+	if r.MinSize > 0 || r.MaxSize > 0 {
+		return fmt.Sprintf("%d-%d", r.MinSize, r.MaxSize)
+	}
+	return fmt.Sprint(r.Instances())
+}
+
+// storage describes a resource's storage class and size, e.g. "gp3 100 GB"
+func storage(r types.InfraResource) string {
+	var parts []string
+	if r.StorageClass != "" {
+		parts = append(parts, r.StorageClass)
+	}
+	if r.StorageGB > 0 {
+		parts = append(parts, fmt.Sprintf("%d GB", r.StorageGB))
+	}
+	return strings.Join(parts, " ")
+}
+
+// baseImage describes an image's base and its approximate size, e.g.
+// "node:20 (~1100 MB)"
+func baseImage(c types.ContainerImage) string {
+	if c.BaseSizeMB > 0 {
+		return fmt.Sprintf("%s (~%d MB)", c.BaseImage, c.BaseSizeMB)
+	}
+	return c.BaseImage
+}
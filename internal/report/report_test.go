@@ -0,0 +1,91 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+	"github.com/fatih/color"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// loadFixture reads the analysis every golden file is rendered from
+func loadFixture(t *testing.T) *types.CodeAnalysis {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "analysis.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a types.CodeAnalysis
+	if err := json.Unmarshal(data, &a); err != nil {
+		t.Fatal(err)
+	}
+	return &a
+}
+
+func TestRenderersGolden(t *testing.T) {
+	ToolVersion = "test"
+	color.NoColor = true
+	t.Cleanup(func() { ToolVersion = "dev" })
+
+	golden := map[string]string{
+		Dashboard:  "analysis.dashboard.golden",
+		"markdown": "analysis.md.golden",
+		"html":     "analysis.html.golden",
+		"sarif":    "analysis.sarif.golden",
+		"csv":      "analysis.csv.golden",
+	}
+	for format, file := range golden {
+		t.Run(format, func(t *testing.T) {
+			render, ok := Get(format)
+			if !ok {
+				t.Fatalf("no %s renderer", format)
+			}
+			var buf bytes.Buffer
+			if err := render(&buf, loadFixture(t)); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", file)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s output differs from %s (run go test -update after checking the change):\n%s", format, path, buf.String())
+			}
+		})
+	}
+}
+
+func TestRenderersEmptyAnalysis(t *testing.T) {
+	for _, name := range append(Names(), Dashboard) {
+		render, _ := Get(name)
+		if err := render(&bytes.Buffer{}, &types.CodeAnalysis{}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestNamesLeavesOutDashboard(t *testing.T) {
+	names := Names()
+	want := []string{"csv", "html", "json", "markdown", "sarif"}
+	if len(names) != len(want) {
+		t.Fatalf("Names = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Names = %v, want %v", names, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	sarifSrcRoot = "%SRCROOT%"
+)
+
+// SARIF 2.1.0 objects, limited to the properties CloudPork fills in
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string                 `json:"id"`
+	Name             string                 `json:"name"`
+	ShortDescription sarifMessage           `json:"shortDescription"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifLevel maps a CloudPork severity to a SARIF result level
+func sarifLevel(severity string) string {
+	switch severity {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity is the CVSS-like score code-scanning UIs use for ranking
+func securitySeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return "9.0"
+	case "high":
+		return "7.0"
+	case "medium":
+		return "5.0"
+	default:
+		return "2.0"
+	}
+}
+
+// ruleID builds a stable rule ID like "cloudpork/bottleneck/database"
+func ruleID(kind, name string) string {
+	slug := strings.ToLower(strings.TrimSpace(name))
+	slug = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, slug)
+	if slug == "" {
+		slug = "other"
+	}
+	return "cloudpork/" + kind + "/" + slug
+}
+
+// sarifLocations points at file:line relative to the project root
+func sarifLocations(root, file string, line int) []sarifLocation {
+	if file == "" {
+		return nil
+	}
+	if filepath.IsAbs(file) {
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(file), URIBaseID: sarifSrcRoot},
+	}}
+	if line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+	}
+	return []sarifLocation{loc}
+}
+
+func renderSARIF(w io.Writer, a *types.CodeAnalysis) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "CloudPork",
+			Version:        ToolVersion,
+			InformationURI: "https://cloudpork.com",
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+	addRule := func(id, name, description string, properties map[string]interface{}) {
+		if rules[id] {
+			return
+		}
+		rules[id] = true
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               id,
+			Name:             name,
+			ShortDescription: sarifMessage{Text: description},
+			Properties:       properties,
+		})
+	}
+
+	for _, b := range a.ScalingBottlenecks {
+		id := ruleID("bottleneck", b.Type)
+		addRule(id, "ScalingBottleneck", fmt.Sprintf("Scaling bottleneck (%s)", b.Type),
+			map[string]interface{}{"tags": []string{"performance", "scalability"}})
+
+		sentences := []string{b.Description}
+		if b.Impact != "" {
+			sentences = append(sentences, b.Impact)
+		}
+		if b.BindingAtUsers > 0 {
+			sentences = append(sentences, fmt.Sprintf("Binding at %s users", types.FormatUsers(b.BindingAtUsers)))
+		}
+		text := strings.TrimSuffix(strings.Join(sentences, ". "), ".") + "."
+		result := sarifResult{
+			RuleID:    id,
+			Level:     sarifLevel(b.Severity),
+			Message:   sarifMessage{Text: text},
+			Locations: sarifLocations(a.Directory, b.File, b.Line),
+		}
+		if b.Endpoint != "" {
+			result.Properties = map[string]interface{}{"endpoint": b.Endpoint}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	for _, r := range a.Recommendations {
+		id := ruleID("recommendation", r.Type)
+		addRule(id, "CostRecommendation", fmt.Sprintf("Cost recommendation (%s)", r.Type),
+			map[string]interface{}{"tags": []string{"cost"}})
+
+		text := r.Description + "."
+		if r.Suggested != "" {
+			text += " Suggested: " + r.Suggested + "."
+		}
+		if r.MonthlySavings > 0 {
+			text += fmt.Sprintf(" Saves about %s a month.", formatUSD(r.MonthlySavings))
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    id,
+			Level:     "note",
+			Message:   sarifMessage{Text: text},
+			Locations: sarifLocations(a.Directory, r.File, r.Line),
+		})
+	}
+
+	for _, s := range a.SecurityIssues {
+		id := ruleID("security", s.Type)
+		addRule(id, "SecurityIssue", fmt.Sprintf("Security issue (%s)", s.Type),
+			map[string]interface{}{"tags": []string{"security"}, "security-severity": securitySeverity(s.Severity)})
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    id,
+			Level:     sarifLevel(s.Severity),
+			Message:   sarifMessage{Text: s.Description},
+			Locations: sarifLocations(a.Directory, s.File, s.Line),
+		})
+	}
+
+	if a.Directory != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			sarifSrcRoot: {URI: "file://" + filepath.ToSlash(strings.TrimSuffix(a.Directory, string(filepath.Separator))) + "/"},
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
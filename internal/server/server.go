@@ -0,0 +1,241 @@
+// Package server implements the endpoints of the CloudPork API the agent
+// uses, backed by a local Store, for on-prem installs and end-to-end tests.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/api"
+	"github.com/Cloudpork/cloudpork-agent/internal/history"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// maxUploadBytes bounds the size of an uploaded analysis
+const maxUploadBytes = 32 << 20
+
+// Server serves the API from a store
+type Server struct {
+	store *Store
+	mux   *http.ServeMux
+
+	// Log, if set, receives one line per request
+	Log io.Writer
+}
+
+// New returns a server backed by store
+func New(store *Store) *Server {
+	s := &Server{store: store, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/v1/analysis", s.handleAnalysis)
+	s.mux.HandleFunc("/v1/subscription", s.handleSubscription)
+	s.mux.HandleFunc("/v1/auth/validate", s.handleValidate)
+	s.mux.HandleFunc("/v1/auth/trial", s.handleTrial)
+	s.mux.HandleFunc("/v1/projects/", s.handleProject)
+	return s
+}
+
+// ServeHTTP routes a request and logs it
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(rec, r)
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "%s %s %s %d %s\n", start.Format(time.RFC3339), r.Method, r.URL.Path, rec.status,
+			time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// handleAnalysis stores an uploaded analysis: POST /v1/analysis
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	account, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var analysis types.CodeAnalysis
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUploadBytes)).Decode(&analysis); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid analysis: %v", err))
+		return
+	}
+	if err := history.ValidateProjectID(analysis.ProjectID); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if analysis.Timestamp.IsZero() {
+		writeError(w, http.StatusUnprocessableEntity, "analysis has no timestamp")
+		return
+	}
+
+	id, created, err := s.store.SaveAnalysis(account.APIKey, r.Header.Get("Idempotency-Key"), &analysis)
+	switch {
+	case errors.Is(err, errQuota) || errors.Is(err, errTrialExpired):
+		writeError(w, http.StatusPaymentRequired, err.Error())
+	case errors.Is(err, errNotOwner):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case created:
+		writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"id": id})
+	}
+}
+
+// handleSubscription reports the caller's plan: GET /v1/subscription
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	account, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	info := types.SubscriptionInfo{
+		Tier:          account.Tier,
+		Status:        "active",
+		AnalysesUsed:  account.AnalysesUsed,
+		AnalysesLimit: account.AnalysesLimit,
+		TrialEndsAt:   account.TrialEndsAt,
+		IsTrialing:    account.Tier == types.TierTrial,
+	}
+	if account.TrialEndsAt != nil {
+		left := time.Until(*account.TrialEndsAt)
+		if left <= 0 {
+			info.Status = "expired"
+		} else {
+			info.DaysRemaining = int(math.Ceil(left.Hours() / 24))
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleValidate checks the caller's API key: GET /v1/auth/validate
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	if _, ok := s.authenticate(w, r); ok {
+		writeJSON(w, http.StatusOK, map[string]bool{"valid": true})
+	}
+}
+
+// handleTrial opens a trial account: POST /v1/auth/trial
+func (s *Server) handleTrial(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var signup api.TrialSignup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&signup); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid signup: %v", err))
+		return
+	}
+	if !strings.Contains(signup.Email, "@") {
+		writeError(w, http.StatusUnprocessableEntity, "a valid email is required")
+		return
+	}
+
+	account, err := s.store.CreateTrial(signup.Email, signup.Name, signup.Company)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, api.TrialInfo{
+		APIKey:            account.APIKey,
+		ProjectID:         account.Projects[0],
+		TrialEndsAt:       *account.TrialEndsAt,
+		AnalysesRemaining: account.AnalysesLimit,
+	})
+}
+
+// handleProject describes one of the caller's projects: GET /v1/projects/{id}
+func (s *Server) handleProject(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	account, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	projectID := strings.TrimPrefix(r.URL.Path, "/v1/projects/")
+	if projectID == "" || strings.Contains(projectID, "/") || !account.owns(projectID) {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+
+	entries, err := s.store.Analyses(projectID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	info := api.ProjectInfo{
+		ID:            projectID,
+		Name:          projectID,
+		CreatedAt:     account.CreatedAt,
+		UpdatedAt:     account.CreatedAt,
+		AnalysisCount: len(entries),
+	}
+	if len(entries) > 0 {
+		newest := entries[0].Analysis.Timestamp
+		info.CreatedAt = entries[len(entries)-1].Analysis.Timestamp
+		info.UpdatedAt = newest
+		info.LastAnalysis = &newest
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// authenticate returns the account of the request's bearer token, or answers
+// 401 and returns false
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (Account, bool) {
+	apiKey, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if found && apiKey != "" {
+		if account, ok := s.store.Account(apiKey); ok {
+			return account, true
+		}
+	}
+	writeError(w, http.StatusUnauthorized, "invalid API key")
+	return Account{}, false
+}
+
+// allowMethod answers 405 unless the request uses method
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("use %s", method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError answers with the {"error": ...} body the API client reads
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// statusRecorder remembers the status code written for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,308 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/api"
+	"github.com/Cloudpork/cloudpork-agent/internal/server"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+const (
+	keyA = "cp_test_a"
+	keyB = "cp_test_b"
+)
+
+// newServer starts a server with two unlimited keys. wrap, if set, sits in
+// front of it to inject failures.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) (*server.Store, *httptest.Server) {
+	t.Helper()
+	store, err := server.OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{keyA, keyB} {
+		if err := store.EnsureKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var handler http.Handler = server.New(store)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return store, ts
+}
+
+func newClient(t *testing.T, ts *httptest.Server, apiKey string) *api.Client {
+	t.Helper()
+	t.Setenv("CLOUDPORK_API_KEY", apiKey)
+	client, err := api.New(api.Config{BaseURL: ts.URL, Version: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMaxAttempts(3)
+	return client
+}
+
+func newAnalysis(projectID string) *types.CodeAnalysis {
+	return &types.CodeAnalysis{ProjectID: projectID, Timestamp: time.Now().UTC()}
+}
+
+// errorKind returns the kind of an *api.Error, failing the test for other errors
+func errorKind(t *testing.T, err error) api.ErrorKind {
+	t.Helper()
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v (%T), want an *api.Error", err, err)
+	}
+	return apiErr.Kind
+}
+
+func TestUploadIsIdempotent(t *testing.T) {
+	store, ts := newServer(t, nil)
+	client := newClient(t, ts, keyA)
+	ctx := context.Background()
+
+	analysis := newAnalysis("proj_shop")
+	for i := 0; i < 2; i++ {
+		if err := client.SendAnalysis(ctx, analysis); err != nil {
+			t.Fatalf("upload %d: %v", i+1, err)
+		}
+	}
+
+	account, _ := store.Account(keyA)
+	if account.AnalysesUsed != 1 {
+		t.Errorf("AnalysesUsed = %d, want 1", account.AnalysesUsed)
+	}
+	info, err := client.GetProjectInfo(ctx, "proj_shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AnalysisCount != 1 {
+		t.Errorf("AnalysisCount = %d, want 1", info.AnalysisCount)
+	}
+}
+
+// A response lost after the server stored the analysis is retried with the
+// same idempotency key, so the analysis is stored and counted once
+func TestRetryAfterLostResponse(t *testing.T) {
+	var requests atomic.Int32
+	var keys []string
+	store, ts := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/analysis" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if requests.Add(1) == 1 {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				http.Error(w, "upstream went away", http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	client := newClient(t, ts, keyA)
+	var retries int
+	client.OnRetry = func(attempt, maxAttempts int, wait time.Duration, err error) {
+		retries++
+		if kind := errorKind(t, err); kind != api.ErrTransient {
+			t.Errorf("retried a %s error", kind)
+		}
+	}
+
+	analysis := newAnalysis("proj_shop")
+	if err := client.SendAnalysis(context.Background(), analysis); err != nil {
+		t.Fatal(err)
+	}
+
+	if retries != 1 || requests.Load() != 2 {
+		t.Errorf("got %d retries and %d requests, want 1 and 2", retries, requests.Load())
+	}
+	if keys[0] == "" || keys[0] != keys[1] || keys[0] != api.IdempotencyKey(analysis) {
+		t.Errorf("idempotency keys = %q, want two copies of %q", keys, api.IdempotencyKey(analysis))
+	}
+	if account, _ := store.Account(keyA); account.AnalysesUsed != 1 {
+		t.Errorf("AnalysesUsed = %d, want 1", account.AnalysesUsed)
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	var requests atomic.Int32
+	_, ts := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error": "slow down"}`, http.StatusTooManyRequests)
+		})
+	})
+	client := newClient(t, ts, keyA)
+	client.SetMaxAttempts(2)
+
+	err := client.SendAnalysis(context.Background(), newAnalysis("proj_shop"))
+	if kind := errorKind(t, err); kind != api.ErrTransient {
+		t.Errorf("kind = %s, want transient", kind)
+	}
+	var apiErr *api.Error
+	errors.As(err, &apiErr)
+	if apiErr.Attempts != 2 || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Message != "slow down" {
+		t.Errorf("got %+v, want 2 attempts at 429 with the server's message", apiErr)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("got %d requests, want 2", requests.Load())
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	_, ts := newServer(t, nil)
+	ctx := context.Background()
+
+	t.Run("invalid key", func(t *testing.T) {
+		client := newClient(t, ts, "cp_unknown")
+		if kind := errorKind(t, client.SendAnalysis(ctx, newAnalysis("proj_x"))); kind != api.ErrAuth {
+			t.Errorf("kind = %s, want auth", kind)
+		}
+		if kind := errorKind(t, client.ValidateAPIKey(ctx, "cp_unknown")); kind != api.ErrAuth {
+			t.Errorf("kind = %s, want auth", kind)
+		}
+	})
+
+	t.Run("malformed analysis", func(t *testing.T) {
+		client := newClient(t, ts, keyA)
+		if kind := errorKind(t, client.SendAnalysis(ctx, newAnalysis("../escape"))); kind != api.ErrValidation {
+			t.Errorf("kind = %s, want validation", kind)
+		}
+	})
+
+	t.Run("trial quota", func(t *testing.T) {
+		trial, err := newClient(t, ts, "").CreateTrial(ctx, api.TrialSignup{Email: "dev@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := newClient(t, ts, trial.APIKey)
+		if err := client.SendAnalysis(ctx, newAnalysis(trial.ProjectID)); err != nil {
+			t.Fatal(err)
+		}
+		later := newAnalysis(trial.ProjectID)
+		later.Timestamp = later.Timestamp.Add(time.Minute)
+		if kind := errorKind(t, client.SendAnalysis(ctx, later)); kind != api.ErrQuota {
+			t.Errorf("kind = %s, want quota", kind)
+		}
+
+		sub, err := client.GetSubscription(ctx, trial.APIKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sub.IsTrialing || sub.AnalysesUsed != 1 || sub.AnalysesLimit != 1 {
+			t.Errorf("subscription = %+v, want a used-up trial", sub)
+		}
+	})
+}
+
+func TestProjectsBelongToOneAccount(t *testing.T) {
+	store, ts := newServer(t, nil)
+	ctx := context.Background()
+
+	owner := newClient(t, ts, keyA)
+	if err := owner.SendAnalysis(ctx, newAnalysis("proj_private")); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newClient(t, ts, keyB)
+	err := other.SendAnalysis(ctx, newAnalysis("proj_private"))
+	if kind := errorKind(t, err); kind != api.ErrValidation {
+		t.Errorf("kind = %s, want validation", kind)
+	}
+	var apiErr *api.Error
+	if errors.As(err, &apiErr); apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("status = %d, want 409", apiErr.StatusCode)
+	}
+	if account, _ := store.Account(keyB); account.AnalysesUsed != 0 || len(account.Projects) != 0 {
+		t.Errorf("other account = %+v, want no uploads or projects", account)
+	}
+
+	if _, err := other.GetProjectInfo(ctx, "proj_private"); err == nil {
+		t.Error("another account can read the project")
+	} else if apiErr, ok := err.(*api.Error); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, want 404", err)
+	}
+}
+
+func TestStoreReopens(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trial, err := store.CreateTrial("dev@example.com", "Dev", "Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.SaveAnalysis(trial.APIKey, "key-1", newAnalysis(trial.Projects[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := server.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, ok := reopened.Account(trial.APIKey)
+	if !ok || account.AnalysesUsed != 1 {
+		t.Fatalf("reopened account = %+v, %v", account, ok)
+	}
+	if _, created, err := reopened.SaveAnalysis(trial.APIKey, "key-1", newAnalysis(trial.Projects[0])); err != nil || created {
+		t.Errorf("replayed upload: created %v, err %v; want a deduplicated upload", created, err)
+	}
+}
+
+func TestFailedUploadIsUndone(t *testing.T) {
+	dir := t.TempDir()
+	store, err := server.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trial, err := store.CreateTrial("dev@example.com", "Dev", "Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory where the accounts file is staged makes every save fail
+	tmp := filepath.Join(dir, "accounts.json.tmp")
+	if err := os.Mkdir(tmp, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.SaveAnalysis(trial.APIKey, "key-1", newAnalysis("proj_new")); err == nil {
+		t.Fatal("SaveAnalysis succeeded without saving the accounts")
+	}
+	account, _ := store.Account(trial.APIKey)
+	if account.AnalysesUsed != 0 || !reflect.DeepEqual(account.Projects, trial.Projects) {
+		t.Errorf("account after a failed upload = %+v, want it unchanged", account)
+	}
+	if entries, _ := store.Analyses("proj_new"); len(entries) != 0 {
+		t.Errorf("failed upload left %d stored analyses", len(entries))
+	}
+
+	// The retry is not mistaken for a duplicate and counts once
+	if err := os.Remove(tmp); err != nil {
+		t.Fatal(err)
+	}
+	if _, created, err := store.SaveAnalysis(trial.APIKey, "key-1", newAnalysis("proj_new")); err != nil || !created {
+		t.Fatalf("retried upload: created %v, err %v; want it stored", created, err)
+	}
+	if account, _ := store.Account(trial.APIKey); account.AnalysesUsed != 1 || len(account.Projects) != 2 {
+		t.Errorf("account after the retry = %+v, want one upload and the new project", account)
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Cloudpork/cloudpork-agent/internal/history"
+	"github.com/Cloudpork/cloudpork-agent/internal/types"
+)
+
+// Trial accounts get one analysis over seven days, like the hosted service
+const (
+	trialAnalyses = 1
+	trialDays     = 7
+	unlimited     = -1
+)
+
+// Account is an API key and the plan it is on
+type Account struct {
+	APIKey        string     `json:"api_key"`
+	Email         string     `json:"email,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Company       string     `json:"company,omitempty"`
+	Tier          string     `json:"tier"`
+	AnalysesUsed  int        `json:"analyses_used"`
+	AnalysesLimit int        `json:"analyses_limit"` // -1 for unlimited
+	TrialEndsAt   *time.Time `json:"trial_ends_at,omitempty"`
+	Projects      []string   `json:"projects,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// owns reports whether a project's analyses belong to the account
+func (a *Account) owns(projectID string) bool {
+	for _, p := range a.Projects {
+		if p == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// state is what accounts.json holds
+type state struct {
+	Accounts []*Account `json:"accounts"`
+	// Uploads maps idempotency keys to the history ID of the analysis they
+	// stored, so a retried upload is stored and counted once
+	Uploads map[string]string `json:"uploads"`
+}
+
+// Store keeps accounts in <dir>/accounts.json and analyses in a history store
+// under <dir>/analyses/
+type Store struct {
+	dir      string
+	mu       sync.Mutex
+	state    state
+	analyses *history.Store
+}
+
+// OpenStore loads the store at dir, creating it if needed
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+	analyses, err := history.Open(filepath.Join(dir, "analyses"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{dir: dir, analyses: analyses, state: state{Uploads: make(map[string]string)}}
+	data, err := os.ReadFile(s.accountsPath())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("corrupt accounts file %s: %v", s.accountsPath(), err)
+	}
+	if s.state.Uploads == nil {
+		s.state.Uploads = make(map[string]string)
+	}
+	return s, nil
+}
+
+// Dir returns the store's data directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// EnsureKey registers an API key on the enterprise plan with unlimited
+// analyses, unless it exists already
+func (s *Store) EnsureKey(apiKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.account(apiKey) != nil {
+		return nil
+	}
+	s.state.Accounts = append(s.state.Accounts, &Account{
+		APIKey:        apiKey,
+		Tier:          types.TierEnterprise,
+		AnalysesLimit: unlimited,
+		CreatedAt:     time.Now().UTC(),
+	})
+	return s.save()
+}
+
+// Account returns a copy of the account of an API key
+func (s *Store) Account(apiKey string) (Account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a := s.account(apiKey); a != nil {
+		return *a, true
+	}
+	return Account{}, false
+}
+
+// CreateTrial opens a trial account and returns it with its first project ID
+func (s *Store) CreateTrial(email, name, company string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apiKey, err := randomHex(16)
+	if err != nil {
+		return Account{}, err
+	}
+	projectID, err := randomHex(8)
+	if err != nil {
+		return Account{}, err
+	}
+
+	now := time.Now().UTC()
+	ends := now.AddDate(0, 0, trialDays)
+	a := &Account{
+		APIKey:        "cp_" + apiKey,
+		Email:         email,
+		Name:          name,
+		Company:       company,
+		Tier:          types.TierTrial,
+		AnalysesLimit: trialAnalyses,
+		TrialEndsAt:   &ends,
+		Projects:      []string{"proj_" + projectID},
+		CreatedAt:     now,
+	}
+	s.state.Accounts = append(s.state.Accounts, a)
+	if err := s.save(); err != nil {
+		s.state.Accounts = s.state.Accounts[:len(s.state.Accounts)-1]
+		return Account{}, err
+	}
+	return *a, nil
+}
+
+// Uploads are refused once an account has no analyses left or its trial
+// ended, and for projects of other accounts
+var (
+	errQuota        = fmt.Errorf("analysis limit reached - upgrade to continue")
+	errTrialExpired = fmt.Errorf("trial expired - upgrade to continue")
+	errNotOwner     = fmt.Errorf("project belongs to another account")
+)
+
+// SaveAnalysis stores an upload and counts it against the account. The first
+// upload of a project makes it the account's; other accounts cannot upload to
+// it. An upload with an idempotency key seen before is not stored again;
+// created is false and id is the earlier analysis.
+func (s *Store) SaveAnalysis(apiKey, idempotencyKey string, analysis *types.CodeAnalysis) (id string, created bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.account(apiKey)
+	if a == nil {
+		return "", false, fmt.Errorf("unknown API key")
+	}
+	if idempotencyKey != "" {
+		if id, ok := s.state.Uploads[apiKey+"/"+idempotencyKey]; ok {
+			return id, false, nil
+		}
+	}
+	if owner := s.owner(analysis.ProjectID); owner != nil && owner != a {
+		return "", false, errNotOwner
+	}
+	if a.AnalysesLimit != unlimited && a.AnalysesUsed >= a.AnalysesLimit {
+		return "", false, errQuota
+	}
+	if a.TrialEndsAt != nil && time.Now().After(*a.TrialEndsAt) {
+		return "", false, errTrialExpired
+	}
+
+	id, err = s.analyses.Save(analysis)
+	if err != nil {
+		return "", false, err
+	}
+	claimed := !a.owns(analysis.ProjectID)
+	a.AnalysesUsed++
+	if claimed {
+		a.Projects = append(a.Projects, analysis.ProjectID)
+	}
+	if idempotencyKey != "" {
+		s.state.Uploads[apiKey+"/"+idempotencyKey] = id
+	}
+	if err := s.save(); err != nil {
+		// Undo the upload so a retry is neither refused nor counted twice
+		a.AnalysesUsed--
+		if claimed {
+			a.Projects = a.Projects[:len(a.Projects)-1]
+		}
+		delete(s.state.Uploads, apiKey+"/"+idempotencyKey)
+		s.analyses.Remove(id)
+		return "", false, err
+	}
+	return id, true, nil
+}
+
+// Analyses returns a project's stored analyses, newest first
+func (s *Store) Analyses(projectID string) ([]history.Entry, error) {
+	return s.analyses.List(projectID)
+}
+
+func (s *Store) account(apiKey string) *Account {
+	for _, a := range s.state.Accounts {
+		if a.APIKey == apiKey {
+			return a
+		}
+	}
+	return nil
+}
+
+// owner returns the account a project belongs to, or nil
+func (s *Store) owner(projectID string) *Account {
+	for _, a := range s.state.Accounts {
+		if a.owns(projectID) {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Store) accountsPath() string {
+	return filepath.Join(s.dir, "accounts.json")
+}
+
+// save writes accounts.json; callers hold mu
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal accounts: %v", err)
+	}
+
+	// Write then rename so a crash never leaves a partial file behind
+	tmp := s.accountsPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write accounts: %v", err)
+	}
+	if err := os.Rename(tmp, s.accountsPath()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write accounts: %v", err)
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -3,10 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
-
-	"github.com/fatih/color"
 )
 
 // Tier constants for subscription tiers
@@ -33,21 +30,59 @@ type CodeAnalysis struct {
 	ProjectID        string          `json:"project_id"`
 	Timestamp        time.Time       `json:"timestamp"`
 	Directory        string          `json:"directory"`
+	Git              *GitInfo        `json:"git,omitempty"`
+	Incremental      *IncrementalInfo `json:"incremental,omitempty"`
+	Workspace        *WorkspaceInfo  `json:"workspace,omitempty"`
 	Language         string          `json:"language"`
 	Framework        string          `json:"framework"`
 	Dependencies     []string        `json:"dependencies"`
 	DatabaseCalls    int             `json:"database_calls"`
 	ApiEndpoints     int             `json:"api_endpoints"`
+	Endpoints        []Endpoint      `json:"endpoints"`
+	QuerySites       []QuerySite     `json:"query_sites"`
 	StatelessFuncs   int             `json:"stateless_functions"`
 	BackgroundJobs   []string        `json:"background_jobs"`
 	CacheUsage       []string        `json:"cache_usage"`
+	Datastores       []string        `json:"datastores,omitempty"`
 	FileUploads      bool            `json:"file_uploads"`
 	ComplexityScore  int             `json:"complexity_score"`
 	ScalingBottlenecks []Bottleneck  `json:"scaling_bottlenecks"`
+	Recommendations  []Recommendation `json:"recommendations,omitempty"`
+	Infrastructure   []InfraResource `json:"infrastructure,omitempty"`
+	Containers       []ContainerImage `json:"containers,omitempty"`
+	Serverless       *ServerlessInfo `json:"serverless,omitempty"`
 	ResourceUsage    ResourceMetrics `json:"resource_usage"`
 	EstimatedUsers   int             `json:"estimated_users"`
 	SecurityIssues   []SecurityIssue `json:"security_issues"`
+	CostEstimates    []CostEstimate  `json:"cost_estimates"`
+	Projections      []ScaleProjection `json:"projections"`
+	ScalingLimits    []ScalingLimit  `json:"scaling_limits"`
 	Performance      PerformanceMetrics `json:"performance"`
+	SchemaVersion    string          `json:"schema_version"`
+	LowConfidence    bool            `json:"low_confidence"`
+	HeuristicPasses  []string        `json:"heuristic_passes,omitempty"` // Passes whose response never matched its schema
+	StaticFacts      *StaticFacts    `json:"static_facts,omitempty"`
+	Confidence       float64         `json:"confidence"`                 // Mean of the per-field confidences
+	Provenance       map[string]FieldProvenance `json:"provenance"`     // Keyed by JSON field path
+}
+
+// Endpoint is an API route declared in the source
+type Endpoint struct {
+	Method    string `json:"method"`    // "GET", "POST", ... or "ANY"
+	Path      string `json:"path"`
+	File      string `json:"file"`      // Path relative to the project root
+	Line      int    `json:"line"`
+	Framework string `json:"framework"`
+}
+
+// QuerySite is an ORM or database driver call found in the source
+type QuerySite struct {
+	ORM      string `json:"orm"`      // "GORM", "SQLAlchemy", "ActiveRecord", ...
+	Call     string `json:"call"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	InLoop   bool   `json:"in_loop"`  // Executed once per item: an N+1 candidate
+	Endpoint string `json:"endpoint,omitempty"`
 }
 
 // ResourceMetrics represents estimated resource requirements
@@ -65,6 +100,10 @@ type Bottleneck struct {
 	Description string `json:"description"`
 	Severity    string `json:"severity"`    // "low", "medium", "high", "critical"
 	Impact      string `json:"impact"`      // Description of impact
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Endpoint    string `json:"endpoint,omitempty"` // "METHOD /path" of the enclosing route
+	BindingAtUsers int `json:"binding_at_users,omitempty"` // First projected tier at which it limits scaling
 }
 
 // SecurityIssue represents a security concern
@@ -85,59 +124,6 @@ type PerformanceMetrics struct {
 	HasLargePayloads bool `json:"has_large_payloads"`
 }
 
-// PrintSummary prints a formatted summary of the analysis
-func (ca *CodeAnalysis) PrintSummary() {
-	fmt.Printf("%s\n", color.New(color.FgCyan, color.Bold).Sprint("📊 Analysis Summary"))
-	fmt.Printf("%s\n\n", color.New(color.Faint).Sprint(strings.Repeat("=", 50)))
-	
-	// Basic Info
-	fmt.Printf("🏗️  %s: %s\n", color.New(color.Bold).Sprint("Framework"), ca.Framework)
-	fmt.Printf("💾  %s: %s\n", color.New(color.Bold).Sprint("Language"), ca.Language)
-	fmt.Printf("📦  %s: %d\n", color.New(color.Bold).Sprint("Dependencies"), len(ca.Dependencies))
-	fmt.Printf("🔌  %s: %d\n", color.New(color.Bold).Sprint("API Endpoints"), ca.ApiEndpoints)
-	fmt.Printf("⚡  %s: %d\n", color.New(color.Bold).Sprint("Background Jobs"), len(ca.BackgroundJobs))
-	fmt.Println()
-	
-	// Resource Usage
-	fmt.Printf("%s\n", color.New(color.FgGreen, color.Bold).Sprint("💻 Resource Requirements"))
-	fmt.Printf("  Memory: %d MB\n", ca.ResourceUsage.MemoryMB)
-	fmt.Printf("  CPU: %.1f cores\n", ca.ResourceUsage.CPUCores)
-	fmt.Printf("  DB Connections: %d\n", ca.ResourceUsage.DatabaseConns)
-	fmt.Printf("  Network: %d Mbps\n", ca.ResourceUsage.NetworkMbps)
-	fmt.Println()
-	
-	// Bottlenecks
-	if len(ca.ScalingBottlenecks) > 0 {
-		fmt.Printf("%s\n", color.New(color.FgYellow, color.Bold).Sprint("⚠️  Scaling Bottlenecks"))
-		for _, bottleneck := range ca.ScalingBottlenecks {
-			severity := getSeverityColor(bottleneck.Severity)
-			fmt.Printf("  %s %s: %s\n", 
-				severity.Sprint("●"), 
-				color.New(color.Bold).Sprint(bottleneck.Type), 
-				bottleneck.Description)
-		}
-		fmt.Println()
-	}
-	
-	// Performance Issues
-	if ca.Performance.HasNPlusOneQuery || ca.Performance.HasLargePayloads {
-		fmt.Printf("%s\n", color.New(color.FgRed, color.Bold).Sprint("🐌 Performance Issues"))
-		if ca.Performance.HasNPlusOneQuery {
-			fmt.Println("  • N+1 query patterns detected")
-		}
-		if ca.Performance.HasLargePayloads {
-			fmt.Println("  • Large payload responses found")
-		}
-		fmt.Println()
-	}
-	
-	// Complexity Score
-	complexityColor := getComplexityColor(ca.ComplexityScore)
-	fmt.Printf("🎯 %s: %s\n", 
-		color.New(color.Bold).Sprint("Complexity Score"), 
-		complexityColor.Sprintf("%d/100", ca.ComplexityScore))
-}
-
 // PrintJSON prints the analysis as JSON
 func (ca *CodeAnalysis) PrintJSON() error {
 	jsonData, err := json.MarshalIndent(ca, "", "  ")
@@ -147,31 +133,3 @@ func (ca *CodeAnalysis) PrintJSON() error {
 	fmt.Println(string(jsonData))
 	return nil
 }
-
-func getSeverityColor(severity string) *color.Color {
-	switch severity {
-	case "critical":
-		return color.New(color.FgRed, color.Bold)
-	case "high":
-		return color.New(color.FgRed)
-	case "medium":
-		return color.New(color.FgYellow)
-	case "low":
-		return color.New(color.FgGreen)
-	default:
-		return color.New(color.FgWhite)
-	}
-}
-
-func getComplexityColor(score int) *color.Color {
-	switch {
-	case score >= 80:
-		return color.New(color.FgRed, color.Bold)
-	case score >= 60:
-		return color.New(color.FgYellow, color.Bold)
-	case score >= 40:
-		return color.New(color.FgCyan, color.Bold)
-	default:
-		return color.New(color.FgGreen, color.Bold)
-	}
-}
\ No newline at end of file
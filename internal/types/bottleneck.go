@@ -0,0 +1,55 @@
+package types
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Prefixes of the descriptions of bottlenecks found by the static scan, one
+// per source it reads. A new run finds these again, so an incremental run
+// never carries them over from its baseline.
+const (
+	NPlusOnePrefix   = "N+1 candidate:" // Query sites inside a loop
+	TerraformPrefix  = "Terraform:"
+	KubernetesPrefix = "Kubernetes:" // Kubernetes manifests and Helm charts
+	ComposePrefix    = "Compose:"
+	DockerPrefix     = "Docker:"
+	ServerlessPrefix = "Serverless:" // Serverless, SAM, CloudFormation and CDK
+)
+
+// StaticBottleneckPrefixes lists every prefix of a static bottleneck
+var StaticBottleneckPrefixes = []string{
+	NPlusOnePrefix,
+	TerraformPrefix,
+	KubernetesPrefix,
+	ComposePrefix,
+	DockerPrefix,
+	ServerlessPrefix,
+}
+
+// StaticPrefix returns the prefix of a bottleneck found by the static scan,
+// or "" for one reported by a model
+func (b Bottleneck) StaticPrefix() string {
+	for _, prefix := range StaticBottleneckPrefixes {
+		if strings.HasPrefix(b.Description, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// Key identifies a bottleneck across runs by its type, severity, location and
+// what it describes. The description is reduced to its lower-case words, so
+// that a static finding whose measured numbers change is still the same one.
+// The line is left out, so that code moving within a file is not a new
+// finding. A bottleneck whose severity changes is a new finding.
+func (b Bottleneck) Key() string {
+	return strings.ToLower(strings.Join([]string{b.Type, b.Severity, b.File, b.Endpoint, descriptionWords(b.Description)}, "|"))
+}
+
+// descriptionWords keeps the words of a description, dropping numbers and
+// punctuation
+func descriptionWords(description string) string {
+	words := strings.FieldsFunc(description, func(r rune) bool { return !unicode.IsLetter(r) })
+	return strings.Join(words, " ")
+}
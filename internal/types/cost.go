@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CostEstimate is the projected monthly cost of the resource requirements on
+// one cloud provider, priced from the local pricing catalog
+type CostEstimate struct {
+	Provider       string   `json:"provider"`
+	Region         string   `json:"region"`
+	Currency       string   `json:"currency"`
+	Compute        CostItem `json:"compute"`
+	Database       CostItem `json:"database"`
+	Storage        CostItem `json:"storage"`
+	Network        CostItem `json:"network"`
+	MonthlyTotal   float64  `json:"monthly_total"`
+	CatalogVersion string   `json:"catalog_version"`
+}
+
+// CostItem is one line of a cost estimate
+type CostItem struct {
+	Description string  `json:"description,omitempty"`
+	Quantity    int     `json:"quantity,omitempty"`
+	Monthly     float64 `json:"monthly"`
+}
+
+// ScaleProjection is the resource requirement and cost at one user tier
+type ScaleProjection struct {
+	Users         int             `json:"users"`
+	Concurrent    int             `json:"concurrent_users"`
+	Resources     ResourceMetrics `json:"resources"`
+	CostEstimates []CostEstimate  `json:"cost_estimates"`
+	Binding       []string        `json:"binding_constraints,omitempty"` // Limits exceeded at this tier
+}
+
+// ScalingLimit is a single-node or single-database limit the app runs into
+type ScalingLimit struct {
+	Constraint     string `json:"constraint"`
+	Type           string `json:"type"` // Matches Bottleneck.Type
+	Limit          string `json:"limit"`
+	ThresholdUsers int    `json:"threshold_users"`            // Users at which the limit is reached
+	BindingAtUsers int    `json:"binding_at_users,omitempty"` // First projected tier past the threshold
+	Mitigation     string `json:"mitigation"`
+}
+
+// FormatUsers abbreviates a user count: 1000 -> "1K", 2500000 -> "2.5M"
+func FormatUsers(users int) string {
+	abbreviate := func(v float64, unit string) string {
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", v), ".0") + unit
+	}
+	switch {
+	case users >= 1000000000:
+		return abbreviate(float64(users)/1e9, "B")
+	case users >= 1000000:
+		return abbreviate(float64(users)/1e6, "M")
+	case users >= 1000:
+		return abbreviate(float64(users)/1e3, "K")
+	default:
+		return fmt.Sprintf("%d", users)
+	}
+}
@@ -0,0 +1,20 @@
+package types
+
+import "testing"
+
+func TestFormatUsers(t *testing.T) {
+	tests := map[int]string{
+		0:          "0",
+		999:        "999",
+		1000:       "1K",
+		2500:       "2.5K",
+		1000000:    "1M",
+		2500000:    "2.5M",
+		1000000000: "1B",
+	}
+	for users, want := range tests {
+		if got := FormatUsers(users); got != want {
+			t.Errorf("FormatUsers(%d) = %q, want %q", users, got, want)
+		}
+	}
+}
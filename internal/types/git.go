@@ -0,0 +1,28 @@
+package types
+
+// GitInfo identifies the revision an analysis was run against
+type GitInfo struct {
+	Commit string `json:"commit"`
+	Branch string `json:"branch,omitempty"` // Empty for a detached HEAD
+	Dirty  bool   `json:"dirty,omitempty"`  // Uncommitted changes to tracked files
+}
+
+// ShortCommit abbreviates the commit hash for display
+func (g *GitInfo) ShortCommit() string {
+	if g == nil {
+		return ""
+	}
+	if len(g.Commit) > 7 {
+		return g.Commit[:7]
+	}
+	return g.Commit
+}
+
+// IncrementalInfo records that only the files changed since a git ref were
+// re-analyzed and the remaining values were carried over from a baseline
+type IncrementalInfo struct {
+	Since          string   `json:"since"`
+	BaselineCommit string   `json:"baseline_commit,omitempty"`
+	ChangedFiles   []string `json:"changed_files"`
+	Dependents     []string `json:"dependents,omitempty"` // Unchanged files that import a changed one
+}
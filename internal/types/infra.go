@@ -0,0 +1,81 @@
+package types
+
+// InfraResource is a cloud resource declared in infrastructure as code
+type InfraResource struct {
+	Source       string  `json:"source"` // "terraform", "kubernetes", "helm" or "docker-compose"
+	Type         string  `json:"type"`   // e.g. "aws_instance"
+	Name         string  `json:"name"`
+	Role         string  `json:"role"`               // "compute", "database", "cache", "queue", "search", "storage", "autoscaling" or "job"
+	Provider     string  `json:"provider,omitempty"` // Pricing catalog ID: "aws", "gcp" or "azure"
+	Region       string  `json:"region,omitempty"`
+	InstanceType string  `json:"instance_type,omitempty"`
+	VCPUs        float64 `json:"vcpus,omitempty"`     // Per instance
+	MemoryGB     float64 `json:"memory_gb,omitempty"` // Per instance
+	Count        int     `json:"count,omitempty"`
+	MinSize      int     `json:"min_size,omitempty"`
+	MaxSize      int     `json:"max_size,omitempty"`
+	DesiredSize  int     `json:"desired_size,omitempty"`
+	StorageClass string  `json:"storage_class,omitempty"`
+	StorageGB    int     `json:"storage_gb,omitempty"`
+
+	// Kubernetes workloads: per-pod requests and limits, summed over containers
+	Image           string  `json:"image,omitempty"`
+	CPURequest      float64 `json:"cpu_request,omitempty"` // Cores
+	CPULimit        float64 `json:"cpu_limit,omitempty"`
+	MemoryRequestMB int     `json:"memory_request_mb,omitempty"`
+	MemoryLimitMB   int     `json:"memory_limit_mb,omitempty"`
+	Stateful        bool    `json:"stateful,omitempty"`     // Has persistent volumes
+	Autoscaled      bool    `json:"autoscaled,omitempty"`   // Targeted by an autoscaler
+	Schedule        string  `json:"schedule,omitempty"`     // Of a CronJob
+	Target          string  `json:"scale_target,omitempty"` // Of an autoscaler, "Kind/name"
+
+	// DependsOn names the services a compose service starts after
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	File string `json:"file"` // Path relative to the project root
+	Line int    `json:"line"`
+}
+
+// Address identifies the resource as its source does, e.g. "aws_instance.api"
+// or "Deployment/api"
+func (r InfraResource) Address() string {
+	if r.Source == "terraform" {
+		return r.Type + "." + r.Name
+	}
+	return r.Type + "/" + r.Name
+}
+
+// Instances is how many instances the resource runs, at least one
+func (r InfraResource) Instances() int {
+	for _, n := range []int{r.DesiredSize, r.MinSize, r.Count} {
+		if n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+// Recommendation is a change that lowers cost without limiting scale
+type Recommendation struct {
+	Type           string  `json:"type"` // "rightsize", "autoscaling", "storage", "requests", "cronjob" or "image"
+	Description    string  `json:"description"`
+	Resource       string  `json:"resource,omitempty"` // Address of the resource it applies to
+	Current        string  `json:"current,omitempty"`
+	Suggested      string  `json:"suggested,omitempty"`
+	MonthlySavings float64 `json:"monthly_savings,omitempty"`
+	File           string  `json:"file,omitempty"`
+	Line           int     `json:"line,omitempty"`
+}
+
+// ContainerImage is an image built by a Dockerfile
+type ContainerImage struct {
+	File            string   `json:"file"`                   // Path relative to the project root
+	Line            int      `json:"line"`                   // Of the final stage's FROM
+	BaseImage       string   `json:"base_image"`             // Of the final stage
+	BaseSizeMB      int      `json:"base_size_mb,omitempty"` // Approximate, for well-known bases
+	Stages          int      `json:"stages"`
+	Layers          int      `json:"layers"`                     // RUN, COPY and ADD instructions of the final stage
+	PackageManagers []string `json:"package_managers,omitempty"` // Used to install packages, e.g. "apt-get"
+	Packages        int      `json:"packages,omitempty"`         // Installed by name
+	ExposedPorts    []string `json:"exposed_ports,omitempty"`
+}
@@ -0,0 +1,94 @@
+package types
+
+import "sort"
+
+// Provenance sources, from most to least trustworthy
+const (
+	SourceStatic   = "static"    // Derived deterministically from the source tree
+	SourceLLMJSON  = "llm-json"  // Schema-validated model response
+	SourceLLMRegex = "llm-regex" // Scraped from free-text model output
+	SourceClamped  = "clamped"   // Model value forced into a sane range
+	SourceComputed = "computed"  // Formula over other recorded values
+	SourceDefault  = "default"   // Fallback constant or formula
+)
+
+// defaultConfidence is the confidence assigned to each source
+var defaultConfidence = map[string]float64{
+	SourceStatic:   0.95,
+	SourceLLMJSON:  0.7,
+	SourceLLMRegex: 0.35,
+	SourceClamped:  0.25,
+	SourceComputed: 0.5,
+	SourceDefault:  0.1,
+}
+
+// FieldProvenance records where a single CodeAnalysis value came from
+type FieldProvenance struct {
+	Source     string  `json:"source"`
+	Confidence float64 `json:"confidence"` // 0.0 - 1.0
+	Note       string  `json:"note,omitempty"`
+}
+
+// SetProvenance records the source of a field, keyed by its JSON path
+// (e.g. "resource_usage.memory_mb"), with the source's default confidence
+func (ca *CodeAnalysis) SetProvenance(field, source, note string) {
+	if ca.Provenance == nil {
+		ca.Provenance = make(map[string]FieldProvenance)
+	}
+	ca.Provenance[field] = FieldProvenance{
+		Source:     source,
+		Confidence: defaultConfidence[source],
+		Note:       note,
+	}
+}
+
+// SetComputedProvenance records a field computed by a formula over the given
+// input fields. It is as confident as its least confident recorded input, so
+// a value derived from sound inputs passes a gate those inputs pass. Without
+// recorded inputs it has the computed source's default confidence.
+func (ca *CodeAnalysis) SetComputedProvenance(field, note string, inputs ...string) {
+	ca.SetProvenance(field, SourceComputed, note)
+
+	confidence, found := 0.0, false
+	for _, input := range inputs {
+		if p, ok := ca.Provenance[input]; ok && (!found || p.Confidence < confidence) {
+			confidence, found = p.Confidence, true
+		}
+	}
+	if found {
+		p := ca.Provenance[field]
+		p.Confidence = confidence
+		ca.Provenance[field] = p
+	}
+}
+
+// FieldSource returns the provenance of a field, if one was recorded
+func (ca *CodeAnalysis) FieldSource(field string) (FieldProvenance, bool) {
+	p, ok := ca.Provenance[field]
+	return p, ok
+}
+
+// OverallConfidence returns the mean confidence over all recorded fields
+func (ca *CodeAnalysis) OverallConfidence() float64 {
+	if len(ca.Provenance) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, p := range ca.Provenance {
+		total += p.Confidence
+	}
+	return total / float64(len(ca.Provenance))
+}
+
+// FieldsBelow returns the fields whose confidence is below min, sorted
+func (ca *CodeAnalysis) FieldsBelow(min float64) []string {
+	var fields []string
+	for field, p := range ca.Provenance {
+		if p.Confidence < min {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSetProvenance(t *testing.T) {
+	var ca CodeAnalysis
+	ca.SetProvenance("language", SourceStatic, "most lines of code")
+	ca.SetProvenance("complexity_score", SourceLLMRegex, "")
+	ca.SetProvenance("complexity_score", SourceLLMJSON, "")
+	ca.SetProvenance("framework", "unknown-source", "")
+
+	want := map[string]FieldProvenance{
+		"language":         {Source: SourceStatic, Confidence: 0.95, Note: "most lines of code"},
+		"complexity_score": {Source: SourceLLMJSON, Confidence: 0.7},
+		"framework":        {Source: "unknown-source"},
+	}
+	if !reflect.DeepEqual(ca.Provenance, want) {
+		t.Errorf("Provenance = %+v\nwant %+v", ca.Provenance, want)
+	}
+	if p, ok := ca.FieldSource("language"); !ok || p.Source != SourceStatic {
+		t.Errorf("FieldSource(language) = %+v, %v", p, ok)
+	}
+	if _, ok := ca.FieldSource("estimated_users"); ok {
+		t.Error("FieldSource found a field that was never recorded")
+	}
+}
+
+func TestSetComputedProvenance(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources map[string]string // Input field to source
+		want    float64
+	}{
+		{"no recorded inputs", nil, 0.5},
+		{"least confident input", map[string]string{"complexity_score": SourceLLMJSON, "api_endpoints": SourceStatic}, 0.7},
+		{"sound inputs above the computed default", map[string]string{"complexity_score": SourceStatic, "api_endpoints": SourceStatic}, 0.95},
+		{"a fallback input", map[string]string{"complexity_score": SourceDefault, "api_endpoints": SourceStatic}, 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ca CodeAnalysis
+			for field, source := range tt.sources {
+				ca.SetProvenance(field, source, "")
+			}
+			ca.SetComputedProvenance("estimated_users", "formula", "complexity_score", "api_endpoints", "background_jobs")
+
+			p, _ := ca.FieldSource("estimated_users")
+			if p.Source != SourceComputed || p.Confidence != tt.want || p.Note != "formula" {
+				t.Errorf("estimated_users = %+v, want computed at %v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverallConfidence(t *testing.T) {
+	var ca CodeAnalysis
+	if got := ca.OverallConfidence(); got != 0 {
+		t.Errorf("OverallConfidence() without provenance = %v, want 0", got)
+	}
+
+	ca.SetProvenance("language", SourceStatic, "")
+	ca.SetProvenance("complexity_score", SourceLLMJSON, "")
+	ca.SetProvenance("framework", SourceDefault, "")
+	ca.SetComputedProvenance("estimated_users", "", "complexity_score")
+	if got, want := ca.OverallConfidence(), (0.95+0.7+0.1+0.7)/4; math.Abs(got-want) > 1e-9 {
+		t.Errorf("OverallConfidence() = %v, want %v", got, want)
+	}
+}
+
+func TestFieldsBelow(t *testing.T) {
+	var ca CodeAnalysis
+	ca.SetProvenance("language", SourceStatic, "")
+	ca.SetProvenance("complexity_score", SourceLLMJSON, "")
+	ca.SetProvenance("api_endpoints", SourceStatic, "")
+	ca.SetProvenance("resource_usage.memory_mb", SourceClamped, "")
+	ca.SetProvenance("framework", SourceDefault, "")
+	ca.SetComputedProvenance("estimated_users", "", "complexity_score", "api_endpoints")
+
+	tests := []struct {
+		min  float64
+		want []string
+	}{
+		{0, nil},
+		{0.1, nil},
+		{0.3, []string{"framework", "resource_usage.memory_mb"}},
+		{0.7, []string{"framework", "resource_usage.memory_mb"}},
+		{0.8, []string{"complexity_score", "estimated_users", "framework", "resource_usage.memory_mb"}},
+		{1, []string{"api_endpoints", "complexity_score", "estimated_users", "framework", "language", "resource_usage.memory_mb"}},
+	}
+	for _, tt := range tests {
+		if got := ca.FieldsBelow(tt.min); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FieldsBelow(%v) = %q, want %q", tt.min, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package types
+
+// ServerlessFunction is a function declared in a Serverless Framework, AWS
+// SAM or CloudFormation template
+type ServerlessFunction struct {
+	Source                 string   `json:"source"` // "serverless", "sam", "cloudformation" or "cdk"
+	Name                   string   `json:"name"`
+	Provider               string   `json:"provider"` // Pricing catalog ID
+	Handler                string   `json:"handler,omitempty"`
+	Runtime                string   `json:"runtime,omitempty"`
+	Architecture           string   `json:"architecture"` // "x86_64" or "arm64"
+	MemoryMB               int      `json:"memory_mb"`
+	TimeoutSeconds         int      `json:"timeout_seconds"`
+	Triggers               []string `json:"triggers,omitempty"` // e.g. "http GET /users", "schedule rate(5 minutes)", "sqs"
+	ProvisionedConcurrency int      `json:"provisioned_concurrency,omitempty"`
+	ScheduledPerMonth      int      `json:"scheduled_invocations_per_month,omitempty"` // From schedule triggers
+	CostPerInvocation      float64  `json:"cost_per_invocation"`                       // At the assumed duration
+	File                   string   `json:"file"`
+	Line                   int      `json:"line"`
+}
+
+// ServerlessInfo is the functions of a serverless project and their cost
+// at each request rate
+type ServerlessInfo struct {
+	Functions   []ServerlessFunction   `json:"functions"`
+	DurationMs  int                    `json:"assumed_duration_ms"` // Mean billed duration of an invocation
+	Projections []ServerlessProjection `json:"projections"`
+}
+
+// ServerlessProjection is the monthly cost of the functions when the project
+// serves a request rate, spread evenly over the request-driven functions
+type ServerlessProjection struct {
+	RequestsPerSecond  float64 `json:"requests_per_second"`
+	MonthlyInvocations int64   `json:"monthly_invocations"` // Including scheduled invocations
+	Requests           float64 `json:"requests"`            // Per-request charges, after the free tier
+	Compute            float64 `json:"compute"`             // GB-second charges, after the free tier
+	Provisioned        float64 `json:"provisioned"`         // Provisioned concurrency kept warm
+	MonthlyTotal       float64 `json:"monthly_total"`
+}
@@ -0,0 +1,28 @@
+package types
+
+// StaticFacts are derived deterministically from the source tree, without an LLM
+type StaticFacts struct {
+	Files        int             `json:"files"`
+	Lines        int             `json:"lines_of_code"`
+	Languages    []LanguageStats `json:"languages"`
+	Language     string          `json:"primary_language"`
+	Framework    string          `json:"framework,omitempty"`
+	Manifests    []string        `json:"manifests"`
+	Dependencies []Dependency    `json:"dependencies"`
+}
+
+// LanguageStats counts the files and non-blank lines of one language
+type LanguageStats struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Lines    int    `json:"lines"`
+}
+
+// Dependency is a package declared in a manifest
+type Dependency struct {
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	Ecosystem string `json:"ecosystem"` // "npm", "go", "pypi", "rubygems", "maven", "cargo", "packagist"
+	Manifest  string `json:"manifest"`  // Path relative to the project root
+	Dev       bool   `json:"dev,omitempty"`
+}
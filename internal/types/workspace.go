@@ -0,0 +1,45 @@
+package types
+
+// WorkspaceInfo describes a monorepo analyzed service by service. The rest of
+// the analysis aggregates the services: resources and cost are summed, and
+// findings are listed with paths relative to the workspace root.
+type WorkspaceInfo struct {
+	Source       string              `json:"source"` // "cloudpork.yaml" or "manifests"
+	Services     []WorkspaceService  `json:"services"`
+	Dependencies []ServiceDependency `json:"dependencies,omitempty"`
+	Shared       int                 `json:"shared_resources,omitempty"` // Infrastructure declared outside every service
+}
+
+// WorkspaceService is one service of a workspace and the headline numbers of
+// its own analysis
+type WorkspaceService struct {
+	Name          string          `json:"name"`
+	Path          string          `json:"path"`               // Relative to the workspace root
+	Manifest      string          `json:"manifest,omitempty"` // Relative to the workspace root
+	Module        string          `json:"module,omitempty"`   // Package or module name the manifest declares
+	DependsOn     []string        `json:"depends_on,omitempty"`
+	Language      string          `json:"language,omitempty"`
+	Framework     string          `json:"framework,omitempty"`
+	ApiEndpoints  int             `json:"api_endpoints"`
+	DatabaseCalls int             `json:"database_calls"`
+	ResourceUsage ResourceMetrics `json:"resource_usage"`
+	CostEstimates []CostEstimate  `json:"cost_estimates,omitempty"`
+	Confidence    float64         `json:"confidence"`
+}
+
+// ServiceDependency is an edge between two services of a workspace
+type ServiceDependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Via  string `json:"via"` // "cloudpork.yaml", "manifest" or "docker-compose"
+}
+
+// MonthlyCost returns the service's estimated monthly cost on a provider
+func (s WorkspaceService) MonthlyCost(provider string) float64 {
+	for _, e := range s.CostEstimates {
+		if e.Provider == provider {
+			return e.MonthlyTotal
+		}
+	}
+	return 0
+}